@@ -0,0 +1,95 @@
+// Package audit builds audit log entries carrying who made a change, from
+// where and what it changed
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/correlation"
+	"idiomatic-go/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxUserAgentLen bounds the user agent stored with an entry
+const maxUserAgentLen = 512
+
+// Client describes the caller of a request
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client stored in ctx
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(Client)
+	return client, ok
+}
+
+// Entry returns the parameters of an audit entry about userID. The actor
+// is the authenticated user in ctx, if any, and the client and request ID
+// are taken from ctx too, so entries written outside a request (by jobs)
+// carry neither.
+func Entry(ctx context.Context, userID int32, action string) database.CreateAuditLogParams {
+	params := database.CreateAuditLogParams{UserID: userID, Action: action}
+	if actorID, ok := authctx.UserID(ctx); ok && actorID > 0 && actorID <= math.MaxInt32 {
+		params.ActorID = pgtype.Int4{Int32: int32(actorID), Valid: true}
+	}
+	if client, ok := ClientFromContext(ctx); ok {
+		params.IpAddress = pgtype.Text{String: client.IP, Valid: client.IP != ""}
+		ua := client.UserAgent
+		if len(ua) > maxUserAgentLen {
+			ua = ua[:maxUserAgentLen]
+		}
+		params.UserAgent = pgtype.Text{String: ua, Valid: ua != ""}
+	}
+	if id := correlation.RequestID(ctx); id != "" {
+		params.RequestID = pgtype.Text{String: id, Valid: true}
+	}
+	return params
+}
+
+// Change is the old and new value of a field
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Changes records the fields an action changed, keyed by field name
+type Changes map[string]Change
+
+// Add records field if from and to differ
+func (c Changes) Add(field string, from, to any) {
+	if !reflect.DeepEqual(from, to) {
+		c[field] = Change{From: from, To: to}
+	}
+}
+
+// AddSecret records that a secret field changed without recording either
+// value
+func (c Changes) AddSecret(field string, changed bool) {
+	if changed {
+		c[field] = Change{From: "[redacted]", To: "[redacted]"}
+	}
+}
+
+// JSON encodes c for the changes column, or returns nil if nothing changed
+func (c Changes) JSON() []byte {
+	if len(c) == 0 {
+		return nil
+	}
+	// A map of strings and plain values always marshals
+	b, _ := json.Marshal(c)
+	return b
+}
@@ -0,0 +1,49 @@
+package authctx
+
+import (
+	"context"
+	"time"
+)
+
+// User is the authenticated identity attached to a request by AuthMiddleware
+type User struct {
+	ID             int64
+	Role           string
+	TokenID        string    // jti of the presented token
+	TokenExpiresAt time.Time // exp of the presented token
+}
+
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying u
+func WithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFromContext returns the authenticated user stored in ctx
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userKey{}).(User)
+	return u, ok
+}
+
+// UserID returns the authenticated user's ID stored in ctx
+func UserID(ctx context.Context) (int64, bool) {
+	u, ok := UserFromContext(ctx)
+	return u.ID, ok
+}
+
+// MustUserID returns the authenticated user's ID and panics if ctx carries
+// none. Only use it in code reachable exclusively behind AuthMiddleware.
+func MustUserID(ctx context.Context) int64 {
+	u, ok := UserFromContext(ctx)
+	if !ok {
+		panic("authctx: no authenticated user in context")
+	}
+	return u.ID
+}
+
+// HasRole reports whether the authenticated user in ctx has role
+func HasRole(ctx context.Context, role string) bool {
+	u, ok := UserFromContext(ctx)
+	return ok && u.Role == role
+}
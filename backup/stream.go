@@ -0,0 +1,224 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The stream is a header followed by chunks, each up to chunkSize bytes of
+// plaintext sealed with AES-256-GCM. A chunk's nonce is the header's random
+// prefix, the chunk's index and a flag marking the last chunk, so chunks
+// cannot be reordered, dropped or cut off at the end without failing to
+// open. Every chunk is authenticated against the header too.
+//
+//	header: magic (8) | nonce prefix (7)
+//	chunk:  ciphertext length (4, big-endian) | ciphertext
+const (
+	magic        = "IGOBAK01"
+	prefixSize   = 7
+	headerSize   = len(magic) + prefixSize
+	chunkSize    = 64 << 10
+	maxSealedLen = chunkSize + 16 // plus the GCM tag
+)
+
+var (
+	ErrNotBackup = errors.New("backup: not an encrypted backup")
+	ErrTruncated = errors.New("backup: stream ends before its last chunk")
+	ErrCorrupt   = errors.New("backup: chunk failed to decrypt; wrong key or corrupted backup")
+)
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("backup key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(header []byte, counter uint32, last bool) []byte {
+	n := make([]byte, 12)
+	copy(n, header[len(magic):])
+	binary.BigEndian.PutUint32(n[prefixSize:], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+// Writer encrypts everything written to it. Close must be called to mark
+// the end of the stream, or readers treat it as truncated.
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+// NewWriter writes the stream header to w and returns a Writer encrypting
+// to it with key
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, fmt.Errorf("generate nonce prefix: %w", err)
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, header: header, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("backup: write after close")
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		k := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *Writer) seal(last bool) error {
+	if w.counter == ^uint32(0) {
+		return errors.New("backup: stream too long")
+	}
+	sealed := w.aead.Seal(nil, nonce(w.header, w.counter, last), w.buf, w.header)
+	w.counter++
+	w.buf = w.buf[:0]
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := w.w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts a stream written by Writer. It returns io.EOF only after
+// the last chunk has been authenticated.
+type Reader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	plain   []byte
+	counter uint32
+	done    bool
+	err     error
+}
+
+// NewReader reads the stream header from r and returns a Reader
+// decrypting it with key
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrNotBackup
+		}
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, ErrNotBackup
+	}
+	return &Reader{r: r, aead: aead, header: header}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			r.err = io.EOF
+			continue
+		}
+		r.err = r.open()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// Err returns the error that stopped the stream, other than io.EOF
+func (r *Reader) Err() error {
+	if errors.Is(r.err, io.EOF) {
+		return nil
+	}
+	return r.err
+}
+
+func (r *Reader) open() error {
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncated
+		}
+		return err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n > maxSealedLen {
+		return ErrCorrupt
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return ErrTruncated
+		}
+		return err
+	}
+
+	// The flag is not stored, so try the chunk as both; only one opens.
+	// Open clears its output on failure, so it must not decrypt in place.
+	plain, err := r.aead.Open(nil, nonce(r.header, r.counter, false), sealed, r.header)
+	if err != nil {
+		plain, err = r.aead.Open(nil, nonce(r.header, r.counter, true), sealed, r.header)
+		if err != nil {
+			return ErrCorrupt
+		}
+		r.done = true
+		// Anything after the last chunk has been tampered with
+		var extra [1]byte
+		if k, _ := r.r.Read(extra[:]); k > 0 {
+			return ErrCorrupt
+		}
+	}
+	r.counter++
+	r.plain = plain
+	return nil
+}
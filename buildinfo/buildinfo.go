@@ -0,0 +1,43 @@
+// Package buildinfo reports which build of the service is running
+package buildinfo
+
+import "runtime/debug"
+
+// Version is set at build time with
+//
+//	go build -ldflags "-X idiomatic-go/buildinfo.Version=v1.2.3"
+//
+// When it is not set, the VCS revision recorded by the Go toolchain is used.
+var Version string
+
+// ServiceVersion returns Version, falling back to the (short) VCS revision
+// embedded in the binary and then to "dev"
+func ServiceVersion() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel carries deletions between regions
+const invalidationChannel = "cache:invalidations"
+
+type invalidation struct {
+	Region string   `json:"region"`
+	Keys   []string `json:"keys"`
+}
+
+// Broadcast is a region-local Cache whose deletions are published on a
+// bus shared by all regions, so that a change written in one region does
+// not stay cached in the others until the TTL runs out
+type Broadcast struct {
+	Cache
+	bus    *redis.Client
+	region string
+	logger *slog.Logger
+}
+
+// NewBroadcast wraps local, publishing its deletions on bus as region
+func NewBroadcast(local Cache, bus *redis.Client, region string, logger *slog.Logger) *Broadcast {
+	return &Broadcast{Cache: local, bus: bus, region: region, logger: logger}
+}
+
+// Delete removes keys locally and asks the other regions to do the same.
+// A failed publish is logged rather than returned: the local deletion
+// succeeded and remote entries still expire with their TTL.
+func (b *Broadcast) Delete(ctx context.Context, keys ...string) error {
+	if err := b.Cache.Delete(ctx, keys...); err != nil {
+		return err
+	}
+	msg, err := json.Marshal(invalidation{Region: b.region, Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := b.bus.Publish(ctx, invalidationChannel, msg).Err(); err != nil {
+		b.logger.WarnContext(ctx, "failed to broadcast cache invalidation", "error", err, "keys", keys)
+	}
+	return nil
+}
+
+// Listen applies the other regions' deletions to the local cache until ctx
+// is cancelled. Messages published while it is not subscribed are lost,
+// which the TTL bounds.
+func (b *Broadcast) Listen(ctx context.Context) {
+	pubsub := b.bus.Subscribe(ctx, invalidationChannel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				b.logger.Warn("malformed cache invalidation", "error", err)
+				continue
+			}
+			if inv.Region == b.region {
+				continue
+			}
+			if err := b.Cache.Delete(ctx, inv.Keys...); err != nil {
+				b.logger.Warn("failed to apply cache invalidation", "error", err, "region", inv.Region, "keys", inv.Keys)
+			}
+		}
+	}
+}
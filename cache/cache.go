@@ -0,0 +1,113 @@
+// Package cache stores short-lived copies of data that is expensive to
+// load. Backends are interchangeable: Redis for multi-replica deployments,
+// memcached, or process memory for small single-instance ones.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"idiomatic-go/memcache"
+	"idiomatic-go/timing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix namespaces cache entries in shared stores
+const KeyPrefix = "cache:"
+
+// ReasonTooLarge labels writes skipped because the value exceeded the
+// backend's MaxValueSize
+const ReasonTooLarge = "value_too_large"
+
+var writesSkipped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cache_writes_skipped_total",
+		Help: "Cache writes dropped by the memory guardrails, by reason",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(writesSkipped)
+}
+
+// Cache stores byte values under string keys. Implementations may drop
+// writes or entries at any time, so callers must always be able to fall
+// back to the source of truth; a skipped write is not an error.
+type Cache interface {
+	// Get returns the value stored under key and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys; missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Noop caches nothing. It is used when caching is disabled.
+type Noop struct{}
+
+func (Noop) Get(context.Context, string) ([]byte, bool, error)        { return nil, false, nil }
+func (Noop) Set(context.Context, string, []byte, time.Duration) error { return nil }
+func (Noop) Delete(context.Context, ...string) error                  { return nil }
+
+// Backends accepted by Config.Backend
+const (
+	BackendRedis     = "redis"
+	BackendMemcached = "memcached"
+	BackendMemory    = "memory"
+	BackendNone      = "none"
+)
+
+// Config selects and sizes a backend
+type Config struct {
+	Backend       string
+	MaxValueSize  int
+	MaxEntries    int     // memory only
+	MaxKeys       int64   // redis only
+	HighWatermark float64 // redis only
+}
+
+// New builds the backend named by config.Backend, timed as the cache layer
+// of the request's timing breakdown. rdb and mc are only used by their
+// respective backends and may be nil otherwise.
+func New(config Config, rdb *redis.Client, mc *memcache.Client, logger *slog.Logger) (Cache, error) {
+	switch config.Backend {
+	case BackendRedis:
+		return timed{NewRedis(rdb, logger, GuardConfig{
+			MaxValueSize:  config.MaxValueSize,
+			MaxKeys:       config.MaxKeys,
+			HighWatermark: config.HighWatermark,
+		})}, nil
+	case BackendMemcached:
+		return timed{NewMemcached(mc, config.MaxValueSize)}, nil
+	case BackendMemory:
+		return timed{NewMemory(MemoryConfig{MaxEntries: config.MaxEntries, MaxValueSize: config.MaxValueSize})}, nil
+	case BackendNone, "":
+		return Noop{}, nil
+	}
+	return nil, fmt.Errorf("unknown cache backend %q", config.Backend)
+}
+
+// timed adds the time spent in a backend to the request's timing breakdown
+type timed struct {
+	next Cache
+}
+
+func (t timed) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	defer timing.Since(ctx, timing.LayerCache, time.Now())
+	return t.next.Get(ctx, key)
+}
+
+func (t timed) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	defer timing.Since(ctx, timing.LayerCache, time.Now())
+	return t.next.Set(ctx, key, value, ttl)
+}
+
+func (t timed) Delete(ctx context.Context, keys ...string) error {
+	defer timing.Since(ctx, timing.LayerCache, time.Now())
+	return t.next.Delete(ctx, keys...)
+}
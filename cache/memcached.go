@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"idiomatic-go/memcache"
+)
+
+// Memcached is a cache backed by a memcached server
+type Memcached struct {
+	client       *memcache.Client
+	maxValueSize int
+}
+
+// NewMemcached caches in client. Values larger than maxValueSize are not
+// cached; memcached's own limit is 1MB by default.
+func NewMemcached(client *memcache.Client, maxValueSize int) *Memcached {
+	if maxValueSize <= 0 {
+		maxValueSize = 1000 * 1000
+	}
+	return &Memcached{client: client, maxValueSize: maxValueSize}
+}
+
+func (m *Memcached) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return m.client.Get(ctx, KeyPrefix+key)
+}
+
+func (m *Memcached) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if len(value) > m.maxValueSize {
+		writesSkipped.WithLabelValues(ReasonTooLarge).Inc()
+		return nil
+	}
+	return m.client.Set(ctx, KeyPrefix+key, value, ttl)
+}
+
+func (m *Memcached) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = KeyPrefix + key
+	}
+	return m.client.Delete(ctx, prefixed...)
+}
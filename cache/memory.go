@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+)
+
+// MemoryConfig bounds an in-process cache
+type MemoryConfig struct {
+	MaxEntries   int // least recently used entries are evicted beyond this
+	MaxValueSize int // larger values are not cached; 0 means no limit
+	Clock        clock.Clock
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Memory is an LRU cache in process memory. Entries are not shared
+// between replicas, so it suits single-instance deployments and data
+// whose staleness is bounded by a short TTL.
+type Memory struct {
+	config MemoryConfig
+
+	mu      sync.Mutex
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+func NewMemory(config MemoryConfig) *Memory {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+	return &Memory{
+		config:  config,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !m.config.Clock.Now().Before(entry.expires) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if m.config.MaxValueSize > 0 && len(value) > m.config.MaxValueSize {
+		writesSkipped.WithLabelValues(ReasonTooLarge).Inc()
+		return nil
+	}
+	// Copy so later changes to the caller's slice cannot leak in
+	value = append([]byte(nil), value...)
+	expires := m.config.Clock.Now().Add(ttl)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for m.order.Len() > m.config.MaxEntries {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		if el, ok := m.entries[key]; ok {
+			m.remove(el)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
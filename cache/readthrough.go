@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	lookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Read-through cache lookups, by cache and result (hit or miss)",
+		},
+		[]string{"cache", "result"},
+	)
+	cacheErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_errors_total",
+			Help: "Cache operations that failed and were bypassed, by cache and operation",
+		},
+		[]string{"cache", "op"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lookups, cacheErrors)
+}
+
+// GetOrLoad returns the value cached under key, or calls load and caches
+// its result for ttl. Values are stored as JSON. name labels the metrics.
+//
+// Cache failures are counted and otherwise ignored, so the cache can never
+// make a lookup fail; errors from load are returned unchanged and nothing
+// is cached for them.
+func GetOrLoad[T any](ctx context.Context, c Cache, name, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	data, found, err := c.Get(ctx, key)
+	if err != nil {
+		cacheErrors.WithLabelValues(name, "get").Inc()
+	}
+	if found {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			lookups.WithLabelValues(name, "hit").Inc()
+			return value, nil
+		}
+		cacheErrors.WithLabelValues(name, "decode").Inc()
+	}
+	lookups.WithLabelValues(name, "miss").Inc()
+
+	value, err := load(ctx)
+	if err != nil {
+		return value, err
+	}
+	Put(ctx, c, name, key, value, ttl)
+	return value, nil
+}
+
+// Put caches value under key as JSON, counting rather than returning
+// failures
+func Put(ctx context.Context, c Cache, name, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		cacheErrors.WithLabelValues(name, "encode").Inc()
+		return
+	}
+	if err := c.Set(ctx, key, data, ttl); err != nil {
+		cacheErrors.WithLabelValues(name, "set").Inc()
+	}
+}
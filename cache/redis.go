@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	reasonMemoryPressure = "memory_pressure"
+	reasonKeyBudget      = "key_budget"
+)
+
+var (
+	passthrough = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cache_passthrough",
+			Help: "1 while cache writes are suspended because Redis is under memory pressure",
+		},
+	)
+	memoryRatio = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cache_redis_memory_used_ratio",
+			Help: "used_memory / maxmemory of the cache's Redis at the last check; 0 when maxmemory is unset",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(passthrough, memoryRatio)
+}
+
+// GuardConfig bounds what the cache may write
+type GuardConfig struct {
+	MaxValueSize  int           // larger values are not cached; 0 means no limit
+	MaxKeys       int64         // writes stop once Redis holds this many keys; 0 means no limit
+	HighWatermark float64       // writes stop once used_memory reaches this fraction of maxmemory, e.g. 0.9
+	CheckInterval time.Duration // how often memory and key count are sampled
+}
+
+// Redis is a cache backed by Redis. Writes are skipped, and the cache
+// behaves as a passthrough, while Redis is close to its memory limit or
+// has started evicting keys; reads keep working throughout.
+type Redis struct {
+	rdb    *redis.Client
+	logger *slog.Logger
+	guard  GuardConfig
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	sampling  bool
+	degraded  string // reason writes are suspended, empty when healthy
+
+	evictedKeys int64 // at the last sample; -1 before the first
+}
+
+func NewRedis(rdb *redis.Client, logger *slog.Logger, guard GuardConfig) *Redis {
+	if guard.CheckInterval <= 0 {
+		guard.CheckInterval = 30 * time.Second
+	}
+	return &Redis{rdb: rdb, logger: logger, guard: guard, evictedKeys: -1}
+}
+
+// Get returns the value stored under key and whether it was found
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.rdb.Get(ctx, KeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value under key for ttl, unless a guardrail rejects it. A
+// rejected write is not an error: callers fall back to their source.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if r.guard.MaxValueSize > 0 && len(value) > r.guard.MaxValueSize {
+		writesSkipped.WithLabelValues(ReasonTooLarge).Inc()
+		return nil
+	}
+	if reason := r.pressure(ctx); reason != "" {
+		writesSkipped.WithLabelValues(reason).Inc()
+		return nil
+	}
+	return r.rdb.Set(ctx, KeyPrefix+key, value, ttl).Err()
+}
+
+// Delete removes keys; missing keys are ignored
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = KeyPrefix + key
+	}
+	return r.rdb.Del(ctx, prefixed...).Err()
+}
+
+// pressure returns why writes are currently suspended, resampling Redis
+// at most once per CheckInterval. Writers never wait for a sample in
+// progress, and a failed sample keeps the last verdict.
+func (r *Redis) pressure(ctx context.Context) string {
+	r.mu.Lock()
+	if r.sampling || time.Since(r.checkedAt) < r.guard.CheckInterval {
+		defer r.mu.Unlock()
+		return r.degraded
+	}
+	r.sampling = true
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+
+	reason, err := r.sample(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sampling = false
+	if err != nil {
+		r.logger.Warn("failed to sample cache memory usage", "error", err)
+		return r.degraded
+	}
+	if reason != r.degraded {
+		if reason != "" {
+			r.logger.Warn("cache writes suspended", "reason", reason)
+			passthrough.Set(1)
+		} else {
+			r.logger.Info("cache writes resumed")
+			passthrough.Set(0)
+		}
+	}
+	r.degraded = reason
+	return reason
+}
+
+// sample checks memory usage, evictions and the key count. Only one
+// sample runs at a time, so evictedKeys needs no locking.
+func (r *Redis) sample(ctx context.Context) (string, error) {
+	memory, err := r.rdb.Info(ctx, "memory").Result()
+	if err != nil {
+		return "", err
+	}
+	stats, err := r.rdb.Info(ctx, "stats").Result()
+	if err != nil {
+		return "", err
+	}
+	info := parseInfo(memory + stats)
+
+	used, _ := strconv.ParseInt(info["used_memory"], 10, 64)
+	maxMemory, _ := strconv.ParseInt(info["maxmemory"], 10, 64)
+	evicted, _ := strconv.ParseInt(info["evicted_keys"], 10, 64)
+	policy := info["maxmemory_policy"]
+
+	// Under an LRU/LFU policy, any eviction since the last sample means
+	// Redis is already making room, possibly by dropping keys far more
+	// important than ours
+	evicting := (strings.Contains(policy, "lru") || strings.Contains(policy, "lfu")) &&
+		r.evictedKeys >= 0 && evicted > r.evictedKeys
+	r.evictedKeys = evicted
+
+	if maxMemory > 0 {
+		ratio := float64(used) / float64(maxMemory)
+		memoryRatio.Set(ratio)
+		if r.guard.HighWatermark > 0 && ratio >= r.guard.HighWatermark {
+			return reasonMemoryPressure, nil
+		}
+	}
+	if evicting {
+		return reasonMemoryPressure, nil
+	}
+
+	if r.guard.MaxKeys > 0 {
+		keys, err := r.rdb.DBSize(ctx).Result()
+		if err != nil {
+			return "", err
+		}
+		if keys >= r.guard.MaxKeys {
+			return reasonKeyBudget, nil
+		}
+	}
+	return "", nil
+}
+
+// parseInfo reads the "field:value" lines of an INFO reply
+func parseInfo(s string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(s, "\r\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "#") {
+			fields[k] = v
+		}
+	}
+	return fields
+}
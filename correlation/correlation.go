@@ -0,0 +1,94 @@
+package correlation
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type (
+	requestIDKey struct{}
+	routeKey     struct{}
+)
+
+// Metadata is the correlation state that travels with asynchronous work
+// (jobs, outbox events, webhook deliveries). It is stored alongside the
+// payload so the worker handling it can be traced back to the request that
+// produced it.
+type Metadata struct {
+	RequestID   string `json:"request_id,omitempty"`
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+}
+
+// NewRequestID generates a fresh request identifier
+func NewRequestID() string {
+	return uuid.NewString()
+}
+
+// WithRequestID returns a copy of ctx carrying id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "" if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRoute returns a copy of ctx carrying the route handling the request,
+// as method and pattern, e.g. "GET /api/v1/users/:id"
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// Route returns the route stored in ctx, or "" if there is none
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// FromContext captures the request ID and W3C trace context from ctx
+func FromContext(ctx context.Context) Metadata {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return Metadata{
+		RequestID:   RequestID(ctx),
+		TraceParent: carrier.Get("traceparent"),
+		TraceState:  carrier.Get("tracestate"),
+	}
+}
+
+// Attach re-attaches the captured request ID and remote span context to ctx,
+// so spans started by the worker become children of the originating request.
+func (m Metadata) Attach(ctx context.Context) context.Context {
+	if m.RequestID != "" {
+		ctx = WithRequestID(ctx, m.RequestID)
+	}
+	if m.TraceParent == "" {
+		return ctx
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": m.TraceParent}
+	if m.TraceState != "" {
+		carrier["tracestate"] = m.TraceState
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Attrs returns the metadata as log attributes for worker log lines
+func (m Metadata) Attrs() []slog.Attr {
+	var attrs []slog.Attr
+	if m.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", m.RequestID))
+	}
+	if m.TraceParent != "" {
+		attrs = append(attrs, slog.String("traceparent", m.TraceParent))
+	}
+	return attrs
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/correlation"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// commentingDB appends a sqlcommenter comment describing the request to
+// every statement (https://google.github.io/sqlcommenter/spec/), so slow
+// query logs, pg_stat_activity and pg_stat_statements can be traced back
+// to the endpoint, trace and user that issued it
+type commentingDB struct {
+	DBTX
+}
+
+func (d commentingDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return d.DBTX.Exec(ctx, withComment(ctx, sql), args...)
+}
+
+func (d commentingDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return d.DBTX.Query(ctx, withComment(ctx, sql), args...)
+}
+
+func (d commentingDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return d.DBTX.QueryRow(ctx, withComment(ctx, sql), args...)
+}
+
+// withComment returns sql followed by the sqlcommenter comment for ctx, or
+// sql unchanged when ctx carries nothing to describe
+func withComment(ctx context.Context, sql string) string {
+	// Added in key order, which the spec requires
+	var tags []string
+	add := func(key, value string) {
+		tags = append(tags, key+"='"+strings.ReplaceAll(url.QueryEscape(value), "'", `\'`)+"'")
+	}
+	if id := correlation.RequestID(ctx); id != "" {
+		add("request_id", id)
+	}
+	if route := correlation.Route(ctx); route != "" {
+		add("route", route)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		add("traceparent", "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-"+sc.TraceFlags().String())
+	}
+	if id, ok := authctx.UserID(ctx); ok {
+		add("user_id", strconv.FormatInt(id, 10))
+	}
+	if len(tags) == 0 {
+		return sql
+	}
+	return strings.TrimRight(sql, "\n; ") + " /*" + strings.Join(tags, ",") + "*/"
+}
@@ -2,17 +2,63 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
-	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("idiomatic-go/database")
+
+var (
+	txDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_transaction_duration_seconds",
+			Help:    "Duration of database transactions from begin to commit or rollback",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"outcome"},
+	)
+	txRollbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_transaction_rollbacks_total",
+			Help: "Database transactions rolled back, by reason",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(txDuration, txRollbacks)
+}
+
+// DB is the application database. Pool is nil in SQLite mode.
 type DB struct {
 	Pool    *pgxpool.Pool
 	Queries *Queries
+
+	conn        conn
+	sqlComments bool
+}
+
+// conn is the connection pool behind a DB: a pgxpool.Pool, or a SQLite
+// database for local development
+type conn interface {
+	DBTX
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
 }
 
 type Config struct {
@@ -21,12 +67,26 @@ type Config struct {
 	MinConns        int32
 	MaxConnLifetime time.Duration
 	MaxConnIdleTime time.Duration
+	SlowQuery       time.Duration // queries taking longer are logged; 0 disables
+	SQLComments     bool          // tag statements with a sqlcommenter comment describing the request
+	Region          string        // stamped on written rows by the write_region triggers
 }
 
-func NewDB(ctx context.Context, config Config, logger *logrus.Logger) (*DB, error) {
+// cancelDeadlineDelay is how long a query may keep its connection after
+// its context is cancelled, waiting on the server to confirm the cancel
+const cancelDeadlineDelay = time.Second
+
+// NewDB connects to the database named by config.DBConn. A "sqlite:"
+// prefix followed by a file path opens a SQLite database instead of a
+// Postgres pool; see OpenSQLite.
+func NewDB(ctx context.Context, config Config, logger *slog.Logger) (*DB, error) {
+	if path, ok := strings.CutPrefix(config.DBConn, "sqlite:"); ok {
+		return OpenSQLite(ctx, path, logger)
+	}
+
 	poolConfig, err := pgxpool.ParseConfig(config.DBConn)
 	if err != nil {
-		logger.WithError(err).Error("failed to parse database config")
+		logger.Error("failed to parse database config", "error", err)
 		return nil, err
 	}
 
@@ -34,56 +94,157 @@ func NewDB(ctx context.Context, config Config, logger *logrus.Logger) (*DB, erro
 	poolConfig.MinConns = config.MinConns
 	poolConfig.MaxConnLifetime = config.MaxConnLifetime
 	poolConfig.MaxConnIdleTime = config.MaxConnIdleTime
-	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer() // Instrument DB for tracing
+	poolConfig.ConnConfig.Tracer = newTracer(logger, config.SlowQuery)
+	if config.Region != "" {
+		poolConfig.ConnConfig.RuntimeParams["app.region"] = config.Region
+	}
+	// A cancelled request also cancels its running query on the server,
+	// instead of dropping the connection and leaving the query to finish
+	// unseen. The deadline bounds the wait for the server to react.
+	poolConfig.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{Conn: conn, DeadlineDelay: cancelDeadlineDelay}
+	}
+	if config.SQLComments {
+		// Every commented statement is unique, so caching prepared
+		// statements or their descriptions by SQL text would only churn
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		logger.WithError(err).Error("failed to create connection pool")
+		logger.Error("failed to create connection pool", "error", err)
 		return nil, err
 	}
 
 	if err := pool.Ping(ctx); err != nil {
-		logger.WithError(err).Error("failed to ping database")
+		logger.Error("failed to ping database", "error", err)
 		pool.Close()
 		return nil, err
 	}
 
-	queries := New(pool)
+	db := &DB{Pool: pool, conn: pool, sqlComments: config.SQLComments}
+	db.Queries = db.queries(pool)
 
 	logger.Info("Database connection pool initialized successfully")
-	return &DB{
-		Pool:    pool,
-		Queries: queries,
-	}, nil
+	return db, nil
+}
+
+// queries returns Queries running on conn, commenting each statement if
+// configured to
+func (db *DB) queries(conn DBTX) *Queries {
+	if db.sqlComments {
+		return New(commentingDB{conn})
+	}
+	return New(conn)
 }
 
 // Close closes the database connection pool
 func (db *DB) Close() {
-	if db.Pool != nil {
-		db.Pool.Close()
+	if db.conn != nil {
+		db.conn.Close()
 	}
 }
 
+// Ping verifies that a connection to the database can be acquired and used
+func (db *DB) Ping(ctx context.Context) error {
+	return db.conn.Ping(ctx)
+}
+
 // BeginTx starts a transaction
 func (db *DB) BeginTx(ctx context.Context) (pgx.Tx, error) {
-	return db.Pool.Begin(ctx)
+	return db.conn.Begin(ctx)
 }
 
-// WithTx executes a function within a transaction
-func (db *DB) WithTx(ctx context.Context, fn func(queries *Queries) error) error {
+// WithTx executes a function within a transaction. The transaction is
+// traced as a db.transaction span with begin, commit and rollback events,
+// and rollbacks are counted by reason.
+func (db *DB) WithTx(ctx context.Context, fn func(queries *Queries) error) (err error) {
+	ctx, span := tracer.Start(ctx, "db.transaction", trace.WithSpanKind(trace.SpanKindClient))
+	start := time.Now()
+	outcome := "commit"
+	defer func() {
+		txDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+		span.End()
+	}()
+
 	tx, err := db.BeginTx(ctx)
 	if err != nil {
+		outcome = "begin_failed"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "begin failed")
 		return err
 	}
+	span.AddEvent("begin", trace.WithAttributes(attribute.Int64("db.tx.begin_ms", time.Since(start).Milliseconds())))
+
+	rollback := func(reason string, cause error) error {
+		outcome = "rollback"
+		txRollbacks.WithLabelValues(reason).Inc()
+		rbStart := time.Now()
+		// Roll back on a fresh context so a cancelled request still
+		// releases its locks immediately
+		rbErr := tx.Rollback(context.WithoutCancel(ctx))
+		span.AddEvent("rollback", trace.WithAttributes(
+			attribute.String("db.tx.rollback_reason", reason),
+			attribute.Int64("db.tx.rollback_ms", time.Since(rbStart).Milliseconds()),
+		))
+		span.RecordError(cause)
+		span.SetStatus(codes.Error, reason)
+		return rbErr
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = rollback("panic", fmt.Errorf("panic: %v", p))
+			panic(p)
+		}
+	}()
 
-	queriesWithTx := New(tx)
+	queriesWithTx := db.queries(tx)
 	err = fn(queriesWithTx)
 	if err != nil {
-		if rbErr := tx.Rollback(ctx); rbErr != nil {
+		if rbErr := rollback(rollbackReason(err), err); rbErr != nil {
 			return rbErr
 		}
 		return err
 	}
 
-	return tx.Commit(ctx)
+	commitStart := time.Now()
+	err = tx.Commit(ctx)
+	span.AddEvent("commit", trace.WithAttributes(
+		attribute.Int64("db.tx.commit_ms", time.Since(commitStart).Milliseconds()),
+		attribute.Bool("db.tx.committed", err == nil),
+	))
+	if err != nil {
+		outcome = "commit_failed"
+		txRollbacks.WithLabelValues("commit_" + rollbackReason(err)).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "commit failed")
+	}
+	return err
+}
+
+// rollbackReason classifies why a transaction was abandoned, keeping the
+// label set small enough for a metric
+func rollbackReason(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001":
+			return "serialization_failure"
+		case pgErr.Code == "40P01":
+			return "deadlock"
+		case pgErr.Code == "55P03":
+			return "lock_not_available"
+		case strings.HasPrefix(pgErr.Code, "23"):
+			return "constraint_violation"
+		}
+		return "database_error"
+	}
+	return "application_error"
 }
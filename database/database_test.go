@@ -0,0 +1,170 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+const (
+	fakePID    = 4242
+	fakeSecret = 1234567
+)
+
+// fakeServer speaks just enough of the PostgreSQL protocol to run simple
+// queries. A query containing pg_sleep runs until a CancelRequest for its
+// backend arrives, which is reported on cancelled.
+type fakeServer struct {
+	ln        net.Listener
+	cancelled chan struct{}
+	running   chan struct{}
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln, cancelled: make(chan struct{}, 1), running: make(chan struct{}, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) dsn() string {
+	return "postgres://test@" + s.ln.Addr().String() + "/test?sslmode=disable"
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	startup, err := backend.ReceiveStartupMessage()
+	if err != nil {
+		return
+	}
+	switch msg := startup.(type) {
+	case *pgproto3.CancelRequest:
+		if msg.ProcessID == fakePID && msg.SecretKey == fakeSecret {
+			s.cancelled <- struct{}{}
+		}
+		return
+	case *pgproto3.StartupMessage:
+	default:
+		return
+	}
+
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.ParameterStatus{Name: "server_version", Value: "16.0"})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: fakePID, SecretKey: fakeSecret})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.Query:
+			if strings.Contains(msg.String, "pg_sleep") {
+				s.running <- struct{}{}
+				select {
+				case <-s.cancelled:
+					// Put back for the test to observe
+					s.cancelled <- struct{}{}
+					backend.Send(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "57014", Message: "canceling statement due to user request"})
+				case <-time.After(10 * time.Second):
+					backend.Send(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")})
+				}
+			} else {
+				backend.Send(&pgproto3.EmptyQueryResponse{})
+			}
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+func TestCancelledContextCancelsQueryOnServer(t *testing.T) {
+	server := newFakeServer(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := NewDB(context.Background(), Config{DBConn: server.dsn(), MaxConns: 1, MinConns: 0, MaxConnLifetime: time.Hour, MaxConnIdleTime: time.Hour}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-server.running
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err = db.Pool.Exec(ctx, "SELECT pg_sleep(30)", pgx.QueryExecModeSimpleProtocol)
+	elapsed := time.Since(start)
+
+	var pgErr *pgconn.PgError
+	if !errors.Is(err, context.Canceled) && !(errors.As(err, &pgErr) && pgErr.Code == "57014") {
+		t.Fatalf("got error %v, want the query cancelled", err)
+	}
+	if elapsed > cancelDeadlineDelay+500*time.Millisecond {
+		t.Errorf("query returned after %s, want it cancelled within %s", elapsed, cancelDeadlineDelay)
+	}
+	select {
+	case <-server.cancelled:
+	default:
+		t.Fatal("server received no CancelRequest, so the query would have run to completion")
+	}
+
+	// The server confirmed the cancel, so the connection went back to the
+	// pool in a usable state instead of being dropped
+	if err := db.Ping(context.Background()); err != nil {
+		t.Errorf("ping after cancel: %v", err)
+	}
+}
+
+func TestQueryWithExpiredDeadlineIsNotSent(t *testing.T) {
+	server := newFakeServer(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := NewDB(context.Background(), Config{DBConn: server.dsn(), MaxConns: 1, MaxConnLifetime: time.Hour, MaxConnIdleTime: time.Hour}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.Pool.Exec(ctx, "SELECT pg_sleep(30)", pgx.QueryExecModeSimpleProtocol); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+	select {
+	case <-server.running:
+		t.Error("query reached the server although its context was already cancelled")
+	default:
+	}
+}
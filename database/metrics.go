@@ -0,0 +1,85 @@
+package database
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exposes pgxpool statistics. Stats are read from the pool
+// at scrape time, so values are never stale.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquires          *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	acquireSeconds    *prometheus.Desc
+	emptyWaitSeconds  *prometheus.Desc
+	newConns          *prometheus.Desc
+	destroyedConns    *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc("db_pool_"+name, help, labels, nil)
+	}
+	return &PoolCollector{
+		pool:              pool,
+		acquiredConns:     desc("acquired_connections", "Connections currently checked out of the pool"),
+		idleConns:         desc("idle_connections", "Connections currently idle in the pool"),
+		constructingConns: desc("constructing_connections", "Connections currently being established"),
+		totalConns:        desc("total_connections", "Connections currently open, whether acquired, idle or being established"),
+		maxConns:          desc("max_connections", "Maximum size of the pool"),
+		acquires:          desc("acquires_total", "Successful connection acquires"),
+		emptyAcquires:     desc("empty_acquires_total", "Acquires that had to wait because no idle connection was available"),
+		canceledAcquires:  desc("canceled_acquires_total", "Acquires canceled by their context before a connection was available"),
+		acquireSeconds:    desc("acquire_duration_seconds_total", "Total time spent in successful acquires"),
+		emptyWaitSeconds:  desc("empty_acquire_wait_seconds_total", "Total time acquires spent waiting for a connection to be released or established"),
+		newConns:          desc("new_connections_total", "Connections opened"),
+		destroyedConns:    desc("destroyed_connections_total", "Connections closed by the pool, by reason", "reason"),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.constructingConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquires
+	ch <- c.emptyAcquires
+	ch <- c.canceledAcquires
+	ch <- c.acquireSeconds
+	ch <- c.emptyWaitSeconds
+	ch <- c.newConns
+	ch <- c.destroyedConns
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, labels...)
+	}
+
+	gauge(c.acquiredConns, float64(s.AcquiredConns()))
+	gauge(c.idleConns, float64(s.IdleConns()))
+	gauge(c.constructingConns, float64(s.ConstructingConns()))
+	gauge(c.totalConns, float64(s.TotalConns()))
+	gauge(c.maxConns, float64(s.MaxConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(s.CanceledAcquireCount()))
+	counter(c.acquireSeconds, s.AcquireDuration().Seconds())
+	counter(c.emptyWaitSeconds, s.EmptyAcquireWaitTime().Seconds())
+	counter(c.newConns, float64(s.NewConnsCount()))
+	counter(c.destroyedConns, float64(s.MaxLifetimeDestroyCount()), "max_lifetime")
+	counter(c.destroyedConns, float64(s.MaxIdleDestroyCount()), "max_idle")
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Migrations holds the golang-migrate files the schema is built from
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// migration is one of the up files in Migrations
+type migration struct {
+	version uint
+	name    string
+}
+
+// upMigrations returns the up files in Migrations in the order they apply
+func upMigrations() ([]migration, error) {
+	names, err := fs.Glob(Migrations, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(strings.TrimPrefix(name, "migrations/"), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: version is not a number", name)
+		}
+		migrations = append(migrations, migration{version: uint(version), name: name})
+	}
+	slices.SortFunc(migrations, func(a, b migration) int { return cmp.Compare(a.version, b.version) })
+	return migrations, nil
+}
+
+// LatestMigration returns the highest version among Migrations
+func LatestMigration() (uint, error) {
+	migrations, err := upMigrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
+// MigrationVersion returns the version golang-migrate last applied and
+// whether that migration failed halfway. It is 0 before the first one.
+// SQLite databases are created at the latest version and never migrated.
+func (db *DB) MigrationVersion(ctx context.Context) (version uint, dirty bool, err error) {
+	if db.Pool == nil {
+		version, err = LatestMigration()
+		return version, false, err
+	}
+	err = db.Pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Migrate applies the Migrations above the current version and returns
+// how many it applied. Versions are recorded in schema_migrations as
+// golang-migrate records them, so the migrate CLI can take over the
+// database afterwards. Each migration commits together with its version.
+// SQLite databases are already at the latest version.
+func (db *DB) Migrate(ctx context.Context) (applied int, err error) {
+	if db.Pool == nil {
+		return 0, nil
+	}
+	migrations, err := upMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.Pool.Exec(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)"); err != nil {
+		return 0, err
+	}
+	current, dirty, err := db.MigrationVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("migration %d failed halfway; fix the schema and force the version with the migrate CLI", current)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		up, err := Migrations.ReadFile(m.name)
+		if err != nil {
+			return applied, err
+		}
+		err = pgx.BeginFunc(ctx, db.Pool, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(up)); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, "TRUNCATE schema_migrations"); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", m.version)
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
@@ -0,0 +1,4 @@
+ALTER TABLE audit_logs
+    DROP CONSTRAINT audit_logs_user_id_fkey,
+    ADD CONSTRAINT audit_logs_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES users(id);
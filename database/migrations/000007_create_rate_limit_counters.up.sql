@@ -0,0 +1,6 @@
+-- Fixed-window request counters for the Postgres rate limit backend
+CREATE TABLE rate_limit_counters (
+    key VARCHAR(255) PRIMARY KEY,
+    window_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    count INT NOT NULL
+);
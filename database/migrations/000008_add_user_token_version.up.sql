@@ -0,0 +1,2 @@
+-- Bumped to invalidate every minimal-claims token issued to the user
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
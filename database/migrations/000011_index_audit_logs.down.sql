@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS audit_logs_created_at_idx;
+DROP INDEX IF EXISTS audit_logs_user_id_idx;
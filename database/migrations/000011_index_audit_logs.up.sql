@@ -0,0 +1,2 @@
+CREATE INDEX audit_logs_user_id_idx ON audit_logs (user_id, id);
+CREATE INDEX audit_logs_created_at_idx ON audit_logs (created_at);
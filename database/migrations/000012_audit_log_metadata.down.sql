@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS audit_logs_actor_id_idx;
+
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS changes,
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS ip_address,
+    DROP COLUMN IF EXISTS target_id,
+    DROP COLUMN IF EXISTS actor_id;
@@ -0,0 +1,9 @@
+ALTER TABLE audit_logs
+    ADD COLUMN actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN target_id INT,
+    ADD COLUMN ip_address VARCHAR(45),
+    ADD COLUMN user_agent TEXT,
+    ADD COLUMN request_id VARCHAR(128),
+    ADD COLUMN changes JSONB;
+
+CREATE INDEX audit_logs_actor_id_idx ON audit_logs (actor_id, id) WHERE actor_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS audit_alerts;
+DROP TABLE IF EXISTS audit_alert_rules;
@@ -0,0 +1,30 @@
+-- Thresholds on the rate of an audit action, e.g. more than 10
+-- user_deleted entries by one actor within 60 seconds
+CREATE TABLE audit_alert_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    per_actor BOOLEAN NOT NULL DEFAULT TRUE,
+    threshold INT NOT NULL CHECK (threshold > 0),
+    window_seconds INT NOT NULL CHECK (window_seconds > 0),
+    cooldown_seconds INT NOT NULL CHECK (cooldown_seconds > 0),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Alerts fired by the rules. bucket is the cooldown period the alert fell
+-- in; the unique index lets one replica fire per rule, actor and period.
+CREATE TABLE audit_alerts (
+    id SERIAL PRIMARY KEY,
+    rule_id INT NOT NULL,
+    actor_id INT,
+    event_count INT NOT NULL,
+    window_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    bucket BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (rule_id) REFERENCES audit_alert_rules(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX audit_alerts_bucket_idx ON audit_alerts (rule_id, (COALESCE(actor_id, 0)), bucket);
+CREATE INDEX audit_alerts_created_at_idx ON audit_alerts (created_at);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS users_write_region ON users;
+DROP FUNCTION IF EXISTS stamp_write_region();
+ALTER TABLE users DROP COLUMN IF EXISTS write_region;
@@ -0,0 +1,16 @@
+-- The region whose replica last wrote the row, for spotting concurrent
+-- writes in active-active deployments. Every connection names its region
+-- in the app.region setting, so the trigger stamps all writes, whichever
+-- query makes them; without the setting the stamp is left unchanged.
+ALTER TABLE users ADD COLUMN write_region VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE FUNCTION stamp_write_region() RETURNS trigger AS $$
+BEGIN
+    NEW.write_region := COALESCE(NULLIF(current_setting('app.region', true), ''), NEW.write_region);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_write_region
+    BEFORE INSERT OR UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION stamp_write_region();
@@ -0,0 +1,5 @@
+-- Users are addressed in the API by a random UUID instead of the serial
+-- primary key, so IDs cannot be guessed or counted
+ALTER TABLE users ADD COLUMN external_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX users_external_id_key ON users (external_id);
@@ -0,0 +1,6 @@
+-- Admin user search matches partial usernames and emails with ILIKE and
+-- ranks by trigram similarity; trigram indexes serve both
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_username_trgm_idx ON users USING gin (username gin_trgm_ops);
+CREATE INDEX users_email_trgm_idx ON users USING gin (email gin_trgm_ops);
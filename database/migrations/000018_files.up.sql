@@ -0,0 +1,20 @@
+-- Metadata of uploaded files; their content lives in the file store under
+-- the file's ID. Files awaiting a virus scan have next_scan_at set.
+CREATE TABLE files (
+    id UUID PRIMARY KEY,
+    owner_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    scan_attempts INT NOT NULL DEFAULT 0,
+    scan_error TEXT,
+    next_scan_at TIMESTAMP WITH TIME ZONE,
+    scanned_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX files_owner_id_idx ON files (owner_id, created_at);
+CREATE INDEX files_scan_due_idx ON files (next_scan_at) WHERE status = 'pending_scan';
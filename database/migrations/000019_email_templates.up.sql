@@ -0,0 +1,14 @@
+-- Operator customizations of the built-in email templates. Every save adds
+-- a version; the highest one of a name is in use, and a name without rows
+-- uses the template embedded in the binary.
+CREATE TABLE email_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    version INT NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    created_by INT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (name, version),
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
@@ -0,0 +1,6 @@
+-- Fails while a deleted and an active account share a username or email
+DROP INDEX IF EXISTS users_username_key;
+DROP INDEX IF EXISTS users_email_key;
+ALTER TABLE users
+    ADD CONSTRAINT users_username_key UNIQUE (username),
+    ADD CONSTRAINT users_email_key UNIQUE (email);
@@ -0,0 +1,8 @@
+-- A soft-deleted account no longer holds its username and email, so they
+-- can be registered again. The indexes keep the names of the constraints
+-- they replace, which the services map to conflicts.
+ALTER TABLE users
+    DROP CONSTRAINT users_username_key,
+    DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_username_key ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (email) WHERE deleted_at IS NULL;
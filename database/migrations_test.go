@@ -0,0 +1,25 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpMigrations(t *testing.T) {
+	migrations, err := upMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// migrate create -seq numbers them from 1 without gaps
+	for i, m := range migrations {
+		if m.version != uint(i+1) {
+			t.Fatalf("migration %d is %s, want version %d", i, m.name, i+1)
+		}
+		if _, err := Migrations.ReadFile(strings.TrimSuffix(m.name, ".up.sql") + ".down.sql"); err != nil {
+			t.Errorf("%s has no down migration", m.name)
+		}
+	}
+	if latest, err := LatestMigration(); err != nil || latest != uint(len(migrations)) {
+		t.Errorf("LatestMigration = %d, %v; want %d", latest, err, len(migrations))
+	}
+}
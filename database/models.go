@@ -8,19 +8,150 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditAlert struct {
+	ID          int32              `json:"id"`
+	RuleID      int32              `json:"rule_id"`
+	ActorID     pgtype.Int4        `json:"actor_id"`
+	EventCount  int32              `json:"event_count"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	Bucket      int64              `json:"bucket"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type AuditAlertRule struct {
+	ID              int32              `json:"id"`
+	Name            string             `json:"name"`
+	Action          string             `json:"action"`
+	PerActor        bool               `json:"per_actor"`
+	Threshold       int32              `json:"threshold"`
+	WindowSeconds   int32              `json:"window_seconds"`
+	CooldownSeconds int32              `json:"cooldown_seconds"`
+	Enabled         bool               `json:"enabled"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+}
+
 type AuditLog struct {
 	ID        int32              `json:"id"`
 	UserID    int32              `json:"user_id"`
 	Action    string             `json:"action"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ActorID   pgtype.Int4        `json:"actor_id"`
+	TargetID  pgtype.Int4        `json:"target_id"`
+	IpAddress pgtype.Text        `json:"ip_address"`
+	UserAgent pgtype.Text        `json:"user_agent"`
+	RequestID pgtype.Text        `json:"request_id"`
+	Changes   []byte             `json:"changes"`
 }
 
-type User struct {
-	ID           int32              `json:"id"`
-	Username     string             `json:"username"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	Role         string             `json:"role"`
+type EmailTemplate struct {
+	ID        int32              `json:"id"`
+	Name      string             `json:"name"`
+	Version   int32              `json:"version"`
+	Subject   string             `json:"subject"`
+	Body      string             `json:"body"`
+	CreatedBy pgtype.Int4        `json:"created_by"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type EmailVerification struct {
+	ID        int32              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type File struct {
+	ID           pgtype.UUID        `json:"id"`
+	OwnerID      int32              `json:"owner_id"`
+	Name         string             `json:"name"`
+	ContentType  string             `json:"content_type"`
+	Size         int64              `json:"size"`
+	Sha256       string             `json:"sha256"`
+	Status       string             `json:"status"`
+	ScanAttempts int32              `json:"scan_attempts"`
+	ScanError    pgtype.Text        `json:"scan_error"`
+	NextScanAt   pgtype.Timestamptz `json:"next_scan_at"`
+	ScannedAt    pgtype.Timestamptz `json:"scanned_at"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+}
+
+type PasswordReset struct {
+	ID        int32              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Profile struct {
+	UserID      int32              `json:"user_id"`
+	DisplayName string             `json:"display_name"`
+	Bio         string             `json:"bio"`
+	AvatarUrl   string             `json:"avatar_url"`
+	Locale      string             `json:"locale"`
+	Timezone    string             `json:"timezone"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RateLimitCounter struct {
+	Key         string             `json:"key"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	Count       int32              `json:"count"`
+}
+
+type RefreshToken struct {
+	ID        int32              `json:"id"`
+	UserID    int32              `json:"user_id"`
+	FamilyID  pgtype.UUID        `json:"family_id"`
+	ParentID  pgtype.Int4        `json:"parent_id"`
+	DeviceID  string             `json:"device_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	RevokedAt pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type User struct {
+	ID            int32              `json:"id"`
+	Username      string             `json:"username"`
+	Email         string             `json:"email"`
+	PasswordHash  string             `json:"password_hash"`
+	Role          string             `json:"role"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
+	EmailVerified bool               `json:"email_verified"`
+	DeletedAt     pgtype.Timestamptz `json:"deleted_at"`
+	TokenVersion  int32              `json:"token_version"`
+	WriteRegion   string             `json:"write_region"`
+	ExternalID    pgtype.UUID        `json:"external_id"`
+}
+
+type Webhook struct {
+	ID          int32              `json:"id"`
+	Url         string             `json:"url"`
+	Secret      string             `json:"secret"`
+	Events      []string           `json:"events"`
+	Description string             `json:"description"`
+	Active      bool               `json:"active"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int32              `json:"id"`
+	WebhookID      int32              `json:"webhook_id"`
+	Event          string             `json:"event"`
+	Payload        []byte             `json:"payload"`
+	Status         string             `json:"status"`
+	Attempts       int32              `json:"attempts"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4        `json:"last_status_code"`
+	LastError      pgtype.Text        `json:"last_error"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
+	RedeliveryOf   pgtype.Int4        `json:"redelivery_of"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
 }
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UserColumns whitelists the users columns that may be selected by a
+// projection. password_hash is deliberately absent.
+var UserColumns = []string{"id", "username", "email", "role", "created_at", "updated_at", "email_verified"}
+
+func userColumnTarget(u *User, column string) (interface{}, bool) {
+	switch column {
+	case "id":
+		return &u.ID, true
+	case "username":
+		return &u.Username, true
+	case "email":
+		return &u.Email, true
+	case "role":
+		return &u.Role, true
+	case "created_at":
+		return &u.CreatedAt, true
+	case "updated_at":
+		return &u.UpdatedAt, true
+	case "email_verified":
+		return &u.EmailVerified, true
+	}
+	return nil, false
+}
+
+// ListUsersProjected is ListUsers restricted to the given columns. Columns
+// outside UserColumns are rejected, and the remaining User fields are left
+// zero, except ExternalID: the API identifies users by it, so it is always
+// selected.
+func (q *Queries) ListUsersProjected(ctx context.Context, columns []string, arg ListUsersParams) ([]User, error) {
+	var probe User
+	for _, column := range columns {
+		if _, ok := userColumnTarget(&probe, column); !ok {
+			return nil, fmt.Errorf("column %q cannot be projected", column)
+		}
+	}
+
+	query := "SELECT external_id, " + strings.Join(columns, ", ") + " FROM users WHERE deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2"
+	rows, err := q.db.Query(ctx, query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []User
+	for rows.Next() {
+		var i User
+		targets := make([]interface{}, len(columns)+1)
+		targets[0] = &i.ExternalID
+		for n, column := range columns {
+			targets[n+1], _ = userColumnTarget(&i, column)
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -5,31 +5,503 @@ RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE id = $1 LIMIT 1;
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetUserForUpdate :one
+SELECT * FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+FOR UPDATE;
+
+-- name: GetUserIDByExternalID :one
+SELECT id FROM users
+WHERE external_id = $1 LIMIT 1;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
-WHERE email = $1 LIMIT 1;
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1;
 
 -- name: ListUsers :many
 SELECT * FROM users
+WHERE deleted_at IS NULL
 ORDER BY id
 LIMIT $1 OFFSET $2;
 
+-- name: ListUsersByExternalIDsForUpdate :many
+SELECT * FROM users
+WHERE external_id = ANY(sqlc.arg(external_ids)::uuid[]) AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE;
+
+-- name: ListUsersFiltered :many
+SELECT * FROM users
+WHERE (sqlc.narg(role)::text IS NULL OR role = sqlc.narg(role))
+  AND (sqlc.narg(email_verified)::boolean IS NULL OR email_verified = sqlc.narg(email_verified))
+  AND (sqlc.arg(include_deleted)::boolean OR deleted_at IS NULL)
+  AND (sqlc.narg(after_id)::int IS NULL OR id > sqlc.narg(after_id))
+ORDER BY id
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: SearchUsers :many
+SELECT sqlc.embed(users),
+    GREATEST(similarity(username, sqlc.arg(query)::text), similarity(email, sqlc.arg(query)::text))::real AS rank
+FROM users
+WHERE (username ILIKE sqlc.arg(pattern) OR email ILIKE sqlc.arg(pattern))
+  AND (sqlc.narg(role)::text IS NULL OR role = sqlc.narg(role))
+  AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at > sqlc.narg(created_after))
+  AND deleted_at IS NULL
+ORDER BY rank DESC, id
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: PatchUser :one
+UPDATE users
+SET username = COALESCE(sqlc.narg(username), username),
+    email = COALESCE(sqlc.narg(email), email),
+    email_verified = email_verified AND email = COALESCE(sqlc.narg(email), email),
+    password_hash = COALESCE(sqlc.narg(password_hash), password_hash),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL
+RETURNING *;
+
 -- name: UpdateUser :one
 UPDATE users
 SET username = $2,
     email = $3,
+    email_verified = email_verified AND email = $3,
     password_hash = $4,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: UpdateUserRoleAndTokenVersion :one
+UPDATE users
+SET role = $2,
+    token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
 
+-- name: IncrementTokenVersion :exec
+UPDATE users
+SET token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
 -- name: DeleteUser :exec
-DELETE FROM users
+UPDATE users
+SET deleted_at = sqlc.arg(deleted_at),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND deleted_at IS NULL;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING *;
+
+-- name: TouchUser :exec
+UPDATE users
+SET updated_at = CURRENT_TIMESTAMP
 WHERE id = $1;
 
+-- name: GetUserWithProfile :one
+SELECT sqlc.embed(users),
+    profiles.display_name, profiles.bio, profiles.avatar_url, profiles.locale, profiles.timezone,
+    profiles.created_at AS profile_created_at, profiles.updated_at AS profile_updated_at
+FROM users
+LEFT JOIN profiles ON profiles.user_id = users.id
+WHERE users.id = $1 AND users.deleted_at IS NULL;
+
+-- name: GetProfile :one
+SELECT * FROM profiles
+WHERE user_id = $1;
+
+-- name: ListProfilesByUserIDs :many
+SELECT * FROM profiles
+WHERE user_id = ANY(sqlc.arg(user_ids)::int[]);
+
+-- name: UpsertProfile :one
+INSERT INTO profiles (user_id, display_name, bio, avatar_url, locale, timezone)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET display_name = EXCLUDED.display_name,
+    bio = EXCLUDED.bio,
+    avatar_url = EXCLUDED.avatar_url,
+    locale = EXCLUDED.locale,
+    timezone = EXCLUDED.timezone,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteProfile :one
+DELETE FROM profiles
+WHERE user_id = $1
+RETURNING *;
+
+-- name: ReassignProfile :execrows
+UPDATE profiles
+SET user_id = sqlc.arg(to_user_id),
+    updated_at = CURRENT_TIMESTAMP
+WHERE user_id = sqlc.arg(from_user_id)
+  AND NOT EXISTS (SELECT 1 FROM profiles WHERE user_id = sqlc.arg(to_user_id));
+
+-- name: ListPurgeableFiles :many
+SELECT files.id, files.owner_id FROM files
+JOIN users ON users.id = files.owner_id
+WHERE users.deleted_at < $1;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < sqlc.arg(cutoff) AND NOT (id = ANY(sqlc.arg(keep_ids)::int[]));
+
 -- name: CreateAuditLog :one
-INSERT INTO audit_logs (user_id, action)
-VALUES ($1, $2)
-RETURNING *;
\ No newline at end of file
+INSERT INTO audit_logs (user_id, action, actor_id, target_id, ip_address, user_agent, request_id, changes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications
+WHERE token_hash = $1 LIMIT 1;
+
+-- name: DeleteEmailVerificationsForUser :exec
+DELETE FROM email_verifications
+WHERE user_id = $1;
+
+-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified = TRUE,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListAuditLogsForUser :many
+SELECT * FROM audit_logs
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: CountAuditLogsForUser :one
+SELECT count(*) FROM audit_logs
+WHERE user_id = $1;
+
+-- name: ListAuditLogs :many
+SELECT * FROM audit_logs
+WHERE (sqlc.narg(user_id)::int IS NULL OR user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(actor_id)::int IS NULL OR actor_id = sqlc.narg(actor_id))
+  AND (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(created_from)::timestamptz IS NULL OR created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+  AND (sqlc.narg(before_id)::int IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(page_limit) OFFSET sqlc.arg(page_offset);
+
+-- name: ReassignAuditLogs :execrows
+UPDATE audit_logs
+SET user_id = sqlc.arg(to_user_id)
+WHERE user_id = sqlc.arg(from_user_id);
+
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetPasswordReset :one
+SELECT * FROM password_resets
+WHERE token_hash = $1 LIMIT 1;
+
+-- name: DeletePasswordResetsForUser :exec
+DELETE FROM password_resets
+WHERE user_id = $1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET password_hash = $2,
+    token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, parent_id, device_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeDeviceRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: ListUserDevices :many
+SELECT device_id,
+    MIN(created_at)::timestamptz AS signed_in_at,
+    MAX(created_at)::timestamptz AS last_refreshed_at
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+GROUP BY device_id
+ORDER BY last_refreshed_at DESC;
+
+-- name: CountActiveSessions :one
+SELECT COUNT(DISTINCT family_id) AS sessions,
+    COUNT(DISTINCT user_id) AS users
+FROM refresh_tokens
+WHERE revoked_at IS NULL AND used_at IS NULL AND expires_at > $1;
+
+-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE revoked_at IS NULL AND expires_at > $1;
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1;
+
+-- name: CountRefreshTokensForUser :one
+SELECT count(*) FROM refresh_tokens
+WHERE user_id = $1;
+
+-- name: ReassignRefreshTokens :execrows
+UPDATE refresh_tokens
+SET user_id = sqlc.arg(to_user_id)
+WHERE user_id = sqlc.arg(from_user_id);
+
+-- name: IncrementRateLimitCounter :one
+INSERT INTO rate_limit_counters (key, window_start, count)
+VALUES ($1, $2, 1)
+ON CONFLICT (key) DO UPDATE
+SET count = CASE
+        WHEN rate_limit_counters.window_start = EXCLUDED.window_start THEN rate_limit_counters.count + 1
+        ELSE 1
+    END,
+    window_start = EXCLUDED.window_start
+RETURNING count;
+
+-- name: DeleteStaleRateLimitCounters :exec
+DELETE FROM rate_limit_counters
+WHERE window_start < $1;
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, events, description)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1 LIMIT 1;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks
+ORDER BY id;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2,
+    events = $3,
+    description = $4,
+    active = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+SELECT id, sqlc.arg(event)::text, sqlc.arg(payload)::jsonb, sqlc.arg(next_attempt_at)::timestamptz
+FROM webhooks
+WHERE active AND sqlc.arg(event)::text = ANY(events);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, redelivery_of)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ReleaseWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(now)
+WHERE id = ANY(sqlc.arg(ids)::int[]) AND status = 'pending';
+
+-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = $6
+WHERE id = $1;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2 LIMIT 1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2;
+
+-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE created_at < $1 AND status <> 'pending';
+
+-- name: CreateAuditAlertRule :one
+INSERT INTO audit_alert_rules (name, action, per_actor, threshold, window_seconds, cooldown_seconds)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ListAuditAlertRules :many
+SELECT * FROM audit_alert_rules
+ORDER BY id;
+
+-- name: UpdateAuditAlertRule :one
+UPDATE audit_alert_rules
+SET name = $2,
+    action = $3,
+    per_actor = $4,
+    threshold = $5,
+    window_seconds = $6,
+    cooldown_seconds = $7,
+    enabled = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteAuditAlertRule :execrows
+DELETE FROM audit_alert_rules
+WHERE id = $1;
+
+-- name: CountAuditActions :many
+SELECT (CASE WHEN sqlc.arg(per_actor)::boolean THEN actor_id END)::int AS actor_id, count(*) AS event_count
+FROM audit_logs
+WHERE action = sqlc.arg(action) AND created_at >= sqlc.arg(since)
+GROUP BY 1
+HAVING count(*) >= sqlc.arg(threshold)::int;
+
+-- name: CreateAuditAlert :one
+INSERT INTO audit_alerts (rule_id, actor_id, event_count, window_start, bucket)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (rule_id, (COALESCE(actor_id, 0)), bucket) DO NOTHING
+RETURNING *;
+
+-- name: ListAuditAlerts :many
+SELECT * FROM audit_alerts
+ORDER BY id DESC
+LIMIT $1;
+
+-- name: CreateFile :one
+INSERT INTO files (id, owner_id, name, content_type, size, sha256, status, next_scan_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetFile :one
+SELECT * FROM files
+WHERE id = $1;
+
+-- name: ListFilesByOwner :many
+SELECT * FROM files
+WHERE owner_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2 OFFSET $3;
+
+-- name: DeleteFile :one
+DELETE FROM files
+WHERE id = $1
+RETURNING *;
+
+-- name: CountFilesByOwner :one
+SELECT count(*) FROM files
+WHERE owner_id = $1;
+
+-- name: ReassignFiles :execrows
+UPDATE files
+SET owner_id = sqlc.arg(to_user_id)
+WHERE owner_id = sqlc.arg(from_user_id);
+
+-- name: ClaimFileScans :many
+UPDATE files
+SET next_scan_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM files
+    WHERE status = 'pending_scan' AND next_scan_at <= sqlc.arg(now)
+    ORDER BY next_scan_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordFileScan :exec
+UPDATE files
+SET status = $2,
+    scan_attempts = scan_attempts + 1,
+    scan_error = $3,
+    next_scan_at = $4,
+    scanned_at = $5
+WHERE id = $1;
+
+-- name: CreateEmailTemplateVersion :one
+INSERT INTO email_templates (name, version, subject, body, created_by)
+SELECT sqlc.arg(name)::text, COALESCE(MAX(version), 0) + 1, sqlc.arg(subject)::text, sqlc.arg(body)::text, sqlc.narg(created_by)::int
+FROM email_templates
+WHERE name = sqlc.arg(name)::text
+RETURNING *;
+
+-- name: GetLatestEmailTemplate :one
+SELECT * FROM email_templates
+WHERE name = $1
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: GetEmailTemplateVersion :one
+SELECT * FROM email_templates
+WHERE name = $1 AND version = $2;
+
+-- name: ListEmailTemplateVersions :many
+SELECT * FROM email_templates
+WHERE name = $1
+ORDER BY version DESC;
+
+-- name: ListLatestEmailTemplates :many
+SELECT DISTINCT ON (name) * FROM email_templates
+ORDER BY name, version DESC;
+
+-- name: DeleteEmailTemplate :execrows
+DELETE FROM email_templates
+WHERE name = $1;
@@ -7,45 +7,1795 @@ package database
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const claimFileScans = `-- name: ClaimFileScans :many
+UPDATE files
+SET next_scan_at = $1
+WHERE id IN (
+    SELECT id FROM files
+    WHERE status = 'pending_scan' AND next_scan_at <= $2
+    ORDER BY next_scan_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`
+
+type ClaimFileScansParams struct {
+	LeaseUntil pgtype.Timestamptz `json:"lease_until"`
+	Now        pgtype.Timestamptz `json:"now"`
+	BatchSize  int32              `json:"batch_size"`
+}
+
+func (q *Queries) ClaimFileScans(ctx context.Context, arg ClaimFileScansParams) ([]File, error) {
+	rows, err := q.db.Query(ctx, claimFileScans, arg.LeaseUntil, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.ContentType,
+			&i.Size,
+			&i.Sha256,
+			&i.Status,
+			&i.ScanAttempts,
+			&i.ScanError,
+			&i.NextScanAt,
+			&i.ScannedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const claimWebhookDeliveries = `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= $2
+    ORDER BY next_attempt_at
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
 )
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at
+`
+
+type ClaimWebhookDeliveriesParams struct {
+	LeaseUntil pgtype.Timestamptz `json:"lease_until"`
+	Now        pgtype.Timestamptz `json:"now"`
+	BatchSize  int32              `json:"batch_size"`
+}
+
+func (q *Queries) ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, claimWebhookDeliveries, arg.LeaseUntil, arg.Now, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.RedeliveryOf,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countActiveSessions = `-- name: CountActiveSessions :one
+SELECT COUNT(DISTINCT family_id) AS sessions,
+    COUNT(DISTINCT user_id) AS users
+FROM refresh_tokens
+WHERE revoked_at IS NULL AND used_at IS NULL AND expires_at > $1
+`
+
+type CountActiveSessionsRow struct {
+	Sessions int64 `json:"sessions"`
+	Users    int64 `json:"users"`
+}
+
+func (q *Queries) CountActiveSessions(ctx context.Context, expiresAt pgtype.Timestamptz) (CountActiveSessionsRow, error) {
+	row := q.db.QueryRow(ctx, countActiveSessions, expiresAt)
+	var i CountActiveSessionsRow
+	err := row.Scan(&i.Sessions, &i.Users)
+	return i, err
+}
+
+const countAuditActions = `-- name: CountAuditActions :many
+SELECT (CASE WHEN $1::boolean THEN actor_id END)::int AS actor_id, count(*) AS event_count
+FROM audit_logs
+WHERE action = $2 AND created_at >= $3
+GROUP BY 1
+HAVING count(*) >= $4::int
+`
+
+type CountAuditActionsParams struct {
+	PerActor  bool               `json:"per_actor"`
+	Action    string             `json:"action"`
+	Since     pgtype.Timestamptz `json:"since"`
+	Threshold int32              `json:"threshold"`
+}
+
+type CountAuditActionsRow struct {
+	ActorID    pgtype.Int4 `json:"actor_id"`
+	EventCount int64       `json:"event_count"`
+}
+
+func (q *Queries) CountAuditActions(ctx context.Context, arg CountAuditActionsParams) ([]CountAuditActionsRow, error) {
+	rows, err := q.db.Query(ctx, countAuditActions,
+		arg.PerActor,
+		arg.Action,
+		arg.Since,
+		arg.Threshold,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountAuditActionsRow
+	for rows.Next() {
+		var i CountAuditActionsRow
+		if err := rows.Scan(&i.ActorID, &i.EventCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countAuditLogsForUser = `-- name: CountAuditLogsForUser :one
+SELECT count(*) FROM audit_logs
+WHERE user_id = $1
+`
+
+func (q *Queries) CountAuditLogsForUser(ctx context.Context, userID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countAuditLogsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countFilesByOwner = `-- name: CountFilesByOwner :one
+SELECT count(*) FROM files
+WHERE owner_id = $1
+`
+
+func (q *Queries) CountFilesByOwner(ctx context.Context, ownerID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countFilesByOwner, ownerID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRefreshTokensForUser = `-- name: CountRefreshTokensForUser :one
+SELECT count(*) FROM refresh_tokens
+WHERE user_id = $1
+`
+
+func (q *Queries) CountRefreshTokensForUser(ctx context.Context, userID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countRefreshTokensForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAuditAlert = `-- name: CreateAuditAlert :one
+INSERT INTO audit_alerts (rule_id, actor_id, event_count, window_start, bucket)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (rule_id, (COALESCE(actor_id, 0)), bucket) DO NOTHING
+RETURNING id, rule_id, actor_id, event_count, window_start, bucket, created_at
+`
+
+type CreateAuditAlertParams struct {
+	RuleID      int32              `json:"rule_id"`
+	ActorID     pgtype.Int4        `json:"actor_id"`
+	EventCount  int32              `json:"event_count"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+	Bucket      int64              `json:"bucket"`
+}
+
+func (q *Queries) CreateAuditAlert(ctx context.Context, arg CreateAuditAlertParams) (AuditAlert, error) {
+	row := q.db.QueryRow(ctx, createAuditAlert,
+		arg.RuleID,
+		arg.ActorID,
+		arg.EventCount,
+		arg.WindowStart,
+		arg.Bucket,
+	)
+	var i AuditAlert
+	err := row.Scan(
+		&i.ID,
+		&i.RuleID,
+		&i.ActorID,
+		&i.EventCount,
+		&i.WindowStart,
+		&i.Bucket,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createAuditAlertRule = `-- name: CreateAuditAlertRule :one
+INSERT INTO audit_alert_rules (name, action, per_actor, threshold, window_seconds, cooldown_seconds)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at
+`
+
+type CreateAuditAlertRuleParams struct {
+	Name            string `json:"name"`
+	Action          string `json:"action"`
+	PerActor        bool   `json:"per_actor"`
+	Threshold       int32  `json:"threshold"`
+	WindowSeconds   int32  `json:"window_seconds"`
+	CooldownSeconds int32  `json:"cooldown_seconds"`
+}
+
+func (q *Queries) CreateAuditAlertRule(ctx context.Context, arg CreateAuditAlertRuleParams) (AuditAlertRule, error) {
+	row := q.db.QueryRow(ctx, createAuditAlertRule,
+		arg.Name,
+		arg.Action,
+		arg.PerActor,
+		arg.Threshold,
+		arg.WindowSeconds,
+		arg.CooldownSeconds,
+	)
+	var i AuditAlertRule
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Action,
+		&i.PerActor,
+		&i.Threshold,
+		&i.WindowSeconds,
+		&i.CooldownSeconds,
+		&i.Enabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
 
 const createAuditLog = `-- name: CreateAuditLog :one
-INSERT INTO audit_logs (user_id, action)
-VALUES ($1, $2)
-RETURNING id, user_id, action, created_at
+INSERT INTO audit_logs (user_id, action, actor_id, target_id, ip_address, user_agent, request_id, changes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes
 `
 
 type CreateAuditLogParams struct {
-	UserID int32  `json:"user_id"`
-	Action string `json:"action"`
+	UserID    int32       `json:"user_id"`
+	Action    string      `json:"action"`
+	ActorID   pgtype.Int4 `json:"actor_id"`
+	TargetID  pgtype.Int4 `json:"target_id"`
+	IpAddress pgtype.Text `json:"ip_address"`
+	UserAgent pgtype.Text `json:"user_agent"`
+	RequestID pgtype.Text `json:"request_id"`
+	Changes   []byte      `json:"changes"`
 }
 
 func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error) {
-	row := q.db.QueryRow(ctx, createAuditLog, arg.UserID, arg.Action)
+	row := q.db.QueryRow(ctx, createAuditLog,
+		arg.UserID,
+		arg.Action,
+		arg.ActorID,
+		arg.TargetID,
+		arg.IpAddress,
+		arg.UserAgent,
+		arg.RequestID,
+		arg.Changes,
+	)
 	var i AuditLog
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
 		&i.Action,
 		&i.CreatedAt,
+		&i.ActorID,
+		&i.TargetID,
+		&i.IpAddress,
+		&i.UserAgent,
+		&i.RequestID,
+		&i.Changes,
+	)
+	return i, err
+}
+
+const createEmailTemplateVersion = `-- name: CreateEmailTemplateVersion :one
+INSERT INTO email_templates (name, version, subject, body, created_by)
+SELECT $1::text, COALESCE(MAX(version), 0) + 1, $2::text, $3::text, $4::int
+FROM email_templates
+WHERE name = $1::text
+RETURNING id, name, version, subject, body, created_by, created_at
+`
+
+type CreateEmailTemplateVersionParams struct {
+	Name      string      `json:"name"`
+	Subject   string      `json:"subject"`
+	Body      string      `json:"body"`
+	CreatedBy pgtype.Int4 `json:"created_by"`
+}
+
+func (q *Queries) CreateEmailTemplateVersion(ctx context.Context, arg CreateEmailTemplateVersionParams) (EmailTemplate, error) {
+	row := q.db.QueryRow(ctx, createEmailTemplateVersion,
+		arg.Name,
+		arg.Subject,
+		arg.Body,
+		arg.CreatedBy,
+	)
+	var i EmailTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Version,
+		&i.Subject,
+		&i.Body,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createEmailVerification = `-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, token_hash, expires_at, created_at
+`
+
+type CreateEmailVerificationParams struct {
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateEmailVerification(ctx context.Context, arg CreateEmailVerificationParams) (EmailVerification, error) {
+	row := q.db.QueryRow(ctx, createEmailVerification, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	var i EmailVerification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createFile = `-- name: CreateFile :one
+INSERT INTO files (id, owner_id, name, content_type, size, sha256, status, next_scan_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`
+
+type CreateFileParams struct {
+	ID          pgtype.UUID        `json:"id"`
+	OwnerID     int32              `json:"owner_id"`
+	Name        string             `json:"name"`
+	ContentType string             `json:"content_type"`
+	Size        int64              `json:"size"`
+	Sha256      string             `json:"sha256"`
+	Status      string             `json:"status"`
+	NextScanAt  pgtype.Timestamptz `json:"next_scan_at"`
+}
+
+func (q *Queries) CreateFile(ctx context.Context, arg CreateFileParams) (File, error) {
+	row := q.db.QueryRow(ctx, createFile,
+		arg.ID,
+		arg.OwnerID,
+		arg.Name,
+		arg.ContentType,
+		arg.Size,
+		arg.Sha256,
+		arg.Status,
+		arg.NextScanAt,
+	)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.ContentType,
+		&i.Size,
+		&i.Sha256,
+		&i.Status,
+		&i.ScanAttempts,
+		&i.ScanError,
+		&i.NextScanAt,
+		&i.ScannedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :one
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, token_hash, expires_at, created_at
+`
+
+type CreatePasswordResetParams struct {
+	UserID    int32              `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error) {
+	row := q.db.QueryRow(ctx, createPasswordReset, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createRefreshToken = `-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, parent_id, device_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, family_id, parent_id, device_id, token_hash, expires_at, used_at, revoked_at, created_at
+`
+
+type CreateRefreshTokenParams struct {
+	UserID    int32              `json:"user_id"`
+	FamilyID  pgtype.UUID        `json:"family_id"`
+	ParentID  pgtype.Int4        `json:"parent_id"`
+	DeviceID  string             `json:"device_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, createRefreshToken,
+		arg.UserID,
+		arg.FamilyID,
+		arg.ParentID,
+		arg.DeviceID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (username, email, password_hash)
+VALUES ($1, $2, $3)
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`
+
+type CreateUserParams struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Email, arg.PasswordHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, events, description)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, secret, events, description, active, created_at, updated_at
+`
+
+type CreateWebhookParams struct {
+	Url         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, createWebhook,
+		arg.Url,
+		arg.Secret,
+		arg.Events,
+		arg.Description,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Description,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, redelivery_of)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID     int32              `json:"webhook_id"`
+	Event         string             `json:"event"`
+	Payload       []byte             `json:"payload"`
+	NextAttemptAt pgtype.Timestamptz `json:"next_attempt_at"`
+	RedeliveryOf  pgtype.Int4        `json:"redelivery_of"`
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.Event,
+		arg.Payload,
+		arg.NextAttemptAt,
+		arg.RedeliveryOf,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.RedeliveryOf,
+		&i.CreatedAt,
 	)
 	return i, err
 }
 
-const createUser = `-- name: CreateUser :one
-INSERT INTO users (username, email, password_hash)
-VALUES ($1, $2, $3)
-RETURNING id, username, email, password_hash, role, created_at, updated_at
+const deleteAuditAlertRule = `-- name: DeleteAuditAlertRule :execrows
+DELETE FROM audit_alert_rules
+WHERE id = $1
+`
+
+func (q *Queries) DeleteAuditAlertRule(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteAuditAlertRule, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteEmailTemplate = `-- name: DeleteEmailTemplate :execrows
+DELETE FROM email_templates
+WHERE name = $1
+`
+
+func (q *Queries) DeleteEmailTemplate(ctx context.Context, name string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteEmailTemplate, name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteEmailVerificationsForUser = `-- name: DeleteEmailVerificationsForUser :exec
+DELETE FROM email_verifications
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteEmailVerificationsForUser(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deleteEmailVerificationsForUser, userID)
+	return err
+}
+
+const deleteExpiredRefreshTokens = `-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredRefreshTokens(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredRefreshTokens, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteFile = `-- name: DeleteFile :one
+DELETE FROM files
+WHERE id = $1
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`
+
+func (q *Queries) DeleteFile(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, deleteFile, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.ContentType,
+		&i.Size,
+		&i.Sha256,
+		&i.Status,
+		&i.ScanAttempts,
+		&i.ScanError,
+		&i.NextScanAt,
+		&i.ScannedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteOldWebhookDeliveries = `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE created_at < $1 AND status <> 'pending'
+`
+
+func (q *Queries) DeleteOldWebhookDeliveries(ctx context.Context, createdAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteOldWebhookDeliveries, createdAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deletePasswordResetsForUser = `-- name: DeletePasswordResetsForUser :exec
+DELETE FROM password_resets
+WHERE user_id = $1
+`
+
+func (q *Queries) DeletePasswordResetsForUser(ctx context.Context, userID int32) error {
+	_, err := q.db.Exec(ctx, deletePasswordResetsForUser, userID)
+	return err
+}
+
+const deleteProfile = `-- name: DeleteProfile :one
+DELETE FROM profiles
+WHERE user_id = $1
+RETURNING user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at
+`
+
+func (q *Queries) DeleteProfile(ctx context.Context, userID int32) (Profile, error) {
+	row := q.db.QueryRow(ctx, deleteProfile, userID)
+	var i Profile
+	err := row.Scan(
+		&i.UserID,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteStaleRateLimitCounters = `-- name: DeleteStaleRateLimitCounters :exec
+DELETE FROM rate_limit_counters
+WHERE window_start < $1
+`
+
+func (q *Queries) DeleteStaleRateLimitCounters(ctx context.Context, windowStart pgtype.Timestamptz) error {
+	_, err := q.db.Exec(ctx, deleteStaleRateLimitCounters, windowStart)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+UPDATE users
+SET deleted_at = $1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $2 AND deleted_at IS NULL
+`
+
+type DeleteUserParams struct {
+	DeletedAt pgtype.Timestamptz `json:"deleted_at"`
+	ID        int32              `json:"id"`
+}
+
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) error {
+	_, err := q.db.Exec(ctx, deleteUser, arg.DeletedAt, arg.ID)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enqueueWebhookDeliveries = `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+SELECT id, $1::text, $2::jsonb, $3::timestamptz
+FROM webhooks
+WHERE active AND $1::text = ANY(events)
+`
+
+type EnqueueWebhookDeliveriesParams struct {
+	Event         string             `json:"event"`
+	Payload       []byte             `json:"payload"`
+	NextAttemptAt pgtype.Timestamptz `json:"next_attempt_at"`
+}
+
+func (q *Queries) EnqueueWebhookDeliveries(ctx context.Context, arg EnqueueWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueWebhookDeliveries, arg.Event, arg.Payload, arg.NextAttemptAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const getEmailTemplateVersion = `-- name: GetEmailTemplateVersion :one
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1 AND version = $2
+`
+
+type GetEmailTemplateVersionParams struct {
+	Name    string `json:"name"`
+	Version int32  `json:"version"`
+}
+
+func (q *Queries) GetEmailTemplateVersion(ctx context.Context, arg GetEmailTemplateVersionParams) (EmailTemplate, error) {
+	row := q.db.QueryRow(ctx, getEmailTemplateVersion, arg.Name, arg.Version)
+	var i EmailTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Version,
+		&i.Subject,
+		&i.Body,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getEmailVerification = `-- name: GetEmailVerification :one
+SELECT id, user_id, token_hash, expires_at, created_at FROM email_verifications
+WHERE token_hash = $1 LIMIT 1
+`
+
+func (q *Queries) GetEmailVerification(ctx context.Context, tokenHash string) (EmailVerification, error) {
+	row := q.db.QueryRow(ctx, getEmailVerification, tokenHash)
+	var i EmailVerification
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getFile = `-- name: GetFile :one
+SELECT id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at FROM files
+WHERE id = $1
+`
+
+func (q *Queries) GetFile(ctx context.Context, id pgtype.UUID) (File, error) {
+	row := q.db.QueryRow(ctx, getFile, id)
+	var i File
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.ContentType,
+		&i.Size,
+		&i.Sha256,
+		&i.Status,
+		&i.ScanAttempts,
+		&i.ScanError,
+		&i.NextScanAt,
+		&i.ScannedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getLatestEmailTemplate = `-- name: GetLatestEmailTemplate :one
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1
+ORDER BY version DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestEmailTemplate(ctx context.Context, name string) (EmailTemplate, error) {
+	row := q.db.QueryRow(ctx, getLatestEmailTemplate, name)
+	var i EmailTemplate
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Version,
+		&i.Subject,
+		&i.Body,
+		&i.CreatedBy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPasswordReset = `-- name: GetPasswordReset :one
+SELECT id, user_id, token_hash, expires_at, created_at FROM password_resets
+WHERE token_hash = $1 LIMIT 1
+`
+
+func (q *Queries) GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRow(ctx, getPasswordReset, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getProfile = `-- name: GetProfile :one
+SELECT user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at FROM profiles
+WHERE user_id = $1
+`
+
+func (q *Queries) GetProfile(ctx context.Context, userID int32) (Profile, error) {
+	row := q.db.QueryRow(ctx, getProfile, userID)
+	var i Profile
+	err := row.Scan(
+		&i.UserID,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getRefreshTokenForUpdate = `-- name: GetRefreshTokenForUpdate :one
+SELECT id, user_id, family_id, parent_id, device_id, token_hash, expires_at, used_at, revoked_at, created_at FROM refresh_tokens
+WHERE token_hash = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetRefreshTokenForUpdate(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	row := q.db.QueryRow(ctx, getRefreshTokenForUpdate, tokenHash)
+	var i RefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const getUserForUpdate = `-- name: GetUserForUpdate :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetUserForUpdate(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, getUserForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const getUserIDByExternalID = `-- name: GetUserIDByExternalID :one
+SELECT id FROM users
+WHERE external_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserIDByExternalID(ctx context.Context, externalID pgtype.UUID) (int32, error) {
+	row := q.db.QueryRow(ctx, getUserIDByExternalID, externalID)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getUserWithProfile = `-- name: GetUserWithProfile :one
+SELECT users.id, users.username, users.email, users.password_hash, users.role, users.created_at, users.updated_at, users.email_verified, users.deleted_at, users.token_version, users.write_region, users.external_id,
+    profiles.display_name, profiles.bio, profiles.avatar_url, profiles.locale, profiles.timezone,
+    profiles.created_at AS profile_created_at, profiles.updated_at AS profile_updated_at
+FROM users
+LEFT JOIN profiles ON profiles.user_id = users.id
+WHERE users.id = $1 AND users.deleted_at IS NULL
+`
+
+type GetUserWithProfileRow struct {
+	User             User               `json:"user"`
+	DisplayName      pgtype.Text        `json:"display_name"`
+	Bio              pgtype.Text        `json:"bio"`
+	AvatarUrl        pgtype.Text        `json:"avatar_url"`
+	Locale           pgtype.Text        `json:"locale"`
+	Timezone         pgtype.Text        `json:"timezone"`
+	ProfileCreatedAt pgtype.Timestamptz `json:"profile_created_at"`
+	ProfileUpdatedAt pgtype.Timestamptz `json:"profile_updated_at"`
+}
+
+func (q *Queries) GetUserWithProfile(ctx context.Context, id int32) (GetUserWithProfileRow, error) {
+	row := q.db.QueryRow(ctx, getUserWithProfile, id)
+	var i GetUserWithProfileRow
+	err := row.Scan(
+		&i.User.ID,
+		&i.User.Username,
+		&i.User.Email,
+		&i.User.PasswordHash,
+		&i.User.Role,
+		&i.User.CreatedAt,
+		&i.User.UpdatedAt,
+		&i.User.EmailVerified,
+		&i.User.DeletedAt,
+		&i.User.TokenVersion,
+		&i.User.WriteRegion,
+		&i.User.ExternalID,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.ProfileCreatedAt,
+		&i.ProfileUpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, url, secret, events, description, active, created_at, updated_at FROM webhooks
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int32) (Webhook, error) {
+	row := q.db.QueryRow(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Description,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2 LIMIT 1
+`
+
+type GetWebhookDeliveryParams struct {
+	ID        int32 `json:"id"`
+	WebhookID int32 `json:"webhook_id"`
+}
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, arg GetWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRow(ctx, getWebhookDelivery, arg.ID, arg.WebhookID)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.Event,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastStatusCode,
+		&i.LastError,
+		&i.DeliveredAt,
+		&i.RedeliveryOf,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const incrementRateLimitCounter = `-- name: IncrementRateLimitCounter :one
+INSERT INTO rate_limit_counters (key, window_start, count)
+VALUES ($1, $2, 1)
+ON CONFLICT (key) DO UPDATE
+SET count = CASE
+        WHEN rate_limit_counters.window_start = EXCLUDED.window_start THEN rate_limit_counters.count + 1
+        ELSE 1
+    END,
+    window_start = EXCLUDED.window_start
+RETURNING count
+`
+
+type IncrementRateLimitCounterParams struct {
+	Key         string             `json:"key"`
+	WindowStart pgtype.Timestamptz `json:"window_start"`
+}
+
+func (q *Queries) IncrementRateLimitCounter(ctx context.Context, arg IncrementRateLimitCounterParams) (int32, error) {
+	row := q.db.QueryRow(ctx, incrementRateLimitCounter, arg.Key, arg.WindowStart)
+	var count int32
+	err := row.Scan(&count)
+	return count, err
+}
+
+const incrementTokenVersion = `-- name: IncrementTokenVersion :exec
+UPDATE users
+SET token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) IncrementTokenVersion(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, incrementTokenVersion, id)
+	return err
+}
+
+const listAuditAlertRules = `-- name: ListAuditAlertRules :many
+SELECT id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at FROM audit_alert_rules
+ORDER BY id
+`
+
+func (q *Queries) ListAuditAlertRules(ctx context.Context) ([]AuditAlertRule, error) {
+	rows, err := q.db.Query(ctx, listAuditAlertRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditAlertRule
+	for rows.Next() {
+		var i AuditAlertRule
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Action,
+			&i.PerActor,
+			&i.Threshold,
+			&i.WindowSeconds,
+			&i.CooldownSeconds,
+			&i.Enabled,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditAlerts = `-- name: ListAuditAlerts :many
+SELECT id, rule_id, actor_id, event_count, window_start, bucket, created_at FROM audit_alerts
+ORDER BY id DESC
+LIMIT $1
+`
+
+func (q *Queries) ListAuditAlerts(ctx context.Context, limit int32) ([]AuditAlert, error) {
+	rows, err := q.db.Query(ctx, listAuditAlerts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditAlert
+	for rows.Next() {
+		var i AuditAlert
+		if err := rows.Scan(
+			&i.ID,
+			&i.RuleID,
+			&i.ActorID,
+			&i.EventCount,
+			&i.WindowStart,
+			&i.Bucket,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditLogs = `-- name: ListAuditLogs :many
+SELECT id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes FROM audit_logs
+WHERE ($1::int IS NULL OR user_id = $1)
+  AND ($2::int IS NULL OR actor_id = $2)
+  AND ($3::text IS NULL OR action = $3)
+  AND ($4::timestamptz IS NULL OR created_at >= $4)
+  AND ($5::timestamptz IS NULL OR created_at < $5)
+  AND ($6::int IS NULL OR id < $6)
+ORDER BY id DESC
+LIMIT $7 OFFSET $8
+`
+
+type ListAuditLogsParams struct {
+	UserID        pgtype.Int4        `json:"user_id"`
+	ActorID       pgtype.Int4        `json:"actor_id"`
+	Action        pgtype.Text        `json:"action"`
+	CreatedFrom   pgtype.Timestamptz `json:"created_from"`
+	CreatedBefore pgtype.Timestamptz `json:"created_before"`
+	BeforeID      pgtype.Int4        `json:"before_id"`
+	PageLimit     int32              `json:"page_limit"`
+	PageOffset    int32              `json:"page_offset"`
+}
+
+func (q *Queries) ListAuditLogs(ctx context.Context, arg ListAuditLogsParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditLogs,
+		arg.UserID,
+		arg.ActorID,
+		arg.Action,
+		arg.CreatedFrom,
+		arg.CreatedBefore,
+		arg.BeforeID,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Action,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.TargetID,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.Changes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAuditLogsForUser = `-- name: ListAuditLogsForUser :many
+SELECT id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes FROM audit_logs
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`
+
+type ListAuditLogsForUserParams struct {
+	UserID int32 `json:"user_id"`
+	Limit  int32 `json:"limit"`
+}
+
+func (q *Queries) ListAuditLogsForUser(ctx context.Context, arg ListAuditLogsForUserParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditLogsForUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditLog
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Action,
+			&i.CreatedAt,
+			&i.ActorID,
+			&i.TargetID,
+			&i.IpAddress,
+			&i.UserAgent,
+			&i.RequestID,
+			&i.Changes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEmailTemplateVersions = `-- name: ListEmailTemplateVersions :many
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1
+ORDER BY version DESC
+`
+
+func (q *Queries) ListEmailTemplateVersions(ctx context.Context, name string) ([]EmailTemplate, error) {
+	rows, err := q.db.Query(ctx, listEmailTemplateVersions, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmailTemplate
+	for rows.Next() {
+		var i EmailTemplate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Version,
+			&i.Subject,
+			&i.Body,
+			&i.CreatedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFilesByOwner = `-- name: ListFilesByOwner :many
+SELECT id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at FROM files
+WHERE owner_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2 OFFSET $3
+`
+
+type ListFilesByOwnerParams struct {
+	OwnerID int32 `json:"owner_id"`
+	Limit   int32 `json:"limit"`
+	Offset  int32 `json:"offset"`
+}
+
+func (q *Queries) ListFilesByOwner(ctx context.Context, arg ListFilesByOwnerParams) ([]File, error) {
+	rows, err := q.db.Query(ctx, listFilesByOwner, arg.OwnerID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []File
+	for rows.Next() {
+		var i File
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.ContentType,
+			&i.Size,
+			&i.Sha256,
+			&i.Status,
+			&i.ScanAttempts,
+			&i.ScanError,
+			&i.NextScanAt,
+			&i.ScannedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLatestEmailTemplates = `-- name: ListLatestEmailTemplates :many
+SELECT DISTINCT ON (name) id, name, version, subject, body, created_by, created_at FROM email_templates
+ORDER BY name, version DESC
+`
+
+func (q *Queries) ListLatestEmailTemplates(ctx context.Context) ([]EmailTemplate, error) {
+	rows, err := q.db.Query(ctx, listLatestEmailTemplates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmailTemplate
+	for rows.Next() {
+		var i EmailTemplate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Version,
+			&i.Subject,
+			&i.Body,
+			&i.CreatedBy,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProfilesByUserIDs = `-- name: ListProfilesByUserIDs :many
+SELECT user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at FROM profiles
+WHERE user_id = ANY($1::int[])
+`
+
+func (q *Queries) ListProfilesByUserIDs(ctx context.Context, userIds []int32) ([]Profile, error) {
+	rows, err := q.db.Query(ctx, listProfilesByUserIDs, userIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Profile
+	for rows.Next() {
+		var i Profile
+		if err := rows.Scan(
+			&i.UserID,
+			&i.DisplayName,
+			&i.Bio,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableFiles = `-- name: ListPurgeableFiles :many
+SELECT files.id, files.owner_id FROM files
+JOIN users ON users.id = files.owner_id
+WHERE users.deleted_at < $1
+`
+
+type ListPurgeableFilesRow struct {
+	ID      pgtype.UUID `json:"id"`
+	OwnerID int32       `json:"owner_id"`
+}
+
+func (q *Queries) ListPurgeableFiles(ctx context.Context, deletedAt pgtype.Timestamptz) ([]ListPurgeableFilesRow, error) {
+	rows, err := q.db.Query(ctx, listPurgeableFiles, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPurgeableFilesRow
+	for rows.Next() {
+		var i ListPurgeableFilesRow
+		if err := rows.Scan(&i.ID, &i.OwnerID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserDevices = `-- name: ListUserDevices :many
+SELECT device_id,
+    MIN(created_at)::timestamptz AS signed_in_at,
+    MAX(created_at)::timestamptz AS last_refreshed_at
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+GROUP BY device_id
+ORDER BY last_refreshed_at DESC
+`
+
+type ListUserDevicesParams struct {
+	UserID    int32              `json:"user_id"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+type ListUserDevicesRow struct {
+	DeviceID        string             `json:"device_id"`
+	SignedInAt      pgtype.Timestamptz `json:"signed_in_at"`
+	LastRefreshedAt pgtype.Timestamptz `json:"last_refreshed_at"`
+}
+
+func (q *Queries) ListUserDevices(ctx context.Context, arg ListUserDevicesParams) ([]ListUserDevicesRow, error) {
+	rows, err := q.db.Query(ctx, listUserDevices, arg.UserID, arg.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListUserDevicesRow
+	for rows.Next() {
+		var i ListUserDevicesRow
+		if err := rows.Scan(&i.DeviceID, &i.SignedInAt, &i.LastRefreshedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+LIMIT $1 OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailVerified,
+			&i.DeletedAt,
+			&i.TokenVersion,
+			&i.WriteRegion,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByExternalIDsForUpdate = `-- name: ListUsersByExternalIDsForUpdate :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE external_id = ANY($1::uuid[]) AND deleted_at IS NULL
+ORDER BY id
+FOR UPDATE
+`
+
+func (q *Queries) ListUsersByExternalIDsForUpdate(ctx context.Context, externalIds []pgtype.UUID) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersByExternalIDsForUpdate, externalIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailVerified,
+			&i.DeletedAt,
+			&i.TokenVersion,
+			&i.WriteRegion,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersFiltered = `-- name: ListUsersFiltered :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE ($1::text IS NULL OR role = $1)
+  AND ($2::boolean IS NULL OR email_verified = $2)
+  AND ($3::boolean OR deleted_at IS NULL)
+  AND ($4::int IS NULL OR id > $4)
+ORDER BY id
+LIMIT $5 OFFSET $6
+`
+
+type ListUsersFilteredParams struct {
+	Role           pgtype.Text `json:"role"`
+	EmailVerified  pgtype.Bool `json:"email_verified"`
+	IncludeDeleted bool        `json:"include_deleted"`
+	AfterID        pgtype.Int4 `json:"after_id"`
+	PageLimit      int32       `json:"page_limit"`
+	PageOffset     int32       `json:"page_offset"`
+}
+
+func (q *Queries) ListUsersFiltered(ctx context.Context, arg ListUsersFilteredParams) ([]User, error) {
+	rows, err := q.db.Query(ctx, listUsersFiltered,
+		arg.Role,
+		arg.EmailVerified,
+		arg.IncludeDeleted,
+		arg.AfterID,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Role,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.EmailVerified,
+			&i.DeletedAt,
+			&i.TokenVersion,
+			&i.WriteRegion,
+			&i.ExternalID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID int32 `json:"webhook_id"`
+	Limit     int32 `json:"limit"`
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.Query(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.Event,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastStatusCode,
+			&i.LastError,
+			&i.DeliveredAt,
+			&i.RedeliveryOf,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooks = `-- name: ListWebhooks :many
+SELECT id, url, secret, events, description, active, created_at, updated_at FROM webhooks
+ORDER BY id
+`
+
+func (q *Queries) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Secret,
+			&i.Events,
+			&i.Description,
+			&i.Active,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified = TRUE,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
 `
 
-type CreateUserParams struct {
-	Username     string `json:"username"`
-	Email        string `json:"email"`
-	PasswordHash string `json:"password_hash"`
-}
-
-func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.Email, arg.PasswordHash)
+func (q *Queries) MarkEmailVerified(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, markEmailVerified, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -55,27 +1805,51 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
 	)
 	return i, err
 }
 
-const deleteUser = `-- name: DeleteUser :exec
-DELETE FROM users
+const markRefreshTokenUsed = `-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens
+SET used_at = CURRENT_TIMESTAMP
 WHERE id = $1
 `
 
-func (q *Queries) DeleteUser(ctx context.Context, id int32) error {
-	_, err := q.db.Exec(ctx, deleteUser, id)
+func (q *Queries) MarkRefreshTokenUsed(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, markRefreshTokenUsed, id)
 	return err
 }
 
-const getUser = `-- name: GetUser :one
-SELECT id, username, email, password_hash, role, created_at, updated_at FROM users
-WHERE id = $1 LIMIT 1
+const patchUser = `-- name: PatchUser :one
+UPDATE users
+SET username = COALESCE($1, username),
+    email = COALESCE($2, email),
+    email_verified = email_verified AND email = COALESCE($2, email),
+    password_hash = COALESCE($3, password_hash),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $4 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
 `
 
-func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
-	row := q.db.QueryRow(ctx, getUser, id)
+type PatchUserParams struct {
+	Username     pgtype.Text `json:"username"`
+	Email        pgtype.Text `json:"email"`
+	PasswordHash pgtype.Text `json:"password_hash"`
+	ID           int32       `json:"id"`
+}
+
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, patchUser,
+		arg.Username,
+		arg.Email,
+		arg.PasswordHash,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -85,17 +1859,201 @@ func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
 	)
 	return i, err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, email, password_hash, role, created_at, updated_at FROM users
-WHERE email = $1 LIMIT 1
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1 AND NOT (id = ANY($2::int[]))
 `
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
+type PurgeDeletedUsersParams struct {
+	Cutoff  pgtype.Timestamptz `json:"cutoff"`
+	KeepIds []int32            `json:"keep_ids"`
+}
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, arg PurgeDeletedUsersParams) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeletedUsers, arg.Cutoff, arg.KeepIds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reassignAuditLogs = `-- name: ReassignAuditLogs :execrows
+UPDATE audit_logs
+SET user_id = $1
+WHERE user_id = $2
+`
+
+type ReassignAuditLogsParams struct {
+	ToUserID   int32 `json:"to_user_id"`
+	FromUserID int32 `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignAuditLogs(ctx context.Context, arg ReassignAuditLogsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reassignAuditLogs, arg.ToUserID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reassignFiles = `-- name: ReassignFiles :execrows
+UPDATE files
+SET owner_id = $1
+WHERE owner_id = $2
+`
+
+type ReassignFilesParams struct {
+	ToUserID   int32 `json:"to_user_id"`
+	FromUserID int32 `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignFiles(ctx context.Context, arg ReassignFilesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reassignFiles, arg.ToUserID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reassignProfile = `-- name: ReassignProfile :execrows
+UPDATE profiles
+SET user_id = $1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $2
+  AND NOT EXISTS (SELECT 1 FROM profiles WHERE user_id = $1)
+`
+
+type ReassignProfileParams struct {
+	ToUserID   int32 `json:"to_user_id"`
+	FromUserID int32 `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignProfile(ctx context.Context, arg ReassignProfileParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reassignProfile, arg.ToUserID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const reassignRefreshTokens = `-- name: ReassignRefreshTokens :execrows
+UPDATE refresh_tokens
+SET user_id = $1
+WHERE user_id = $2
+`
+
+type ReassignRefreshTokensParams struct {
+	ToUserID   int32 `json:"to_user_id"`
+	FromUserID int32 `json:"from_user_id"`
+}
+
+func (q *Queries) ReassignRefreshTokens(ctx context.Context, arg ReassignRefreshTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, reassignRefreshTokens, arg.ToUserID, arg.FromUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const recordFileScan = `-- name: RecordFileScan :exec
+UPDATE files
+SET status = $2,
+    scan_attempts = scan_attempts + 1,
+    scan_error = $3,
+    next_scan_at = $4,
+    scanned_at = $5
+WHERE id = $1
+`
+
+type RecordFileScanParams struct {
+	ID         pgtype.UUID        `json:"id"`
+	Status     string             `json:"status"`
+	ScanError  pgtype.Text        `json:"scan_error"`
+	NextScanAt pgtype.Timestamptz `json:"next_scan_at"`
+	ScannedAt  pgtype.Timestamptz `json:"scanned_at"`
+}
+
+func (q *Queries) RecordFileScan(ctx context.Context, arg RecordFileScanParams) error {
+	_, err := q.db.Exec(ctx, recordFileScan,
+		arg.ID,
+		arg.Status,
+		arg.ScanError,
+		arg.NextScanAt,
+		arg.ScannedAt,
+	)
+	return err
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = $6
+WHERE id = $1
+`
+
+type RecordWebhookAttemptParams struct {
+	ID             int32              `json:"id"`
+	Status         string             `json:"status"`
+	NextAttemptAt  pgtype.Timestamptz `json:"next_attempt_at"`
+	LastStatusCode pgtype.Int4        `json:"last_status_code"`
+	LastError      pgtype.Text        `json:"last_error"`
+	DeliveredAt    pgtype.Timestamptz `json:"delivered_at"`
+}
+
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordWebhookAttempt,
+		arg.ID,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastStatusCode,
+		arg.LastError,
+		arg.DeliveredAt,
+	)
+	return err
+}
+
+const releaseWebhookDeliveries = `-- name: ReleaseWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id = ANY($2::int[]) AND status = 'pending'
+`
+
+type ReleaseWebhookDeliveriesParams struct {
+	Now pgtype.Timestamptz `json:"now"`
+	Ids []int32            `json:"ids"`
+}
+
+func (q *Queries) ReleaseWebhookDeliveries(ctx context.Context, arg ReleaseWebhookDeliveriesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, releaseWebhookDeliveries, arg.Now, arg.Ids)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRow(ctx, restoreUser, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -105,38 +2063,132 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
 	)
 	return i, err
 }
 
-const listUsers = `-- name: ListUsers :many
-SELECT id, username, email, password_hash, role, created_at, updated_at FROM users
-ORDER BY id
-LIMIT $1 OFFSET $2
+const revokeAllRefreshTokens = `-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE revoked_at IS NULL AND expires_at > $1
 `
 
-type ListUsersParams struct {
-	Limit  int32 `json:"limit"`
-	Offset int32 `json:"offset"`
+func (q *Queries) RevokeAllRefreshTokens(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeAllRefreshTokens, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers, arg.Limit, arg.Offset)
+const revokeDeviceRefreshTokens = `-- name: RevokeDeviceRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeDeviceRefreshTokensParams struct {
+	UserID   int32  `json:"user_id"`
+	DeviceID string `json:"device_id"`
+}
+
+func (q *Queries) RevokeDeviceRefreshTokens(ctx context.Context, arg RevokeDeviceRefreshTokensParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeDeviceRefreshTokens, arg.UserID, arg.DeviceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeRefreshTokenFamily = `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE family_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenFamily(ctx context.Context, familyID pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeRefreshTokenFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID int32) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserRefreshTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT users.id, users.username, users.email, users.password_hash, users.role, users.created_at, users.updated_at, users.email_verified, users.deleted_at, users.token_version, users.write_region, users.external_id,
+    GREATEST(similarity(username, $1::text), similarity(email, $1::text))::real AS rank
+FROM users
+WHERE (username ILIKE $2 OR email ILIKE $2)
+  AND ($3::text IS NULL OR role = $3)
+  AND ($4::timestamptz IS NULL OR created_at > $4)
+  AND deleted_at IS NULL
+ORDER BY rank DESC, id
+LIMIT $5 OFFSET $6
+`
+
+type SearchUsersParams struct {
+	Query        string             `json:"query"`
+	Pattern      string             `json:"pattern"`
+	Role         pgtype.Text        `json:"role"`
+	CreatedAfter pgtype.Timestamptz `json:"created_after"`
+	PageLimit    int32              `json:"page_limit"`
+	PageOffset   int32              `json:"page_offset"`
+}
+
+type SearchUsersRow struct {
+	User User    `json:"user"`
+	Rank float32 `json:"rank"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers,
+		arg.Query,
+		arg.Pattern,
+		arg.Role,
+		arg.CreatedAfter,
+		arg.PageLimit,
+		arg.PageOffset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []User
+	var items []SearchUsersRow
 	for rows.Next() {
-		var i User
+		var i SearchUsersRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Username,
-			&i.Email,
-			&i.PasswordHash,
-			&i.Role,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.User.ID,
+			&i.User.Username,
+			&i.User.Email,
+			&i.User.PasswordHash,
+			&i.User.Role,
+			&i.User.CreatedAt,
+			&i.User.UpdatedAt,
+			&i.User.EmailVerified,
+			&i.User.DeletedAt,
+			&i.User.TokenVersion,
+			&i.User.WriteRegion,
+			&i.User.ExternalID,
+			&i.Rank,
 		); err != nil {
 			return nil, err
 		}
@@ -148,14 +2200,78 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, e
 	return items, nil
 }
 
+const touchUser = `-- name: TouchUser :exec
+UPDATE users
+SET updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) TouchUser(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, touchUser, id)
+	return err
+}
+
+const updateAuditAlertRule = `-- name: UpdateAuditAlertRule :one
+UPDATE audit_alert_rules
+SET name = $2,
+    action = $3,
+    per_actor = $4,
+    threshold = $5,
+    window_seconds = $6,
+    cooldown_seconds = $7,
+    enabled = $8,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at
+`
+
+type UpdateAuditAlertRuleParams struct {
+	ID              int32  `json:"id"`
+	Name            string `json:"name"`
+	Action          string `json:"action"`
+	PerActor        bool   `json:"per_actor"`
+	Threshold       int32  `json:"threshold"`
+	WindowSeconds   int32  `json:"window_seconds"`
+	CooldownSeconds int32  `json:"cooldown_seconds"`
+	Enabled         bool   `json:"enabled"`
+}
+
+func (q *Queries) UpdateAuditAlertRule(ctx context.Context, arg UpdateAuditAlertRuleParams) (AuditAlertRule, error) {
+	row := q.db.QueryRow(ctx, updateAuditAlertRule,
+		arg.ID,
+		arg.Name,
+		arg.Action,
+		arg.PerActor,
+		arg.Threshold,
+		arg.WindowSeconds,
+		arg.CooldownSeconds,
+		arg.Enabled,
+	)
+	var i AuditAlertRule
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Action,
+		&i.PerActor,
+		&i.Threshold,
+		&i.WindowSeconds,
+		&i.CooldownSeconds,
+		&i.Enabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET username = $2,
     email = $3,
+    email_verified = email_verified AND email = $3,
     password_hash = $4,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = $1
-RETURNING id, username, email, password_hash, role, created_at, updated_at
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
 `
 
 type UpdateUserParams struct {
@@ -181,6 +2297,165 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Role,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET password_hash = $2,
+    token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`
+
+type UpdateUserPasswordParams struct {
+	ID           int32  `json:"id"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const updateUserRoleAndTokenVersion = `-- name: UpdateUserRoleAndTokenVersion :one
+UPDATE users
+SET role = $2,
+    token_version = token_version + 1,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`
+
+type UpdateUserRoleAndTokenVersionParams struct {
+	ID   int32  `json:"id"`
+	Role string `json:"role"`
+}
+
+func (q *Queries) UpdateUserRoleAndTokenVersion(ctx context.Context, arg UpdateUserRoleAndTokenVersionParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRoleAndTokenVersion, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Role,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.TokenVersion,
+		&i.WriteRegion,
+		&i.ExternalID,
+	)
+	return i, err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2,
+    events = $3,
+    description = $4,
+    active = $5,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, url, secret, events, description, active, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	ID          int32    `json:"id"`
+	Url         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRow(ctx, updateWebhook,
+		arg.ID,
+		arg.Url,
+		arg.Events,
+		arg.Description,
+		arg.Active,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Secret,
+		&i.Events,
+		&i.Description,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertProfile = `-- name: UpsertProfile :one
+INSERT INTO profiles (user_id, display_name, bio, avatar_url, locale, timezone)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET display_name = EXCLUDED.display_name,
+    bio = EXCLUDED.bio,
+    avatar_url = EXCLUDED.avatar_url,
+    locale = EXCLUDED.locale,
+    timezone = EXCLUDED.timezone,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at
+`
+
+type UpsertProfileParams struct {
+	UserID      int32  `json:"user_id"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarUrl   string `json:"avatar_url"`
+	Locale      string `json:"locale"`
+	Timezone    string `json:"timezone"`
+}
+
+func (q *Queries) UpsertProfile(ctx context.Context, arg UpsertProfileParams) (Profile, error) {
+	row := q.db.QueryRow(ctx, upsertProfile,
+		arg.UserID,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.Locale,
+		arg.Timezone,
+	)
+	var i Profile
+	err := row.Scan(
+		&i.UserID,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QueryStat is a pg_stat_statements entry of the current database
+type QueryStat struct {
+	Name      string // sqlc query name; "" for SQL not issued through Queries
+	Query     string // normalised statement text
+	Calls     int64
+	Rows      int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+	MaxTime   time.Duration
+}
+
+// Orders of QueryStats
+const (
+	QueryStatsByTotalTime = "total_time"
+	QueryStatsByMeanTime  = "mean_time"
+	QueryStatsByCalls     = "calls"
+)
+
+// QueryStatsOrders lists every order QueryStats accepts
+var QueryStatsOrders = []string{QueryStatsByTotalTime, QueryStatsByMeanTime, QueryStatsByCalls}
+
+var queryStatsOrderColumns = map[string]string{
+	QueryStatsByTotalTime: "total_exec_time",
+	QueryStatsByMeanTime:  "mean_exec_time",
+	QueryStatsByCalls:     "calls",
+}
+
+// QueryStats returns the top limit statements of the current database from
+// pg_stat_statements, ordered by one of QueryStatsOrders descending. The
+// extension has to be preloaded and created; otherwise Postgres reports
+// undefined_table or object_not_in_prerequisite_state.
+func (q *Queries) QueryStats(ctx context.Context, order string, limit int32) ([]QueryStat, error) {
+	column, ok := queryStatsOrderColumns[order]
+	if !ok {
+		return nil, fmt.Errorf("unknown query stats order %q", order)
+	}
+	rows, err := q.db.Query(ctx, `SELECT query, calls, rows, total_exec_time, mean_exec_time, max_exec_time
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+ORDER BY `+column+` DESC
+LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []QueryStat
+	for rows.Next() {
+		var s QueryStat
+		var total, mean, peak float64 // milliseconds
+		if err := rows.Scan(&s.Query, &s.Calls, &s.Rows, &total, &mean, &peak); err != nil {
+			return nil, err
+		}
+		s.Name = queryName(s.Query)
+		s.TotalTime = milliseconds(total)
+		s.MeanTime = milliseconds(mean)
+		s.MaxTime = milliseconds(peak)
+		items = append(items, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,491 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+//go:generate go run ./sqlitegen
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// sqliteTimeLayout is how timestamps are stored: UTC and fixed-width, so
+// they order as strings, like strftime('%Y-%m-%d %H:%M:%f', 'now')
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+var errSQLiteUnsupported = errors.New("not supported by SQLite")
+
+// The Postgres functions the schema and queries call
+func init() {
+	sqlite.MustRegisterScalarFunction("gen_random_uuid", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+		return uuid.NewString(), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("similarity", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		a, _ := args[0].(string)
+		b, _ := args[1].(string)
+		return similarity(a, b), nil
+	})
+}
+
+// OpenSQLite opens, creating it if needed, the SQLite database at path and
+// runs the sqlc queries on it in the dialect sqlitegen generates from them.
+// It is meant for local development and demos: queries Postgres alone can
+// run, such as ListLatestEmailTemplates, fail, row locks are replaced by
+// SQLite's single writer, and timestamps are kept to the millisecond. The
+// schema is created where missing but never migrated, so a database made
+// by an older version has to be deleted.
+func OpenSQLite(ctx context.Context, path string, logger *slog.Logger) (*DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sqlDB.ExecContext(ctx, sqliteSchema); err != nil {
+		sqlDB.Close()
+		logger.Error("failed to create SQLite schema", "path", path, "error", err)
+		return nil, fmt.Errorf("create SQLite schema: %w", err)
+	}
+
+	conn := &sqliteDB{sqliteQuerier: sqliteQuerier{sqlDB}, db: sqlDB}
+	db := &DB{conn: conn}
+	db.Queries = db.queries(conn)
+
+	logger.Info("SQLite database opened", "path", path)
+	return db, nil
+}
+
+// sqliteExecutor is what *sql.DB and *sql.Tx have in common
+type sqliteExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// sqliteQuerier implements DBTX on a SQLite database or transaction
+type sqliteQuerier struct {
+	db sqliteExecutor
+}
+
+func (q sqliteQuerier) Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error) {
+	query, args, err := sqliteStatement(query, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	res, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return pgconn.CommandTag{}, sqliteError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag(statementVerb(query) + " " + strconv.FormatInt(n, 10)), nil
+}
+
+func (q sqliteQuerier) Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+	query, args, err := sqliteStatement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return &sqliteRows{rows: rows}, nil
+}
+
+func (q sqliteQuerier) QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row {
+	rows, err := q.Query(ctx, query, args...)
+	return sqliteRow{rows: rows, err: err}
+}
+
+type sqliteDB struct {
+	sqliteQuerier
+	db *sql.DB
+}
+
+func (d *sqliteDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	return &sqliteTx{sqliteQuerier: sqliteQuerier{tx}, tx: tx}, nil
+}
+
+func (d *sqliteDB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+func (d *sqliteDB) Close() {
+	d.db.Close()
+}
+
+// sqliteTx is a pgx.Tx on SQLite. Only what the sqlc queries and WithTx
+// use is supported.
+type sqliteTx struct {
+	sqliteQuerier
+	tx *sql.Tx
+}
+
+func (t *sqliteTx) Begin(context.Context) (pgx.Tx, error) {
+	return nil, fmt.Errorf("nested transactions are %w", errSQLiteUnsupported)
+}
+
+func (t *sqliteTx) Commit(context.Context) error {
+	return sqliteTxError(t.tx.Commit())
+}
+
+func (t *sqliteTx) Rollback(context.Context) error {
+	return sqliteTxError(t.tx.Rollback())
+}
+
+func (t *sqliteTx) CopyFrom(context.Context, pgx.Identifier, []string, pgx.CopyFromSource) (int64, error) {
+	return 0, fmt.Errorf("COPY is %w", errSQLiteUnsupported)
+}
+
+func (t *sqliteTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return sqliteBatch{}
+}
+
+func (t *sqliteTx) LargeObjects() pgx.LargeObjects {
+	return pgx.LargeObjects{}
+}
+
+func (t *sqliteTx) Prepare(context.Context, string, string) (*pgconn.StatementDescription, error) {
+	return nil, fmt.Errorf("named statements are %w", errSQLiteUnsupported)
+}
+
+func (t *sqliteTx) Conn() *pgx.Conn {
+	return nil
+}
+
+func sqliteTxError(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return pgx.ErrTxClosed
+	}
+	return sqliteError(err)
+}
+
+// sqliteBatch fails every batched query
+type sqliteBatch struct{}
+
+func (sqliteBatch) Exec() (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, fmt.Errorf("batches are %w", errSQLiteUnsupported)
+}
+func (b sqliteBatch) Query() (pgx.Rows, error) { _, err := b.Exec(); return nil, err }
+func (b sqliteBatch) QueryRow() pgx.Row        { _, err := b.Exec(); return sqliteRow{err: err} }
+func (sqliteBatch) Close() error               { return nil }
+
+type sqliteRows struct {
+	rows *sql.Rows
+}
+
+func (r *sqliteRows) Close()                        { r.rows.Close() }
+func (r *sqliteRows) Err() error                    { return sqliteError(r.rows.Err()) }
+func (r *sqliteRows) CommandTag() pgconn.CommandTag { return pgconn.NewCommandTag("SELECT") }
+func (r *sqliteRows) Next() bool                    { return r.rows.Next() }
+func (r *sqliteRows) RawValues() [][]byte           { return nil }
+func (r *sqliteRows) Conn() *pgx.Conn               { return nil }
+
+func (r *sqliteRows) FieldDescriptions() []pgconn.FieldDescription {
+	columns, _ := r.rows.Columns()
+	fields := make([]pgconn.FieldDescription, len(columns))
+	for i, name := range columns {
+		fields[i].Name = name
+	}
+	return fields
+}
+
+func (r *sqliteRows) Values() ([]any, error) {
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	return values, r.rows.Scan(dest...)
+}
+
+// Scan reads the row as the driver returns it and converts each value to
+// its destination, since database/sql knows nothing of pgtype types,
+// timestamps stored as text or arrays stored as JSON
+func (r *sqliteRows) Scan(dest ...any) error {
+	values := make([]any, len(dest))
+	raw := make([]any, len(dest))
+	for i := range values {
+		raw[i] = &values[i]
+	}
+	if err := r.rows.Scan(raw...); err != nil {
+		return err
+	}
+	for i, d := range dest {
+		if err := scanSQLiteValue(d, values[i]); err != nil {
+			columns, _ := r.rows.Columns()
+			return fmt.Errorf("scan column %s: %w", columns[i], err)
+		}
+	}
+	return nil
+}
+
+type sqliteRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r sqliteRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+func scanSQLiteValue(dest, src any) error {
+	switch d := dest.(type) {
+	case *pgtype.Timestamptz:
+		t, err := sqliteTime(src)
+		if err != nil {
+			return err
+		}
+		return d.Scan(t)
+	case *pgtype.Bool:
+		if n, ok := src.(int64); ok {
+			src = n != 0
+		}
+		return d.Scan(src)
+	case *time.Time:
+		t, err := sqliteTime(src)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return errors.New("cannot scan NULL into time.Time")
+		}
+		*d = t.(time.Time)
+		return nil
+	case sql.Scanner:
+		return d.Scan(src)
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("cannot scan into %T", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		dv.SetZero()
+		return nil
+	}
+	sv := reflect.ValueOf(src)
+	switch {
+	case dv.Kind() == reflect.Slice && dv.Type().Elem().Kind() != reflect.Uint8:
+		// Arrays are stored as JSON
+		var text []byte
+		switch s := src.(type) {
+		case string:
+			text = []byte(s)
+		case []byte:
+			text = s
+		default:
+			return fmt.Errorf("cannot scan %T into %T", src, dest)
+		}
+		return json.Unmarshal(text, dest)
+	case dv.Kind() == reflect.Bool && sv.CanInt():
+		dv.SetBool(sv.Int() != 0)
+	case dv.Kind() == reflect.String && sv.CanInt():
+		dv.SetString(strconv.FormatInt(sv.Int(), 10))
+	case sv.Type().ConvertibleTo(dv.Type()):
+		dv.Set(sv.Convert(dv.Type()))
+	default:
+		return fmt.Errorf("cannot scan %T into %T", src, dest)
+	}
+	return nil
+}
+
+// sqliteTime returns src, a stored timestamp, as a time.Time, or nil
+func sqliteTime(src any) (any, error) {
+	switch s := src.(type) {
+	case nil, time.Time:
+		return s, nil
+	case []byte:
+		src = string(s)
+	}
+	s, ok := src.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot scan %T as a timestamp", src)
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot parse timestamp %q", s)
+}
+
+// sqliteStatement returns the SQLite dialect of a query and its arguments.
+// Statements that are not sqlc queries, such as QueryStats, run as they are.
+func sqliteStatement(query string, args []any) (string, []any, error) {
+	if name := queryName(query); name != "" {
+		translated, ok := sqliteQueries[name]
+		if !ok {
+			return "", nil, fmt.Errorf("%s is %w", name, errSQLiteUnsupported)
+		}
+		query = translated
+	}
+	converted := make([]any, len(args))
+	for i, arg := range args {
+		var err error
+		if converted[i], err = sqliteArg(arg); err != nil {
+			return "", nil, fmt.Errorf("argument $%d: %w", i+1, err)
+		}
+	}
+	return query, converted, nil
+}
+
+// sqliteArg converts a query argument to a value stored the way the
+// schema expects
+func sqliteArg(arg any) (any, error) {
+	if v, ok := arg.(driver.Valuer); ok {
+		value, err := v.Value()
+		if err != nil {
+			return nil, err
+		}
+		arg = value
+	}
+	switch v := arg.(type) {
+	case nil, []byte, string:
+		return v, nil
+	case time.Time:
+		return v.UTC().Format(sqliteTimeLayout), nil
+	}
+	if reflect.ValueOf(arg).Kind() == reflect.Slice {
+		text, err := json.Marshal(arg)
+		return string(text), err
+	}
+	return arg, nil
+}
+
+// statementVerb returns the command a query runs, for its CommandTag
+func statementVerb(query string) string {
+	for _, line := range strings.Split(query, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			verb, _, _ := strings.Cut(line, " ")
+			return strings.ToUpper(verb)
+		}
+	}
+	return ""
+}
+
+// sqliteError reports constraint violations and missing tables, such as
+// pg_stat_statements, as the Postgres errors the services look for, and a
+// missing row as pgx.ErrNoRows
+func sqliteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	pgErr := &pgconn.PgError{Severity: "ERROR", Message: sqliteErr.Error()}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_ERROR:
+		if !strings.Contains(pgErr.Message, "no such table") {
+			return err
+		}
+		pgErr.Code = "42P01"
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		pgErr.Code = "23505"
+		pgErr.ConstraintName = uniqueConstraintName(pgErr.Message)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		pgErr.Code = "23503"
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		pgErr.Code = "23502"
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		pgErr.Code = "23514"
+	default:
+		return err
+	}
+	return pgErr
+}
+
+// uniqueConstraintName names the constraint in a message such as
+// "constraint failed: UNIQUE constraint failed: users.email (2067)" the way
+// Postgres names it by default, users_email_key
+func uniqueConstraintName(msg string) string {
+	_, columns, ok := strings.Cut(msg, " constraint failed: ")
+	if !ok {
+		return ""
+	}
+	columns, _, _ = strings.Cut(columns, " (")
+	if index, ok := strings.CutPrefix(columns, "index '"); ok {
+		return strings.TrimSuffix(index, "'")
+	}
+	var table string
+	var names []string
+	for _, column := range strings.Split(columns, ", ") {
+		var name string
+		table, name, _ = strings.Cut(column, ".")
+		names = append(names, name)
+	}
+	return table + "_" + strings.Join(names, "_") + "_key"
+}
+
+// similarity is pg_trgm's similarity: the share of trigrams two strings
+// have in common
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	if total := len(ta) + len(tb) - shared; total > 0 {
+		return float64(shared) / float64(total)
+	}
+	return 0
+}
+
+// trigrams splits s into the trigrams pg_trgm would: of each lowercased
+// word, padded with two spaces in front and one behind
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,507 @@
+// Code generated by sqlitegen from queries.sql.go. DO NOT EDIT.
+
+package database
+
+// sqliteQueries holds the SQLite dialect of the sqlc queries by name.
+// Not supported by SQLite: ListLatestEmailTemplates.
+var sqliteQueries = map[string]string{
+	"ClaimFileScans": `-- name: ClaimFileScans :many
+UPDATE files
+SET next_scan_at = $1
+WHERE id IN (
+    SELECT id FROM files
+    WHERE status = 'pending_scan' AND next_scan_at <= $2
+    ORDER BY next_scan_at
+    LIMIT $3
+)
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`,
+	"ClaimWebhookDeliveries": `-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= $2
+    ORDER BY next_attempt_at
+    LIMIT $3
+)
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at
+`,
+	"CountActiveSessions": `-- name: CountActiveSessions :one
+SELECT COUNT(DISTINCT family_id) AS sessions,
+    COUNT(DISTINCT user_id) AS users
+FROM refresh_tokens
+WHERE revoked_at IS NULL AND used_at IS NULL AND expires_at > $1
+`,
+	"CountAuditActions": `-- name: CountAuditActions :many
+SELECT (CASE WHEN $1 THEN actor_id END) AS actor_id, count(*) AS event_count
+FROM audit_logs
+WHERE action = $2 AND created_at >= $3
+GROUP BY 1
+HAVING count(*) >= $4
+`,
+	"CountAuditLogsForUser": `-- name: CountAuditLogsForUser :one
+SELECT count(*) FROM audit_logs
+WHERE user_id = $1
+`,
+	"CountFilesByOwner": `-- name: CountFilesByOwner :one
+SELECT count(*) FROM files
+WHERE owner_id = $1
+`,
+	"CountRefreshTokensForUser": `-- name: CountRefreshTokensForUser :one
+SELECT count(*) FROM refresh_tokens
+WHERE user_id = $1
+`,
+	"CreateAuditAlert": `-- name: CreateAuditAlert :one
+INSERT INTO audit_alerts (rule_id, actor_id, event_count, window_start, bucket)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (rule_id, (COALESCE(actor_id, 0)), bucket) DO NOTHING
+RETURNING id, rule_id, actor_id, event_count, window_start, bucket, created_at
+`,
+	"CreateAuditAlertRule": `-- name: CreateAuditAlertRule :one
+INSERT INTO audit_alert_rules (name, action, per_actor, threshold, window_seconds, cooldown_seconds)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at
+`,
+	"CreateAuditLog": `-- name: CreateAuditLog :one
+INSERT INTO audit_logs (user_id, action, actor_id, target_id, ip_address, user_agent, request_id, changes)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes
+`,
+	"CreateEmailTemplateVersion": `-- name: CreateEmailTemplateVersion :one
+INSERT INTO email_templates (name, version, subject, body, created_by)
+SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4
+FROM email_templates
+WHERE name = $1
+RETURNING id, name, version, subject, body, created_by, created_at
+`,
+	"CreateEmailVerification": `-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, token_hash, expires_at, created_at
+`,
+	"CreateFile": `-- name: CreateFile :one
+INSERT INTO files (id, owner_id, name, content_type, size, sha256, status, next_scan_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`,
+	"CreatePasswordReset": `-- name: CreatePasswordReset :one
+INSERT INTO password_resets (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+RETURNING id, user_id, token_hash, expires_at, created_at
+`,
+	"CreateRefreshToken": `-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, parent_id, device_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, user_id, family_id, parent_id, device_id, token_hash, expires_at, used_at, revoked_at, created_at
+`,
+	"CreateUser": `-- name: CreateUser :one
+INSERT INTO users (username, email, password_hash)
+VALUES ($1, $2, $3)
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"CreateWebhook": `-- name: CreateWebhook :one
+INSERT INTO webhooks (url, secret, events, description)
+VALUES ($1, $2, $3, $4)
+RETURNING id, url, secret, events, description, active, created_at, updated_at
+`,
+	"CreateWebhookDelivery": `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, redelivery_of)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at
+`,
+	"DeleteAuditAlertRule": `-- name: DeleteAuditAlertRule :execrows
+DELETE FROM audit_alert_rules
+WHERE id = $1
+`,
+	"DeleteEmailTemplate": `-- name: DeleteEmailTemplate :execrows
+DELETE FROM email_templates
+WHERE name = $1
+`,
+	"DeleteEmailVerificationsForUser": `-- name: DeleteEmailVerificationsForUser :exec
+DELETE FROM email_verifications
+WHERE user_id = $1
+`,
+	"DeleteExpiredRefreshTokens": `-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < $1
+`,
+	"DeleteFile": `-- name: DeleteFile :one
+DELETE FROM files
+WHERE id = $1
+RETURNING id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at
+`,
+	"DeleteOldWebhookDeliveries": `-- name: DeleteOldWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE created_at < $1 AND status <> 'pending'
+`,
+	"DeletePasswordResetsForUser": `-- name: DeletePasswordResetsForUser :exec
+DELETE FROM password_resets
+WHERE user_id = $1
+`,
+	"DeleteProfile": `-- name: DeleteProfile :one
+DELETE FROM profiles
+WHERE user_id = $1
+RETURNING user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at
+`,
+	"DeleteStaleRateLimitCounters": `-- name: DeleteStaleRateLimitCounters :exec
+DELETE FROM rate_limit_counters
+WHERE window_start < $1
+`,
+	"DeleteUser": `-- name: DeleteUser :exec
+UPDATE users
+SET deleted_at = $1,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $2 AND deleted_at IS NULL
+`,
+	"DeleteWebhook": `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1
+`,
+	"EnqueueWebhookDeliveries": `-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at)
+SELECT id, $1, $2, $3
+FROM webhooks
+WHERE active AND $1 IN (SELECT value FROM json_each(events))
+`,
+	"GetEmailTemplateVersion": `-- name: GetEmailTemplateVersion :one
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1 AND version = $2
+`,
+	"GetEmailVerification": `-- name: GetEmailVerification :one
+SELECT id, user_id, token_hash, expires_at, created_at FROM email_verifications
+WHERE token_hash = $1 LIMIT 1
+`,
+	"GetFile": `-- name: GetFile :one
+SELECT id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at FROM files
+WHERE id = $1
+`,
+	"GetLatestEmailTemplate": `-- name: GetLatestEmailTemplate :one
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1
+ORDER BY version DESC
+LIMIT 1
+`,
+	"GetPasswordReset": `-- name: GetPasswordReset :one
+SELECT id, user_id, token_hash, expires_at, created_at FROM password_resets
+WHERE token_hash = $1 LIMIT 1
+`,
+	"GetProfile": `-- name: GetProfile :one
+SELECT user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at FROM profiles
+WHERE user_id = $1
+`,
+	"GetRefreshTokenForUpdate": `-- name: GetRefreshTokenForUpdate :one
+SELECT id, user_id, family_id, parent_id, device_id, token_hash, expires_at, used_at, revoked_at, created_at FROM refresh_tokens
+WHERE token_hash = $1 LIMIT 1
+`,
+	"GetUser": `-- name: GetUser :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+`,
+	"GetUserByEmail": `-- name: GetUserByEmail :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1
+`,
+	"GetUserForUpdate": `-- name: GetUserForUpdate :one
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
+`,
+	"GetUserIDByExternalID": `-- name: GetUserIDByExternalID :one
+SELECT id FROM users
+WHERE external_id = $1 LIMIT 1
+`,
+	"GetUserWithProfile": `-- name: GetUserWithProfile :one
+SELECT users.id, users.username, users.email, users.password_hash, users.role, users.created_at, users.updated_at, users.email_verified, users.deleted_at, users.token_version, users.write_region, users.external_id,
+    profiles.display_name, profiles.bio, profiles.avatar_url, profiles.locale, profiles.timezone,
+    profiles.created_at AS profile_created_at, profiles.updated_at AS profile_updated_at
+FROM users
+LEFT JOIN profiles ON profiles.user_id = users.id
+WHERE users.id = $1 AND users.deleted_at IS NULL
+`,
+	"GetWebhook": `-- name: GetWebhook :one
+SELECT id, url, secret, events, description, active, created_at, updated_at FROM webhooks
+WHERE id = $1 LIMIT 1
+`,
+	"GetWebhookDelivery": `-- name: GetWebhookDelivery :one
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2 LIMIT 1
+`,
+	"IncrementRateLimitCounter": `-- name: IncrementRateLimitCounter :one
+INSERT INTO rate_limit_counters (key, window_start, count)
+VALUES ($1, $2, 1)
+ON CONFLICT (key) DO UPDATE
+SET count = CASE
+        WHEN rate_limit_counters.window_start = EXCLUDED.window_start THEN rate_limit_counters.count + 1
+        ELSE 1
+    END,
+    window_start = EXCLUDED.window_start
+RETURNING count
+`,
+	"IncrementTokenVersion": `-- name: IncrementTokenVersion :exec
+UPDATE users
+SET token_version = token_version + 1,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1
+`,
+	"ListAuditAlertRules": `-- name: ListAuditAlertRules :many
+SELECT id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at FROM audit_alert_rules
+ORDER BY id
+`,
+	"ListAuditAlerts": `-- name: ListAuditAlerts :many
+SELECT id, rule_id, actor_id, event_count, window_start, bucket, created_at FROM audit_alerts
+ORDER BY id DESC
+LIMIT $1
+`,
+	"ListAuditLogs": `-- name: ListAuditLogs :many
+SELECT id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes FROM audit_logs
+WHERE ($1 IS NULL OR user_id = $1)
+  AND ($2 IS NULL OR actor_id = $2)
+  AND ($3 IS NULL OR action = $3)
+  AND ($4 IS NULL OR created_at >= $4)
+  AND ($5 IS NULL OR created_at < $5)
+  AND ($6 IS NULL OR id < $6)
+ORDER BY id DESC
+LIMIT $7 OFFSET $8
+`,
+	"ListAuditLogsForUser": `-- name: ListAuditLogsForUser :many
+SELECT id, user_id, action, created_at, actor_id, target_id, ip_address, user_agent, request_id, changes FROM audit_logs
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`,
+	"ListEmailTemplateVersions": `-- name: ListEmailTemplateVersions :many
+SELECT id, name, version, subject, body, created_by, created_at FROM email_templates
+WHERE name = $1
+ORDER BY version DESC
+`,
+	"ListFilesByOwner": `-- name: ListFilesByOwner :many
+SELECT id, owner_id, name, content_type, size, sha256, status, scan_attempts, scan_error, next_scan_at, scanned_at, created_at FROM files
+WHERE owner_id = $1
+ORDER BY created_at DESC, id
+LIMIT $2 OFFSET $3
+`,
+	"ListProfilesByUserIDs": `-- name: ListProfilesByUserIDs :many
+SELECT user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at FROM profiles
+WHERE user_id IN (SELECT value FROM json_each($1))
+`,
+	"ListPurgeableFiles": `-- name: ListPurgeableFiles :many
+SELECT files.id, files.owner_id FROM files
+JOIN users ON users.id = files.owner_id
+WHERE users.deleted_at < $1
+`,
+	"ListUserDevices": `-- name: ListUserDevices :many
+SELECT device_id,
+    MIN(created_at) AS signed_in_at,
+    MAX(created_at) AS last_refreshed_at
+FROM refresh_tokens
+WHERE user_id = $1 AND revoked_at IS NULL AND used_at IS NULL AND expires_at > $2
+GROUP BY device_id
+ORDER BY last_refreshed_at DESC
+`,
+	"ListUsers": `-- name: ListUsers :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+LIMIT $1 OFFSET $2
+`,
+	"ListUsersByExternalIDsForUpdate": `-- name: ListUsersByExternalIDsForUpdate :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE external_id IN (SELECT value FROM json_each($1)) AND deleted_at IS NULL
+ORDER BY id
+`,
+	"ListUsersFiltered": `-- name: ListUsersFiltered :many
+SELECT id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id FROM users
+WHERE ($1 IS NULL OR role = $1)
+  AND ($2 IS NULL OR email_verified = $2)
+  AND ($3 OR deleted_at IS NULL)
+  AND ($4 IS NULL OR id > $4)
+ORDER BY id
+LIMIT $5 OFFSET $6
+`,
+	"ListWebhookDeliveries": `-- name: ListWebhookDeliveries :many
+SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, delivered_at, redelivery_of, created_at FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY id DESC
+LIMIT $2
+`,
+	"ListWebhooks": `-- name: ListWebhooks :many
+SELECT id, url, secret, events, description, active, created_at, updated_at FROM webhooks
+ORDER BY id
+`,
+	"MarkEmailVerified": `-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified = TRUE,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"MarkRefreshTokenUsed": `-- name: MarkRefreshTokenUsed :exec
+UPDATE refresh_tokens
+SET used_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1
+`,
+	"PatchUser": `-- name: PatchUser :one
+UPDATE users
+SET username = COALESCE($1, username),
+    email = COALESCE($2, email),
+    email_verified = email_verified AND email = COALESCE($2, email),
+    password_hash = COALESCE($3, password_hash),
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $4 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"PurgeDeletedUsers": `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1 AND NOT (id IN (SELECT value FROM json_each($2)))
+`,
+	"ReassignAuditLogs": `-- name: ReassignAuditLogs :execrows
+UPDATE audit_logs
+SET user_id = $1
+WHERE user_id = $2
+`,
+	"ReassignFiles": `-- name: ReassignFiles :execrows
+UPDATE files
+SET owner_id = $1
+WHERE owner_id = $2
+`,
+	"ReassignProfile": `-- name: ReassignProfile :execrows
+UPDATE profiles
+SET user_id = $1,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = $2
+  AND NOT EXISTS (SELECT 1 FROM profiles WHERE user_id = $1)
+`,
+	"ReassignRefreshTokens": `-- name: ReassignRefreshTokens :execrows
+UPDATE refresh_tokens
+SET user_id = $1
+WHERE user_id = $2
+`,
+	"RecordFileScan": `-- name: RecordFileScan :exec
+UPDATE files
+SET status = $2,
+    scan_attempts = scan_attempts + 1,
+    scan_error = $3,
+    next_scan_at = $4,
+    scanned_at = $5
+WHERE id = $1
+`,
+	"RecordWebhookAttempt": `-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET status = $2,
+    attempts = attempts + 1,
+    next_attempt_at = $3,
+    last_status_code = $4,
+    last_error = $5,
+    delivered_at = $6
+WHERE id = $1
+`,
+	"ReleaseWebhookDeliveries": `-- name: ReleaseWebhookDeliveries :execrows
+UPDATE webhook_deliveries
+SET next_attempt_at = $1
+WHERE id IN (SELECT value FROM json_each($2)) AND status = 'pending'
+`,
+	"RestoreUser": `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1 AND deleted_at IS NOT NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"RevokeAllRefreshTokens": `-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE revoked_at IS NULL AND expires_at > $1
+`,
+	"RevokeDeviceRefreshTokens": `-- name: RevokeDeviceRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL
+`,
+	"RevokeRefreshTokenFamily": `-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE family_id = $1 AND revoked_at IS NULL
+`,
+	"RevokeUserRefreshTokens": `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE user_id = $1 AND revoked_at IS NULL
+`,
+	"SearchUsers": `-- name: SearchUsers :many
+SELECT users.id, users.username, users.email, users.password_hash, users.role, users.created_at, users.updated_at, users.email_verified, users.deleted_at, users.token_version, users.write_region, users.external_id,
+    MAX(similarity(username, $1), similarity(email, $1)) AS rank
+FROM users
+WHERE (username LIKE $2 ESCAPE '\' OR email LIKE $2 ESCAPE '\')
+  AND ($3 IS NULL OR role = $3)
+  AND ($4 IS NULL OR created_at > $4)
+  AND deleted_at IS NULL
+ORDER BY rank DESC, id
+LIMIT $5 OFFSET $6
+`,
+	"TouchUser": `-- name: TouchUser :exec
+UPDATE users
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1
+`,
+	"UpdateAuditAlertRule": `-- name: UpdateAuditAlertRule :one
+UPDATE audit_alert_rules
+SET name = $2,
+    action = $3,
+    per_actor = $4,
+    threshold = $5,
+    window_seconds = $6,
+    cooldown_seconds = $7,
+    enabled = $8,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1
+RETURNING id, name, action, per_actor, threshold, window_seconds, cooldown_seconds, enabled, created_at, updated_at
+`,
+	"UpdateUser": `-- name: UpdateUser :one
+UPDATE users
+SET username = $2,
+    email = $3,
+    email_verified = email_verified AND email = $3,
+    password_hash = $4,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"UpdateUserPassword": `-- name: UpdateUserPassword :one
+UPDATE users
+SET password_hash = $2,
+    token_version = token_version + 1,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"UpdateUserRoleAndTokenVersion": `-- name: UpdateUserRoleAndTokenVersion :one
+UPDATE users
+SET role = $2,
+    token_version = token_version + 1,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, email, password_hash, role, created_at, updated_at, email_verified, deleted_at, token_version, write_region, external_id
+`,
+	"UpdateWebhook": `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2,
+    events = $3,
+    description = $4,
+    active = $5,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = $1
+RETURNING id, url, secret, events, description, active, created_at, updated_at
+`,
+	"UpsertProfile": `-- name: UpsertProfile :one
+INSERT INTO profiles (user_id, display_name, bio, avatar_url, locale, timezone)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE
+SET display_name = EXCLUDED.display_name,
+    bio = EXCLUDED.bio,
+    avatar_url = EXCLUDED.avatar_url,
+    locale = EXCLUDED.locale,
+    timezone = EXCLUDED.timezone,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+RETURNING user_id, display_name, bio, avatar_url, locale, timezone, created_at, updated_at
+`,
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func openTestSQLite(t *testing.T) *DB {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := NewDB(context.Background(), Config{DBConn: "sqlite:" + filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func createTestUser(t *testing.T, q *Queries, name string) User {
+	t.Helper()
+	user, err := q.CreateUser(context.Background(), CreateUserParams{Username: name, Email: name + "@example.com", PasswordHash: "hash"})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+	return user
+}
+
+func TestSQLiteUsers(t *testing.T) {
+	ctx := context.Background()
+	q := openTestSQLite(t).Queries
+
+	jane := createTestUser(t, q, "jane")
+	if jane.ID == 0 || !jane.ExternalID.Valid || !jane.CreatedAt.Valid || jane.Role != "user" || jane.EmailVerified {
+		t.Fatalf("created %+v, want defaults filled in", jane)
+	}
+	if age := time.Since(jane.CreatedAt.Time); age < 0 || age > time.Minute {
+		t.Errorf("created_at = %v, want about now", jane.CreatedAt.Time)
+	}
+	john := createTestUser(t, q, "john")
+
+	_, err := q.CreateUser(ctx, CreateUserParams{Username: "other", Email: jane.Email, PasswordHash: "hash"})
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" || pgErr.ConstraintName != "users_email_key" {
+		t.Fatalf("duplicate email error = %v, want a users_email_key violation", err)
+	}
+
+	id, err := q.GetUserIDByExternalID(ctx, jane.ExternalID)
+	if err != nil || id != jane.ID {
+		t.Fatalf("GetUserIDByExternalID = %d, %v; want %d", id, err, jane.ID)
+	}
+
+	page, err := q.ListUsersFiltered(ctx, ListUsersFilteredParams{AfterID: pgtype.Int4{Int32: jane.ID, Valid: true}, PageLimit: 10})
+	if err != nil || len(page) != 1 || page[0].ID != john.ID {
+		t.Fatalf("ListUsersFiltered after jane = %v, %v; want [john]", page, err)
+	}
+
+	patched, err := q.PatchUser(ctx, PatchUserParams{ID: jane.ID, Username: pgtype.Text{String: "janet", Valid: true}})
+	if err != nil || patched.Username != "janet" || patched.Email != jane.Email {
+		t.Fatalf("PatchUser = %+v, %v", patched, err)
+	}
+
+	if err := q.DeleteUser(ctx, DeleteUserParams{DeletedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true}, ID: john.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.GetUser(ctx, john.ID); !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("GetUser of a deleted user error = %v, want pgx.ErrNoRows", err)
+	}
+	purged, err := q.PurgeDeletedUsers(ctx, PurgeDeletedUsersParams{Cutoff: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true}, KeepIds: []int32{}})
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v; want 1", purged, err)
+	}
+}
+
+func TestSQLiteArraysAndSearch(t *testing.T) {
+	ctx := context.Background()
+	q := openTestSQLite(t).Queries
+	jane := createTestUser(t, q, "jane")
+	john := createTestUser(t, q, "john")
+
+	for _, u := range []User{jane, john} {
+		if _, err := q.UpsertProfile(ctx, UpsertProfileParams{UserID: u.ID, DisplayName: u.Username}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	profiles, err := q.ListProfilesByUserIDs(ctx, []int32{john.ID})
+	if err != nil || len(profiles) != 1 || profiles[0].DisplayName != "john" {
+		t.Fatalf("ListProfilesByUserIDs = %v, %v; want john's", profiles, err)
+	}
+
+	hook, err := q.CreateWebhook(ctx, CreateWebhookParams{Url: "https://example.com/hook", Secret: "s", Events: []string{"user.created", "user.deleted"}})
+	if err != nil || len(hook.Events) != 2 {
+		t.Fatalf("CreateWebhook = %+v, %v", hook, err)
+	}
+	enqueued, err := q.EnqueueWebhookDeliveries(ctx, EnqueueWebhookDeliveriesParams{
+		Event:         "user.deleted",
+		Payload:       []byte(`{}`),
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	})
+	if err != nil || enqueued != 1 {
+		t.Fatalf("EnqueueWebhookDeliveries = %d, %v; want 1", enqueued, err)
+	}
+
+	found, err := q.SearchUsers(ctx, SearchUsersParams{Query: "jan", Pattern: "%jan%", PageLimit: 10})
+	if err != nil || len(found) != 1 || found[0].User.ID != jane.ID || found[0].Rank <= 0 {
+		t.Fatalf("SearchUsers = %+v, %v; want jane ranked", found, err)
+	}
+}
+
+func TestSQLiteWithTx(t *testing.T) {
+	ctx := context.Background()
+	db := openTestSQLite(t)
+
+	errAbort := errors.New("abort")
+	err := db.WithTx(ctx, func(q *Queries) error {
+		createTestUser(t, q, "jane")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx error = %v, want %v", err, errAbort)
+	}
+	if users, err := db.Queries.ListUsers(ctx, ListUsersParams{Limit: 10}); err != nil || len(users) != 0 {
+		t.Fatalf("ListUsers after rollback = %v, %v; want none", users, err)
+	}
+
+	if err := db.WithTx(ctx, func(q *Queries) error {
+		createTestUser(t, q, "jane")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if users, err := db.Queries.ListUsers(ctx, ListUsersParams{Limit: 10}); err != nil || len(users) != 1 {
+		t.Fatalf("ListUsers after commit = %v, %v; want jane", users, err)
+	}
+}
+
+func TestSQLiteUnsupported(t *testing.T) {
+	q := openTestSQLite(t).Queries
+	if _, err := q.ListLatestEmailTemplates(context.Background()); !errors.Is(err, errSQLiteUnsupported) {
+		t.Fatalf("ListLatestEmailTemplates error = %v, want %v", err, errSQLiteUnsupported)
+	}
+	// Reported as Postgres reports pg_stat_statements missing
+	_, err := q.QueryStats(context.Background(), QueryStatsByCalls, 10)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42P01" {
+		t.Fatalf("QueryStats error = %v, want undefined_table", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Command sqlitegen writes the SQLite dialect of the database package's
+// schema and queries for OpenSQLite: sqlite_schema.sql from schema.sql,
+// and sqlite_queries.go from the queries sqlc generated into
+// queries.sql.go. It runs through go generate in the database package,
+// after sqlc generate, and a test fails while its output is out of date.
+//
+// Both translations are a fixed list of rewrites, each for a Postgres
+// construct the schema or queries use. A query that cannot be rewritten,
+// such as one using DISTINCT ON, is left out and fails on SQLite.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sqliteNow is CURRENT_TIMESTAMP in the form timestamps are stored in: UTC
+// and fixed-width to the millisecond, so they order as strings
+const sqliteNow = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+type rewrite struct {
+	pattern *regexp.Regexp
+	with    string
+}
+
+func rewrites(pairs ...string) []rewrite {
+	var rs []rewrite
+	for i := 0; i < len(pairs); i += 2 {
+		rs = append(rs, rewrite{regexp.MustCompile(pairs[i]), pairs[i+1]})
+	}
+	return rs
+}
+
+func apply(rs []rewrite, s string) string {
+	for _, r := range rs {
+		s = r.pattern.ReplaceAllString(s, r.with)
+	}
+	return s
+}
+
+var schemaRewrites = rewrites(
+	`(?m)^CREATE TABLE `, "CREATE TABLE IF NOT EXISTS ",
+	`(?m)^CREATE (UNIQUE )?INDEX `, "CREATE ${1}INDEX IF NOT EXISTS ",
+	// SERIAL never hands out an ID twice; a plain INTEGER PRIMARY KEY
+	// reuses the highest one once its row is deleted
+	`\bSERIAL PRIMARY KEY\b`, "INTEGER PRIMARY KEY AUTOINCREMENT",
+	`\bTIMESTAMP WITH TIME ZONE\b`, "TIMESTAMP",
+	`\bDEFAULT CURRENT_TIMESTAMP\b`, "DEFAULT ("+sqliteNow+")",
+	`\bDEFAULT gen_random_uuid\(\)`, "DEFAULT (gen_random_uuid())",
+	// Stored as text: a numeric type affinity would turn some of them into
+	// numbers. Arrays and JSONB hold JSON.
+	`\bUUID\b`, "TEXT",
+	`\bTEXT\[\]`, "TEXT",
+	`\bJSONB\b`, "TEXT",
+)
+
+// schemaSkipped matches statements SQLite has no equivalent of: extensions
+// and the trigram indexes behind SearchUsers, which SQLite runs as a scan
+var schemaSkipped = regexp.MustCompile(`^CREATE EXTENSION |\bUSING gin\b`)
+
+var queryRewrites = rewrites(
+	// SQLite is dynamically typed
+	`::[a-z]+(\[\])?`, "",
+	// Arrays are stored and bound as JSON
+	`(\S+) = ANY\(([^)]+)\)`, "${1} IN (SELECT value FROM json_each(${2}))",
+	// LIKE ignores ASCII case already
+	`ILIKE (\$\d+)`, `LIKE ${1} ESCAPE '\'`,
+	// There is a single writer, so nothing needs locking
+	`\s+FOR UPDATE( SKIP LOCKED)?`, "",
+	`\bGREATEST\(`, "MAX(",
+	`\bCURRENT_TIMESTAMP\b`, sqliteNow,
+)
+
+// queryUnsupported matches queries left out of sqlite_queries.go
+var queryUnsupported = regexp.MustCompile(`\bDISTINCT ON\b`)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the database package")
+	flag.Parse()
+	files, err := generate(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(*dir, name), content, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// generate returns the generated files by name
+func generate(dir string) (map[string][]byte, error) {
+	schema, err := os.ReadFile(filepath.Join(dir, "schema.sql"))
+	if err != nil {
+		return nil, err
+	}
+	queries, err := sqlcQueries(filepath.Join(dir, "queries.sql.go"))
+	if err != nil {
+		return nil, err
+	}
+	queriesFile, err := translateQueries(queries)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		"sqlite_schema.sql": translateSchema(string(schema)),
+		"sqlite_queries.go": queriesFile,
+	}, nil
+}
+
+func translateSchema(schema string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`-- Code generated by sqlitegen from schema.sql. DO NOT EDIT.
+--
+-- Applied by OpenSQLite. Timestamps are UTC text in the fixed-width form
+-- 'YYYY-MM-DD HH:MM:SS.SSS' so they compare as strings. UUIDs, arrays and
+-- JSONB are TEXT, the arrays and JSONB holding JSON.
+`)
+	for _, stmt := range strings.Split(schema, ";\n") {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" || schemaSkipped.MatchString(stmt) {
+			continue
+		}
+		b.WriteString("\n" + apply(schemaRewrites, stmt) + ";\n")
+	}
+	return b.Bytes()
+}
+
+// sqlcQueries returns the queries declared in a file generated by sqlc,
+// by name
+func sqlcQueries(path string) (map[string]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	queries := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, value := range spec.(*ast.ValueSpec).Values {
+				lit, ok := value.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				query, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					return nil, err
+				}
+				// The same test as the database package's queryName
+				if rest, ok := strings.CutPrefix(query, "-- name: "); ok {
+					name, _, _ := strings.Cut(rest, " ")
+					queries[name] = query
+				}
+			}
+		}
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%s declares no sqlc queries", path)
+	}
+	return queries, nil
+}
+
+func translateQueries(queries map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by sqlitegen from queries.sql.go. DO NOT EDIT.
+
+package database
+
+// sqliteQueries holds the SQLite dialect of the sqlc queries by name.
+`)
+	var unsupported []string
+	for _, name := range names {
+		if queryUnsupported.MatchString(queries[name]) {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) > 0 {
+		b.WriteString("// Not supported by SQLite: " + strings.Join(unsupported, ", ") + ".\n")
+	}
+	b.WriteString("var sqliteQueries = map[string]string{\n")
+	for _, name := range names {
+		query := queries[name]
+		if queryUnsupported.MatchString(query) {
+			continue
+		}
+		fmt.Fprintf(&b, "%q: %s,\n", name, quote(apply(queryRewrites, query)))
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// quote returns s as a raw string literal where it can be one, which keeps
+// the generated queries readable
+func quote(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	files, err := generate("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join("..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is out of date; run go generate ./database", name)
+		}
+	}
+}
+
+func TestTranslateSchema(t *testing.T) {
+	got := string(translateSchema(`CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE t (
+    id SERIAL PRIMARY KEY,
+    ref UUID NOT NULL DEFAULT gen_random_uuid(),
+    tags TEXT[] NOT NULL,
+    doc JSONB,
+    at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX t_ref_key ON t (ref) WHERE doc IS NULL;
+CREATE INDEX t_tags_trgm_idx ON t USING gin (tags gin_trgm_ops);
+`))
+	want := `
+CREATE TABLE IF NOT EXISTS t (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ref TEXT NOT NULL DEFAULT (gen_random_uuid()),
+    tags TEXT NOT NULL,
+    doc TEXT,
+    at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS t_ref_key ON t (ref) WHERE doc IS NULL;
+`
+	if _, body, _ := bytes.Cut([]byte(got), []byte("JSONB holding JSON.\n")); string(body) != want {
+		t.Errorf("translateSchema =\n%s\nwant\n%s", body, want)
+	}
+}
+
+func TestTranslateQueries(t *testing.T) {
+	src, err := translateQueries(map[string]string{
+		"Search": "-- name: Search :many\nSELECT GREATEST(a, b)::real FROM t WHERE name ILIKE $1 AND id = ANY($2::int[])\nFOR UPDATE SKIP LOCKED",
+		"Touch":  "-- name: Touch :exec\nUPDATE t SET at = CURRENT_TIMESTAMP WHERE $1::text = ANY(tags)",
+		"Latest": "-- name: Latest :many\nSELECT DISTINCT ON (name) * FROM t",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"// Not supported by SQLite: Latest.\n",
+		"\"Search\": `-- name: Search :many\nSELECT MAX(a, b) FROM t WHERE name LIKE $1 ESCAPE '\\' AND id IN (SELECT value FROM json_each($2))`,",
+		"\"Touch\": `-- name: Touch :exec\nUPDATE t SET at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE $1 IN (SELECT value FROM json_each(tags))`,",
+	} {
+		if !bytes.Contains(src, []byte(want)) {
+			t.Errorf("translateQueries output lacks %q:\n%s", want, src)
+		}
+	}
+	if bytes.Contains(src, []byte(`"Latest":`)) {
+		t.Errorf("translateQueries kept a DISTINCT ON query:\n%s", src)
+	}
+}
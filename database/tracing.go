@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"idiomatic-go/timing"
+
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statementNameKey is the span attribute holding the sqlc query name
+const statementNameKey = attribute.Key("db.statement.name")
+
+// newTracer instruments queries with an OTel span each, named after the
+// sqlc query, adds their time to the request's timing breakdown and logs
+// queries slower than slowQuery. A zero slowQuery disables the log.
+func newTracer(logger *slog.Logger, slowQuery time.Duration) pgx.QueryTracer {
+	return multitracer.New(
+		otelpgx.NewTracer(otelpgx.WithSpanNameFunc(spanName)),
+		&queryTracer{logger: logger, slowQuery: slowQuery},
+	)
+}
+
+// queryName returns the name sqlc puts in the "-- name: X :kind" comment
+// at the start of every generated query, or "" for other SQL
+func queryName(sql string) string {
+	rest, ok := strings.CutPrefix(sql, "-- name: ")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+// spanName names a query span after its sqlc query, or the SQL command
+// for hand-written statements, keeping the statement itself out of the name
+func spanName(sql string) string {
+	if name := queryName(sql); name != "" {
+		return name
+	}
+	command, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	return strings.ToUpper(command)
+}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	name string
+	args []any
+	at   time.Time
+}
+
+// queryTracer runs after otelpgx, so the span in the context is the query's
+type queryTracer struct {
+	logger    *slog.Logger
+	slowQuery time.Duration
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := queryName(data.SQL)
+	if name != "" {
+		trace.SpanFromContext(ctx).SetAttributes(statementNameKey.String(name))
+	}
+	if name == "" {
+		name = spanName(data.SQL)
+	}
+	return context.WithValue(ctx, queryStartKey{}, queryStart{name: name, args: data.Args, at: time.Now()})
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	took := time.Since(start.at)
+	timing.Record(ctx, timing.LayerDB, took)
+	if t.slowQuery == 0 || took < t.slowQuery {
+		return
+	}
+	attrs := []any{"query", start.name, "duration", took, "args", redactArgs(start.args)}
+	if data.Err != nil {
+		attrs = append(attrs, "error", data.Err)
+	} else {
+		attrs = append(attrs, "rows", data.CommandTag.RowsAffected())
+	}
+	t.logger.WarnContext(ctx, "slow query", attrs...)
+}
+
+// redactArgs keeps numbers and booleans, which are mostly IDs, limits and
+// flags useful for reproducing a slow query, and replaces every other
+// argument with its type so no personal data reaches the log
+func redactArgs(args []any) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		switch arg.(type) {
+		case nil:
+			out[i] = "NULL"
+		case bool, int, int16, int32, int64, float32, float64:
+			out[i] = fmt.Sprint(arg)
+		default:
+			out[i] = fmt.Sprintf("<%T>", arg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package debugmode
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/flags"
+	"idiomatic-go/signer"
+)
+
+// TokenHeader carries a signed debug token of the form "<unix-expiry>.<hex-hmac>"
+const TokenHeader = "X-Debug-Token"
+
+type forcedKey struct{}
+
+// WithForced marks ctx for forced trace sampling and debug logging
+func WithForced(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcedKey{}, true)
+}
+
+// Forced reports whether ctx was marked by WithForced
+func Forced(ctx context.Context) bool {
+	forced, _ := ctx.Value(forcedKey{}).(bool)
+	return forced
+}
+
+// UserFlagPrefix namespaces per-user debug toggles in the flag store
+const UserFlagPrefix = "debug_user:"
+
+// Controller tracks which users are under live debugging and verifies
+// debug tokens. Every toggle carries an expiry so debugging can never be
+// left on by accident. User toggles are kept in the shared flag store so
+// they apply on every replica.
+type Controller struct {
+	mu     sync.RWMutex
+	users  map[int64]time.Time
+	store  *flags.Store
+	tokens *signer.TokenSigner
+	clock  clock.Clock
+}
+
+// NewController returns a Controller. An empty secret disables debug tokens.
+func NewController(store *flags.Store, secret string, clk clock.Clock) *Controller {
+	c := &Controller{
+		users:  make(map[int64]time.Time),
+		store:  store,
+		tokens: signer.NewTokenSigner(secret, clk),
+		clock:  clk,
+	}
+	store.OnChange(c.onFlagChange)
+	return c
+}
+
+func (c *Controller) onFlagChange(name, value string, deleted bool) {
+	idStr, ok := strings.CutPrefix(name, UserFlagPrefix)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if deleted {
+		delete(c.users, userID)
+		return
+	}
+	if expUnix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		c.users[userID] = time.Unix(expUnix, 0)
+	}
+}
+
+// EnableUser turns on debugging for userID for ttl and returns the expiry
+func (c *Controller) EnableUser(ctx context.Context, userID int64, ttl time.Duration) (time.Time, error) {
+	expires := c.clock.Now().Add(ttl)
+	name := UserFlagPrefix + strconv.FormatInt(userID, 10)
+	if err := c.store.Set(ctx, name, strconv.FormatInt(expires.Unix(), 10)); err != nil {
+		return time.Time{}, err
+	}
+	return expires, nil
+}
+
+// DisableUser turns off debugging for userID
+func (c *Controller) DisableUser(ctx context.Context, userID int64) error {
+	return c.store.Delete(ctx, UserFlagPrefix+strconv.FormatInt(userID, 10))
+}
+
+// UserEnabled reports whether debugging is active for userID
+func (c *Controller) UserEnabled(userID int64) bool {
+	c.mu.RLock()
+	expires, ok := c.users[userID]
+	c.mu.RUnlock()
+	return ok && c.clock.Now().Before(expires)
+}
+
+// Users returns the users currently under debugging with their expiry
+func (c *Controller) Users() map[int64]time.Time {
+	now := c.clock.Now()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make(map[int64]time.Time, len(c.users))
+	for id, expires := range c.users {
+		if now.Before(expires) {
+			users[id] = expires
+		}
+	}
+	return users
+}
+
+// TokensEnabled reports whether a signing secret is configured
+func (c *Controller) TokensEnabled() bool {
+	return c.tokens.Enabled()
+}
+
+// IssueToken returns a debug token valid for ttl
+func (c *Controller) IssueToken(ttl time.Duration) (string, time.Time) {
+	return c.tokens.IssueFor("", ttl)
+}
+
+// VerifyToken reports whether token is correctly signed and unexpired
+func (c *Controller) VerifyToken(token string) bool {
+	payload, err := c.tokens.Verify(token)
+	return err == nil && payload == ""
+}
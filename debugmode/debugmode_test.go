@@ -0,0 +1,38 @@
+package debugmode
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/flags"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestUserToggleExpires(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	c := NewController(flags.NewStore(rdb, slog.New(slog.NewTextHandler(io.Discard, nil))), "", clk)
+
+	expires, err := c.EnableUser(context.Background(), 42, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := clk.Now().Add(time.Hour); !expires.Equal(want) {
+		t.Fatalf("EnableUser expiry = %v, want %v", expires, want)
+	}
+
+	clk.Set(expires.Add(-time.Second))
+	if !c.UserEnabled(42) || len(c.Users()) != 1 {
+		t.Fatal("debugging off a second before the toggle expires")
+	}
+	clk.Set(expires)
+	if c.UserEnabled(42) || len(c.Users()) != 0 {
+		t.Fatal("debugging still on once the toggle expired")
+	}
+}
@@ -0,0 +1,32 @@
+package debugmode
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sampler struct {
+	base sdktrace.Sampler
+}
+
+// NewSampler wraps base so that requests marked with WithForced are always
+// recorded and sampled, regardless of the configured sampling ratio.
+func NewSampler(base sdktrace.Sampler) sdktrace.Sampler {
+	return sampler{base: base}
+}
+
+func (s sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if Forced(p.ParentContext) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Attributes: []attribute.KeyValue{attribute.Bool("debug.forced", true)},
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s sampler) Description() string {
+	return "DebugForced{" + s.base.Description() + "}"
+}
@@ -0,0 +1,44 @@
+package denylist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix namespaces denied client IPs in Redis
+const KeyPrefix = "denylist:ip:"
+
+// Store is a Redis-backed list of temporarily denied client IPs, shared by
+// every instance. Entries expire on their own.
+type Store struct {
+	rdb *redis.Client
+}
+
+func NewStore(rdb *redis.Client) *Store {
+	return &Store{rdb: rdb}
+}
+
+// Add denies ip for ttl, recording why
+func (s *Store) Add(ctx context.Context, ip string, ttl time.Duration, reason string) error {
+	return s.rdb.Set(ctx, KeyPrefix+ip, reason, ttl).Err()
+}
+
+// Remove lifts the denial for ip, if any
+func (s *Store) Remove(ctx context.Context, ip string) error {
+	return s.rdb.Del(ctx, KeyPrefix+ip).Err()
+}
+
+// Reason returns why ip is denied and whether it is denied at all
+func (s *Store) Reason(ctx context.Context, ip string) (string, bool, error) {
+	reason, err := s.rdb.Get(ctx, KeyPrefix+ip).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return reason, true, nil
+}
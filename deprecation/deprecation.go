@@ -0,0 +1,146 @@
+// Package deprecation announces the retirement of API routes and records
+// who still calls them. Deprecated routes answer with Deprecation, Sunset
+// and Link headers; every call is counted per caller in Redis, so the
+// usage report covers all replicas and shows whom to contact before a
+// route is removed.
+package deprecation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix namespaces deprecation usage keys in Redis
+const KeyPrefix = "deprecation:"
+
+const (
+	noticesKey  = KeyPrefix + "notices" // route -> JSON Notice
+	usagePrefix = KeyPrefix + "usage:"  // route -> hash of caller -> requests
+)
+
+// Notice describes the retirement of a route
+type Notice struct {
+	Since       time.Time `json:"since"`                 // when the route was deprecated
+	Sunset      time.Time `json:"sunset,omitempty"`      // when it will be removed; zero if not decided yet
+	Replacement string    `json:"replacement,omitempty"` // URL of the successor endpoint or a migration guide
+}
+
+// SetHeaders announces n in response headers: Deprecation (RFC 9745),
+// Sunset (RFC 8594) and a successor-version Link
+func (n Notice) SetHeaders(h http.Header) {
+	h.Set("Deprecation", "@"+strconv.FormatInt(n.Since.Unix(), 10))
+	if !n.Sunset.IsZero() {
+		h.Set("Sunset", n.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if n.Replacement != "" {
+		h.Add("Link", "<"+n.Replacement+`>; rel="successor-version"`)
+	}
+}
+
+// CallerUsage counts the requests of one caller to a deprecated route
+type CallerUsage struct {
+	Caller   string
+	Requests int64
+}
+
+// Usage summarizes the calls to a deprecated route within the retention
+type Usage struct {
+	Route    string
+	Notice   Notice
+	Requests int64
+	Callers  []CallerUsage // most requests first
+}
+
+// Tracker records calls to deprecated routes
+type Tracker struct {
+	rdb       *redis.Client
+	retention time.Duration
+
+	mu     sync.Mutex
+	stored map[string]bool // routes whose notice this replica has stored
+}
+
+// NewTracker keeps usage for retention after the last call to a route
+func NewTracker(rdb *redis.Client, retention time.Duration) *Tracker {
+	return &Tracker{rdb: rdb, retention: retention, stored: make(map[string]bool)}
+}
+
+// Record counts a call by caller to route, deprecated by notice
+func (t *Tracker) Record(ctx context.Context, route string, notice Notice, caller string) error {
+	pipe := t.rdb.TxPipeline()
+	if t.firstCall(route) {
+		raw, err := json.Marshal(notice)
+		if err != nil {
+			return err
+		}
+		pipe.HSet(ctx, noticesKey, route, raw)
+	}
+	pipe.Expire(ctx, noticesKey, t.retention)
+	pipe.HIncrBy(ctx, usagePrefix+route, caller, 1)
+	pipe.Expire(ctx, usagePrefix+route, t.retention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.mu.Lock()
+		delete(t.stored, route)
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (t *Tracker) firstCall(route string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stored[route] {
+		return false
+	}
+	t.stored[route] = true
+	return true
+}
+
+// Report returns the usage of every deprecated route called within the
+// retention, most requested first. Routes nobody called any more are
+// listed with zero requests: they are safe to remove.
+func (t *Tracker) Report(ctx context.Context) ([]Usage, error) {
+	notices, err := t.rdb.HGetAll(ctx, noticesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]Usage, 0, len(notices))
+	for route, raw := range notices {
+		usage := Usage{Route: route}
+		if err := json.Unmarshal([]byte(raw), &usage.Notice); err != nil {
+			return nil, err
+		}
+		counts, err := t.rdb.HGetAll(ctx, usagePrefix+route).Result()
+		if err != nil {
+			return nil, err
+		}
+		for caller, count := range counts {
+			n, _ := strconv.ParseInt(count, 10, 64)
+			usage.Requests += n
+			usage.Callers = append(usage.Callers, CallerUsage{Caller: caller, Requests: n})
+		}
+		sort.Slice(usage.Callers, func(i, j int) bool {
+			if usage.Callers[i].Requests != usage.Callers[j].Requests {
+				return usage.Callers[i].Requests > usage.Callers[j].Requests
+			}
+			return usage.Callers[i].Caller < usage.Callers[j].Caller
+		})
+		report = append(report, usage)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Requests != report[j].Requests {
+			return report[i].Requests > report[j].Requests
+		}
+		return report[i].Route < report[j].Route
+	})
+	return report, nil
+}
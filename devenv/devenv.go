@@ -0,0 +1,160 @@
+// Package devenv runs the service's Postgres and Redis in-process for
+// serve -dev, so contributors can run the API without Docker or locally
+// installed servers. Postgres is a real server, run from binaries
+// downloaded once into ~/.embedded-postgres-go; Redis is miniredis.
+package devenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"idiomatic-go/database"
+	"idiomatic-go/passwords"
+	"idiomatic-go/services"
+
+	"github.com/alicebob/miniredis/v2"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/jackc/pgx/v5"
+)
+
+// SeedPassword is the password of every seeded user
+const SeedPassword = "password123"
+
+// SeedUsers are the users Seed creates, with verified email addresses so
+// they can log in right away
+var SeedUsers = []SeedUser{
+	{Username: "admin", Email: "admin@example.com", Role: services.RoleAdmin},
+	{Username: "user", Email: "user@example.com"},
+}
+
+// SeedUser is one of SeedUsers
+type SeedUser struct {
+	Username string
+	Email    string
+	Role     string // the default role when empty
+}
+
+// Env is a running Postgres and Redis
+type Env struct {
+	DatabaseURL string
+	RedisAddr   string
+
+	postgres *embeddedpostgres.EmbeddedPostgres
+	redis    *miniredis.Miniredis
+	tempDir  string
+}
+
+// Start starts Postgres and Redis on free local ports. The database lives
+// in dataDir, and is kept between runs, or with an empty dataDir in a
+// temporary directory that Stop removes. Postgres logs to log.
+func Start(dataDir string, log io.Writer) (*Env, error) {
+	env := &Env{}
+	if dataDir == "" {
+		dir, err := os.MkdirTemp("", "idiomatic-go-dev-")
+		if err != nil {
+			return nil, err
+		}
+		dataDir, env.tempDir = dir, dir
+	}
+	dataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		env.Stop()
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		env.Stop()
+		return nil, err
+	}
+
+	cfg := embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("idiomatic_go").
+		Username("dev").
+		Password("dev").
+		// Binaries are unpacked afresh on every start, so only the data
+		// directory needs to outlive the process
+		RuntimePath(filepath.Join(dataDir, "runtime")).
+		DataPath(filepath.Join(dataDir, "data")).
+		Logger(log)
+	env.postgres = embeddedpostgres.NewDatabase(cfg)
+	if err := env.postgres.Start(); err != nil {
+		env.postgres = nil
+		env.Stop()
+		return nil, fmt.Errorf("failed to start Postgres: %w", err)
+	}
+	env.DatabaseURL = cfg.GetConnectionURL() + "?sslmode=disable"
+
+	env.redis, err = miniredis.Run()
+	if err != nil {
+		env.Stop()
+		return nil, fmt.Errorf("failed to start Redis: %w", err)
+	}
+	env.RedisAddr = env.redis.Addr()
+	return env, nil
+}
+
+// Stop stops Postgres and Redis and removes a temporary data directory
+func (e *Env) Stop() error {
+	var errs []error
+	if e.redis != nil {
+		e.redis.Close()
+	}
+	if e.postgres != nil {
+		errs = append(errs, e.postgres.Stop())
+	}
+	if e.tempDir != "" {
+		errs = append(errs, os.RemoveAll(e.tempDir))
+	}
+	return errors.Join(errs...)
+}
+
+// Seed creates those of SeedUsers that do not exist yet and returns them
+func Seed(ctx context.Context, db *database.DB, hasher *passwords.Hasher) ([]SeedUser, error) {
+	hash, err := hasher.Hash(SeedPassword)
+	if err != nil {
+		return nil, err
+	}
+	var created []SeedUser
+	err = db.WithTx(ctx, func(q *database.Queries) error {
+		for _, u := range SeedUsers {
+			_, err := q.GetUserByEmail(ctx, u.Email)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+			user, err := q.CreateUser(ctx, database.CreateUserParams{Username: u.Username, Email: u.Email, PasswordHash: hash})
+			if err != nil {
+				return fmt.Errorf("seed %s: %w", u.Username, err)
+			}
+			if _, err := q.MarkEmailVerified(ctx, user.ID); err != nil {
+				return err
+			}
+			if u.Role != "" {
+				if _, err := q.UpdateUserRoleAndTokenVersion(ctx, database.UpdateUserRoleAndTokenVersionParams{ID: user.ID, Role: u.Role}); err != nil {
+					return err
+				}
+			}
+			created = append(created, u)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// freePort returns a local port nothing listens on
+func freePort() (uint32, error) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return uint32(ln.Addr().(*net.TCPAddr).Port), nil
+}
@@ -0,0 +1,162 @@
+// Package emails holds the templates of the emails the service sends.
+// Each has a default embedded in the binary, which operators can replace
+// at runtime with versions stored in the database.
+package emails
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template names
+const (
+	VerifyEmail        = "verify_email"
+	PasswordReset      = "password_reset"
+	PasswordForceReset = "password_force_reset"
+	AccountExists      = "account_exists"
+	AuditAlert         = "audit_alert"
+)
+
+// AccountData is what the account emails can refer to. Link is empty in
+// account_exists.
+type AccountData struct {
+	Username string
+	Link     string
+}
+
+// AlertData is what the audit_alert email can refer to
+type AlertData struct {
+	Rule      string
+	Action    string
+	Actor     string // "user 42" or "all actors"
+	Count     int32
+	Threshold int32
+	Window    time.Duration
+}
+
+// samples stand in for real data in previews and when checking that a
+// template only refers to fields its email has
+var samples = map[string]any{
+	VerifyEmail:        AccountData{Username: "johndoe", Link: "https://example.com/api/v1/verify?token=sample"},
+	PasswordReset:      AccountData{Username: "johndoe", Link: "https://example.com/reset-password?token=sample"},
+	PasswordForceReset: AccountData{Username: "johndoe", Link: "https://example.com/reset-password?token=sample"},
+	AccountExists:      AccountData{},
+	AuditAlert:         AlertData{Rule: "Mass deletion", Action: "user_deleted", Actor: "user 42", Count: 14, Threshold: 10, Window: time.Minute},
+}
+
+const (
+	MaxSubjectLen = 200
+	MaxBodyLen    = 10000
+)
+
+// Template is the source of an email's subject and body, in text/template
+// syntax
+type Template struct {
+	Subject string
+	Body    string
+}
+
+//go:embed defaults/*.txt
+var defaultFiles embed.FS
+
+var defaults = map[string]Template{}
+
+func init() {
+	for name := range samples {
+		raw, err := defaultFiles.ReadFile("defaults/" + name + ".txt")
+		if err != nil {
+			panic(fmt.Sprintf("emails: no default for %s: %v", name, err))
+		}
+		head, body, _ := strings.Cut(string(raw), "\n\n")
+		subject, ok := strings.CutPrefix(head, "Subject: ")
+		if !ok {
+			panic("emails: default " + name + " does not start with a Subject line")
+		}
+		t := Template{Subject: subject, Body: body}
+		if err := t.Check(name); err != nil {
+			panic(fmt.Sprintf("emails: default %s: %v", name, err))
+		}
+		defaults[name] = t
+	}
+}
+
+// Names lists every template in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Known reports whether name is a template the service sends
+func Known(name string) bool {
+	_, ok := samples[name]
+	return ok
+}
+
+// Default returns the embedded template for name
+func Default(name string) (Template, bool) {
+	t, ok := defaults[name]
+	return t, ok
+}
+
+// Sample returns the example data previews of name are rendered with
+func Sample(name string) any {
+	return samples[name]
+}
+
+// ErrSubject and ErrBody tell which part of a template failed Check
+var (
+	ErrSubject = errors.New("invalid subject")
+	ErrBody    = errors.New("invalid body")
+)
+
+// Check reports whether t is fit to be the template for name: within the
+// size limits, a single-line subject, and both parts rendering with the
+// sample data, which rules out references to fields the email lacks
+func (t Template) Check(name string) error {
+	switch {
+	case strings.TrimSpace(t.Subject) == "" || len(t.Subject) > MaxSubjectLen:
+		return fmt.Errorf("%w: must be 1 to %d characters", ErrSubject, MaxSubjectLen)
+	case strings.ContainsAny(t.Subject, "\r\n"):
+		return fmt.Errorf("%w: must be a single line", ErrSubject)
+	case strings.TrimSpace(t.Body) == "" || len(t.Body) > MaxBodyLen:
+		return fmt.Errorf("%w: must be 1 to %d characters", ErrBody, MaxBodyLen)
+	}
+	_, _, err := t.Render(Sample(name))
+	return err
+}
+
+// Render executes t with data. A rendered subject is kept to one line so
+// data cannot inject headers.
+func (t Template) Render(data any) (subject, body string, err error) {
+	subject, err = execute(t.Subject, data)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrSubject, err)
+	}
+	body, err = execute(t.Body, data)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrBody, err)
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
+	return subject, body, nil
+}
+
+func execute(text string, data any) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,122 @@
+package custom_errors
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ErrorCode is a stable, machine-readable snake_case identifier returned to
+// clients in the "code" field of every APIError.
+type ErrorCode string
+
+const (
+	CodeBadRequest            ErrorCode = "bad_request"
+	CodeUnauthorized          ErrorCode = "unauthorized"
+	CodeForbidden             ErrorCode = "forbidden"
+	CodeNotFound              ErrorCode = "not_found"
+	CodeInternalServerError   ErrorCode = "internal_server_error"
+	CodeInvalidAuthHeader     ErrorCode = "invalid_auth_header"
+	CodeInvalidToken          ErrorCode = "invalid_token"
+	CodeInvalidClaims         ErrorCode = "invalid_claims"
+	CodeRateLimitExceeded     ErrorCode = "rate_limit_exceeded"
+	CodeUnknownFields         ErrorCode = "unknown_fields"
+	CodeConflict              ErrorCode = "conflict"
+	CodeServiceUnavailable    ErrorCode = "service_unavailable"
+	CodeTokenRevoked          ErrorCode = "token_revoked"
+	CodeEmailNotVerified      ErrorCode = "email_not_verified"
+	CodeInvalidVerification   ErrorCode = "invalid_verification_token"
+	CodeUsernameTaken         ErrorCode = "username_taken"
+	CodeEmailTaken            ErrorCode = "email_taken"
+	CodeInvalidReset          ErrorCode = "invalid_password_reset_token"
+	CodeInvalidRefresh        ErrorCode = "invalid_refresh_token"
+	CodeValidationFailed      ErrorCode = "validation_failed"
+	CodeBotDetected           ErrorCode = "bot_detected"
+	CodeBotChallenge          ErrorCode = "bot_challenge_required"
+	CodeIPDenied              ErrorCode = "ip_denied"
+	CodeInvalidSignature      ErrorCode = "invalid_signature"
+	CodeLinkExpired           ErrorCode = "link_expired"
+	CodeLinkUsed              ErrorCode = "link_already_used"
+	CodeQueryStatsUnavailable ErrorCode = "query_stats_unavailable"
+	CodePayloadTooLarge       ErrorCode = "payload_too_large"
+	CodeRequestTimeout        ErrorCode = "request_timeout"
+	CodeIdempotencyKeyReused  ErrorCode = "idempotency_key_reused"
+	CodePreconditionFailed    ErrorCode = "precondition_failed"
+	CodeFileNotAvailable      ErrorCode = "file_not_available"
+	CodeClientClosedRequest   ErrorCode = "client_closed_request"
+)
+
+// CatalogEntry documents a single ErrorCode
+type CatalogEntry struct {
+	Code        ErrorCode `json:"code"`
+	Description string    `json:"description"`
+}
+
+// catalog lists every code the API may return. New codes must be added here;
+// init rejects duplicates and codes that are not snake_case.
+var catalog = []CatalogEntry{
+	{CodeBadRequest, "The request was malformed or failed validation"},
+	{CodeUnauthorized, "Authentication is missing or failed"},
+	{CodeForbidden, "The caller is not allowed to perform this action"},
+	{CodeNotFound, "The requested resource does not exist"},
+	{CodeInternalServerError, "An unexpected server error occurred"},
+	{CodeInvalidAuthHeader, "The Authorization header is not a Bearer token"},
+	{CodeInvalidToken, "The token is malformed, expired or has an invalid signature"},
+	{CodeInvalidClaims, "The token claims could not be read"},
+	{CodeRateLimitExceeded, "Too many requests; retry after the advertised delay"},
+	{CodeUnknownFields, "The request body contains fields the endpoint does not accept"},
+	{CodeConflict, "The resource changed concurrently; re-read it and retry"},
+	{CodeServiceUnavailable, "A dependency is temporarily unavailable; retry after the advertised delay"},
+	{CodeTokenRevoked, "The token was revoked by logout and can no longer be used"},
+	{CodeEmailNotVerified, "The account's email address has not been verified yet"},
+	{CodeInvalidVerification, "The email verification token is unknown or expired"},
+	{CodeUsernameTaken, "The requested username is already in use"},
+	{CodeEmailTaken, "The requested email address already belongs to another account"},
+	{CodeInvalidReset, "The password reset token is unknown or expired"},
+	{CodeInvalidRefresh, "The refresh token is unknown, expired or revoked; sign in again"},
+	{CodeValidationFailed, "One or more request fields are invalid; see fields for details"},
+	{CodeBotDetected, "The request was classified as automated traffic and blocked"},
+	{CodeBotChallenge, "The request looks automated; pass the edge challenge and retry"},
+	{CodeIPDenied, "The client IP is temporarily denied after abusive requests"},
+	{CodeInvalidSignature, "The signed link was tampered with or signed by an unknown key"},
+	{CodeLinkExpired, "The signed link has expired; request a new one"},
+	{CodeLinkUsed, "The single-use link has already been used"},
+	{CodeQueryStatsUnavailable, "The pg_stat_statements extension is not enabled in the database"},
+	{CodePayloadTooLarge, "The request body exceeds the configured size limit"},
+	{CodeRequestTimeout, "The request did not complete within the server's time limit; retry later"},
+	{CodePreconditionFailed, "The If-Match ETag no longer matches the resource; re-read it and retry"},
+	{CodeFileNotAvailable, "The file is still being scanned for malware, or the scan rejected it"},
+	{CodeIdempotencyKeyReused, "The Idempotency-Key was already used for a request with a different method, path or body"},
+	{CodeClientClosedRequest, "The client closed the connection before the response was ready; only seen in server logs"},
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+func init() {
+	seen := make(map[ErrorCode]struct{}, len(catalog))
+	for _, entry := range catalog {
+		if !snakeCase.MatchString(string(entry.Code)) {
+			panic(fmt.Sprintf("custom_errors: error code %q is not snake_case", entry.Code))
+		}
+		if _, dup := seen[entry.Code]; dup {
+			panic(fmt.Sprintf("custom_errors: duplicate error code %q", entry.Code))
+		}
+		seen[entry.Code] = struct{}{}
+	}
+}
+
+// Catalog returns a copy of all registered error codes
+func Catalog() []CatalogEntry {
+	entries := make([]CatalogEntry, len(catalog))
+	copy(entries, catalog)
+	return entries
+}
+
+// Registered reports whether code is part of the catalog
+func Registered(code ErrorCode) bool {
+	for _, entry := range catalog {
+		if entry.Code == code {
+			return true
+		}
+	}
+	return false
+}
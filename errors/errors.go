@@ -1,25 +1,61 @@
 package custom_errors
 
 import (
+	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var (
-	ErrBadRequest          = NewAPIError(http.StatusBadRequest, "bad_request", "Invalid request")
-	ErrUnauthorized        = NewAPIError(http.StatusUnauthorized, "unauthorized", "Authentication failed")
-	ErrForbidden           = NewAPIError(http.StatusForbidden, "forbidden", "Permission denied")
-	ErrNotFound            = NewAPIError(http.StatusNotFound, "not_found", "Resource not found")
-	ErrInternalServerError = NewAPIError(http.StatusInternalServerError, "internal_server_error", "Something went wrong")
+	ErrBadRequest          = NewAPIError(http.StatusBadRequest, CodeBadRequest, "Invalid request")
+	ErrUnauthorized        = NewAPIError(http.StatusUnauthorized, CodeUnauthorized, "Authentication failed")
+	ErrForbidden           = NewAPIError(http.StatusForbidden, CodeForbidden, "Permission denied")
+	ErrNotFound            = NewAPIError(http.StatusNotFound, CodeNotFound, "Resource not found")
+	ErrInternalServerError = NewAPIError(http.StatusInternalServerError, CodeInternalServerError, "Something went wrong")
+	ErrConflict            = NewAPIError(http.StatusConflict, CodeConflict, "Resource was modified concurrently").WithRetry(0)
+	ErrTooManyRequests     = NewAPIError(http.StatusTooManyRequests, CodeRateLimitExceeded, "Too many requests").WithRetry(0)
+	ErrServiceUnavailable  = NewAPIError(http.StatusServiceUnavailable, CodeServiceUnavailable, "Service temporarily unavailable").WithRetry(0)
+	ErrEmailNotVerified    = NewAPIError(http.StatusForbidden, CodeEmailNotVerified, "Email address not verified")
+	ErrValidation          = NewAPIError(http.StatusBadRequest, CodeValidationFailed, "Request validation failed")
+	ErrPreconditionFailed  = NewAPIError(http.StatusPreconditionFailed, CodePreconditionFailed, "Resource has changed since it was read")
+	ErrPayloadTooLarge     = NewAPIError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Request body too large")
+	ErrGatewayTimeout      = NewAPIError(http.StatusGatewayTimeout, CodeRequestTimeout, "Request took too long").WithRetry(0)
+
+	// ErrClientClosedRequest uses the non-standard 499 status, as nginx
+	// does, so abandoned requests stand apart from server errors in logs
+	ErrClientClosedRequest = NewAPIError(StatusClientClosedRequest, CodeClientClosedRequest, "Client closed request")
 )
 
+// StatusClientClosedRequest is the status recorded for requests whose
+// client went away before the response was written
+const StatusClientClosedRequest = 499
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 type APIError struct {
-	StatusCode int    `json:"-"`
-	Code       string `json:"code"`
-	Message    string `json:"message"`
+	StatusCode int       `json:"-"`
+	Code       ErrorCode `json:"code"`
+	Message    string    `json:"message"`
+
+	// Retryable tells clients the same request may succeed if sent again;
+	// RetryAfter, when set, is the minimum wait before doing so.
+	Retryable  bool          `json:"retryable"`
+	RetryAfter time.Duration `json:"-"`
+
+	Fields    []FieldError `json:"fields,omitempty"`     // per-field validation failures
+	RequestID string       `json:"request_id,omitempty"` // set when the error is rendered
+
+	cause error // underlying error, never serialized to clients
 }
 
-func NewAPIError(statusCode int, code, message string) *APIError {
+func NewAPIError(statusCode int, code ErrorCode, message string) *APIError {
 	return &APIError{
 		StatusCode: statusCode,
 		Code:       code,
@@ -28,9 +64,80 @@ func NewAPIError(statusCode int, code, message string) *APIError {
 }
 
 func (e *APIError) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
 	return e.Message
 }
 
+// WithRetry returns a copy of e marked retryable after the given delay.
+// A zero delay marks the error retryable without a Retry-After hint.
+func (e *APIError) WithRetry(after time.Duration) *APIError {
+	retry := *e
+	retry.Retryable = true
+	retry.RetryAfter = after
+	return &retry
+}
+
+// WithFields returns a copy of e carrying per-field details
+func (e *APIError) WithFields(fields []FieldError) *APIError {
+	detailed := *e
+	detailed.Fields = fields
+	return &detailed
+}
+
+// WithRequestID returns a copy of e tagged with the request it answers
+func (e *APIError) WithRequestID(id string) *APIError {
+	tagged := *e
+	tagged.RequestID = id
+	return &tagged
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds
+func (e *APIError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// SetHeaders adds the client retry hints for e to h
+func (e *APIError) SetHeaders(h http.Header) {
+	if e.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(e.RetryAfterSeconds()))
+	}
+}
+
+// MarshalJSON adds retry_after (in seconds) to the body when a delay is set
+func (e *APIError) MarshalJSON() ([]byte, error) {
+	type apiError APIError
+	return json.Marshal(struct {
+		*apiError
+		RetryAfter int `json:"retry_after,omitempty"`
+	}{
+		apiError:   (*apiError)(e),
+		RetryAfter: e.RetryAfterSeconds(),
+	})
+}
+
+// Wrap returns a copy of e that records err as its cause. The client-facing
+// Code and Message stay unchanged while the cause remains reachable through
+// errors.Unwrap, errors.Is and errors.As for logging.
+func (e *APIError) Wrap(err error) *APIError {
+	wrapped := *e
+	wrapped.cause = err
+	return &wrapped
+}
+
+// Unwrap returns the underlying cause, if any
+func (e *APIError) Unwrap() error {
+	return e.cause
+}
+
+// Is matches any APIError with the same code, so wrapped copies of the
+// sentinel errors above still satisfy errors.Is(err, ErrNotFound).
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
 // IsAPIError checks if an error is an APIError
 func IsAPIError(err error) (*APIError, bool) {
 	var apiErr *APIError
@@ -39,3 +146,14 @@ func IsAPIError(err error) (*APIError, bool) {
 	}
 	return nil, false
 }
+
+// RootCause follows the Unwrap chain of err and returns the innermost error
+func RootCause(err error) error {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+}
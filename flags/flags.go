@@ -0,0 +1,176 @@
+package flags
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	hashKey       = "flags"
+	changeChannel = "flags:changed"
+)
+
+// Well-known runtime toggles
+const (
+	LogLevel        = "log_level"
+	MaintenanceMode = "maintenance_mode"
+	FeaturePrefix   = "feature:"
+)
+
+// ChangeFunc is called when a flag is set (deleted == false) or removed
+type ChangeFunc func(name, value string, deleted bool)
+
+// Store holds runtime toggles shared by all replicas. Values live in a Redis
+// hash; writers publish on a channel so every replica reloads within
+// moments, and a periodic resync covers missed messages.
+type Store struct {
+	rdb    *redis.Client
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	values    map[string]string
+	listeners []ChangeFunc
+}
+
+func NewStore(rdb *redis.Client, logger *slog.Logger) *Store {
+	return &Store{
+		rdb:    rdb,
+		logger: logger,
+		values: make(map[string]string),
+	}
+}
+
+// Get returns the locally cached value of name
+func (s *Store) Get(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// Bool returns name parsed as a boolean, false if unset or invalid
+func (s *Store) Bool(name string) bool {
+	value, ok := s.Get(name)
+	if !ok {
+		return false
+	}
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// FeatureEnabled reports whether the feature flag "feature:<name>" is on
+func (s *Store) FeatureEnabled(name string) bool {
+	return s.Bool(FeaturePrefix + name)
+}
+
+// All returns a copy of every cached flag
+func (s *Store) All() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		all[k] = v
+	}
+	return all
+}
+
+// OnChange registers fn to be called for every flag change, including
+// those made by other replicas
+func (s *Store) OnChange(fn ChangeFunc) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+// Set stores value under name and notifies all replicas
+func (s *Store) Set(ctx context.Context, name, value string) error {
+	if err := s.rdb.HSet(ctx, hashKey, name, value).Err(); err != nil {
+		return err
+	}
+	return s.notify(ctx)
+}
+
+// Delete removes name and notifies all replicas
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if err := s.rdb.HDel(ctx, hashKey, name).Err(); err != nil {
+		return err
+	}
+	return s.notify(ctx)
+}
+
+func (s *Store) notify(ctx context.Context) error {
+	if err := s.rdb.Publish(ctx, changeChannel, "").Err(); err != nil {
+		return err
+	}
+	return s.Load(ctx)
+}
+
+// Load replaces the local cache with the contents of Redis and fires
+// listeners for every difference
+func (s *Store) Load(ctx context.Context) error {
+	values, err := s.rdb.HGetAll(ctx, hashKey).Result()
+	if err != nil {
+		return err
+	}
+
+	type change struct {
+		name, value string
+		deleted     bool
+	}
+	var changes []change
+
+	s.mu.Lock()
+	for name, value := range values {
+		if old, ok := s.values[name]; !ok || old != value {
+			changes = append(changes, change{name: name, value: value})
+		}
+	}
+	for name := range s.values {
+		if _, ok := values[name]; !ok {
+			changes = append(changes, change{name: name, deleted: true})
+		}
+	}
+	s.values = values
+	listeners := make([]ChangeFunc, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+
+	for _, ch := range changes {
+		for _, fn := range listeners {
+			fn(ch.name, ch.value, ch.deleted)
+		}
+	}
+	return nil
+}
+
+// Watch keeps the cache in sync until ctx is cancelled. It reloads on every
+// change notification and at least once per resync interval.
+func (s *Store) Watch(ctx context.Context, resync time.Duration) {
+	if err := s.Load(ctx); err != nil {
+		s.logger.Warn("failed to load runtime flags", "error", err)
+	}
+
+	pubsub := s.rdb.Subscribe(ctx, changeChannel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-messages:
+		case <-ticker.C:
+		}
+		if err := s.Load(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Warn("failed to reload runtime flags", "error", err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package gox runs long-lived background goroutines (event listeners,
+// relays, watchers) so that they all behave the same way: a panic is
+// recovered and logged instead of crashing the server, and a gauge shows
+// how many of each are running.
+package gox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	running = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "background_goroutines",
+			Help: "Background goroutines started with gox.Run that have not returned, by name",
+		},
+		[]string{"name"},
+	)
+	panics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "background_goroutine_panics_total",
+			Help: "Panics recovered in background goroutines, by name",
+		},
+		[]string{"name"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(running, panics)
+}
+
+// Run calls fn with ctx in a new goroutine and returns a channel that is
+// closed once fn has returned. fn must return when ctx is done; cancelling
+// ctx is how it is stopped. A panic in fn ends the goroutine, is logged
+// with its stack under name and counted; it is not restarted.
+func Run(ctx context.Context, logger *slog.Logger, name string, fn func(ctx context.Context)) <-chan struct{} {
+	done := make(chan struct{})
+	gauge := running.WithLabelValues(name)
+	gauge.Inc()
+	go func() {
+		defer close(done)
+		defer gauge.Dec()
+		defer func() {
+			if p := recover(); p != nil {
+				panics.WithLabelValues(name).Inc()
+				logger.ErrorContext(ctx, "background goroutine panicked",
+					"goroutine", name, "error", fmt.Errorf("panic: %v", p), "stack", string(debug.Stack()))
+			}
+		}()
+		fn(ctx)
+	}()
+	return done
+}
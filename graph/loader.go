@@ -0,0 +1,106 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"idiomatic-go/database"
+	"idiomatic-go/services"
+)
+
+const (
+	batchWait = 2 * time.Millisecond // how long a batch collects keys before it is fetched
+	batchMax  = 100                  // keys fetched at most in one batch, a page of users
+)
+
+// batchLoader coalesces the keys requested by concurrently resolving
+// fields into one fetch, so a page of users costs one profile query
+// instead of one per user. gqlgen resolves the items of a list
+// concurrently, which is what gives a batch time to fill.
+type batchLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu    sync.Mutex
+	batch *batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	started bool
+	done    chan struct{} // closed once values and err are set
+	values  map[K]V
+	err     error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{fetch: fetch}
+}
+
+// Load returns the value for key, and false if fetch found none. The
+// batch is fetched with the context of the call that started it.
+func (l *batchLoader[K, V]) Load(ctx context.Context, key K) (V, bool, error) {
+	l.mu.Lock()
+	b := l.batch
+	if b == nil {
+		b = &batch[K, V]{done: make(chan struct{})}
+		l.batch = b
+		time.AfterFunc(batchWait, func() { l.run(ctx, b) })
+	}
+	b.keys = append(b.keys, key)
+	if len(b.keys) == batchMax {
+		l.batch = nil
+		go l.run(ctx, b)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		var zero V
+		return zero, false, ctx.Err()
+	}
+	if b.err != nil {
+		var zero V
+		return zero, false, b.err
+	}
+	v, ok := b.values[key]
+	return v, ok, nil
+}
+
+// run fetches b unless it already was, when it filled up before the wait
+// was over
+func (l *batchLoader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	l.mu.Lock()
+	if b.started {
+		l.mu.Unlock()
+		return
+	}
+	b.started = true
+	if l.batch == b {
+		l.batch = nil
+	}
+	keys := b.keys
+	l.mu.Unlock()
+
+	b.values, b.err = l.fetch(ctx, keys)
+	close(b.done)
+}
+
+// loaders batch the lookups of a single operation. Nothing they load is
+// invalidated, so they must not outlive it.
+type loaders struct {
+	profiles *batchLoader[int32, database.Profile]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, userService *services.UserService) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		profiles: newBatchLoader(userService.ProfilesByUserID),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,27 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package model
+
+type Mutation struct {
+}
+
+// A complete profile; omitted fields are cleared
+type ProfileInput struct {
+	DisplayName string `json:"displayName" binding:"max=100"`
+	Bio         string `json:"bio" binding:"max=500"`
+	AvatarURL   string `json:"avatarUrl" binding:"omitempty,http_url,max=2048"`
+	// A BCP 47 language tag such as en-GB
+	Locale string `json:"locale" binding:"omitempty,bcp47_language_tag,max=35"`
+	// An IANA time zone name such as Europe/London
+	Timezone string `json:"timezone" binding:"omitempty,timezone,max=64"`
+}
+
+type Query struct {
+}
+
+// Changes to a user; omitted fields are left unchanged
+type UserPatch struct {
+	Username *string `json:"username,omitempty" binding:"omitnil,min=1,max=50,username,notreserved"`
+	Email    *string `json:"email,omitempty" binding:"omitnil,email,max=255"`
+	Password *string `json:"password,omitempty" binding:"omitnil,password"`
+}
@@ -0,0 +1,89 @@
+// Package graph serves users and profiles over GraphQL. The schema is in
+// schema.graphqls; generated.go and model/ are generated from it, and the
+// resolvers in schema.resolvers.go are filled in by hand.
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"context"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/services"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Resolver resolves every operation through the user service, so GraphQL
+// and REST share their rules
+type Resolver struct {
+	userService *services.UserService
+	denied      error // for operations on other users' data
+}
+
+// Limits bound the cost of a single operation
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// NewServer returns the GraphQL server. Callers must be authenticated
+// before it runs. With concealForeign, operations on other users' data
+// fail as not found instead of forbidden, as in the REST API.
+func NewServer(userService *services.UserService, concealForeign bool, limits Limits) *handler.Server {
+	r := &Resolver{userService: userService, denied: custom_errors.ErrForbidden}
+	if concealForeign {
+		r.denied = custom_errors.ErrNotFound
+	}
+
+	cfg := Config{Resolvers: r}
+	// Every user in a page costs what its selection does
+	cfg.Complexity.Query.Users = func(childComplexity, limit, _ int) int {
+		return max(limit, 1) * childComplexity
+	}
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(limits.MaxComplexity))
+	srv.Use(DepthLimit{Max: limits.MaxDepth})
+	srv.AroundOperations(func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		return next(withLoaders(ctx, userService))
+	})
+	return srv
+}
+
+// authorize lets the caller act on user id if it is them or they are an
+// admin, like the REST API's ownership policy
+func (r *Resolver) authorize(ctx context.Context, id int32) error {
+	if canAccess(ctx, id) {
+		return nil
+	}
+	return r.denied
+}
+
+func canAccess(ctx context.Context, id int32) bool {
+	u, ok := authctx.UserFromContext(ctx)
+	return ok && (u.ID == int64(id) || u.Role == "admin")
+}
+
+// resolveUser maps the external ID of a user to its primary key and
+// authorizes the caller for it
+func (r *Resolver) resolveUser(ctx context.Context, externalID string) (int32, error) {
+	id, err := r.userService.ResolveUserID(ctx, externalID)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.authorize(ctx, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
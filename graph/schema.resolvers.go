@@ -0,0 +1,175 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.70
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/graph/model"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/optional"
+	"idiomatic-go/services"
+	"idiomatic-go/validation"
+)
+
+// UpdateUser is the resolver for the updateUser field.
+func (r *mutationResolver) UpdateUser(ctx context.Context, id string, patch model.UserPatch) (*database.User, error) {
+	userID, err := r.resolveUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(patch); err != nil {
+		return nil, err
+	}
+
+	var userPatch services.UserPatch
+	if patch.Username != nil {
+		userPatch.Username = optional.Some(*patch.Username)
+	}
+	if patch.Email != nil {
+		userPatch.Email = optional.Some(*patch.Email)
+	}
+	if patch.Password != nil {
+		userPatch.Password = optional.Some(*patch.Password)
+	}
+	user, err := r.userService.PatchUser(ctx, userID, userPatch)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PutProfile is the resolver for the putProfile field.
+func (r *mutationResolver) PutProfile(ctx context.Context, userID string, profile model.ProfileInput) (*database.Profile, error) {
+	id, err := r.resolveUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Struct(profile); err != nil {
+		return nil, err
+	}
+
+	saved, _, err := r.userService.PutProfile(ctx, database.UpsertProfileParams{
+		UserID:      id,
+		DisplayName: profile.DisplayName,
+		Bio:         profile.Bio,
+		AvatarUrl:   profile.AvatarURL,
+		Locale:      profile.Locale,
+		Timezone:    profile.Timezone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// CreatedAt is the resolver for the createdAt field.
+func (r *profileResolver) CreatedAt(ctx context.Context, obj *database.Profile) (*jsontime.Time, error) {
+	t := jsontime.FromTimestamptz(obj.CreatedAt)
+	return &t, nil
+}
+
+// UpdatedAt is the resolver for the updatedAt field.
+func (r *profileResolver) UpdatedAt(ctx context.Context, obj *database.Profile) (*jsontime.Time, error) {
+	t := jsontime.FromTimestamptz(obj.UpdatedAt)
+	return &t, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*database.User, error) {
+	user, err := r.userService.GetUser(ctx, int32(authctx.MustUserID(ctx)))
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*database.User, error) {
+	userID, err := r.resolveUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.userService.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, limit int, offset int) ([]*database.User, error) {
+	if !authctx.HasRole(ctx, "admin") {
+		return nil, custom_errors.ErrForbidden
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+	}
+	if offset < 0 || offset > math.MaxInt32 {
+		return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "offset must be a non-negative integer")
+	}
+
+	users, err := r.userService.ListUsers(ctx, nil, int32(limit), int32(offset))
+	if err != nil {
+		return nil, err
+	}
+	page := make([]*database.User, len(users))
+	for i := range users {
+		page[i] = &users[i]
+	}
+	return page, nil
+}
+
+// ID is the resolver for the id field.
+func (r *userResolver) ID(ctx context.Context, obj *database.User) (string, error) {
+	return services.ExternalUserID(*obj), nil
+}
+
+// CreatedAt is the resolver for the createdAt field.
+func (r *userResolver) CreatedAt(ctx context.Context, obj *database.User) (*jsontime.Time, error) {
+	t := jsontime.FromTimestamptz(obj.CreatedAt)
+	return &t, nil
+}
+
+// UpdatedAt is the resolver for the updatedAt field.
+func (r *userResolver) UpdatedAt(ctx context.Context, obj *database.User) (*jsontime.Time, error) {
+	t := jsontime.FromTimestamptz(obj.UpdatedAt)
+	return &t, nil
+}
+
+// Profile is the resolver for the profile field.
+func (r *userResolver) Profile(ctx context.Context, obj *database.User) (*database.Profile, error) {
+	if !canAccess(ctx, obj.ID) {
+		return nil, nil
+	}
+	profile, ok, err := loadersFrom(ctx).profiles.Load(ctx, obj.ID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Profile returns ProfileResolver implementation.
+func (r *Resolver) Profile() ProfileResolver { return &profileResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type profileResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
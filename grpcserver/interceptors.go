@@ -0,0 +1,127 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/middleware"
+	userv1 "idiomatic-go/proto/user/v1"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain names this service in the ErrorInfo detail of every error
+const errorDomain = "idiomatic-go"
+
+// public are the methods callers reach without a token
+var public = map[string]bool{
+	userv1.UserService_CreateUser_FullMethodName: true,
+	userv1.UserService_Login_FullMethodName:      true,
+}
+
+var errInvalidAuthHeader = custom_errors.NewAPIError(http.StatusUnauthorized, custom_errors.CodeInvalidAuthHeader, "Invalid authorization header format")
+
+// authenticate puts the user of the bearer token in the authorization
+// metadata on the context, as AuthMiddleware does for REST
+func authenticate(auth *middleware.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 {
+			return nil, custom_errors.ErrUnauthorized
+		}
+		raw, ok := strings.CutPrefix(values[0], "Bearer ")
+		if !ok || len(values) > 1 {
+			return nil, errInvalidAuthHeader
+		}
+
+		user, err := auth.Authenticate(ctx, raw)
+		if err != nil {
+			return nil, err
+		}
+		return handler(authctx.WithUser(ctx, user), req)
+	}
+}
+
+// reportErrors turns the errors of the inner handlers into gRPC statuses
+// and logs server failures, like the REST error middleware
+func reportErrors(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		apiErr, ok := custom_errors.IsAPIError(err)
+		if !ok {
+			apiErr = custom_errors.ErrInternalServerError
+		}
+		if apiErr.StatusCode >= 500 {
+			logger.ErrorContext(ctx, "grpc call failed", "method", info.FullMethod, "error", err)
+		}
+		return nil, toStatus(apiErr).Err()
+	}
+}
+
+// grpcCodes maps the HTTP status of an APIError to its gRPC code
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:                   codes.InvalidArgument,
+	http.StatusUnauthorized:                 codes.Unauthenticated,
+	http.StatusForbidden:                    codes.PermissionDenied,
+	http.StatusNotFound:                     codes.NotFound,
+	http.StatusConflict:                     codes.Aborted,
+	http.StatusPreconditionFailed:           codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge:        codes.InvalidArgument,
+	http.StatusTooManyRequests:              codes.ResourceExhausted,
+	custom_errors.StatusClientClosedRequest: codes.Canceled,
+	http.StatusInternalServerError:          codes.Internal,
+	http.StatusServiceUnavailable:           codes.Unavailable,
+	http.StatusGatewayTimeout:               codes.DeadlineExceeded,
+}
+
+// toStatus describes apiErr as a gRPC status. The APIError code travels as
+// the ErrorInfo reason, so clients can tell errors apart as they do by the
+// code field of a REST error; field failures and retry hints follow as
+// BadRequest and RetryInfo details.
+func toStatus(apiErr *custom_errors.APIError) *status.Status {
+	code, ok := grpcCodes[apiErr.StatusCode]
+	if !ok {
+		code = codes.Unknown
+	}
+	// A taken username conflicts with no concurrent writer; retrying
+	// cannot help
+	if apiErr.StatusCode == http.StatusConflict && !apiErr.Retryable {
+		code = codes.AlreadyExists
+	}
+
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: string(apiErr.Code), Domain: errorDomain}}
+	if len(apiErr.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(apiErr.Fields))
+		for i, f := range apiErr.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if apiErr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(apiErr.RetryAfter)})
+	}
+
+	st := status.New(code, apiErr.Message)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st
+}
@@ -0,0 +1,180 @@
+// Package grpcserver serves the UserService of proto/user/v1 over gRPC
+// for internal callers. Every call goes through the same
+// services.UserService as REST and GraphQL, so the three share their
+// rules and error codes. Unlike /login, Login here sits behind no rate
+// limiter or tarpit, so the listener must not be reachable from outside.
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"idiomatic-go/authctx"
+	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/middleware"
+	userv1 "idiomatic-go/proto/user/v1"
+	"idiomatic-go/services"
+	"idiomatic-go/validation"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxPageSize bounds ListUsers, as it does GET /users
+const maxPageSize = 100
+
+// Server implements userv1.UserServiceServer on the user service
+type Server struct {
+	userv1.UnimplementedUserServiceServer
+	userService *services.UserService
+	tokens      *middleware.TokenIssuer
+	denied      error // for calls on other users' data
+}
+
+// New returns the gRPC server with the UserService and server reflection
+// registered. Calls other than CreateUser and Login need a bearer token in
+// the authorization metadata, checked by auth. With concealForeign, calls
+// on other users' data fail as not found instead of permission denied, as
+// in the REST API.
+func New(userService *services.UserService, auth *middleware.Authenticator, tokens *middleware.TokenIssuer, logger *slog.Logger, concealForeign bool) *grpc.Server {
+	s := &Server{userService: userService, tokens: tokens, denied: custom_errors.ErrForbidden}
+	if concealForeign {
+		s.denied = custom_errors.ErrNotFound
+	}
+
+	// otelgrpc traces through a stats handler; its interceptors are
+	// deprecated
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(reportErrors(logger), authenticate(auth)),
+	)
+	userv1.RegisterUserServiceServer(srv, s)
+	reflection.Register(srv)
+	return srv
+}
+
+type createUserRequest struct {
+	Username string `json:"username" binding:"required,max=50,username,notreserved"`
+	Email    string `json:"email" binding:"required,email,max=255"`
+	Password string `json:"password" binding:"required,password"`
+}
+
+// CreateUser registers a user, as POST /users does
+func (s *Server) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	in := createUserRequest{Username: req.GetUsername(), Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := validation.Struct(in); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.CreateUser(ctx, db.CreateUserParams{
+		Username:     in.Username,
+		Email:        in.Email,
+		PasswordHash: in.Password, // hashed by the service
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newUser(user), nil
+}
+
+// GetUser returns a user to themselves or an admin
+func (s *Server) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	id, err := s.userService.ResolveUserID(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := authctx.UserFromContext(ctx); !ok || (u.ID != int64(id) && u.Role != "admin") {
+		return nil, s.denied
+	}
+
+	user, err := s.userService.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return newUser(user), nil
+}
+
+// ListUsers returns a page of users to an admin
+func (s *Server) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	if !authctx.HasRole(ctx, "admin") {
+		return nil, custom_errors.ErrForbidden
+	}
+	if req.GetLimit() < 1 || req.GetLimit() > maxPageSize {
+		return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+	}
+	if req.GetOffset() < 0 {
+		return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "offset must be a non-negative integer")
+	}
+
+	users, err := s.userService.ListUsers(ctx, nil, req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+	resp := &userv1.ListUsersResponse{Users: make([]*userv1.User, len(users))}
+	for i, user := range users {
+		resp.Users[i] = newUser(user)
+	}
+	return resp, nil
+}
+
+type loginRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	DeviceID string `json:"device_id" binding:"max=64"`
+}
+
+// Login checks a user's credentials and issues an access and a refresh
+// token, as POST /login does
+func (s *Server) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	in := loginRequest{Email: req.GetEmail(), Password: req.GetPassword(), DeviceID: req.GetDeviceId()}
+	if err := validation.Struct(in); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.Login(ctx, in.Email, in.Password)
+	if err != nil {
+		return nil, err
+	}
+	token, expires, err := s.tokens.Issue(user.ID, user.Role, user.TokenVersion)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(err)
+	}
+	if in.DeviceID == "" {
+		in.DeviceID = uuid.NewString()
+	}
+	refresh, err := s.userService.IssueRefreshToken(ctx, user.ID, in.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userv1.LoginResponse{
+		Token:        token,
+		User:         newUser(user),
+		ExpiresAt:    timestamppb.New(expires),
+		RefreshToken: refresh,
+		DeviceId:     in.DeviceID,
+	}, nil
+}
+
+func newUser(u db.User) *userv1.User {
+	user := &userv1.User{
+		Id:            services.ExternalUserID(u),
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+	}
+	if u.CreatedAt.Valid {
+		user.CreatedAt = timestamppb.New(u.CreatedAt.Time)
+	}
+	if u.UpdatedAt.Valid {
+		user.UpdatedAt = timestamppb.New(u.UpdatedAt.Time)
+	}
+	return user
+}
@@ -0,0 +1,199 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+	db "idiomatic-go/database"
+	"idiomatic-go/middleware"
+	userv1 "idiomatic-go/proto/user/v1"
+	"idiomatic-go/revocation"
+	"idiomatic-go/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// emptyDB answers every query with no rows
+type emptyDB struct{}
+
+func (emptyDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+func (emptyDB) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	return emptyRows{}, nil
+}
+func (emptyDB) QueryRow(context.Context, string, ...interface{}) pgx.Row { return emptyRows{} }
+
+type emptyRows struct{}
+
+func (emptyRows) Close()                                       {}
+func (emptyRows) Err() error                                   { return nil }
+func (emptyRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (emptyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (emptyRows) Next() bool                                   { return false }
+func (emptyRows) Scan(...any) error                            { return pgx.ErrNoRows }
+func (emptyRows) Values() ([]any, error)                       { return nil, nil }
+func (emptyRows) RawValues() [][]byte                          { return nil }
+func (emptyRows) Conn() *pgx.Conn                              { return nil }
+
+// roles resolves token users by ID
+type roles map[int64]string
+
+func (r roles) ResolveUser(_ context.Context, userID int64) (string, int32, error) {
+	return r[userID], 0, nil
+}
+
+// serve starts the server on an in-memory listener and returns a client
+// connection to it, and a token for each of a user and an admin
+func serve(t *testing.T) (conn *grpc.ClientConn, userToken, adminToken string) {
+	t.Helper()
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { rdb.Close() })
+
+	userService := services.NewUserService(&db.DB{Queries: db.New(emptyDB{})}, logger, clk, nil, nil, nil, 0, nil, nil, nil, "", "")
+	auth := middleware.NewAuthenticator(middleware.NewTokenParser("grpc-secret", 0, clk), roles{1: "user", 2: "admin"}, revocation.NewStore(rdb, clk))
+	issuer := middleware.NewTokenIssuer("grpc-secret", false, clk)
+	srv := New(userService, auth, issuer, logger, false)
+
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	userToken, _, err = issuer.Issue(1, "user", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, _, err = issuer.Issue(2, "admin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, userToken, adminToken
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestErrors(t *testing.T) {
+	conn, userToken, adminToken := serve(t)
+	client := userv1.NewUserServiceClient(conn)
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantCode   codes.Code
+		wantReason string
+		wantField  string
+	}{
+		{"no token", func() error {
+			_, err := client.ListUsers(context.Background(), &userv1.ListUsersRequest{Limit: 10})
+			return err
+		}, codes.Unauthenticated, "unauthorized", ""},
+		{"bad token", func() error {
+			_, err := client.ListUsers(withToken("not-a-jwt"), &userv1.ListUsersRequest{Limit: 10})
+			return err
+		}, codes.Unauthenticated, "invalid_token", ""},
+		{"not an admin", func() error {
+			_, err := client.ListUsers(withToken(userToken), &userv1.ListUsersRequest{Limit: 10})
+			return err
+		}, codes.PermissionDenied, "forbidden", ""},
+		{"limit out of range", func() error {
+			_, err := client.ListUsers(withToken(adminToken), &userv1.ListUsersRequest{Limit: 0})
+			return err
+		}, codes.InvalidArgument, "bad_request", ""},
+		{"unknown user", func() error {
+			_, err := client.GetUser(withToken(userToken), &userv1.GetUserRequest{Id: "not-a-uuid"})
+			return err
+		}, codes.NotFound, "not_found", ""},
+		{"invalid new user without a token", func() error {
+			_, err := client.CreateUser(context.Background(), &userv1.CreateUserRequest{Username: "jane", Email: "not-an-email", Password: "password123"})
+			return err
+		}, codes.InvalidArgument, "validation_failed", "email"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(tt.call())
+			if st.Code() != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %s", st.Code(), tt.wantCode, st.Message())
+			}
+			var reason, field string
+			for _, d := range st.Details() {
+				switch d := d.(type) {
+				case *errdetails.ErrorInfo:
+					reason = d.GetReason()
+				case *errdetails.BadRequest:
+					field = d.GetFieldViolations()[0].GetField()
+				}
+			}
+			if reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if field != tt.wantField {
+				t.Errorf("field violation = %q, want %q", field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	conn, _, adminToken := serve(t)
+	resp, err := userv1.NewUserServiceClient(conn).ListUsers(withToken(adminToken), &userv1.ListUsersRequest{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetUsers()) != 0 {
+		t.Errorf("users = %v, want none", resp.GetUsers())
+	}
+}
+
+func TestReflection(t *testing.T) {
+	conn, _, _ := serve(t)
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		if s.GetName() == userv1.UserService_ServiceDesc.ServiceName {
+			return
+		}
+	}
+	t.Errorf("services = %v, want %s listed", resp.GetListServicesResponse().GetService(), userv1.UserService_ServiceDesc.ServiceName)
+}
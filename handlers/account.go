@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	db "idiomatic-go/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minAccountResponseTime is the floor for signup and password-forgot
+// responses, so their latency does not reveal whether an email is registered.
+const minAccountResponseTime = 750 * time.Millisecond
+
+type acceptedResponse struct {
+	Message string `json:"message" example:"If the address can receive email, a message is on its way"`
+}
+
+type signUpRequest struct {
+	Username string `json:"username" binding:"required,max=50,username,notreserved" example:"johndoe"`
+	Email    string `json:"email" binding:"required,email,max=255" example:"john@example.com"`
+	Password string `json:"password" binding:"required,password" example:"password123"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email" example:"john@example.com"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,password" example:"newpassword123"`
+}
+
+// SignUp godoc
+// @Summary Sign up
+// @Description Register a new account. The response is identical whether or not the email is already registered; a verification or notice email is sent either way.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param user body signUpRequest true "Account details"
+// @Success 202 {object} acceptedResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 409 {object} custom_errors.APIError "Username already taken"
+// @Failure 429 {object} custom_errors.APIError "Too many requests"
+// @Router /signup [post]
+func (h *UserHandler) SignUp(c *gin.Context) {
+	deadline := h.clock.Now().Add(minAccountResponseTime)
+	defer h.waitUntil(c.Request.Context(), deadline)
+
+	var req signUpRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	err := h.userService.SignUp(c.Request.Context(), db.CreateUserParams{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: req.Password,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, acceptedResponse{Message: "Check your inbox to finish signing up"})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a password reset link. The response is identical whether or not the email is registered.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param request body forgotPasswordRequest true "Account email"
+// @Success 202 {object} acceptedResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 429 {object} custom_errors.APIError "Too many requests"
+// @Router /password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	deadline := h.clock.Now().Add(minAccountResponseTime)
+	defer h.waitUntil(c.Request.Context(), deadline)
+
+	var req forgotPasswordRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	if err := h.userService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, acceptedResponse{Message: "If an account uses this address, a reset link is on its way"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password using the token from a password reset email
+// @Tags account
+// @Accept json
+// @Param request body resetPasswordRequest true "Reset token and new password"
+// @Success 204 "Password changed"
+// @Failure 400 {object} custom_errors.APIError "Invalid or expired token"
+// @Router /password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	if err := h.userService.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// waitUntil blocks until deadline or until ctx is cancelled. Callers defer
+// it so every exit path is padded; net/http holds small responses until the
+// handler returns.
+func (h *UserHandler) waitUntil(ctx context.Context, deadline time.Time) {
+	d := deadline.Sub(h.clock.Now())
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertHandler struct {
+	service    *services.AlertService
+	strictJSON bool
+}
+
+func NewAlertHandler(service *services.AlertService, strictJSON bool) *AlertHandler {
+	return &AlertHandler{service: service, strictJSON: strictJSON}
+}
+
+type createAlertRuleRequest struct {
+	Name            string `json:"name" binding:"required" example:"Mass deletion"`
+	Action          string `json:"action" binding:"required" example:"user_deleted"`
+	PerActor        *bool  `json:"per_actor" binding:"required" example:"true"`
+	Threshold       int32  `json:"threshold" binding:"required" example:"10"`
+	WindowSeconds   int32  `json:"window_seconds" binding:"required" example:"60"`
+	CooldownSeconds int32  `json:"cooldown_seconds" binding:"required" example:"900"`
+}
+
+type updateAlertRuleRequest struct {
+	createAlertRuleRequest
+	Enabled *bool `json:"enabled" binding:"required" example:"true"`
+}
+
+type AlertRuleResponse struct {
+	ID              int32         `json:"id" example:"1"`
+	Name            string        `json:"name" example:"Mass deletion"`
+	Action          string        `json:"action" example:"user_deleted"`
+	PerActor        bool          `json:"per_actor" example:"true"`
+	Threshold       int32         `json:"threshold" example:"10"`
+	WindowSeconds   int32         `json:"window_seconds" example:"60"`
+	CooldownSeconds int32         `json:"cooldown_seconds" example:"900"`
+	Enabled         bool          `json:"enabled" example:"true"`
+	CreatedAt       jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	UpdatedAt       jsontime.Time `json:"updated_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+type AlertResponse struct {
+	ID          int32         `json:"id" example:"7"`
+	RuleID      int32         `json:"rule_id" example:"1"`
+	ActorID     *int32        `json:"actor_id,omitempty" example:"2"` // set for per-actor rules
+	Count       int32         `json:"count" example:"14"`
+	WindowStart jsontime.Time `json:"window_start" swaggertype:"string" example:"2025-03-23T15:03:05Z"`
+	CreatedAt   jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+func newAlertRuleResponse(r db.AuditAlertRule) AlertRuleResponse {
+	return AlertRuleResponse{
+		ID:              r.ID,
+		Name:            r.Name,
+		Action:          r.Action,
+		PerActor:        r.PerActor,
+		Threshold:       r.Threshold,
+		WindowSeconds:   r.WindowSeconds,
+		CooldownSeconds: r.CooldownSeconds,
+		Enabled:         r.Enabled,
+		CreatedAt:       jsontime.FromTimestamptz(r.CreatedAt),
+		UpdatedAt:       jsontime.FromTimestamptz(r.UpdatedAt),
+	}
+}
+
+func newAlertResponse(a db.AuditAlert) AlertResponse {
+	resp := AlertResponse{
+		ID:          a.ID,
+		RuleID:      a.RuleID,
+		Count:       a.EventCount,
+		WindowStart: jsontime.FromTimestamptz(a.WindowStart),
+		CreatedAt:   jsontime.FromTimestamptz(a.CreatedAt),
+	}
+	if a.ActorID.Valid {
+		resp.ActorID = &a.ActorID.Int32
+	}
+	return resp
+}
+
+func (r createAlertRuleRequest) params() services.AlertRuleParams {
+	return services.AlertRuleParams{
+		Name:      r.Name,
+		Action:    r.Action,
+		PerActor:  *r.PerActor,
+		Threshold: r.Threshold,
+		Window:    time.Duration(r.WindowSeconds) * time.Second,
+		Cooldown:  time.Duration(r.CooldownSeconds) * time.Second,
+	}
+}
+
+// parseAlertRuleID reads the :id path parameter
+func parseAlertRuleID(c *gin.Context) (int32, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil || id <= 0 {
+		return 0, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid alert rule ID")
+	}
+	return int32(id), nil
+}
+
+// CreateAlertRule godoc
+// @Summary Create an audit alert rule
+// @Description Alert when an audit action is recorded at least threshold times within window_seconds, per actor or across all actors. Alerts are sent as audit.alert webhook events and emailed to the configured recipients, then suppressed for cooldown_seconds. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param rule body createAlertRuleRequest true "Rule"
+// @Success 201 {object} AlertRuleResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid rule"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/alert-rules [post]
+func (h *AlertHandler) CreateAlertRule(c *gin.Context) {
+	var req createAlertRuleRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+	rule, err := h.service.CreateRule(c.Request.Context(), req.params())
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newAlertRuleResponse(rule))
+}
+
+// ListAlertRules godoc
+// @Summary List audit alert rules
+// @Tags admin
+// @Produce json
+// @Success 200 {array} AlertRuleResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/alert-rules [get]
+func (h *AlertHandler) ListAlertRules(c *gin.Context) {
+	rules, err := h.service.ListRules(c.Request.Context())
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]AlertRuleResponse, 0, len(rules))
+	for _, r := range rules {
+		resp = append(resp, newAlertRuleResponse(r))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateAlertRule godoc
+// @Summary Update an audit alert rule
+// @Description Replace a rule's settings, or disable it with enabled=false
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Rule ID"
+// @Param rule body updateAlertRuleRequest true "Rule"
+// @Success 200 {object} AlertRuleResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid rule"
+// @Failure 404 {object} custom_errors.APIError "Rule not found"
+// @Router /admin/alert-rules/{id} [put]
+func (h *AlertHandler) UpdateAlertRule(c *gin.Context) {
+	id, err := parseAlertRuleID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	var req updateAlertRuleRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+	params := req.params()
+	params.Enabled = *req.Enabled
+	rule, err := h.service.UpdateRule(c.Request.Context(), id, params)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newAlertRuleResponse(rule))
+}
+
+// DeleteAlertRule godoc
+// @Summary Delete an audit alert rule
+// @Description Delete a rule along with the alerts it fired
+// @Tags admin
+// @Param id path int true "Rule ID"
+// @Success 204
+// @Failure 404 {object} custom_errors.APIError "Rule not found"
+// @Router /admin/alert-rules/{id} [delete]
+func (h *AlertHandler) DeleteAlertRule(c *gin.Context) {
+	id, err := parseAlertRuleID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := h.service.DeleteRule(c.Request.Context(), id); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListAlerts godoc
+// @Summary List fired audit alerts
+// @Description The most recently fired alerts, newest first. Admin only.
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Number of alerts (1-100)" default(20)
+// @Success 200 {array} AlertResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/alerts [get]
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	limit, _, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	alerts, err := h.service.ListAlerts(c.Request.Context(), limit)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]AlertResponse, 0, len(alerts))
+	for _, a := range alerts {
+		resp = append(resp, newAlertResponse(a))
+	}
+	c.JSON(http.StatusOK, resp)
+}
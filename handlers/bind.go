@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/middleware"
+	"idiomatic-go/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// unknownFieldsError reports request body fields that the target struct
+// does not declare, e.g. a client sending "pasword" instead of "password".
+type unknownFieldsError struct {
+	Fields []string
+}
+
+func (e *unknownFieldsError) Error() string {
+	return "unknown fields in request body: " + strings.Join(e.Fields, ", ")
+}
+
+// bindJSON binds the request body into obj. When strict decoding is enabled
+// every top-level field missing from obj is collected and returned as an
+// unknownFieldsError instead of being silently dropped.
+func bindJSON(c *gin.Context, obj interface{}, strict bool) error {
+	if !strict {
+		return c.ShouldBindJSON(obj)
+	}
+
+	if c.Request.Body == nil {
+		return errors.New("request body is empty")
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+	if unknown := unknownFields(raw, obj); len(unknown) > 0 {
+		return &unknownFieldsError{Fields: unknown}
+	}
+
+	return binding.JSON.BindBody(body, obj)
+}
+
+// unknownFields returns the sorted keys of raw that have no matching json tag
+// on the struct pointed to by obj.
+func unknownFields(raw map[string]json.RawMessage, obj interface{}) []string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	known := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		known[strings.ToLower(name)] = struct{}{}
+	}
+
+	var unknown []string
+	for key := range raw {
+		if _, ok := known[strings.ToLower(key)]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// renderBindError writes the 400 response for a failed bindJSON call, with
+// per-field details where the failure can be attributed to fields.
+func renderBindError(c *gin.Context, err error) {
+	var ufe *unknownFieldsError
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxErr):
+		renderError(c, custom_errors.ErrPayloadTooLarge.Wrap(err))
+	case errors.As(err, &ufe):
+		fields := make([]custom_errors.FieldError, len(ufe.Fields))
+		for i, name := range ufe.Fields {
+			fields[i] = custom_errors.FieldError{Field: name, Message: "is not accepted by this endpoint"}
+		}
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeUnknownFields, "Unknown fields in request body").WithFields(fields).Wrap(err))
+	case errors.As(err, &verrs):
+		renderError(c, validation.Error(err))
+	case errors.As(err, &typeErr):
+		fields := []custom_errors.FieldError{{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}}
+		renderError(c, custom_errors.ErrValidation.WithFields(fields).Wrap(err))
+	default:
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Request body is not valid JSON").Wrap(err))
+	}
+}
+
+// renderError records err and writes it as the JSON error envelope. The
+// returned gin.Error can carry extra log fields via SetMeta.
+func renderError(c *gin.Context, err error) *gin.Error {
+	return middleware.RenderError(c, err)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"idiomatic-go/debugmode"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/features"
+	"idiomatic-go/flags"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDebugTTL = time.Hour
+
+type DebugHandler struct {
+	controller *debugmode.Controller
+	toggles    *features.Signer
+	flags      *flags.Store
+	logger     *slog.Logger
+	strictJSON bool
+}
+
+func NewDebugHandler(controller *debugmode.Controller, toggles *features.Signer, flagStore *flags.Store, logger *slog.Logger, strictJSON bool) *DebugHandler {
+	return &DebugHandler{
+		controller: controller,
+		toggles:    toggles,
+		flags:      flagStore,
+		logger:     logger,
+		strictJSON: strictJSON,
+	}
+}
+
+type debugToggleRequest struct {
+	TTLSeconds int `json:"ttl_seconds" binding:"required,min=1" example:"900"`
+}
+
+type debugToggleResponse struct {
+	UserID    int64         `json:"user_id,omitempty" example:"1"`
+	Token     string        `json:"token,omitempty"`
+	ExpiresAt jsontime.Time `json:"expires_at" swaggertype:"string"`
+}
+
+func (h *DebugHandler) bindDebugTTL(c *gin.Context) (time.Duration, bool) {
+	var req debugToggleRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return 0, false
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl > maxDebugTTL {
+		ttl = maxDebugTTL
+	}
+	return ttl, true
+}
+
+// ListTracing godoc
+// @Summary List live debugging sessions
+// @Description List users currently under forced tracing and debug logging
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /debug/tracing [get]
+func (h *DebugHandler) ListTracing(c *gin.Context) {
+	users := make(map[int64]jsontime.Time)
+	for id, expires := range h.controller.Users() {
+		users[id] = jsontime.New(expires)
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// EnableUserTracing godoc
+// @Summary Enable live debugging for a user
+// @Description Force trace sampling and debug logging for a user's requests until the TTL expires (max 1h)
+// @Tags debug
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID" format(uuid)
+// @Param ttl body debugToggleRequest true "Duration"
+// @Success 200 {object} debugToggleResponse
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /debug/tracing/users/{id} [post]
+func (h *DebugHandler) EnableUserTracing(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	userID := int64(id)
+	ttl, ok := h.bindDebugTTL(c)
+	if !ok {
+		return
+	}
+
+	expires, err := h.controller.EnableUser(c.Request.Context(), userID, ttl)
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	h.logger.InfoContext(c.Request.Context(), "live debugging enabled for user",
+		"user_id", userID,
+		"expires_at", expires,
+	)
+	c.JSON(http.StatusOK, debugToggleResponse{UserID: userID, ExpiresAt: jsontime.New(expires)})
+}
+
+// DisableUserTracing godoc
+// @Summary Disable live debugging for a user
+// @Tags debug
+// @Param id path string true "User ID" format(uuid)
+// @Success 204
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /debug/tracing/users/{id} [delete]
+func (h *DebugHandler) DisableUserTracing(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	userID := int64(id)
+	if err := h.controller.DisableUser(c.Request.Context(), userID); err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	h.logger.InfoContext(c.Request.Context(), "live debugging disabled for user", "user_id", userID)
+	c.Status(http.StatusNoContent)
+}
+
+// IssueDebugToken godoc
+// @Summary Issue a debug token
+// @Description Issue a signed X-Debug-Token that forces tracing and debug logging for any request carrying it
+// @Tags debug
+// @Accept json
+// @Produce json
+// @Param ttl body debugToggleRequest true "Duration"
+// @Success 200 {object} debugToggleResponse
+// @Failure 404 {object} custom_errors.APIError "Debug tokens are not configured"
+// @Router /debug/tracing/tokens [post]
+func (h *DebugHandler) IssueDebugToken(c *gin.Context) {
+	if !h.controller.TokensEnabled() {
+		renderError(c, custom_errors.ErrNotFound)
+		return
+	}
+	ttl, ok := h.bindDebugTTL(c)
+	if !ok {
+		return
+	}
+
+	token, expires := h.controller.IssueToken(ttl)
+	h.logger.InfoContext(c.Request.Context(), "debug token issued", "expires_at", expires)
+	c.JSON(http.StatusOK, debugToggleResponse{Token: token, ExpiresAt: jsontime.New(expires)})
+}
+
+type featureTokenRequest struct {
+	Features   []string `json:"features" binding:"required,min=1,dive,required,excludesall=.0x2C" example:"cursor_pagination"`
+	TTLSeconds int      `json:"ttl_seconds" binding:"required,min=1" example:"900"`
+}
+
+type featureTokenResponse struct {
+	Token     string        `json:"token"`
+	Features  []string      `json:"features"`
+	ExpiresAt jsontime.Time `json:"expires_at" swaggertype:"string"`
+}
+
+// IssueFeatureToken godoc
+// @Summary Issue a feature toggle token
+// @Description Issue a signed X-Feature-Toggles token that opts any request carrying it into the named experimental features
+// @Tags debug
+// @Accept json
+// @Produce json
+// @Param request body featureTokenRequest true "Features and duration"
+// @Success 200 {object} featureTokenResponse
+// @Failure 404 {object} custom_errors.APIError "Feature toggle tokens are not configured"
+// @Router /debug/features/tokens [post]
+func (h *DebugHandler) IssueFeatureToken(c *gin.Context) {
+	if !h.toggles.TokensEnabled() {
+		renderError(c, custom_errors.ErrNotFound)
+		return
+	}
+	var req featureTokenRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl > maxDebugTTL {
+		ttl = maxDebugTTL
+	}
+
+	token, expires := h.toggles.Issue(req.Features, ttl)
+	h.logger.InfoContext(c.Request.Context(), "feature toggle token issued", "features", req.Features, "expires_at", expires)
+	c.JSON(http.StatusOK, featureTokenResponse{Token: token, Features: req.Features, ExpiresAt: jsontime.New(expires)})
+}
+
+type setFlagRequest struct {
+	Value string `json:"value" binding:"required" example:"true"`
+}
+
+// ListFlags godoc
+// @Summary List runtime flags
+// @Description List runtime toggles shared by all replicas (log level, maintenance mode, feature flags)
+// @Tags debug
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /debug/flags [get]
+func (h *DebugHandler) ListFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"flags": h.flags.All()})
+}
+
+// SetFlag godoc
+// @Summary Set a runtime flag
+// @Description Set a runtime toggle; all replicas apply it within seconds
+// @Tags debug
+// @Accept json
+// @Param name path string true "Flag name"
+// @Param flag body setFlagRequest true "Flag value"
+// @Success 204
+// @Router /debug/flags/{name} [put]
+func (h *DebugHandler) SetFlag(c *gin.Context) {
+	var req setFlagRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	name := c.Param("name")
+	if name == flags.LogLevel {
+		if _, err := logging.ParseLevel(req.Value); err != nil {
+			renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid log level"))
+			return
+		}
+	}
+
+	if err := h.flags.Set(c.Request.Context(), name, req.Value); err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	h.logger.InfoContext(c.Request.Context(), "runtime flag set", "flag", name, "value", req.Value)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteFlag godoc
+// @Summary Delete a runtime flag
+// @Tags debug
+// @Param name path string true "Flag name"
+// @Success 204
+// @Router /debug/flags/{name} [delete]
+func (h *DebugHandler) DeleteFlag(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.flags.Delete(c.Request.Context(), name); err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	h.logger.InfoContext(c.Request.Context(), "runtime flag deleted", "flag", name)
+	c.Status(http.StatusNoContent)
+}
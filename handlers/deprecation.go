@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"idiomatic-go/deprecation"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeprecationHandler struct {
+	tracker *deprecation.Tracker
+}
+
+func NewDeprecationHandler(tracker *deprecation.Tracker) *DeprecationHandler {
+	return &DeprecationHandler{tracker: tracker}
+}
+
+type DeprecatedRouteResponse struct {
+	Route       string                `json:"route" example:"PUT /api/v1/users/:id"`
+	Since       jsontime.Time         `json:"since" swaggertype:"string" example:"2025-03-01T00:00:00Z"`
+	Sunset      *jsontime.Time        `json:"sunset,omitempty" swaggertype:"string" example:"2025-09-01T00:00:00Z"`
+	Replacement string                `json:"replacement,omitempty" example:"https://api.example.com/docs/migrations/patch-users"`
+	Requests    int64                 `json:"requests" example:"42"`
+	Callers     []DeprecatedCallerUse `json:"callers"`
+}
+
+type DeprecatedCallerUse struct {
+	Caller   string `json:"caller" example:"user:17"`
+	Requests int64  `json:"requests" example:"40"`
+}
+
+// ListDeprecations godoc
+// @Summary Report usage of deprecated routes
+// @Description Deprecated routes called within the retention period, with their sunset dates and request counts per caller (user or IP), most used first. Routes with zero requests are no longer called. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} DeprecatedRouteResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/deprecations [get]
+func (h *DeprecationHandler) ListDeprecations(c *gin.Context) {
+	report, err := h.tracker.Report(c.Request.Context())
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("deprecation report: %w", err)))
+		return
+	}
+
+	resp := make([]DeprecatedRouteResponse, 0, len(report))
+	for _, u := range report {
+		route := DeprecatedRouteResponse{
+			Route:       u.Route,
+			Since:       jsontime.New(u.Notice.Since),
+			Replacement: u.Notice.Replacement,
+			Requests:    u.Requests,
+			Callers:     make([]DeprecatedCallerUse, 0, len(u.Callers)),
+		}
+		if !u.Notice.Sunset.IsZero() {
+			sunset := jsontime.New(u.Notice.Sunset)
+			route.Sunset = &sunset
+		}
+		for _, caller := range u.Callers {
+			route.Callers = append(route.Callers, DeprecatedCallerUse{Caller: caller.Caller, Requests: caller.Requests})
+		}
+		resp = append(resp, route)
+	}
+	c.JSON(http.StatusOK, resp)
+}
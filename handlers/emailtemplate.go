@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EmailTemplateHandler serves the admin endpoints customizing the emails
+// the service sends
+type EmailTemplateHandler struct {
+	service    *services.EmailTemplateService
+	strictJSON bool
+}
+
+func NewEmailTemplateHandler(service *services.EmailTemplateService, strictJSON bool) *EmailTemplateHandler {
+	return &EmailTemplateHandler{service: service, strictJSON: strictJSON}
+}
+
+type emailTemplateRequest struct {
+	Subject string `json:"subject" binding:"required" example:"Verify your email address"`
+	Body    string `json:"body" binding:"required" example:"Hi {{.Username}},\n\nConfirm your address: {{.Link}}\n"`
+}
+
+// previewEmailTemplateRequest renders the template in use when both
+// fields are empty
+type previewEmailTemplateRequest struct {
+	Subject string `json:"subject" example:"Verify your email address"`
+	Body    string `json:"body" example:"Hi {{.Username}},\n\nConfirm your address: {{.Link}}\n"`
+}
+
+type EmailTemplateResponse struct {
+	Name      string         `json:"name" example:"verify_email"`
+	Version   int32          `json:"version" example:"3"`     // 0 for the embedded default
+	Default   bool           `json:"default" example:"false"` // whether the embedded default is in use
+	Subject   string         `json:"subject" example:"Verify your email address"`
+	Body      string         `json:"body" example:"Hi {{.Username}},\n\nConfirm your address: {{.Link}}\n"`
+	CreatedBy *int32         `json:"created_by,omitempty" example:"1"` // absent for the default or a deleted admin
+	CreatedAt *jsontime.Time `json:"created_at,omitempty" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+type EmailPreviewResponse struct {
+	Subject string `json:"subject" example:"Verify your email address"`
+	Body    string `json:"body" example:"Hi johndoe,\n\nConfirm your address: https://example.com/api/v1/verify?token=sample\n"`
+}
+
+func newEmailTemplateResponse(t services.EmailTemplate) EmailTemplateResponse {
+	resp := EmailTemplateResponse{
+		Name:    t.Name,
+		Version: t.Version,
+		Default: t.Default(),
+		Subject: t.Subject,
+		Body:    t.Body,
+	}
+	if t.Stored.CreatedBy.Valid {
+		resp.CreatedBy = &t.Stored.CreatedBy.Int32
+	}
+	if t.Stored.CreatedAt.Valid {
+		createdAt := jsontime.FromTimestamptz(t.Stored.CreatedAt)
+		resp.CreatedAt = &createdAt
+	}
+	return resp
+}
+
+// ListEmailTemplates godoc
+// @Summary List email templates
+// @Description The template in use for every email the service sends: the latest saved version, or the embedded default. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} EmailTemplateResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/email-templates [get]
+func (h *EmailTemplateHandler) ListEmailTemplates(c *gin.Context) {
+	templates, err := h.service.List(c.Request.Context())
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]EmailTemplateResponse, len(templates))
+	for i, t := range templates {
+		resp[i] = newEmailTemplateResponse(t)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetEmailTemplate godoc
+// @Summary Get an email template
+// @Description The template in use for an email. Admin only.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Template name" Enums(verify_email, password_reset, password_force_reset, account_exists, audit_alert)
+// @Success 200 {object} EmailTemplateResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template"
+// @Router /admin/email-templates/{name} [get]
+func (h *EmailTemplateHandler) GetEmailTemplate(c *gin.Context) {
+	t, err := h.service.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newEmailTemplateResponse(t))
+}
+
+// ListEmailTemplateVersions godoc
+// @Summary List the versions of an email template
+// @Description Every saved version of a template, newest first. Empty while the embedded default is in use. Admin only.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {array} EmailTemplateResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template"
+// @Router /admin/email-templates/{name}/versions [get]
+func (h *EmailTemplateHandler) ListEmailTemplateVersions(c *gin.Context) {
+	versions, err := h.service.Versions(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]EmailTemplateResponse, len(versions))
+	for i, t := range versions {
+		resp[i] = newEmailTemplateResponse(t)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SaveEmailTemplate godoc
+// @Summary Save an email template
+// @Description Save a new version of a template, used for every email sent from then on. Subject and body are Go text/template source and may only refer to the fields the email has, which the preview endpoint shows. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param template body emailTemplateRequest true "Template"
+// @Success 201 {object} EmailTemplateResponse
+// @Failure 400 {object} custom_errors.APIError "Template does not parse or render"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template"
+// @Failure 409 {object} custom_errors.APIError "Saved concurrently by another admin"
+// @Router /admin/email-templates/{name} [put]
+func (h *EmailTemplateHandler) SaveEmailTemplate(c *gin.Context) {
+	var req emailTemplateRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+	actorID := authctx.MustUserID(c.Request.Context())
+	t, err := h.service.Save(c.Request.Context(), int32(actorID), c.Param("name"), emails.Template{Subject: req.Subject, Body: req.Body})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newEmailTemplateResponse(t))
+}
+
+// RestoreEmailTemplate godoc
+// @Summary Restore an email template version
+// @Description Save a copy of an earlier version as the newest one. Admin only.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Template name"
+// @Param version path int true "Version to restore"
+// @Success 201 {object} EmailTemplateResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template or version"
+// @Router /admin/email-templates/{name}/versions/{version}/restore [post]
+func (h *EmailTemplateHandler) RestoreEmailTemplate(c *gin.Context) {
+	version, err := strconv.ParseInt(c.Param("version"), 10, 32)
+	if err != nil || version <= 0 {
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid template version"))
+		return
+	}
+	actorID := authctx.MustUserID(c.Request.Context())
+	t, err := h.service.Restore(c.Request.Context(), int32(actorID), c.Param("name"), int32(version))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newEmailTemplateResponse(t))
+}
+
+// ResetEmailTemplate godoc
+// @Summary Reset an email template to its default
+// @Description Delete every saved version of a template, so the default embedded in the binary is used again. Admin only.
+// @Tags admin
+// @Param name path string true "Template name"
+// @Success 204
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template"
+// @Router /admin/email-templates/{name} [delete]
+func (h *EmailTemplateHandler) ResetEmailTemplate(c *gin.Context) {
+	actorID := authctx.MustUserID(c.Request.Context())
+	if err := h.service.Reset(c.Request.Context(), int32(actorID), c.Param("name")); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PreviewEmailTemplate godoc
+// @Summary Preview an email template
+// @Description Render a draft template, or the one in use when subject and body are empty, with sample data. Nothing is saved or sent. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param template body previewEmailTemplateRequest false "Draft template"
+// @Success 200 {object} EmailPreviewResponse
+// @Failure 400 {object} custom_errors.APIError "Template does not parse or render"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown template"
+// @Router /admin/email-templates/{name}/preview [post]
+func (h *EmailTemplateHandler) PreviewEmailTemplate(c *gin.Context) {
+	var req previewEmailTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &req, h.strictJSON); err != nil {
+			renderBindError(c, err)
+			return
+		}
+	}
+	var draft *emails.Template
+	if req.Subject != "" || req.Body != "" {
+		draft = &emails.Template{Subject: req.Subject, Body: req.Body}
+	}
+	subject, body, err := h.service.Preview(c.Request.Context(), c.Param("name"), draft)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, EmailPreviewResponse{Subject: subject, Body: body})
+}
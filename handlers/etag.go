@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	db "idiomatic-go/database"
+)
+
+// userETag identifies the stored version of u. updated_at changes with
+// every write, so it is all the tag needs.
+func userETag(u db.User) string {
+	return `"` + strconv.FormatInt(u.UpdatedAt.Time.UnixMicro(), 36) + `"`
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header value
+// list names etag or is "*". If-None-Match compares weakly, ignoring a W/
+// prefix; If-Match compares strongly, so weak tags never match (RFC 9110).
+func etagMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"idiomatic-go/authctx"
+	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+type AuditLogResponse struct {
+	ID        int64           `json:"id" example:"1"`
+	UserID    int64           `json:"user_id" example:"1"`
+	Action    string          `json:"action" example:"user_created"`
+	ActorID   *int64          `json:"actor_id,omitempty" example:"2"`  // who acted; absent for background jobs
+	TargetID  *int64          `json:"target_id,omitempty" example:"3"` // the other account involved, e.g. in a merge
+	IPAddress string          `json:"ip_address,omitempty" example:"203.0.113.7"`
+	UserAgent string          `json:"user_agent,omitempty" example:"Mozilla/5.0"`
+	RequestID string          `json:"request_id,omitempty" example:"5b1f0c1e-8a4e-4c7a-9d1b-2f3e4a5b6c7d"`
+	Changes   json.RawMessage `json:"changes,omitempty" swaggertype:"object"` // field name to {"from", "to"}
+	CreatedAt jsontime.Time   `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+func newAuditLogResponse(l db.AuditLog) AuditLogResponse {
+	resp := AuditLogResponse{
+		ID:        int64(l.ID),
+		UserID:    int64(l.UserID),
+		Action:    l.Action,
+		IPAddress: l.IpAddress.String,
+		UserAgent: l.UserAgent.String,
+		RequestID: l.RequestID.String,
+		Changes:   l.Changes,
+		CreatedAt: jsontime.FromTimestamptz(l.CreatedAt),
+	}
+	if l.ActorID.Valid {
+		id := int64(l.ActorID.Int32)
+		resp.ActorID = &id
+	}
+	if l.TargetID.Valid {
+		id := int64(l.TargetID.Int32)
+		resp.TargetID = &id
+	}
+	return resp
+}
+
+// userExpansion loads one related collection for ?expand=. limit caps the
+// number of rows any single expansion may load.
+type userExpansion struct {
+	limit int32
+	load  func(ctx context.Context, h *UserHandler, userID int32, limit int32) (interface{}, error)
+}
+
+var userExpansions = map[string]userExpansion{
+	"audit_logs": {
+		limit: 50,
+		load: func(ctx context.Context, h *UserHandler, userID int32, limit int32) (interface{}, error) {
+			logs, err := h.userService.ListUserAuditLogs(ctx, userID, limit)
+			if err != nil {
+				return nil, err
+			}
+			resp := make([]AuditLogResponse, 0, len(logs))
+			for _, l := range logs {
+				resp = append(resp, newAuditLogResponse(l))
+			}
+			return resp, nil
+		},
+	},
+}
+
+// parseExpand reads ?expand=. Expansions are restricted to admins.
+func parseExpand(c *gin.Context) ([]string, error) {
+	raw := c.Query("expand")
+	if raw == "" {
+		return nil, nil
+	}
+	if !authctx.HasRole(c.Request.Context(), "admin") {
+		return nil, custom_errors.NewAPIError(http.StatusForbidden, custom_errors.CodeForbidden, "expand is restricted to admins")
+	}
+
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := userExpansions[name]; !ok {
+			allowed := make([]string, 0, len(userExpansions))
+			for n := range userExpansions {
+				allowed = append(allowed, n)
+			}
+			sort.Strings(allowed)
+			return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest,
+				"Unknown expansion "+name+"; allowed expansions are "+strings.Join(allowed, ","))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// expandUser loads the requested expansions concurrently
+func (h *UserHandler) expandUser(ctx context.Context, userID int32, names []string) (map[string]interface{}, error) {
+	results := make([]interface{}, len(names))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, name := range names {
+		i, exp := i, userExpansions[name]
+		g.Go(func() error {
+			related, err := exp.load(gctx, h, userID, exp.limit)
+			results[i] = related
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	expanded := make(map[string]interface{}, len(names))
+	for i, name := range names {
+		expanded[name] = results[i]
+	}
+	return expanded, nil
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseFieldset reads the ?fields= sparse fieldset parameter. It returns nil
+// when the parameter is absent and rejects names outside allowed.
+func parseFieldset(c *gin.Context, allowed []string) ([]string, error) {
+	raw, ok := c.GetQuery("fields")
+	if !ok {
+		return nil, nil
+	}
+
+	valid := make(map[string]struct{}, len(allowed))
+	for _, field := range allowed {
+		valid[field] = struct{}{}
+	}
+
+	var fields []string
+	seen := make(map[string]struct{})
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if _, ok := valid[field]; !ok {
+			return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest,
+				"Unknown field "+field+"; allowed fields are "+strings.Join(allowed, ","))
+		}
+		if _, dup := seen[field]; !dup {
+			seen[field] = struct{}{}
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "fields must not be empty")
+	}
+	return fields, nil
+}
+
+// projectFields returns the JSON representation of v restricted to fields.
+// A nil fields slice returns v unchanged.
+func projectFields(v interface{}, fields []string) (interface{}, error) {
+	if fields == nil {
+		return v, nil
+	}
+
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &all); err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected, nil
+}
+
+// mergeFields adds extra top-level keys to the JSON representation of v
+func mergeFields(v interface{}, extra map[string]interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var merged map[string]interface{}
+	if err := json.Unmarshal(encoded, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		merged[key] = value
+	}
+	return merged, nil
+}
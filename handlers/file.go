@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"idiomatic-go/authctx"
+	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxFileNameLen matches files.name
+const maxFileNameLen = 255
+
+// FileHandler serves the /files endpoints
+type FileHandler struct {
+	files         *services.FileService
+	logger        *slog.Logger
+	uploadTimeout time.Duration
+}
+
+// NewFileHandler returns a FileHandler. Uploads may take uploadTimeout to
+// arrive, instead of the server's read timeout.
+func NewFileHandler(files *services.FileService, logger *slog.Logger, uploadTimeout time.Duration) *FileHandler {
+	return &FileHandler{files: files, logger: logger, uploadTimeout: uploadTimeout}
+}
+
+type FileResponse struct {
+	ID          string        `json:"id" example:"3f2b8c1e-7d4a-4b6e-9c01-5a2d3e4f6b7c"`
+	Name        string        `json:"name" example:"report.pdf"`
+	ContentType string        `json:"content_type" example:"application/pdf"`
+	Size        int64         `json:"size" example:"48213"`
+	SHA256      string        `json:"sha256" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Status      string        `json:"status" example:"available" enums:"pending_scan,available,rejected,scan_failed"`
+	CreatedAt   jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+type ListFilesResponse struct {
+	Files  []FileResponse `json:"files"`
+	Limit  int32          `json:"limit" example:"20"`
+	Offset int32          `json:"offset" example:"0"`
+}
+
+type DownloadURLResponse struct {
+	URL       string        `json:"url" example:"https://api.example.com/api/v1/files/3f2b8c1e-7d4a-4b6e-9c01-5a2d3e4f6b7c/content?expires=1742742245&kid=k1&sig=..."`
+	ExpiresAt jsontime.Time `json:"expires_at" swaggertype:"string" example:"2025-03-23T15:19:05Z"`
+}
+
+func newFileResponse(f db.File) FileResponse {
+	return FileResponse{
+		ID:          services.FileID(f),
+		Name:        f.Name,
+		ContentType: f.ContentType,
+		Size:        f.Size,
+		SHA256:      f.Sha256,
+		Status:      f.Status,
+		CreatedAt:   jsontime.FromTimestamptz(f.CreatedAt),
+	}
+}
+
+// UploadFile godoc
+// @Summary Upload a file
+// @Description Stream a file as the "file" part of a multipart/form-data body. When virus scanning is enabled the file is pending_scan until the scan finds it clean, and cannot be downloaded before.
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "File content"
+// @Success 201 {object} FileResponse
+// @Failure 400 {object} custom_errors.APIError "Not a multipart body or no file part"
+// @Failure 413 {object} custom_errors.APIError "File too large"
+// @Router /files [post]
+func (h *FileHandler) UploadFile(c *gin.Context) {
+	// Large uploads outlast the server's read timeout. Middleware that
+	// wraps the writer may hide the connection; the timeout then stays.
+	if err := http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(h.uploadTimeout)); err != nil {
+		h.logger.DebugContext(c.Request.Context(), "cannot extend upload read deadline", "error", err)
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Request body must be multipart/form-data").Wrap(err))
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Request body has no file part"))
+			return
+		}
+		if err != nil {
+			renderMultipartError(c, err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		name := cleanFileName(part.FileName())
+		if name == "" {
+			renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "The file part needs a filename"))
+			return
+		}
+		ownerID := authctx.MustUserID(c.Request.Context())
+		file, err := h.files.Upload(c.Request.Context(), int32(ownerID), name, part)
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, newFileResponse(file))
+		return
+	}
+}
+
+// renderMultipartError rejects a multipart body that could not be read
+func renderMultipartError(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		renderError(c, custom_errors.ErrPayloadTooLarge.Wrap(err))
+		return
+	}
+	renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Malformed multipart body").Wrap(err))
+}
+
+// cleanFileName keeps the last element of a client-supplied file name,
+// without control characters and at most maxFileNameLen characters long
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(strings.ToValidUTF8(name, ""), `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	if runes := []rune(name); len(runes) > maxFileNameLen {
+		name = string(runes[:maxFileNameLen])
+	}
+	return strings.TrimSpace(name)
+}
+
+// ListFiles godoc
+// @Summary List your files
+// @Description The caller's uploaded files, newest first
+// @Tags files
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param offset query int false "Number of files to skip" default(0)
+// @Success 200 {object} ListFilesResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid pagination parameters"
+// @Router /files [get]
+func (h *FileHandler) ListFiles(c *gin.Context) {
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	ownerID := authctx.MustUserID(c.Request.Context())
+	files, err := h.files.ListFiles(c.Request.Context(), int32(ownerID), limit, offset)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := ListFilesResponse{Files: make([]FileResponse, 0, len(files)), Limit: limit, Offset: offset}
+	for _, f := range files {
+		resp.Files = append(resp.Files, newFileResponse(f))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ownedFile returns the file addressed by the :id path parameter if the
+// caller owns it or is an admin. Other users' files are not found.
+func (h *FileHandler) ownedFile(c *gin.Context) (db.File, error) {
+	ctx := c.Request.Context()
+	file, err := h.files.GetFile(ctx, c.Param("id"))
+	if err != nil {
+		return db.File{}, err
+	}
+	if int64(file.OwnerID) != authctx.MustUserID(ctx) && !authctx.HasRole(ctx, "admin") {
+		return db.File{}, custom_errors.ErrNotFound
+	}
+	return file, nil
+}
+
+// GetFile godoc
+// @Summary Get a file's metadata
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Success 200 {object} FileResponse
+// @Failure 404 {object} custom_errors.APIError "No such file of the caller's"
+// @Router /files/{id} [get]
+func (h *FileHandler) GetFile(c *gin.Context) {
+	file, err := h.ownedFile(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newFileResponse(file))
+}
+
+// GetDownloadURL godoc
+// @Summary Get a download link for a file
+// @Description A pre-signed link to the file's content. It needs no authentication and works for anyone holding it until it expires.
+// @Tags files
+// @Produce json
+// @Param id path string true "File ID"
+// @Success 200 {object} DownloadURLResponse
+// @Failure 404 {object} custom_errors.APIError "No such file of the caller's"
+// @Failure 409 {object} custom_errors.APIError "File is still being scanned or was rejected"
+// @Router /files/{id}/download [get]
+func (h *FileHandler) GetDownloadURL(c *gin.Context) {
+	file, err := h.ownedFile(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	link, expires, err := h.files.DownloadURL(file)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, DownloadURLResponse{URL: link, ExpiresAt: jsontime.New(expires)})
+}
+
+// DownloadFile godoc
+// @Summary Download a file
+// @Description The file's content as an attachment. Reached through a link from GET /files/{id}/download.
+// @Tags files
+// @Produce octet-stream
+// @Param id path string true "File ID"
+// @Success 200 {file} file
+// @Failure 403 {object} custom_errors.APIError "Invalid link signature"
+// @Failure 410 {object} custom_errors.APIError "Link has expired"
+// @Router /files/{id}/content [get]
+func (h *FileHandler) DownloadFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	file, err := h.files.GetFile(ctx, c.Param("id"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	content, err := h.files.OpenContent(ctx, file)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", file.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	// Once the first byte is out the status is committed, so a failure
+	// can only cut the download short
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		h.logger.WarnContext(ctx, "file download interrupted", "file_id", services.FileID(file), "error", err)
+	}
+}
+
+// DeleteFile godoc
+// @Summary Delete a file
+// @Tags files
+// @Param id path string true "File ID"
+// @Success 204
+// @Failure 404 {object} custom_errors.APIError "No such file of the caller's"
+// @Router /files/{id} [delete]
+func (h *FileHandler) DeleteFile(c *gin.Context) {
+	file, err := h.ownedFile(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := h.files.DeleteFile(c.Request.Context(), file); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"idiomatic-go/correlation"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/gin-gonic/gin"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// GraphQLHandler serves the GraphQL endpoint. Resolver errors are reported
+// with the same codes, messages and field details as REST errors.
+type GraphQLHandler struct {
+	server *handler.Server
+	logger *slog.Logger
+}
+
+func NewGraphQLHandler(server *handler.Server, logger *slog.Logger) *GraphQLHandler {
+	h := &GraphQLHandler{server: server, logger: logger}
+	server.SetErrorPresenter(h.presentError)
+	server.SetRecoverFunc(h.recoverPanic)
+	return h
+}
+
+// Serve godoc
+// @Summary GraphQL endpoint
+// @Description Run a GraphQL query or mutation on users and profiles; see graph/schema.graphqls for the schema. Operations nesting too deeply or resolving too many fields are rejected. Errors carry the REST error code in extensions.code.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Success 200 {object} object "GraphQL response with data and errors, including operations over the depth or complexity limit"
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Failure 422 {object} object "Operation failed to parse or validate against the schema"
+// @Router /graphql [post]
+func (h *GraphQLHandler) Serve(c *gin.Context) {
+	h.server.ServeHTTP(c.Writer, c.Request)
+}
+
+// presentError turns an error returned by a resolver into a GraphQL error
+// carrying the APIError code. Errors that are not APIErrors, such as
+// parse and validation errors, are reported as gqlgen reports them.
+func (h *GraphQLHandler) presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	var apiErr *custom_errors.APIError
+	if !errors.As(err, &apiErr) {
+		if gqlErr.Err == nil {
+			return gqlErr // the operation itself was invalid
+		}
+		apiErr = custom_errors.ErrInternalServerError.Wrap(err)
+	}
+	if apiErr.StatusCode >= 500 {
+		h.logger.ErrorContext(ctx, "graphql resolver failed", "error", err, "path", gqlErr.Path.String())
+	}
+
+	gqlErr.Message = apiErr.Message
+	gqlErr.Extensions = map[string]any{
+		"code":      apiErr.Code,
+		"retryable": apiErr.Retryable,
+	}
+	if len(apiErr.Fields) > 0 {
+		gqlErr.Extensions["fields"] = apiErr.Fields
+	}
+	if id := correlation.RequestID(ctx); id != "" {
+		gqlErr.Extensions["request_id"] = id
+	}
+	return gqlErr
+}
+
+// recoverPanic reports a panicking resolver as an internal error, logged
+// with its stack by presentError; the rest of the operation still resolves
+func (h *GraphQLHandler) recoverPanic(_ context.Context, p any) error {
+	return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("panic: %v\n%s", p, debug.Stack()))
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/keyspace"
+	"idiomatic-go/middleware"
+	"idiomatic-go/revocation"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IncidentHandler serves the admin endpoints for inspecting and clearing
+// the Redis keyspace and for signing everyone out during an incident
+type IncidentHandler struct {
+	reaper      *keyspace.Reaper
+	userService *services.UserService
+	revoked     *revocation.Store
+	logger      *slog.Logger
+	strictJSON  bool
+}
+
+func NewIncidentHandler(reaper *keyspace.Reaper, userService *services.UserService, revoked *revocation.Store, logger *slog.Logger, strictJSON bool) *IncidentHandler {
+	return &IncidentHandler{reaper: reaper, userService: userService, revoked: revoked, logger: logger, strictJSON: strictJSON}
+}
+
+// KeyspaceResponse reports Redis key usage next to the sessions held in
+// the database
+type KeyspaceResponse struct {
+	Keyspace *keyspace.Report      `json:"keyspace"`
+	Sessions services.SessionStats `json:"sessions"`
+}
+
+type FlushKeyspaceResponse struct {
+	Prefix  string `json:"prefix" example:"idempotency"`
+	Deleted int64  `json:"deleted" example:"512"`
+}
+
+type revokeSessionsRequest struct {
+	AccessTokens bool `json:"access_tokens" example:"true"` // also revoke every access token issued so far
+}
+
+type RevokeSessionsResponse struct {
+	RefreshTokens int64 `json:"refresh_tokens" example:"1200"`
+	AccessTokens  bool  `json:"access_tokens" example:"true"`
+}
+
+// GetKeyspace godoc
+// @Summary Report keyspace and session usage
+// @Description Active sessions, and key counts, keys without a TTL and estimated memory per Redis key prefix, including blacklisted tokens and idempotency keys. The key counts come from the last reaper run unless refresh=true or no run has completed yet. Admin only.
+// @Tags admin
+// @Produce json
+// @Param refresh query bool false "Scan Redis now instead of returning the last report"
+// @Success 200 {object} KeyspaceResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/keyspace [get]
+func (h *IncidentHandler) GetKeyspace(c *gin.Context) {
+	report, err := h.reaper.Current(c.Request.Context(), c.Query("refresh") == "true")
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	sessions, err := h.userService.CountSessions(c.Request.Context())
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, KeyspaceResponse{Keyspace: report, Sessions: sessions})
+}
+
+// FlushKeyspace godoc
+// @Summary Flush a Redis key prefix
+// @Description Delete every key under a prefix that can be dropped without weakening security, such as idempotency keys, rate limit counters or the user cache. Revoked tokens cannot be flushed. Admin only.
+// @Tags admin
+// @Produce json
+// @Param prefix path string true "Prefix name as reported by GET /admin/keyspace"
+// @Success 200 {object} FlushKeyspaceResponse
+// @Failure 400 {object} custom_errors.APIError "Prefix cannot be flushed"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 404 {object} custom_errors.APIError "Unknown prefix"
+// @Router /admin/keyspace/{prefix} [delete]
+func (h *IncidentHandler) FlushKeyspace(c *gin.Context) {
+	prefix := c.Param("prefix")
+	n, err := h.reaper.Flush(c.Request.Context(), prefix)
+	if err != nil {
+		switch {
+		case errors.Is(err, keyspace.ErrUnknownPrefix):
+			renderError(c, custom_errors.ErrNotFound.Wrap(err))
+		case errors.Is(err, keyspace.ErrNotFlushable):
+			renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Keys under "+prefix+" cannot be flushed"))
+		default:
+			renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		}
+		return
+	}
+	actorID := authctx.MustUserID(c.Request.Context())
+	h.logger.WarnContext(c.Request.Context(), "admin flushed key prefix", "actor_id", actorID, "prefix", prefix, "count", n)
+	c.JSON(http.StatusOK, FlushKeyspaceResponse{Prefix: prefix, Deleted: n})
+}
+
+// RevokeSessions godoc
+// @Summary Sign everyone out
+// @Description Revoke every refresh token, so no device can refresh again. With access_tokens, every access token issued so far stops working too, the caller's included. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body revokeSessionsRequest true "What to revoke"
+// @Success 200 {object} RevokeSessionsResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/sessions/revoke [post]
+func (h *IncidentHandler) RevokeSessions(c *gin.Context) {
+	var req revokeSessionsRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	actorID := authctx.MustUserID(c.Request.Context())
+	n, err := h.userService.RevokeAllSessions(c.Request.Context(), int32(actorID))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if req.AccessTokens {
+		if err := h.revoked.RevokeAll(c.Request.Context(), middleware.AccessTokenTTL); err != nil {
+			renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke access tokens: %w", err)))
+			return
+		}
+		h.logger.WarnContext(c.Request.Context(), "admin revoked all access tokens", "actor_id", actorID)
+	}
+	c.JSON(http.StatusOK, RevokeSessionsResponse{RefreshTokens: n, AccessTokens: req.AccessTokens})
+}
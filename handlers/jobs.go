@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler exposes the background job scheduler to admins
+type JobHandler struct {
+	runner *jobs.Runner
+	logger *slog.Logger
+}
+
+func NewJobHandler(runner *jobs.Runner, logger *slog.Logger) *JobHandler {
+	return &JobHandler{runner: runner, logger: logger}
+}
+
+// renderJobError renders err, mapping unknown job names to 404
+func renderJobError(c *gin.Context, err error) {
+	if errors.Is(err, jobs.ErrUnknownJob) {
+		renderError(c, custom_errors.ErrNotFound.Wrap(err))
+		return
+	}
+	renderError(c, err)
+}
+
+// ListJobs godoc
+// @Summary List background jobs
+// @Description Every scheduled job with its interval, whether it is running or paused, and the outcome of its last run on this replica. Admin only.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} jobs.Status
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/jobs [get]
+func (h *JobHandler) ListJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, h.runner.Statuses())
+}
+
+// GetJob godoc
+// @Summary Get a background job
+// @Tags admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} jobs.Status
+// @Failure 404 {object} custom_errors.APIError "Unknown job"
+// @Router /admin/jobs/{name} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	status, err := h.runner.Status(c.Param("name"))
+	if err != nil {
+		renderJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// RunJob godoc
+// @Summary Run a background job now
+// @Description Queue an immediate run of a job on this replica, even if it is paused. The run starts once any run in progress finishes.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 202 {object} jobs.Status
+// @Failure 404 {object} custom_errors.APIError "Unknown job"
+// @Router /admin/jobs/{name}/run [post]
+func (h *JobHandler) RunJob(c *gin.Context) {
+	h.control(c, "triggered", h.runner.Trigger, http.StatusAccepted)
+}
+
+// PauseJob godoc
+// @Summary Pause a background job
+// @Description Skip the scheduled runs of a job on this replica until it is resumed. A run in progress is left to finish.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} jobs.Status
+// @Failure 404 {object} custom_errors.APIError "Unknown job"
+// @Router /admin/jobs/{name}/pause [post]
+func (h *JobHandler) PauseJob(c *gin.Context) {
+	h.control(c, "paused", h.runner.Pause, http.StatusOK)
+}
+
+// ResumeJob godoc
+// @Summary Resume a background job
+// @Tags admin
+// @Produce json
+// @Param name path string true "Job name"
+// @Success 200 {object} jobs.Status
+// @Failure 404 {object} custom_errors.APIError "Unknown job"
+// @Router /admin/jobs/{name}/resume [post]
+func (h *JobHandler) ResumeJob(c *gin.Context) {
+	h.control(c, "resumed", h.runner.Resume, http.StatusOK)
+}
+
+// control applies action to the named job, logs who did it and responds
+// with the job's new state
+func (h *JobHandler) control(c *gin.Context, verb string, action func(string) error, status int) {
+	name := c.Param("name")
+	if err := action(name); err != nil {
+		renderJobError(c, err)
+		return
+	}
+	actorID, _ := authctx.UserID(c.Request.Context())
+	h.logger.InfoContext(c.Request.Context(), "background job "+verb, "actor_id", actorID, "job", name)
+
+	state, err := h.runner.Status(name)
+	if err != nil {
+		renderJobError(c, err)
+		return
+	}
+	c.JSON(status, state)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler serves the WebSocket notification stream and admin
+// broadcasts
+type NotificationHandler struct {
+	hub        *ws.Hub
+	logger     *slog.Logger
+	strictJSON bool
+}
+
+func NewNotificationHandler(hub *ws.Hub, logger *slog.Logger, strictJSON bool) *NotificationHandler {
+	return &NotificationHandler{hub: hub, logger: logger, strictJSON: strictJSON}
+}
+
+type broadcastRequest struct {
+	Message string `json:"message" binding:"required,max=1000" example:"Maintenance starts at 22:00 UTC"`
+}
+
+// BroadcastData is the data of broadcast notifications
+type BroadcastData struct {
+	Message string `json:"message" example:"Maintenance starts at 22:00 UTC"`
+}
+
+// Connect godoc
+// @Summary Stream notifications
+// @Description Upgrade to a WebSocket over which the caller's notifications, such as profile_updated, and admin broadcasts arrive as JSON text frames. Browsers, which cannot set headers on a WebSocket, pass the access token as the subprotocols "bearer, <token>".
+// @Tags notifications
+// @Success 101 {object} ws.Notification "One frame per notification"
+// @Failure 400 {object} custom_errors.APIError "Not a WebSocket upgrade"
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Router /ws [get]
+func (h *NotificationHandler) Connect(c *gin.Context) {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Expected a WebSocket upgrade"))
+		return
+	}
+	userID := authctx.MustUserID(c.Request.Context())
+	h.hub.Serve(c.Writer, c.Request, userID)
+}
+
+// Broadcast godoc
+// @Summary Broadcast a notification
+// @Description Send a message to every connected client on every replica. Admin only.
+// @Tags admin
+// @Accept json
+// @Param request body broadcastRequest true "Message to send"
+// @Success 202
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /admin/broadcast [post]
+func (h *NotificationHandler) Broadcast(c *gin.Context) {
+	var req broadcastRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+	if err := h.hub.Broadcast(c.Request.Context(), ws.TypeBroadcast, BroadcastData{Message: req.Message}); err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("broadcast: %w", err)))
+		return
+	}
+	actorID := authctx.MustUserID(c.Request.Context())
+	h.logger.InfoContext(c.Request.Context(), "admin broadcast a notification", "actor_id", actorID)
+	c.Status(http.StatusAccepted)
+}
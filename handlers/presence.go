@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"idiomatic-go/authctx"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/presence"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PresenceHandler struct {
+	tracker     *presence.Tracker
+	userService *services.UserService
+}
+
+func NewPresenceHandler(tracker *presence.Tracker, userService *services.UserService) *PresenceHandler {
+	return &PresenceHandler{tracker: tracker, userService: userService}
+}
+
+type PresenceResponse struct {
+	UserID   string         `json:"user_id" example:"0b8e7c1a-5f7d-4c8e-9a51-2f1d6c3e4b7a"`
+	Online   bool           `json:"online" example:"true"`
+	LastSeen *jsontime.Time `json:"last_seen,omitempty" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+// Heartbeat godoc
+// @Summary Report activity
+// @Description Mark the caller as online. Clients that are open but idle call this periodically; other authenticated requests count as activity too.
+// @Tags presence
+// @Success 204
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Failure 500 {object} custom_errors.APIError "Internal server error"
+// @Router /me/heartbeat [post]
+func (h *PresenceHandler) Heartbeat(c *gin.Context) {
+	userID := authctx.MustUserID(c.Request.Context())
+	if err := h.tracker.Touch(c.Request.Context(), userID); err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("record heartbeat: %w", err)))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Presence godoc
+// @Summary Get a user's presence
+// @Description Whether a user is online, and when they were last active if that is known
+// @Tags presence
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} PresenceResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid user ID"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /users/{id}/presence [get]
+func (h *PresenceHandler) Presence(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	user, err := h.userService.GetUser(c.Request.Context(), id)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	lastSeen, ok, err := h.tracker.LastSeen(c.Request.Context(), int64(id))
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get last seen: %w", err)))
+		return
+	}
+	resp := PresenceResponse{UserID: services.ExternalUserID(user)}
+	if ok {
+		t := jsontime.New(lastSeen)
+		resp.LastSeen = &t
+		resp.Online = h.tracker.Online(lastSeen)
+	}
+	c.JSON(http.StatusOK, resp)
+}
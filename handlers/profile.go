@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+
+	db "idiomatic-go/database"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+)
+
+type profileRequest struct {
+	DisplayName string `json:"display_name" binding:"max=100" example:"John Doe"`
+	Bio         string `json:"bio" binding:"max=500" example:"Backend developer"`
+	AvatarURL   string `json:"avatar_url" binding:"omitempty,http_url,max=2048" example:"https://cdn.example.com/avatars/johndoe.png"`
+	Locale      string `json:"locale" binding:"omitempty,bcp47_language_tag,max=35" example:"en-GB"`
+	Timezone    string `json:"timezone" binding:"omitempty,timezone,max=64" example:"Europe/London"`
+}
+
+type ProfileResponse struct {
+	DisplayName string        `json:"display_name" example:"John Doe"`
+	Bio         string        `json:"bio" example:"Backend developer"`
+	AvatarURL   string        `json:"avatar_url" example:"https://cdn.example.com/avatars/johndoe.png"`
+	Locale      string        `json:"locale" example:"en-GB"`
+	Timezone    string        `json:"timezone" example:"Europe/London"`
+	CreatedAt   jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	UpdatedAt   jsontime.Time `json:"updated_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+// UserDetailResponse is a single user with their profile, absent if they
+// have not created one
+type UserDetailResponse struct {
+	UserResponse
+	Profile *ProfileResponse `json:"profile,omitempty"`
+}
+
+func newProfileResponse(p db.Profile) ProfileResponse {
+	return ProfileResponse{
+		DisplayName: p.DisplayName,
+		Bio:         p.Bio,
+		AvatarURL:   p.AvatarUrl,
+		Locale:      p.Locale,
+		Timezone:    p.Timezone,
+		CreatedAt:   jsontime.FromTimestamptz(p.CreatedAt),
+		UpdatedAt:   jsontime.FromTimestamptz(p.UpdatedAt),
+	}
+}
+
+// GetProfile godoc
+// @Summary Get a user's profile
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} ProfileResponse
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found or has no profile"
+// @Router /users/{id}/profile [get]
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	profile, err := h.userService.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newProfileResponse(profile))
+}
+
+// PutProfile godoc
+// @Summary Create or replace a user's profile
+// @Description Set every profile field; omitted fields are cleared. The locale is a BCP 47 language tag and the timezone an IANA time zone name.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param profile body profileRequest true "Profile"
+// @Success 200 {object} ProfileResponse "Profile replaced"
+// @Success 201 {object} ProfileResponse "Profile created"
+// @Failure 400 {object} custom_errors.APIError "Invalid profile"
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /users/{id}/profile [put]
+func (h *UserHandler) PutProfile(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	var req profileRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	profile, created, err := h.userService.PutProfile(c.Request.Context(), db.UpsertProfileParams{
+		UserID:      id,
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AvatarUrl:   req.AvatarURL,
+		Locale:      req.Locale,
+		Timezone:    req.Timezone,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, newProfileResponse(profile))
+}
+
+// DeleteProfile godoc
+// @Summary Delete a user's profile
+// @Tags users
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found or has no profile"
+// @Router /users/{id}/profile [delete]
+func (h *UserHandler) DeleteProfile(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := h.userService.DeleteProfile(c.Request.Context(), id); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	db "idiomatic-go/database"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QueryStatsHandler struct {
+	service *services.QueryStatsService
+}
+
+func NewQueryStatsHandler(service *services.QueryStatsService) *QueryStatsHandler {
+	return &QueryStatsHandler{service: service}
+}
+
+type QueryStatResponse struct {
+	Name        string  `json:"name,omitempty" example:"GetUserByEmail"` // sqlc query; empty for other SQL
+	Query       string  `json:"query" example:"-- name: GetUserByEmail :one\nSELECT id, username, email FROM users WHERE email = $1"`
+	Calls       int64   `json:"calls" example:"18250"`
+	Rows        int64   `json:"rows" example:"18011"`
+	TotalTimeMS float64 `json:"total_time_ms" example:"2310.5"`
+	MeanTimeMS  float64 `json:"mean_time_ms" example:"0.127"`
+	MaxTimeMS   float64 `json:"max_time_ms" example:"41.2"`
+}
+
+func newQueryStatResponse(s db.QueryStat) QueryStatResponse {
+	return QueryStatResponse{
+		Name:        s.Name,
+		Query:       s.Query,
+		Calls:       s.Calls,
+		Rows:        s.Rows,
+		TotalTimeMS: durationMS(s.TotalTime),
+		MeanTimeMS:  durationMS(s.MeanTime),
+		MaxTimeMS:   durationMS(s.MaxTime),
+	}
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ListQueryStats godoc
+// @Summary List the most expensive database queries
+// @Description Top statements of the service's database from pg_stat_statements, mapped to the sqlc queries that issued them. Requires the extension to be preloaded and created. Admin only.
+// @Tags admin
+// @Produce json
+// @Param order query string false "Sort key, descending" Enums(total_time, mean_time, calls) default(total_time)
+// @Param limit query int false "Number of queries (1-100)" default(20)
+// @Success 200 {array} QueryStatResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid order or limit"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Failure 501 {object} custom_errors.APIError "pg_stat_statements is not enabled"
+// @Router /admin/query-stats [get]
+func (h *QueryStatsHandler) ListQueryStats(c *gin.Context) {
+	limit, _, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	stats, err := h.service.TopQueries(c.Request.Context(), c.DefaultQuery("order", db.QueryStatsByTotalTime), limit)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]QueryStatResponse, 0, len(stats))
+	for _, s := range stats {
+		resp = append(resp, newQueryStatResponse(s))
+	}
+	c.JSON(http.StatusOK, resp)
+}
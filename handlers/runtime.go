@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+
+	"idiomatic-go/buildinfo"
+	"idiomatic-go/clock"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RuntimeHandler struct {
+	clock   clock.Clock
+	started time.Time
+}
+
+func NewRuntimeHandler(clk clock.Clock) *RuntimeHandler {
+	return &RuntimeHandler{clock: clk, started: clk.Now()}
+}
+
+type RuntimeVarsResponse struct {
+	Version       string        `json:"version" example:"3f2c9e1a7b4d"`
+	GoVersion     string        `json:"go_version" example:"go1.23.4"`
+	Module        string        `json:"module,omitempty" example:"idiomatic-go"`
+	StartedAt     jsontime.Time `json:"started_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	UptimeSeconds int64         `json:"uptime_seconds" example:"86400"`
+	Goroutines    int           `json:"goroutines" example:"42"`
+	NumCPU        int           `json:"num_cpu" example:"4"`
+	GOMAXPROCS    int           `json:"gomaxprocs" example:"4"`
+	Memory        MemoryStats   `json:"memory"`
+	GC            GCStats       `json:"gc"`
+}
+
+type MemoryStats struct {
+	HeapAllocBytes   uint64 `json:"heap_alloc_bytes" example:"12582912"`
+	HeapInuseBytes   uint64 `json:"heap_inuse_bytes" example:"14680064"`
+	HeapObjects      uint64 `json:"heap_objects" example:"80412"`
+	StackInuseBytes  uint64 `json:"stack_inuse_bytes" example:"1048576"`
+	SysBytes         uint64 `json:"sys_bytes" example:"33554432"`
+	TotalAllocBytes  uint64 `json:"total_alloc_bytes" example:"9663676416"`
+	MemoryLimitBytes int64  `json:"memory_limit_bytes" example:"9223372036854775807"` // GOMEMLIMIT
+}
+
+type GCStats struct {
+	Cycles        uint32        `json:"cycles" example:"1520"`
+	ForcedCycles  uint32        `json:"forced_cycles" example:"0"`
+	LastAt        jsontime.Time `json:"last_at" swaggertype:"string" example:"2025-03-23T15:04:03Z"` // null before the first cycle
+	PauseTotalMS  float64       `json:"pause_total_ms" example:"312.4"`
+	LastPauseMS   float64       `json:"last_pause_ms" example:"0.21"`
+	NextHeapBytes uint64        `json:"next_heap_bytes" example:"16777216"`
+	CPUFraction   float64       `json:"cpu_fraction" example:"0.0004"`
+	GOGCPercent   int           `json:"gogc_percent" example:"100"` // -1 with GOGC=off
+}
+
+// Vars godoc
+// @Summary Runtime diagnostics
+// @Description Build, goroutine, memory and garbage collector statistics of this replica. Reading them briefly stops the world, so do not poll it at a high rate. Admin only.
+// @Tags debug
+// @Produce json
+// @Success 200 {object} RuntimeVarsResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /debug/vars [get]
+func (h *RuntimeHandler) Vars(c *gin.Context) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	settings := []metrics.Sample{{Name: "/gc/gogc:percent"}, {Name: "/gc/gomemlimit:bytes"}}
+	metrics.Read(settings)
+
+	now := h.clock.Now()
+	resp := RuntimeVarsResponse{
+		Version:       buildinfo.ServiceVersion(),
+		GoVersion:     runtime.Version(),
+		StartedAt:     jsontime.New(h.started),
+		UptimeSeconds: int64(now.Sub(h.started).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		NumCPU:        runtime.NumCPU(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		Memory: MemoryStats{
+			HeapAllocBytes:   m.HeapAlloc,
+			HeapInuseBytes:   m.HeapInuse,
+			HeapObjects:      m.HeapObjects,
+			StackInuseBytes:  m.StackInuse,
+			SysBytes:         m.Sys,
+			TotalAllocBytes:  m.TotalAlloc,
+			MemoryLimitBytes: int64(settings[1].Value.Uint64()),
+		},
+		GC: GCStats{
+			Cycles:        m.NumGC,
+			ForcedCycles:  m.NumForcedGC,
+			PauseTotalMS:  durationMS(time.Duration(m.PauseTotalNs)),
+			NextHeapBytes: m.NextGC,
+			CPUFraction:   m.GCCPUFraction,
+			GOGCPercent:   int(settings[0].Value.Uint64()),
+		},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		resp.Module = info.Main.Path
+	}
+	if m.NumGC > 0 {
+		resp.GC.LastAt = jsontime.New(time.Unix(0, int64(m.LastGC)))
+		resp.GC.LastPauseMS = durationMS(time.Duration(m.PauseNs[(m.NumGC+255)%256]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/optional"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bounds on the search query q. Trigram similarity needs a few characters
+// to rank meaningfully.
+const (
+	minSearchQueryLen = 2
+	maxSearchQueryLen = 100
+)
+
+// SearchResult is a user matching a search with its relevance
+type SearchResult struct {
+	User UserResponse `json:"user"`
+	Rank float32      `json:"rank" example:"0.42"` // similarity to the query, 0 to 1
+}
+
+type SearchUsersResponse struct {
+	Results    []SearchResult `json:"results"`
+	Limit      int32          `json:"limit" example:"20"`
+	Offset     int32          `json:"offset" example:"0"`
+	NextOffset *int32         `json:"next_offset,omitempty" example:"20"` // absent on the last page
+}
+
+// parseUserSearch reads the q, role and created_after query parameters
+func parseUserSearch(c *gin.Context) (services.UserSearch, error) {
+	search := services.UserSearch{Query: c.Query("q")}
+	if n := utf8.RuneCountInString(search.Query); n < minSearchQueryLen || n > maxSearchQueryLen {
+		return search, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest,
+			"q must be between "+strconv.Itoa(minSearchQueryLen)+" and "+strconv.Itoa(maxSearchQueryLen)+" characters")
+	}
+	if role := c.Query("role"); role != "" {
+		search.Role = optional.Some(role)
+	}
+	if raw := c.Query("created_after"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return search, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "created_after must be an RFC 3339 time")
+		}
+		search.CreatedAfter = optional.Some(t)
+	}
+	return search, nil
+}
+
+// SearchUsers godoc
+// @Summary Search users
+// @Description Find active users whose username or email contains q, case-insensitively, best match first. Admin only.
+// @Tags users
+// @Produce json
+// @Param q query string true "Part of a username or email (2-100 characters)"
+// @Param role query string false "Only users with this role"
+// @Param created_after query string false "Only users created after this RFC 3339 time"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param offset query int false "Number of results to skip" default(0)
+// @Success 200 {object} SearchUsersResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid query or pagination"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /users/search [get]
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	search, err := parseUserSearch(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	matches, more, err := h.userService.SearchUsers(c.Request.Context(), search, limit, offset)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := SearchUsersResponse{
+		Results: make([]SearchResult, 0, len(matches)),
+		Limit:   limit,
+		Offset:  offset,
+	}
+	for _, m := range matches {
+		resp.Results = append(resp.Results, SearchResult{User: newUserResponse(m.User), Rank: m.Rank})
+	}
+	if more {
+		next := offset + limit
+		resp.NextOffset = &next
+	}
+	c.JSON(http.StatusOK, resp)
+}
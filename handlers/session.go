@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" example:"9f86d081884c7d65..."`
+	DeviceID     string `json:"device_id" binding:"required,max=64" example:"3f1c2e4a-8b7d-4c1e-9a2b-5d6e7f8a9b0c"`
+}
+
+type DeviceResponse struct {
+	DeviceID        string        `json:"device_id" example:"3f1c2e4a-8b7d-4c1e-9a2b-5d6e7f8a9b0c"`
+	SignedInAt      jsontime.Time `json:"signed_in_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	LastRefreshedAt jsontime.Time `json:"last_refreshed_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+type RevokeDevicesResponse struct {
+	Revoked int64 `json:"revoked" example:"3"`
+}
+
+// Refresh godoc
+// @Summary Refresh an access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token works once and only from the device it was issued to; presenting it again, or from another device, signs that device out.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param request body refreshRequest true "Refresh token and the device presenting it"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 401 {object} custom_errors.APIError "Invalid, expired or revoked refresh token"
+// @Router /token/refresh [post]
+func (h *UserHandler) Refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	user, refresh, err := h.userService.RotateRefreshToken(c.Request.Context(), req.RefreshToken, req.DeviceID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	token, expires, err := h.signToken(user)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, h.tokenResponse(token, expires, refresh))
+}
+
+// ListDevices godoc
+// @Summary List signed-in devices
+// @Description Devices holding a usable refresh token for the caller
+// @Tags sessions
+// @Produce json
+// @Success 200 {array} DeviceResponse
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Router /me/devices [get]
+func (h *UserHandler) ListDevices(c *gin.Context) {
+	userID := authctx.MustUserID(c.Request.Context())
+	devices, err := h.userService.ListDevices(c.Request.Context(), int32(userID))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]DeviceResponse, 0, len(devices))
+	for _, d := range devices {
+		resp = append(resp, DeviceResponse{
+			DeviceID:        d.DeviceID,
+			SignedInAt:      jsontime.FromTimestamptz(d.SignedInAt),
+			LastRefreshedAt: jsontime.FromTimestamptz(d.LastRefreshedAt),
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeDevice godoc
+// @Summary Sign out a device
+// @Description Revoke the refresh tokens of one of the caller's devices. Access tokens it already holds stay valid until they expire.
+// @Tags sessions
+// @Param device_id path string true "Device ID"
+// @Success 204
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Failure 404 {object} custom_errors.APIError "No active sign-in on that device"
+// @Router /me/devices/{device_id} [delete]
+func (h *UserHandler) RevokeDevice(c *gin.Context) {
+	userID := authctx.MustUserID(c.Request.Context())
+	if err := h.userService.RevokeDevice(c.Request.Context(), int32(userID), c.Param("device_id")); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAllDevices godoc
+// @Summary Sign out all devices
+// @Description Revoke every refresh token of the caller, including the current device's
+// @Tags sessions
+// @Produce json
+// @Success 200 {object} RevokeDevicesResponse
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Router /me/devices [delete]
+func (h *UserHandler) RevokeAllDevices(c *gin.Context) {
+	userID := authctx.MustUserID(c.Request.Context())
+	n, err := h.userService.RevokeAllDevices(c.Request.Context(), int32(userID))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RevokeDevicesResponse{Revoked: n})
+}
@@ -1,44 +1,193 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"idiomatic-go/authctx"
+	"idiomatic-go/clock"
 	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/features"
+	"idiomatic-go/flags"
+	"idiomatic-go/jsontime"
 	"idiomatic-go/middleware"
+	"idiomatic-go/optional"
+	"idiomatic-go/revocation"
 	"idiomatic-go/services"
+	"idiomatic-go/validation"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/sirupsen/logrus"
+	"github.com/google/uuid"
 )
 
 type UserHandler struct {
 	userService *services.UserService
-	logger      *logrus.Logger
-	jwtSecret   string
+	flags       *flags.Store
+	logger      *slog.Logger
+	tokens      *middleware.TokenIssuer
+	strictJSON  bool // reject request bodies carrying unknown fields
+	clock       clock.Clock
+	revoked     *revocation.Store
 }
 
-func NewUserHandler(userService *services.UserService, logger *logrus.Logger, jwtSecret string) *UserHandler {
+func NewUserHandler(userService *services.UserService, flagStore *flags.Store, logger *slog.Logger, clk clock.Clock, revoked *revocation.Store, tokens *middleware.TokenIssuer, strictJSON bool) *UserHandler {
 	return &UserHandler{
 		userService: userService,
+		flags:       flagStore,
 		logger:      logger,
-		jwtSecret:   jwtSecret,
+		tokens:      tokens,
+		strictJSON:  strictJSON,
+		clock:       clk,
+		revoked:     revoked,
 	}
 }
 
 type createUserRequest struct {
-	Username string `json:"username" binding:"required" example:"johndoe"`
-	Email    string `json:"email" binding:"required,email" example:"john@example.com"`
-	Password string `json:"password" binding:"required" example:"password123"`
+	Username string `json:"username" binding:"required,max=50,username,notreserved" example:"johndoe"`
+	Email    string `json:"email" binding:"required,email,max=255" example:"john@example.com"`
+	Password string `json:"password" binding:"required,password" example:"password123"`
+}
+
+type updateUserRequest struct {
+	Username string `json:"username" binding:"required,max=50,username,notreserved" example:"johndoe"`
+	Email    string `json:"email" binding:"required,email,max=255" example:"john@example.com"`
+	Password string `json:"password" binding:"required,password" example:"password123"`
+}
+
+// patchUserRequest is a JSON Merge Patch document: absent fields are left
+// unchanged and null is rejected since none of the fields are nullable.
+type patchUserRequest struct {
+	Username optional.Option[string] `json:"username" swaggertype:"string" example:"johndoe"`
+	Email    optional.Option[string] `json:"email" swaggertype:"string" example:"john@example.com"`
+	Password optional.Option[string] `json:"password" swaggertype:"string" example:"password123"`
 }
 
 type UserResponse struct {
-	ID        int64  `json:"id" example:"1"`
-	Username  string `json:"username" example:"johndoe"`
-	Email     string `json:"email" example:"john@example.com"`
-	CreatedAt string `json:"created_at" example:"2025-03-23T15:04:05Z"` // Using string instead of pgtype.Timestamptz
+	ID            string        `json:"id" example:"0b8e7c1a-5f7d-4c8e-9a51-2f1d6c3e4b7a"`
+	Username      string        `json:"username" example:"johndoe"`
+	Email         string        `json:"email" example:"john@example.com"`
+	Role          string        `json:"role" example:"user"`
+	EmailVerified bool          `json:"email_verified" example:"true"`
+	CreatedAt     jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	UpdatedAt     jsontime.Time `json:"updated_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+// TokenResponse carries an access token and, where one was issued, the
+// refresh token to renew it
+type TokenResponse struct {
+	Token        string        `json:"token"`
+	TokenType    string        `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64         `json:"expires_in" example:"86400"` // seconds until the access token expires
+	ExpiresAt    jsontime.Time `json:"expires_at" swaggertype:"string" example:"2025-03-24T15:04:05Z"`
+	RefreshToken string        `json:"refresh_token,omitempty"`
+}
+
+type LoginResponse struct {
+	TokenResponse
+	DeviceID string      `json:"device_id" example:"5b7f2c1e-8d4a-4f3b-9c6e-1a2b3c4d5e6f"`
+	User     UserSummary `json:"user"`
+}
+
+// UserSummary identifies the signed-in user in login responses
+type UserSummary struct {
+	ID            string `json:"id" example:"0b8e7c1a-5f7d-4c8e-9a51-2f1d6c3e4b7a"`
+	Username      string `json:"username" example:"johndoe"`
+	Email         string `json:"email" example:"john@example.com"`
+	Role          string `json:"role" example:"user"`
+	EmailVerified bool   `json:"email_verified" example:"true"`
+}
+
+func newUserSummary(u db.User) UserSummary {
+	return UserSummary{
+		ID:            services.ExternalUserID(u),
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
+type ListUsersResponse struct {
+	Users      []interface{} `json:"users"`
+	Limit      int32         `json:"limit" example:"20"`
+	Offset     int32         `json:"offset" example:"0"`
+	NextCursor *int32        `json:"next_cursor,omitempty" example:"42"` // only with the cursor_pagination feature; absent on the last page
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// newUserResponse converts a database row into its public representation,
+// leaving out the password hash.
+func newUserResponse(u db.User) UserResponse {
+	return UserResponse{
+		ID:            services.ExternalUserID(u),
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+		CreatedAt:     jsontime.FromTimestamptz(u.CreatedAt),
+		UpdatedAt:     jsontime.FromTimestamptz(u.UpdatedAt),
+	}
+}
+
+// parseUserID returns the user addressed by the :id path parameter, as
+// resolved by ResolveIDMiddleware
+func parseUserID(c *gin.Context) (int32, error) {
+	id, ok := middleware.ResolvedID(c.Request.Context(), "id")
+	if !ok {
+		return 0, custom_errors.ErrInternalServerError.Wrap(errors.New("route does not resolve the user id"))
+	}
+	return id, nil
+}
+
+// parsePagination reads the limit and offset query parameters
+func parsePagination(c *gin.Context) (int32, int32, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, 0, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "offset must be a non-negative integer")
+	}
+	return int32(limit), int32(offset), nil
+}
+
+// parsePageRequest reads the limit query parameter and either offset or
+// cursor, the next_cursor of the previous page
+func parsePageRequest(c *gin.Context) (services.PageRequest, error) {
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		return services.PageRequest{}, err
+	}
+	req := services.PageRequest{Limit: limit, Offset: offset}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || cursor <= 0 {
+			return req, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid cursor")
+		}
+		if offset != 0 {
+			return req, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "offset and cursor cannot be combined")
+		}
+		req.Cursor = optional.Some(int32(cursor))
+	}
+	return req, nil
+}
+
+// nextCursor returns the cursor of the page after page for responses
+func nextCursor[T any](page services.Page[T]) *int32 {
+	if next, ok := page.Next.Get(); ok {
+		return &next
+	}
+	return nil
 }
 
 // CreateUser godoc
@@ -48,16 +197,19 @@ type UserResponse struct {
 // @Accept json
 // @Produce json
 // @Param user body createUserRequest true "User details"
+// @Param Idempotency-Key header string false "Client-chosen key; retries with the same key replay the first response"
 // @Success 201 {object} UserResponse
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 409 {object} custom_errors.APIError "Username or email already taken, or a request with the same Idempotency-Key is in progress"
+// @Failure 422 {object} custom_errors.APIError "Idempotency-Key reused for a different request"
+// @Failure 500 {object} custom_errors.APIError "Internal server error"
 // @Router /users [post]
 func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	var req createUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.WithError(err).Warn("invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		renderBindError(c, err)
 		return
 	}
 
@@ -69,12 +221,12 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	user, err := h.userService.CreateUser(c.Request.Context(), params)
 	if err != nil {
-		log.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		actorID, _ := authctx.UserID(c.Request.Context())
+		_ = renderError(c, err).SetMeta(gin.H{"actor_id": actorID})
 		return
 	}
 
-	c.JSON(http.StatusCreated, user)
+	c.JSON(http.StatusCreated, newUserResponse(user))
 }
 
 // Login godoc
@@ -84,48 +236,571 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param credentials body loginRequest true "User credentials"
-// @Success 200 {object} loginResponse
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 401 {object} map[string]string "Invalid credentials"
+// @Success 200 {object} LoginResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request body"
+// @Failure 401 {object} custom_errors.APIError "Invalid credentials"
+// @Failure 403 {object} custom_errors.APIError "Email address not verified"
 // @Router /login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	type loginRequest struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required"`
-	}
-
-	type loginResponse struct {
-		Token string `json:"token"`
+		DeviceID string `json:"device_id" binding:"max=64"` // generated when empty
 	}
 
 	var req loginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		h.logger.WithError(err).Warn("invalid request body")
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		renderBindError(c, err)
 		return
 	}
+	middleware.TarpitAccount(c, req.Email)
 
 	user, err := h.userService.Login(c.Request.Context(), req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		renderError(c, err)
+		return
+	}
+	middleware.MarkAuthenticated(c)
+
+	tokenString, expires, err := h.signToken(user)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if req.DeviceID == "" {
+		req.DeviceID = uuid.NewString()
+	}
+	refresh, err := h.userService.IssueRefreshToken(c.Request.Context(), user.ID, req.DeviceID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		TokenResponse: h.tokenResponse(tokenString, expires, refresh),
+		DeviceID:      req.DeviceID,
+		User:          newUserSummary(user),
+	})
+}
+
+// signToken issues an access token for user and returns it with its expiry
+func (h *UserHandler) signToken(user db.User) (string, time.Time, error) {
+	token, expires, err := h.tokens.Issue(user.ID, user.Role, user.TokenVersion)
+	if err != nil {
+		return "", time.Time{}, custom_errors.ErrInternalServerError.Wrap(err)
+	}
+	return token, expires, nil
+}
+
+// tokenResponse describes an issued access token the way OAuth 2.0 token
+// responses do, so clients need not decode the JWT to learn its expiry
+func (h *UserHandler) tokenResponse(token string, expires time.Time, refresh string) TokenResponse {
+	return TokenResponse{
+		Token:        token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(expires.Sub(h.clock.Now()).Round(time.Second) / time.Second),
+		ExpiresAt:    jsontime.New(expires),
+		RefreshToken: refresh,
+	}
+}
+
+// Logout godoc
+// @Summary User logout
+// @Description Revoke the presented JWT so it can no longer be used
+// @Tags users
+// @Success 204
+// @Failure 401 {object} custom_errors.APIError "Invalid or missing token"
+// @Failure 500 {object} custom_errors.APIError "Internal server error"
+// @Router /logout [post]
+func (h *UserHandler) Logout(c *gin.Context) {
+	user, ok := authctx.UserFromContext(c.Request.Context())
+	if !ok || user.TokenID == "" {
+		renderError(c, custom_errors.NewAPIError(http.StatusUnauthorized, custom_errors.CodeInvalidToken, "Token cannot be revoked"))
+		return
+	}
+
+	if err := h.revoked.Revoke(c.Request.Context(), user.TokenID, user.TokenExpiresAt); err != nil {
+		_ = renderError(c, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke token: %w", err))).SetMeta(gin.H{"user_id": user.ID})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUsers godoc
+// @Summary List users
+// @Description List users ordered by ID with limit/offset pagination (admin only). Requests opted into the cursor_pagination feature may page by cursor instead.
+// @Tags users
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param offset query int false "Number of users to skip" default(0)
+// @Param cursor query int false "next_cursor of the previous page (cursor_pagination feature only)"
+// @Param fields query string false "Comma-separated fields to return (id,username,email,role,created_at,updated_at)"
+// @Success 200 {object} ListUsersResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid pagination parameters"
+// @Failure 403 {object} custom_errors.APIError "Caller is not an admin"
+// @Failure 500 {object} custom_errors.APIError "Internal server error"
+// @Router /users [get]
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	if features.Enabled(c.Request.Context(), h.flags, features.CursorPagination) {
+		h.listUsersByCursor(c)
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	fields, err := parseFieldset(c, db.UserColumns)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	users, err := h.userService.ListUsers(c.Request.Context(), fields, limit, offset)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	projected, err := projectUsers(users, fields)
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, ListUsersResponse{Users: projected, Limit: limit, Offset: offset})
+}
+
+// listUsersByCursor is ListUsers under the cursor_pagination feature. Rows
+// are read whole and projected afterwards.
+func (h *UserHandler) listUsersByCursor(c *gin.Context) {
+	req, err := parsePageRequest(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	fields, err := parseFieldset(c, db.UserColumns)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	page, err := h.userService.ListUsersPage(c.Request.Context(), req)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	projected, err := projectUsers(page.Items, fields)
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, ListUsersResponse{
+		Users:      projected,
+		Limit:      req.Limit,
+		Offset:     req.Offset,
+		NextCursor: nextCursor(page),
+	})
+}
+
+func projectUsers(users []db.User, fields []string) ([]interface{}, error) {
+	projected := make([]interface{}, 0, len(users))
+	for _, u := range users {
+		p, err := projectFields(newUserResponse(u), fields)
+		if err != nil {
+			return nil, err
+		}
+		projected = append(projected, p)
+	}
+	return projected, nil
+}
+
+// GetUser godoc
+// @Summary Get a user
+// @Description Get a single user by ID with their profile. A fields selection leaves the profile out.
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Param fields query string false "Comma-separated fields to return (id,username,email,role,created_at,updated_at)"
+// @Param expand query string false "Comma-separated related collections to embed (admin only): audit_logs"
+// @Success 200 {object} UserDetailResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid user ID"
+// @Param If-None-Match header string false "ETag of a cached copy; answered with 304 if it is still current"
+// @Success 304 "Cached copy is current"
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin, or expansion requires admin"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	fields, err := parseFieldset(c, db.UserColumns)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	expand, err := parseExpand(c)
+	if err != nil {
+		renderError(c, err)
 		return
 	}
 
-	claims := middleware.Claims{
-		UserID: int64(user.ID),
-		Role:   user.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	user, profile, err := h.userService.GetUserWithProfile(c.Request.Context(), id)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	// Expansions change independently of the user, so only the plain
+	// representation is versioned by the user's ETag
+	if len(expand) == 0 {
+		etag := userETag(user)
+		c.Header("ETag", etag)
+		if inm := c.GetHeader("If-None-Match"); inm != "" && etagMatches(inm, etag, true) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	resp := UserDetailResponse{UserResponse: newUserResponse(user)}
+	if p, ok := profile.Get(); ok {
+		profileResp := newProfileResponse(p)
+		resp.Profile = &profileResp
+	}
+	body, err := projectFields(resp, fields)
+	if err != nil {
+		renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+		return
+	}
+	if len(expand) > 0 {
+		related, err := h.expandUser(c.Request.Context(), user.ID, expand)
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+		if body, err = mergeFields(body, related); err != nil {
+			renderError(c, custom_errors.ErrInternalServerError.Wrap(err))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, body)
+}
+
+// UpdateUser godoc
+// @Summary Update a user
+// @Description Replace a user's username, email and password. Changing the email marks it unverified and mails a new verification link.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param user body updateUserRequest true "User details"
+// @Param If-Match header string false "ETag from a previous read; the update fails with 412 if the user changed since"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request"
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Failure 409 {object} custom_errors.APIError "Username or email already taken"
+// @Failure 412 {object} custom_errors.APIError "User changed since the If-Match ETag was read"
+// @Router /users/{id} [put]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	var req updateUserRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		renderBindError(c, err)
+		return
+	}
+
+	var precondition services.Precondition
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		precondition = func(current db.User) bool { return etagMatches(ifMatch, userETag(current), false) }
+	}
+
+	user, err := h.userService.UpdateUser(c.Request.Context(), db.UpdateUserParams{
+		ID:           id,
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: req.Password,
+	}, precondition)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.Header("ETag", userETag(user))
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Soft-delete a user by ID and sign it out everywhere. The user can be restored by an admin until the retention window passes; it then signs in again.
+// @Tags users
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} custom_errors.APIError "Invalid user ID"
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Router /users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	if err := h.userService.DeleteUser(c.Request.Context(), id); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetMe godoc
+// @Summary Get the current user
+// @Description Get the user the token was issued to, with their profile. Takes the same query parameters as GET /users/{id}.
+// @Tags users
+// @Produce json
+// @Param fields query string false "Comma-separated fields to return (id,username,email,role,created_at,updated_at)"
+// @Param expand query string false "Comma-separated related collections to embed (admin only): audit_logs"
+// @Param If-None-Match header string false "ETag of a cached copy; answered with 304 if it is still current"
+// @Success 200 {object} UserDetailResponse
+// @Success 304 "Cached copy is current"
+// @Failure 401 {object} custom_errors.APIError "Not authenticated"
+// @Failure 404 {object} custom_errors.APIError "User no longer exists"
+// @Router /users/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	h.GetUser(c)
+}
+
+// UpdateMe godoc
+// @Summary Update the current user
+// @Description Replace the username, email and password of the user the token was issued to. Changing the email marks it unverified and mails a new verification link.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body updateUserRequest true "User details"
+// @Param If-Match header string false "ETag from a previous read; the update fails with 412 if the user changed since"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request"
+// @Failure 401 {object} custom_errors.APIError "Not authenticated"
+// @Failure 404 {object} custom_errors.APIError "User no longer exists"
+// @Failure 409 {object} custom_errors.APIError "Username or email already taken"
+// @Failure 412 {object} custom_errors.APIError "User changed since the If-Match ETag was read"
+// @Router /users/me [put]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	h.UpdateUser(c)
+}
+
+// DeleteMe godoc
+// @Summary Delete the current user
+// @Description Soft-delete the user the token was issued to. An admin can restore the account until the retention window passes.
+// @Tags users
+// @Success 204
+// @Failure 401 {object} custom_errors.APIError "Not authenticated"
+// @Failure 404 {object} custom_errors.APIError "User no longer exists"
+// @Router /users/me [delete]
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	h.DeleteUser(c)
+}
+
+// RestoreUser godoc
+// @Summary Restore a deleted user
+// @Description Undo the soft delete of a user that has not been purged yet (admin only)
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid user ID"
+// @Failure 403 {object} custom_errors.APIError "Caller is not an admin"
+// @Failure 404 {object} custom_errors.APIError "No deleted user with this ID"
+// @Failure 409 {object} custom_errors.APIError "Username or email registered again since the delete"
+// @Router /users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	user, err := h.userService.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type mergeUserRequest struct {
+	TargetID string `json:"target_id" binding:"required" example:"5d2c9e4f-1a3b-4e6d-8f70-9b1c2d3e4f5a"`
+	DryRun   bool   `json:"dry_run" example:"true"`
+}
+
+type MergeUserResponse struct {
+	Source             UserResponse `json:"source"`
+	Target             UserResponse `json:"target"`
+	AuditLogsMoved     int64        `json:"audit_logs_moved" example:"12"`
+	FilesMoved         int64        `json:"files_moved" example:"3"`
+	RefreshTokensMoved int64        `json:"refresh_tokens_moved" example:"2"`
+	ProfileMoved       bool         `json:"profile_moved" example:"true"`
+	DryRun             bool         `json:"dry_run" example:"true"`
+}
+
+// MergeUser godoc
+// @Summary Merge a duplicate user into another
+// @Description Move the user's audit history, files and sessions to the target account, and its profile unless the target has one, discard its pending email verification and password reset tokens, and soft-delete it, all in one transaction (admin only). With dry_run nothing changes and the response previews the merge.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the duplicate user to merge away" format(uuid)
+// @Param merge body mergeUserRequest true "Surviving account"
+// @Success 200 {object} MergeUserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid request, or a user merged into itself"
+// @Failure 403 {object} custom_errors.APIError "Caller is not an admin"
+// @Failure 404 {object} custom_errors.APIError "Either user not found"
+// @Router /users/{id}/merge [post]
+func (h *UserHandler) MergeUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	var req mergeUserRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		renderBindError(c, err)
+		return
+	}
+
+	targetID, err := h.userService.ResolveUserID(c.Request.Context(), req.TargetID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	result, err := h.userService.MergeUsers(c.Request.Context(), id, targetID, req.DryRun)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MergeUserResponse{
+		Source:             newUserResponse(result.Source),
+		Target:             newUserResponse(result.Target),
+		AuditLogsMoved:     result.AuditLogsMoved,
+		FilesMoved:         result.FilesMoved,
+		RefreshTokensMoved: result.RefreshTokensMoved,
+		ProfileMoved:       result.ProfileMoved,
+		DryRun:             result.DryRun,
+	})
+}
+
+// PatchUser godoc
+// @Summary Partially update a user
+// @Description Apply a JSON Merge Patch (RFC 7396) to a user; omitted fields are left unchanged. Changing the email marks it unverified and mails a new verification link.
+// @Tags users
+// @Accept json
+// @Accept application/merge-patch+json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param user body patchUserRequest true "Fields to change"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid field values, or a patch that sets no field"
+// @Failure 403 {object} custom_errors.APIError "Caller is neither the user nor an admin"
+// @Failure 404 {object} custom_errors.APIError "User not found"
+// @Failure 409 {object} custom_errors.APIError "Username or email already taken"
+// @Router /users/{id} [patch]
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id, err := parseUserID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	var req patchUserRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		h.logger.WarnContext(c.Request.Context(), "invalid request body", "error", err)
+		renderBindError(c, err)
+		return
+	}
+
+	// Present fields must satisfy the same rules as in a full update
+	var invalid []custom_errors.FieldError
+	for _, field := range []struct {
+		name  string
+		value optional.Option[string]
+		rules string
+	}{
+		{"username", req.Username, "required,max=50,username,notreserved"},
+		{"email", req.Email, "required,email,max=255"},
+		{"password", req.Password, "required,password"},
+	} {
+		if field.value.IsNull() {
+			invalid = append(invalid, custom_errors.FieldError{Field: field.name, Message: "cannot be null"})
+			continue
+		}
+		if value, ok := field.value.Get(); ok {
+			if msg, ok := validation.Var(value, field.rules); !ok {
+				invalid = append(invalid, custom_errors.FieldError{Field: field.name, Message: msg})
+			}
+		}
+	}
+	if len(invalid) > 0 {
+		renderError(c, custom_errors.ErrValidation.WithFields(invalid))
+		return
+	}
+
+	user, err := h.userService.PatchUser(c.Request.Context(), id, services.UserPatch{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm ownership of an email address using the token from the verification link
+// @Tags users
+// @Produce json
+// @Param token query string true "Verification token"
+// @Param expires query int true "Link expiry (Unix seconds)"
+// @Param kid query string true "Signing key ID"
+// @Param sig query string true "Link signature"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid or expired token"
+// @Failure 403 {object} custom_errors.APIError "Invalid link signature"
+// @Failure 410 {object} custom_errors.APIError "Link expired"
+// @Router /verify [get]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeInvalidVerification, "token is required"))
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	user, err := h.userService.VerifyEmail(c.Request.Context(), token)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		renderError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{Token: tokenString})
+	c.JSON(http.StatusOK, newUserResponse(user))
 }
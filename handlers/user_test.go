@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+	db "idiomatic-go/database"
+	"idiomatic-go/features"
+	"idiomatic-go/flags"
+	"idiomatic-go/middleware"
+	"idiomatic-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// recordingDB answers every query with no rows and remembers which sqlc
+// queries ran, by the name in their leading "-- name:" comment
+type recordingDB struct {
+	queries []string
+	args    [][]interface{}
+}
+
+func (d *recordingDB) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, nil
+}
+
+func (d *recordingDB) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(sql, "-- name: "), " ")
+	d.queries = append(d.queries, name)
+	d.args = append(d.args, args)
+	return emptyRows{}, nil
+}
+
+func (d *recordingDB) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return emptyRows{}
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Close()                                       {}
+func (emptyRows) Err() error                                   { return nil }
+func (emptyRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (emptyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (emptyRows) Next() bool                                   { return false }
+func (emptyRows) Scan(...any) error                            { return pgx.ErrNoRows }
+func (emptyRows) Values() ([]any, error)                       { return nil, nil }
+func (emptyRows) RawValues() [][]byte                          { return nil }
+func (emptyRows) Conn() *pgx.Conn                              { return nil }
+
+func TestListUsersCursorPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		optIn      bool
+		query      string
+		wantStatus int
+		wantQuery  string
+	}{
+		{"default ignores cursor", false, "?cursor=5", http.StatusOK, "ListUsers"},
+		{"opted in pages by cursor", true, "?cursor=5", http.StatusOK, "ListUsersFiltered"},
+		{"opted in rejects a bad cursor", true, "?cursor=0", http.StatusBadRequest, ""},
+		{"opted in rejects offset with cursor", true, "?offset=5&cursor=3", http.StatusBadRequest, ""},
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingDB{}
+			clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+			userService := services.NewUserService(&db.DB{Queries: db.New(rec)}, logger, clk, nil, nil, nil, 0, nil, nil, nil, "", "")
+			h := NewUserHandler(userService, flags.NewStore(nil, logger), logger, clk, nil, middleware.NewTokenIssuer("", false, clk), false)
+
+			r := gin.New()
+			r.GET("/users", func(c *gin.Context) {
+				if tt.optIn {
+					c.Request = c.Request.WithContext(features.With(c.Request.Context(), []string{features.CursorPagination}))
+				}
+			}, h.ListUsers)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantQuery == "" {
+				if len(rec.queries) != 0 {
+					t.Errorf("ran %v, want no queries", rec.queries)
+				}
+				return
+			}
+			if len(rec.queries) != 1 || rec.queries[0] != tt.wantQuery {
+				t.Fatalf("ran %v, want [%s]", rec.queries, tt.wantQuery)
+			}
+
+			var resp ListUsersResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.NextCursor != nil {
+				t.Errorf("next_cursor = %d on the last page", *resp.NextCursor)
+			}
+			if tt.optIn {
+				// ListUsersFiltered takes after_id as $4
+				if after := rec.args[0][3].(pgtype.Int4); !after.Valid || after.Int32 != 5 {
+					t.Errorf("after_id = %+v, want 5", after)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	db "idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/jsontime"
+	"idiomatic-go/services"
+	"idiomatic-go/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	service    *services.WebhookService
+	strictJSON bool
+}
+
+func NewWebhookHandler(service *services.WebhookService, strictJSON bool) *WebhookHandler {
+	return &WebhookHandler{service: service, strictJSON: strictJSON}
+}
+
+type createWebhookRequest struct {
+	URL         string   `json:"url" binding:"required" example:"https://example.com/hooks/users"`
+	Events      []string `json:"events" binding:"required" example:"user.created,user.deleted"`
+	Description string   `json:"description" example:"CRM sync"`
+}
+
+type updateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required" example:"https://example.com/hooks/users"`
+	Events      []string `json:"events" binding:"required" example:"user.created,user.deleted"`
+	Description string   `json:"description" example:"CRM sync"`
+	Active      *bool    `json:"active" binding:"required" example:"true"`
+}
+
+type WebhookResponse struct {
+	ID          int32         `json:"id" example:"1"`
+	URL         string        `json:"url" example:"https://example.com/hooks/users"`
+	Events      []string      `json:"events" example:"user.created,user.deleted"`
+	Description string        `json:"description" example:"CRM sync"`
+	Active      bool          `json:"active" example:"true"`
+	Secret      string        `json:"secret,omitempty" example:"4f9a..."` // only returned on creation
+	CreatedAt   jsontime.Time `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	UpdatedAt   jsontime.Time `json:"updated_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+type WebhookDeliveryResponse struct {
+	ID             int32           `json:"id" example:"42"`
+	Event          string          `json:"event" example:"user.created"`
+	Status         string          `json:"status" example:"succeeded"`
+	Attempts       int32           `json:"attempts" example:"1"`
+	NextAttemptAt  *jsontime.Time  `json:"next_attempt_at,omitempty" swaggertype:"string" example:"2025-03-23T15:04:05Z"` // while pending
+	LastStatusCode *int32          `json:"last_status_code,omitempty" example:"200"`
+	LastError      string          `json:"last_error,omitempty" example:"receiver answered 503 Service Unavailable"`
+	DeliveredAt    *jsontime.Time  `json:"delivered_at,omitempty" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+	RedeliveryOf   *int32          `json:"redelivery_of,omitempty" example:"41"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt      jsontime.Time   `json:"created_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+func newWebhookResponse(h db.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:          h.ID,
+		URL:         h.Url,
+		Events:      h.Events,
+		Description: h.Description,
+		Active:      h.Active,
+		CreatedAt:   jsontime.FromTimestamptz(h.CreatedAt),
+		UpdatedAt:   jsontime.FromTimestamptz(h.UpdatedAt),
+	}
+}
+
+func newWebhookDeliveryResponse(d db.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:        d.ID,
+		Event:     d.Event,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		LastError: d.LastError.String,
+		Payload:   d.Payload,
+		CreatedAt: jsontime.FromTimestamptz(d.CreatedAt),
+	}
+	if d.Status == webhooks.StatusPending {
+		t := jsontime.FromTimestamptz(d.NextAttemptAt)
+		resp.NextAttemptAt = &t
+	}
+	if d.LastStatusCode.Valid {
+		resp.LastStatusCode = &d.LastStatusCode.Int32
+	}
+	if d.DeliveredAt.Valid {
+		t := jsontime.FromTimestamptz(d.DeliveredAt)
+		resp.DeliveredAt = &t
+	}
+	if d.RedeliveryOf.Valid {
+		resp.RedeliveryOf = &d.RedeliveryOf.Int32
+	}
+	return resp
+}
+
+// parseWebhookID reads the :id path parameter
+func parseWebhookID(c *gin.Context) (int32, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil || id <= 0 {
+		return 0, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid webhook ID")
+	}
+	return int32(id), nil
+}
+
+// CreateWebhook godoc
+// @Summary Register a webhook
+// @Description Register an endpoint to receive signed callbacks for the given user events. The response carries the signing secret, which is not shown again. Admin only.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body createWebhookRequest true "Webhook"
+// @Success 201 {object} WebhookResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid URL or events"
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req createWebhookRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	hook, err := h.service.CreateWebhook(c.Request.Context(), services.WebhookParams{
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListWebhooks godoc
+// @Summary List webhooks
+// @Description List every registered webhook. Admin only.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} WebhookResponse
+// @Failure 403 {object} custom_errors.APIError "Not an admin"
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	hooks, err := h.service.ListWebhooks(c.Request.Context())
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]WebhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		resp = append(resp, newWebhookResponse(hook))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetWebhook godoc
+// @Summary Get a webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} WebhookResponse
+// @Failure 404 {object} custom_errors.APIError "Webhook not found"
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := parseWebhookID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	hook, err := h.service.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(hook))
+}
+
+// UpdateWebhook godoc
+// @Summary Update a webhook
+// @Description Replace a webhook's URL, events and description, or pause it with active=false. The secret is kept.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body updateWebhookRequest true "Webhook"
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid URL or events"
+// @Failure 404 {object} custom_errors.APIError "Webhook not found"
+// @Router /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := parseWebhookID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	var req updateWebhookRequest
+	if err := bindJSON(c, &req, h.strictJSON); err != nil {
+		renderBindError(c, err)
+		return
+	}
+
+	hook, err := h.service.UpdateWebhook(c.Request.Context(), id, services.WebhookParams{
+		URL:         req.URL,
+		Events:      req.Events,
+		Description: req.Description,
+		Active:      *req.Active,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, newWebhookResponse(hook))
+}
+
+// DeleteWebhook godoc
+// @Summary Delete a webhook
+// @Description Delete a webhook along with its delivery log
+// @Tags webhooks
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 404 {object} custom_errors.APIError "Webhook not found"
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := parseWebhookID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := h.service.DeleteWebhook(c.Request.Context(), id); err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description The most recent deliveries to a webhook, newest first, with the outcome of their last attempt
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Number of deliveries (1-100)" default(20)
+// @Success 200 {array} WebhookDeliveryResponse
+// @Failure 400 {object} custom_errors.APIError "Invalid limit"
+// @Failure 404 {object} custom_errors.APIError "Webhook not found"
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := parseWebhookID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	limit, _, err := parsePagination(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), id, limit)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	resp := make([]WebhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(d))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Redeliver godoc
+// @Summary Redeliver a webhook event
+// @Description Queue the payload of an earlier delivery again as a new delivery
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} WebhookDeliveryResponse
+// @Failure 404 {object} custom_errors.APIError "Webhook or delivery not found"
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := parseWebhookID(c)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 32)
+	if err != nil || deliveryID <= 0 {
+		renderError(c, custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := h.service.Redeliver(c.Request.Context(), id, int32(deliveryID))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, newWebhookDeliveryResponse(delivery))
+}
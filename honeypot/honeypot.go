@@ -0,0 +1,163 @@
+package honeypot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"idiomatic-go/denylist"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPaths are decoy routes commonly probed by vulnerability scanners.
+// Nothing legitimate ever requests them.
+var DefaultPaths = []string{
+	"/wp-login.php",
+	"/wp-admin",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git/config",
+	"/phpmyadmin",
+	"/admin.php",
+}
+
+// Config holds configuration for a Trap
+type Config struct {
+	Paths []string // decoy routes; defaults to DefaultPaths
+
+	// CanaryTokens are credentials that are never issued, e.g. planted in
+	// old config files or docs. Presenting one as a bearer token or API key
+	// springs the trap.
+	CanaryTokens []string
+
+	BlockTTL time.Duration // how long an offending IP is denied; zero only logs
+}
+
+var honeypotHitsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "honeypot_hits_total",
+		Help: "Total number of requests that hit a decoy route or presented a canary token, by trigger",
+	},
+	[]string{"trigger"},
+)
+
+func init() {
+	prometheus.MustRegister(honeypotHitsTotal)
+}
+
+// Trap logs and fingerprints clients that touch decoy routes or canary
+// tokens and optionally puts their IP on the shared denylist
+type Trap struct {
+	logger   *slog.Logger
+	deny     *denylist.Store
+	config   Config
+	canaries map[string]struct{}
+}
+
+func New(logger *slog.Logger, deny *denylist.Store, config Config) *Trap {
+	if config.Paths == nil {
+		config.Paths = DefaultPaths
+	}
+	canaries := make(map[string]struct{}, len(config.CanaryTokens))
+	for _, token := range config.CanaryTokens {
+		canaries[token] = struct{}{}
+	}
+	return &Trap{
+		logger:   logger,
+		deny:     deny,
+		config:   config,
+		canaries: canaries,
+	}
+}
+
+// Paths returns the decoy routes to register
+func (t *Trap) Paths() []string {
+	return t.config.Paths
+}
+
+// Handler serves decoy routes. It answers like a missing page so scanners
+// learn nothing from the response.
+func (t *Trap) Handler(c *gin.Context) {
+	t.spring(c, "decoy_route")
+	c.String(http.StatusNotFound, "404 page not found")
+}
+
+// CanaryMiddleware springs the trap for requests presenting a canary token.
+// The request is then rejected as unauthenticated, like any bad credential.
+func (t *Trap) CanaryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(t.canaries) == 0 || !t.hasCanary(c.Request) {
+			c.Next()
+			return
+		}
+		t.spring(c, "canary_token")
+		middleware.RenderError(c, custom_errors.ErrUnauthorized)
+	}
+}
+
+func (t *Trap) hasCanary(r *http.Request) bool {
+	if _, ok := t.canaries[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]; ok {
+		return true
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		if _, ok := t.canaries[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Trap) spring(c *gin.Context, trigger string) {
+	ip := c.ClientIP()
+	honeypotHitsTotal.WithLabelValues(trigger).Inc()
+
+	entry := t.logger.With(
+		"trigger", trigger,
+		"ip", ip,
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"query", c.Request.URL.RawQuery,
+		"user_agent", c.Request.UserAgent(),
+		"fingerprint", Fingerprint(c.Request),
+	)
+	if t.config.BlockTTL > 0 {
+		if err := t.deny.Add(c.Request.Context(), ip, t.config.BlockTTL, trigger+" "+c.Request.URL.Path); err != nil {
+			entry = entry.With("denylist_error", err.Error())
+		} else {
+			entry = entry.With("denied_for", t.config.BlockTTL.String())
+		}
+	}
+	entry.Warn("honeypot triggered")
+}
+
+// Fingerprint summarizes the client software behind r: its user agent, the
+// content negotiation headers it sends and the set of header names present.
+// Scanners rotating IPs usually keep the same fingerprint.
+func Fingerprint(r *http.Request) string {
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, part := range []string{
+		r.UserAgent(),
+		r.Header.Get("Accept"),
+		r.Header.Get("Accept-Language"),
+		r.Header.Get("Accept-Encoding"),
+		strings.Join(names, ","),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
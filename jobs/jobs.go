@@ -0,0 +1,313 @@
+// Package jobs runs periodic background maintenance inside the API process
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"idiomatic-go/gox"
+	"idiomatic-go/jsontime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	jobRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_runs_total",
+			Help: "Background job runs, by job and result",
+		},
+		[]string{"job", "result"},
+	)
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "job_duration_seconds",
+			Help:    "Duration of background job runs",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		},
+		[]string{"job"},
+	)
+	jobLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "job_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful run of each background job",
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(jobRuns, jobDuration, jobLastSuccess)
+}
+
+// ErrUnknownJob is returned for a job name that was never added
+var ErrUnknownJob = errors.New("jobs: unknown job")
+
+// Triggers of a run, as reported in Status.LastTrigger
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Func does one unit of work. It should return promptly once ctx is done.
+type Func func(ctx context.Context) error
+
+// Job is a Func run every Interval, each run bounded by Timeout
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration // defaults to Interval
+	Run      Func
+}
+
+// Status describes the most recent run of a job
+type Status struct {
+	Name         string         `json:"name" example:"keyspace_reaper"`
+	Interval     string         `json:"interval" example:"5m0s"`
+	Running      bool           `json:"running"`
+	Paused       bool           `json:"paused"`
+	Pending      bool           `json:"pending"` // a manual run is queued
+	Runs         int64          `json:"runs" example:"12"`
+	Failures     int64          `json:"failures" example:"0"`
+	LastRun      *jsontime.Time `json:"last_run,omitempty" swaggertype:"string"`
+	LastDuration string         `json:"last_duration,omitempty" example:"120ms"`
+	LastError    string         `json:"last_error,omitempty"`
+	LastTrigger  string         `json:"last_trigger,omitempty" example:"schedule"`
+}
+
+// Runner schedules jobs on their own goroutines. A job never overlaps
+// with itself: a run that outlasts its interval delays the next one, and
+// a manual run waits for the one in progress. Pausing and triggering only
+// affect the replica they are sent to.
+type Runner struct {
+	logger *slog.Logger
+	jobs   []Job
+
+	mu             sync.Mutex
+	status         map[string]*Status
+	triggers       map[string]chan struct{}
+	stopScheduling context.CancelFunc
+	cancelRuns     context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+func NewRunner(logger *slog.Logger) *Runner {
+	return &Runner{logger: logger, status: make(map[string]*Status), triggers: make(map[string]chan struct{})}
+}
+
+// Add registers job. It must be called before Start.
+func (r *Runner) Add(job Job) *Runner {
+	if job.Timeout <= 0 {
+		job.Timeout = job.Interval
+	}
+	r.jobs = append(r.jobs, job)
+	r.status[job.Name] = &Status{Name: job.Name, Interval: job.Interval.String()}
+	r.triggers[job.Name] = make(chan struct{}, 1)
+	return r
+}
+
+// Start launches every job. The first run of each happens after one
+// interval, so startup is not slowed by maintenance work.
+func (r *Runner) Start(ctx context.Context) {
+	runCtx, cancelRuns := context.WithCancel(ctx)
+	scheduleCtx, stopScheduling := context.WithCancel(runCtx)
+	r.cancelRuns, r.stopScheduling = cancelRuns, stopScheduling
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		gox.Run(scheduleCtx, r.logger, "job:"+job.Name, func(ctx context.Context) { r.loop(ctx, runCtx, job) })
+	}
+}
+
+// Stop stops scheduling runs, scheduled or manual. Runs in progress go on.
+func (r *Runner) Stop() {
+	if r.stopScheduling != nil {
+		r.stopScheduling()
+	}
+}
+
+// Shutdown stops scheduling and gives runs in progress drain to finish.
+// Runs still going after that are cancelled, so that jobs checkpoint or
+// hand back their unfinished work, and waited for until ctx is done.
+func (r *Runner) Shutdown(ctx context.Context, drain time.Duration) error {
+	r.Stop()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(drain)
+	defer timer.Stop()
+	select {
+	case <-done:
+		r.logger.Info("background jobs drained")
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	running := r.running()
+	r.logger.Warn("background jobs still running at drain timeout, cancelling them", "jobs", running)
+	if r.cancelRuns != nil {
+		r.cancelRuns()
+	}
+	select {
+	case <-done:
+		r.logger.Info("background jobs stopped", "interrupted", running)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for background jobs %v: %w", running, ctx.Err())
+	}
+}
+
+// running returns the names of the jobs with a run in progress
+func (r *Runner) running() []string {
+	var names []string
+	for _, s := range r.Statuses() {
+		if s.Running {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// Statuses returns the state of every job, sorted by name
+func (r *Runner) Statuses() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.status))
+	for _, s := range r.status {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Status returns the state of the named job
+func (r *Runner) Status(name string) (Status, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.status[name]
+	if !ok {
+		return Status{}, ErrUnknownJob
+	}
+	return *s, nil
+}
+
+// Trigger queues an immediate run of the named job, even if it is paused.
+// Triggering a job that already has a run queued does nothing.
+func (r *Runner) Trigger(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.status[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	select {
+	case r.triggers[name] <- struct{}{}:
+		s.Pending = true
+	default:
+	}
+	return nil
+}
+
+// Pause stops the scheduled runs of the named job until Resume. A run in
+// progress is left to finish.
+func (r *Runner) Pause(name string) error {
+	return r.setPaused(name, true)
+}
+
+// Resume lets the named job run on schedule again
+func (r *Runner) Resume(name string) error {
+	return r.setPaused(name, false)
+}
+
+func (r *Runner) setPaused(name string, paused bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.status[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	s.Paused = paused
+	return nil
+}
+
+// loop schedules job until schedule is done. Runs get runCtx instead, so
+// that stopping the schedule does not interrupt them.
+func (r *Runner) loop(schedule, runCtx context.Context, job Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-schedule.Done():
+			return
+		case <-ticker.C:
+			if s, _ := r.Status(job.Name); s.Paused {
+				continue
+			}
+			r.run(runCtx, job, TriggerSchedule)
+		case <-r.triggers[job.Name]:
+			r.run(runCtx, job, TriggerManual)
+		}
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job Job, trigger string) {
+	r.update(job.Name, func(s *Status) {
+		s.Running = true
+		if trigger == TriggerManual {
+			s.Pending = false
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := job.Run(ctx)
+	elapsed := time.Since(start)
+
+	jobDuration.WithLabelValues(job.Name).Observe(elapsed.Seconds())
+	entry := r.logger.With("job", job.Name, "trigger", trigger, "duration", elapsed)
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		// Not a timeout: Shutdown cancelled the run
+		jobRuns.WithLabelValues(job.Name, "interrupted").Inc()
+		entry.Info("background job interrupted by shutdown", "error", err)
+	case err != nil:
+		jobRuns.WithLabelValues(job.Name, "error").Inc()
+		entry.Error("background job failed", "error", err)
+	default:
+		jobRuns.WithLabelValues(job.Name, "success").Inc()
+		jobLastSuccess.WithLabelValues(job.Name).SetToCurrentTime()
+		entry.Debug("background job finished")
+	}
+
+	r.update(job.Name, func(s *Status) {
+		s.Running = false
+		s.Runs++
+		lastRun := jsontime.New(start)
+		s.LastRun = &lastRun
+		s.LastDuration = elapsed.String()
+		s.LastError = ""
+		s.LastTrigger = trigger
+		if err != nil {
+			s.Failures++
+			s.LastError = err.Error()
+		}
+	})
+}
+
+func (r *Runner) update(name string, fn func(*Status)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.status[name])
+}
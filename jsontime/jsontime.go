@@ -0,0 +1,89 @@
+package jsontime
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var precision atomic.Int64
+
+func init() {
+	precision.Store(int64(time.Second))
+}
+
+// SetPrecision sets the truncation applied to every serialized Time, e.g.
+// time.Second or time.Millisecond. It is meant to be called once at startup.
+func SetPrecision(d time.Duration) {
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+	precision.Store(int64(d))
+}
+
+// Time is a time.Time that always serializes as RFC 3339 in UTC, truncated
+// to the configured precision. The zero value serializes as null.
+type Time struct {
+	time.Time
+}
+
+// New wraps t
+func New(t time.Time) Time {
+	return Time{Time: t}
+}
+
+// FromTimestamptz converts a nullable database timestamp
+func FromTimestamptz(ts pgtype.Timestamptz) Time {
+	if !ts.Valid {
+		return Time{}
+	}
+	return Time{Time: ts.Time}
+}
+
+// String formats t the same way it is serialized
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Truncate(time.Duration(precision.Load())).Format(time.RFC3339Nano)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.Parse(`"`+time.RFC3339Nano+`"`, string(data))
+	if err != nil {
+		return err
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
+
+// MarshalGQL writes t as a GraphQL Time scalar, formatted as in JSON
+func (t Time) MarshalGQL(w io.Writer) {
+	data, _ := t.MarshalJSON()
+	_, _ = w.Write(data)
+}
+
+// UnmarshalGQL reads a GraphQL Time scalar, an RFC 3339 string
+func (t *Time) UnmarshalGQL(v any) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("time must be an RFC 3339 string, got %T", v)
+	}
+	return t.UnmarshalJSON([]byte(strconv.Quote(s)))
+}
@@ -0,0 +1,122 @@
+package jsontime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	plus5 := time.FixedZone("UTC+5", 5*60*60)
+
+	tests := []struct {
+		name      string
+		in        Time
+		precision time.Duration
+		want      string
+	}{
+		{"utc", New(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)), time.Second, `"2024-03-01T12:30:00Z"`},
+		{"offset converted to utc", New(time.Date(2024, 3, 1, 17, 30, 0, 0, plus5)), time.Second, `"2024-03-01T12:30:00Z"`},
+		{"truncated to seconds", New(time.Date(2024, 3, 1, 12, 30, 0, 987654321, time.UTC)), time.Second, `"2024-03-01T12:30:00Z"`},
+		{"truncated to milliseconds", New(time.Date(2024, 3, 1, 12, 30, 0, 987654321, time.UTC)), time.Millisecond, `"2024-03-01T12:30:00.987Z"`},
+		{"zero", Time{}, time.Second, `null`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetPrecision(tt.precision)
+			t.Cleanup(func() { SetPrecision(time.Second) })
+
+			got, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromTimestamptz(t *testing.T) {
+	tests := []struct {
+		name string
+		in   pgtype.Timestamptz
+		want string
+	}{
+		{"null", pgtype.Timestamptz{}, `null`},
+		{"invalid with a time set", pgtype.Timestamptz{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: false}, `null`},
+		{"infinity", pgtype.Timestamptz{InfinityModifier: pgtype.Infinity, Valid: true}, `null`},
+		{"valid", pgtype.Timestamptz{Time: time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("", -3*60*60)), Valid: true}, `"2024-03-01T11:00:00Z"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(FromTimestamptz(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Marshal = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{"utc", `"2024-03-01T12:30:00Z"`, want, false},
+		{"positive offset", `"2024-03-01T14:30:00+02:00"`, want, false},
+		{"negative offset", `"2024-03-01T07:00:00-05:30"`, want, false},
+		{"null", `null`, time.Time{}, false},
+		{"not rfc 3339", `"2024-03-01 12:30:00"`, time.Time{}, true},
+		{"not a string", `1709296200`, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Time
+			err := json.Unmarshal([]byte(tt.in), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %v, want an error", tt.in, got.Time)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(tt.want) {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.in, got.Time, tt.want)
+			}
+			if !got.IsZero() && got.Location() != time.UTC {
+				t.Errorf("Unmarshal(%s) location = %v, want UTC", tt.in, got.Location())
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ts := pgtype.Timestamptz{Time: time.Date(2024, 3, 1, 23, 15, 42, 0, time.FixedZone("", 9*60*60)), Valid: true}
+
+	data, err := json.Marshal(FromTimestamptz(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Time
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Time.Equal(ts.Time) {
+		t.Errorf("round trip through %s = %v, want %v", data, got.Time, ts.Time)
+	}
+}
@@ -0,0 +1,282 @@
+// Package keyspace accounts for the Redis keys the service creates and
+// reaps the ones that were written without an expiry
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"idiomatic-go/jsontime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	keyCount = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "redis_keyspace_keys",
+			Help: "Keys per prefix at the last keyspace scan",
+		},
+		[]string{"prefix"},
+	)
+	keyBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "redis_keyspace_estimated_bytes",
+			Help: "Estimated memory used per prefix at the last keyspace scan, extrapolated from a sample",
+		},
+		[]string{"prefix"},
+	)
+	keysReaped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redis_keyspace_reaped_total",
+			Help: "Keys found without a TTL under a prefix that must expire, and given one",
+		},
+		[]string{"prefix"},
+	)
+	keysFlushed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redis_keyspace_flushed_total",
+			Help: "Keys deleted by flushing a prefix",
+		},
+		[]string{"prefix"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(keyCount, keyBytes, keysReaped, keysFlushed)
+}
+
+var (
+	ErrUnknownPrefix = errors.New("unknown key prefix")
+	ErrNotFlushable  = errors.New("key prefix cannot be flushed")
+)
+
+const (
+	scanBatch  = 500
+	sampleSize = 20 // keys per prefix measured with MEMORY USAGE
+)
+
+// Prefix describes one family of keys
+type Prefix struct {
+	Name    string        // metric label, e.g. "revocation"
+	Pattern string        // key prefix, e.g. revocation.KeyPrefix
+	MaxTTL  time.Duration // when set, keys must expire; any found without a TTL get this one
+
+	// Flushable marks keys that can all be dropped at once, such as caches
+	// and counters. Revocations and replay guards must never be.
+	Flushable bool
+}
+
+// Usage is the scan result for one Prefix
+type Usage struct {
+	Name           string `json:"name" example:"revocation"`
+	Prefix         string `json:"prefix" example:"blacklist:jti:"`
+	Keys           int64  `json:"keys" example:"1024"`
+	WithoutTTL     int64  `json:"without_ttl" example:"0"`
+	EstimatedBytes int64  `json:"estimated_bytes" example:"98304"`
+	Reaped         int64  `json:"reaped" example:"0"`
+}
+
+// Report is the result of a full scan
+type Report struct {
+	ScannedAt    jsontime.Time `json:"scanned_at" swaggertype:"string"`
+	TotalKeys    int64         `json:"total_keys" example:"2048"`
+	Unclassified int64         `json:"unclassified" example:"3"` // keys matching no known prefix
+	Prefixes     []Usage       `json:"prefixes"`
+}
+
+// Reaper scans the registered prefixes, records their usage and expires
+// leaked keys
+type Reaper struct {
+	rdb      *redis.Client
+	prefixes []Prefix
+
+	mu   sync.Mutex
+	last *Report
+}
+
+func NewReaper(rdb *redis.Client, prefixes ...Prefix) *Reaper {
+	return &Reaper{rdb: rdb, prefixes: prefixes}
+}
+
+// Last returns the report of the most recent Run, or nil before the first
+func (r *Reaper) Last() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Current returns the last report, scanning first when refresh is set or
+// no scan has completed yet
+func (r *Reaper) Current(ctx context.Context, refresh bool) (*Report, error) {
+	if last := r.Last(); last != nil && !refresh {
+		return last, nil
+	}
+	if err := r.Run(ctx); err != nil {
+		return nil, err
+	}
+	return r.Last(), nil
+}
+
+// Run scans every prefix with SCAN, so it never blocks Redis for long
+func (r *Reaper) Run(ctx context.Context) error {
+	total, err := r.rdb.DBSize(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("count keys: %w", err)
+	}
+
+	report := &Report{ScannedAt: jsontime.New(time.Now()), TotalKeys: total, Unclassified: total}
+	for _, p := range r.prefixes {
+		usage, err := r.scan(ctx, p)
+		if err != nil {
+			return fmt.Errorf("scan %s: %w", p.Name, err)
+		}
+		keyCount.WithLabelValues(p.Name).Set(float64(usage.Keys))
+		keyBytes.WithLabelValues(p.Name).Set(float64(usage.EstimatedBytes))
+		keysReaped.WithLabelValues(p.Name).Add(float64(usage.Reaped))
+
+		report.Unclassified -= usage.Keys
+		report.Prefixes = append(report.Prefixes, usage)
+	}
+	if report.Unclassified < 0 {
+		report.Unclassified = 0 // keys created or expired during the scan
+	}
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reaper) scan(ctx context.Context, p Prefix) (Usage, error) {
+	usage := Usage{Name: p.Name, Prefix: p.Pattern}
+	var sampled, sampledBytes int64
+
+	var cursor uint64
+	for {
+		keys, next, err := r.rdb.Scan(ctx, cursor, p.Pattern+"*", scanBatch).Result()
+		if err != nil {
+			return usage, err
+		}
+		usage.Keys += int64(len(keys))
+
+		if len(keys) > 0 {
+			pipe := r.rdb.Pipeline()
+			ttls := make([]*redis.DurationCmd, len(keys))
+			for i, key := range keys {
+				ttls[i] = pipe.TTL(ctx, key)
+			}
+			var sizes []*redis.IntCmd
+			for _, key := range keys {
+				if sampled+int64(len(sizes)) >= sampleSize {
+					break
+				}
+				sizes = append(sizes, pipe.MemoryUsage(ctx, key))
+			}
+			// Errors are checked per command: MEMORY USAGE is disabled on
+			// some managed Redis offerings and only costs us the estimate
+			_, _ = pipe.Exec(ctx)
+			for _, size := range sizes {
+				if n, err := size.Result(); err == nil {
+					sampled++
+					sampledBytes += n
+				}
+			}
+
+			// TTL reports -1 for keys without an expiry and -2 for keys
+			// that vanished since SCAN returned them
+			var leaked []string
+			for i, ttl := range ttls {
+				d, err := ttl.Result()
+				if err != nil {
+					return usage, err
+				}
+				if d == -1 {
+					leaked = append(leaked, keys[i])
+				}
+			}
+			usage.WithoutTTL += int64(len(leaked))
+			if p.MaxTTL > 0 && len(leaked) > 0 {
+				reaped, err := r.expire(ctx, leaked, p.MaxTTL)
+				usage.Reaped += reaped
+				if err != nil {
+					return usage, err
+				}
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	if sampled > 0 {
+		usage.EstimatedBytes = sampledBytes * usage.Keys / sampled
+	}
+	return usage, nil
+}
+
+// expire gives keys a TTL rather than deleting them, so an entry that is
+// still meaningful (such as a revoked token) keeps working until then.
+// EXPIRE NX leaves keys that gained a TTL since the scan alone.
+func (r *Reaper) expire(ctx context.Context, keys []string, ttl time.Duration) (int64, error) {
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.ExpireNX(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("expire leaked keys: %w", err)
+	}
+	var n int64
+	for _, cmd := range cmds {
+		if cmd.Val() {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Flush deletes every key of the named prefix and returns how many it
+// deleted. Keys are found with SCAN and removed with UNLINK, so neither
+// blocks Redis for long.
+func (r *Reaper) Flush(ctx context.Context, name string) (int64, error) {
+	var prefix *Prefix
+	for i := range r.prefixes {
+		if r.prefixes[i].Name == name {
+			prefix = &r.prefixes[i]
+		}
+	}
+	if prefix == nil {
+		return 0, ErrUnknownPrefix
+	}
+	if !prefix.Flushable {
+		return 0, ErrNotFlushable
+	}
+
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := r.rdb.Scan(ctx, cursor, prefix.Pattern+"*", scanBatch).Result()
+		if err != nil {
+			return deleted, fmt.Errorf("scan %s: %w", name, err)
+		}
+		if len(keys) > 0 {
+			n, err := r.rdb.Unlink(ctx, keys...).Result()
+			deleted += n
+			keysFlushed.WithLabelValues(name).Add(float64(n))
+			if err != nil {
+				return deleted, fmt.Errorf("unlink %s: %w", name, err)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/correlation"
+	"idiomatic-go/features"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attrs returns the request ID, trace and span IDs, authenticated user ID
+// and feature toggles carried by ctx. Missing values are left out.
+func Attrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if id := correlation.RequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs,
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	if id, ok := authctx.UserID(ctx); ok {
+		attrs = append(attrs, slog.Int64("user_id", id))
+	}
+	if names := features.Requested(ctx); len(names) > 0 {
+		attrs = append(attrs, slog.Any("feature_toggles", names))
+	}
+	return attrs
+}
+
+// ContextHandler adds the Attrs of the context passed to the *Context
+// logging methods (InfoContext and so on) to every record. Attributes the
+// caller set under the same key win, so logging an explicit user_id for
+// the target of an admin action is not overwritten by the admin's ID.
+type ContextHandler struct {
+	next   slog.Handler
+	preset map[string]bool // keys added through WithAttrs
+}
+
+// NewContextHandler wraps next
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{next: next}
+}
+
+func (h *ContextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := Attrs(ctx)
+	if len(attrs) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+	set := make(map[string]bool, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		set[a.Key] = true
+		return true
+	})
+	for _, a := range attrs {
+		if !set[a.Key] && !h.preset[a.Key] {
+			r.AddAttrs(a)
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	preset := make(map[string]bool, len(h.preset)+len(attrs))
+	for k := range h.preset {
+		preset[k] = true
+	}
+	for _, a := range attrs {
+		preset[a.Key] = true
+	}
+	return &ContextHandler{next: h.next.WithAttrs(attrs), preset: preset}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{next: h.next.WithGroup(name), preset: h.preset}
+}
@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Modules lists the components that log through their own child logger,
+// as named in the log_levels setting
+var Modules = []string{"cache", "database", "handlers", "jobs", "mailer", "middleware", "ratelimit", "services", "webhooks", "ws"}
+
+// Levels is the global log level together with per-module overrides, so a
+// noisy module can be quieted, or a single one made verbose, without
+// touching the rest
+type Levels struct {
+	global  slog.Leveler
+	modules map[string]slog.Level
+}
+
+// NewLevels returns global overridden for the modules in modules. global
+// may change later, e.g. a *slog.LevelVar set from a flag.
+func NewLevels(global slog.Leveler, modules map[string]slog.Level) *Levels {
+	return &Levels{global: global, modules: modules}
+}
+
+// Level returns the lowest level any module logs at. Output handlers are
+// built with it and leave the filtering per module to LevelHandler.
+func (l *Levels) Level() slog.Level {
+	lowest := l.global.Level()
+	for _, level := range l.modules {
+		lowest = min(lowest, level)
+	}
+	return lowest
+}
+
+// For returns the level of module: its override or else the global level
+func (l *Levels) For(module string) slog.Leveler {
+	if level, ok := l.modules[module]; ok {
+		return level
+	}
+	return l.global
+}
+
+// LevelHandler drops records below the level of the module its logger
+// belongs to, or below the global level for loggers outside any module
+type LevelHandler struct {
+	next   slog.Handler
+	levels *Levels
+	level  slog.Leveler
+}
+
+// NewLevelHandler wraps next, filtering at the global level of levels
+func NewLevelHandler(next slog.Handler, levels *Levels) *LevelHandler {
+	return &LevelHandler{next: next, levels: levels, level: levels.global}
+}
+
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.next.Enabled(ctx, level)
+}
+
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LevelHandler{next: h.next.WithAttrs(attrs), levels: h.levels, level: h.level}
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{next: h.next.WithGroup(name), levels: h.levels, level: h.level}
+}
+
+// Module returns the child logger of module: its lines carry a module
+// attribute and are filtered at the module's level. A logger not built on
+// a LevelHandler only gains the attribute.
+func Module(logger *slog.Logger, module string) *slog.Logger {
+	attr := slog.String("module", module)
+	h, ok := logger.Handler().(*LevelHandler)
+	if !ok {
+		return logger.With(attr)
+	}
+	return slog.New(&LevelHandler{
+		next:   h.next.WithAttrs([]slog.Attr{attr}),
+		levels: h.levels,
+		level:  h.levels.For(module),
+	})
+}
@@ -0,0 +1,38 @@
+// Package logging builds the application's slog handlers: JSON or text
+// output, debug sampling and the request context (request, trace and user
+// IDs) attached to every line, so a line from deep inside a service can be
+// joined with the request and trace that caused it.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Output formats accepted by NewHandler
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Formats lists every output format
+var Formats = []string{FormatJSON, FormatText}
+
+// ParseLevel parses debug, info, warn or error in any case
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.TrimSpace(s)))
+	return level, err
+}
+
+// NewHandler returns a handler writing lines of format to w: one JSON
+// object per line, or key=value text for reading in a terminal. Lines
+// below level are discarded.
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatText {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
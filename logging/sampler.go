@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var linesSampled = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "log_lines_sampled_total",
+	Help: "Debug log lines dropped by sampling",
+})
+
+func init() {
+	prometheus.MustRegister(linesSampled)
+}
+
+// SamplerConfig controls which repeated lines are kept. Within every Tick,
+// the first Initial lines with the same level and message are written and
+// after that only every Thereafter-th one.
+type SamplerConfig struct {
+	Initial    int
+	Thereafter int // 0 drops every line past Initial
+	Tick       time.Duration
+}
+
+type sampleKey struct {
+	level   slog.Level
+	message string
+}
+
+type sampleState struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[sampleKey]int
+}
+
+// Sampler is a slog.Handler that thins out debug lines logged from hot
+// paths before handing them to the wrapped handler. Info and more severe
+// lines are always passed on.
+type Sampler struct {
+	next   slog.Handler
+	config SamplerConfig
+	state  *sampleState // shared by the handlers derived with WithAttrs
+}
+
+// NewSampler wraps next with sampling as configured
+func NewSampler(next slog.Handler, config SamplerConfig) *Sampler {
+	if config.Tick <= 0 {
+		config.Tick = time.Second
+	}
+	return &Sampler{next: next, config: config, state: &sampleState{counts: make(map[sampleKey]int)}}
+}
+
+func (s *Sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *Sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelInfo || s.keep(r) {
+		return s.next.Handle(ctx, r)
+	}
+	linesSampled.Inc()
+	return nil
+}
+
+func (s *Sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Sampler{next: s.next.WithAttrs(attrs), config: s.config, state: s.state}
+}
+
+func (s *Sampler) WithGroup(name string) slog.Handler {
+	return &Sampler{next: s.next.WithGroup(name), config: s.config, state: s.state}
+}
+
+func (s *Sampler) keep(r slog.Record) bool {
+	st := s.state
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if r.Time.Sub(st.windowStart) >= s.config.Tick || r.Time.Before(st.windowStart) {
+		clear(st.counts)
+		st.windowStart = r.Time
+	}
+	key := sampleKey{level: r.Level, message: r.Message}
+	st.counts[key]++
+	n := st.counts[key]
+	if n <= s.config.Initial {
+		return true
+	}
+	return s.config.Thereafter > 0 && (n-s.config.Initial)%s.config.Thereafter == 0
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRecipientThrottled is returned when an address has already received
+// the maximum number of messages allowed in the current window
+var ErrRecipientThrottled = errors.New("mailer: recipient throttled")
+
+// RecipientKeyPrefix namespaces per-recipient send counters in Redis
+const RecipientKeyPrefix = "mail:recipient:"
+
+// ThrottledMailer caps how many messages a single address receives per
+// window, so public endpoints such as signup cannot be used to flood a
+// third party's inbox.
+type ThrottledMailer struct {
+	next   Mailer
+	rdb    *redis.Client
+	limit  int
+	window time.Duration
+}
+
+func NewThrottledMailer(next Mailer, rdb *redis.Client, limit int, window time.Duration) *ThrottledMailer {
+	return &ThrottledMailer{
+		next:   next,
+		rdb:    rdb,
+		limit:  limit,
+		window: window,
+	}
+}
+
+func (m *ThrottledMailer) Send(ctx context.Context, msg Message) error {
+	// Key by a hash so addresses never appear in the Redis keyspace
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(msg.To))))
+	key := RecipientKeyPrefix + hex.EncodeToString(sum[:])
+
+	pipe := m.rdb.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, m.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("check recipient limit: %w", err)
+	}
+	if count.Val() > int64(m.limit) {
+		return ErrRecipientThrottled
+	}
+
+	return m.next.Send(ctx, msg)
+}
@@ -0,0 +1,269 @@
+// Package memcache is a minimal client for the memcached text protocol,
+// covering the handful of commands the cache and rate limiter need
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxKeyLen = 250
+	maxTTL    = 30 * 24 * time.Hour // longer exptimes are read as Unix timestamps
+)
+
+// Config configures a Client
+type Config struct {
+	Addr         string        // host:port
+	MaxIdleConns int           // connections kept open between requests
+	Timeout      time.Duration // per operation, when ctx has no earlier deadline
+}
+
+// Client talks to a single memcached server
+type Client struct {
+	config Config
+	idle   chan *conn
+}
+
+type conn struct {
+	nc net.Conn
+	rw *bufio.ReadWriter
+}
+
+func New(config Config) *Client {
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+	return &Client{config: config, idle: make(chan *conn, config.MaxIdleConns)}
+}
+
+// Get returns the value stored under key and whether it was found
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	var found bool
+	err := c.do(ctx, func(cn *conn) error {
+		if err := cn.send("get %s\r\n", Key(key)); err != nil {
+			return err
+		}
+		line, err := cn.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("memcache: unexpected reply %q", line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("memcache: bad value size %q", fields[3])
+		}
+		buf := make([]byte, size+2) // value and trailing \r\n
+		if _, err := io.ReadFull(cn.rw, buf); err != nil {
+			return err
+		}
+		if err := cn.expect("END"); err != nil {
+			return err
+		}
+		value, found = buf[:size], true
+		return nil
+	})
+	return value, found, err
+}
+
+// Set stores value under key for ttl; zero means no expiry
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.do(ctx, func(cn *conn) error {
+		if err := cn.sendValue("set", key, value, ttl); err != nil {
+			return err
+		}
+		return cn.expect("STORED")
+	})
+}
+
+// Add stores value under key only if key does not exist yet, and reports
+// whether it did
+func (c *Client) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	var stored bool
+	err := c.do(ctx, func(cn *conn) error {
+		if err := cn.sendValue("add", key, value, ttl); err != nil {
+			return err
+		}
+		line, err := cn.readLine()
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "STORED":
+			stored = true
+		case "NOT_STORED":
+		default:
+			return errors.New("memcache: " + line)
+		}
+		return nil
+	})
+	return stored, err
+}
+
+// Incr adds delta to the decimal counter under key and returns the new
+// value. found is false when key does not exist; it is not created.
+func (c *Client) Incr(ctx context.Context, key string, delta uint64) (value uint64, found bool, err error) {
+	err = c.do(ctx, func(cn *conn) error {
+		if err := cn.send("incr %s %d\r\n", Key(key), delta); err != nil {
+			return err
+		}
+		line, err := cn.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "NOT_FOUND" {
+			return nil
+		}
+		value, err = strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return errors.New("memcache: " + line)
+		}
+		found = true
+		return nil
+	})
+	return value, found, err
+}
+
+// Delete removes keys; missing keys are ignored
+func (c *Client) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.do(ctx, func(cn *conn) error {
+		for _, key := range keys {
+			fmt.Fprintf(cn.rw, "delete %s\r\n", Key(key))
+		}
+		if err := cn.rw.Flush(); err != nil {
+			return err
+		}
+		for range keys {
+			if err := cn.expect("DELETED", "NOT_FOUND"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// do runs fn on a pooled connection. Connections that saw an error are
+// closed rather than returned, since their stream may be out of sync.
+func (c *Client) do(ctx context.Context, fn func(*conn) error) error {
+	cn, err := c.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(c.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cn.nc.SetDeadline(deadline)
+
+	if err := fn(cn); err != nil {
+		cn.nc.Close()
+		return err
+	}
+
+	select {
+	case c.idle <- cn:
+	default:
+		cn.nc.Close()
+	}
+	return nil
+}
+
+func (c *Client) conn(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+	dialer := net.Dialer{Timeout: c.config.Timeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.config.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("memcache: %w", err)
+	}
+	return &conn{nc: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}, nil
+}
+
+func (cn *conn) send(format string, args ...any) error {
+	if _, err := fmt.Fprintf(cn.rw, format, args...); err != nil {
+		return err
+	}
+	return cn.rw.Flush()
+}
+
+func (cn *conn) sendValue(cmd, key string, value []byte, ttl time.Duration) error {
+	fmt.Fprintf(cn.rw, "%s %s 0 %d %d\r\n", cmd, Key(key), exptime(ttl), len(value))
+	cn.rw.Write(value)
+	cn.rw.WriteString("\r\n")
+	return cn.rw.Flush()
+}
+
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.rw.ReadSlice('\n')
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(line, []byte("\r\n"))), nil
+}
+
+func (cn *conn) expect(want ...string) error {
+	line, err := cn.readLine()
+	if err != nil {
+		return err
+	}
+	for _, w := range want {
+		if line == w {
+			return nil
+		}
+	}
+	return errors.New("memcache: " + line)
+}
+
+// Key returns key unchanged when it is a valid memcached key, and a hash
+// of it when it is too long or contains whitespace or control characters
+func Key(key string) string {
+	valid := len(key) <= maxKeyLen
+	for i := 0; valid && i < len(key); i++ {
+		valid = key[i] > ' ' && key[i] != 0x7f
+	}
+	if valid {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// exptime converts ttl to memcached's expiry, rounding up to whole seconds
+func exptime(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0 // never expires
+	}
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if ttl > maxTTL {
+		return time.Now().Unix() + seconds
+	}
+	return seconds
+}
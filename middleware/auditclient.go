@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"idiomatic-go/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditClientMiddleware stores the caller's IP and user agent in the
+// request context, where audit.Entry picks them up
+func AuditClientMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithClient(c.Request.Context(), audit.Client{
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
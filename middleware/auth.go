@@ -1,57 +1,172 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"idiomatic-go/authctx"
 	customErrors "idiomatic-go/errors"
+	"idiomatic-go/revocation"
+	"idiomatic-go/timing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/sirupsen/logrus"
 )
 
+// Claims are the JWT claims. A full token carries the user ID and role. A
+// minimal token carries only the subject and token version, and holds
+// nothing about the user beyond its ID. Every token carries the token
+// version, which is checked per request, so a role change or sign-out
+// applies at once whatever the token holds.
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Role   string `json:"role"`
+	UserID       int64  `json:"user_id,omitempty"`
+	Role         string `json:"role,omitempty"`
+	TokenVersion int32  `json:"tv,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(logger *logrus.Logger, jwtSecret string) gin.HandlerFunc {
+// UserResolver looks up the current role and token version of a token's
+// user. It returns an error matching customErrors.ErrNotFound when the
+// user no longer exists.
+type UserResolver interface {
+	ResolveUser(ctx context.Context, userID int64) (role string, tokenVersion int32, err error)
+}
+
+var (
+	errInvalidToken  = customErrors.NewAPIError(http.StatusUnauthorized, customErrors.CodeInvalidToken, "Invalid token")
+	errInvalidClaims = customErrors.NewAPIError(http.StatusUnauthorized, customErrors.CodeInvalidClaims, "Invalid token claims")
+	errTokenRevoked  = customErrors.NewAPIError(http.StatusUnauthorized, customErrors.CodeTokenRevoked, "Token has been revoked")
+)
+
+// rejections are the failures RejectedTokens may cache, by code
+var rejections = map[customErrors.ErrorCode]*customErrors.APIError{
+	errInvalidToken.Code:  errInvalidToken,
+	errInvalidClaims.Code: errInvalidClaims,
+	errTokenRevoked.Code:  errTokenRevoked,
+}
+
+// Authenticator turns a bearer token into the user it was issued to. It
+// is shared by AuthMiddleware and the gRPC server.
+type Authenticator struct {
+	tokens  *TokenParser
+	users   UserResolver
+	revoked *revocation.Store
+}
+
+// NewAuthenticator returns an Authenticator. Every token is resolved
+// through users, and rejected when it is nil.
+func NewAuthenticator(tokens *TokenParser, users UserResolver, revoked *revocation.Store) *Authenticator {
+	return &Authenticator{tokens: tokens, users: users, revoked: revoked}
+}
+
+// Authenticate checks raw and returns its user. A token that is not
+// acceptable fails with an APIError; a failed lookup fails with
+// customErrors.ErrServiceUnavailable wrapping the cause.
+func (a *Authenticator) Authenticate(ctx context.Context, raw string) (authctx.User, error) {
+	claims, err := a.tokens.Parse(raw)
+	if err != nil {
+		return authctx.User{}, errInvalidToken
+	}
+	if claims.UserID <= 0 {
+		return authctx.User{}, errInvalidClaims
+	}
+
+	if claims.ID != "" {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		isRevoked, err := a.revoked.IsRevoked(ctx, claims.ID, issuedAt)
+		if err != nil {
+			return authctx.User{}, customErrors.ErrServiceUnavailable.Wrap(fmt.Errorf("check token revocation: %w", err))
+		}
+		if isRevoked {
+			return authctx.User{}, errTokenRevoked
+		}
+	}
+
+	if a.users == nil {
+		return authctx.User{}, errInvalidClaims
+	}
+	role, version, err := a.users.ResolveUser(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, customErrors.ErrNotFound) {
+			return authctx.User{}, customErrors.ErrUnauthorized
+		}
+		return authctx.User{}, customErrors.ErrServiceUnavailable.Wrap(fmt.Errorf("resolve token user: %w", err))
+	}
+	// Versions only move forward, so a stale token never heals. The role a
+	// full token carries is only a hint; the current one is used.
+	if version != claims.TokenVersion {
+		return authctx.User{}, errTokenRevoked
+	}
+
+	user := authctx.User{ID: claims.UserID, Role: role, TokenID: claims.ID}
+	if claims.ExpiresAt != nil {
+		user.TokenExpiresAt = claims.ExpiresAt.Time
+	}
+	return user, nil
+}
+
+// AuthMiddleware authenticates the bearer token. When rejected is not nil,
+// tokens it has seen fail are turned away before any parsing.
+func AuthMiddleware(logger *slog.Logger, auth *Authenticator, rejected *RejectedTokens) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		phase := timing.StartPhase(c.Request.Context(), timing.PhaseAuth)
+		defer phase.End()
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, customErrors.ErrUnauthorized)
-			c.Abort()
+			RenderError(c, customErrors.ErrUnauthorized)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, customErrors.NewAPIError(http.StatusUnauthorized, "invalid_auth_header", "Invalid authorization header format"))
-			c.Abort()
+			RenderError(c, customErrors.NewAPIError(http.StatusUnauthorized, customErrors.CodeInvalidAuthHeader, "Invalid authorization header format"))
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(parts[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, customErrors.NewAPIError(http.StatusUnauthorized, "invalid_token", "Invalid token"))
-			c.Abort()
-			return
+		raw := parts[1]
+		if rejected != nil {
+			if apiErr, ok := rejected.lookup(c.Request.Context(), raw); ok {
+				// Not recorded with c.Error: the first rejection was logged
+				writeError(c, apiErr)
+				c.Abort()
+				return
+			}
 		}
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, customErrors.NewAPIError(http.StatusUnauthorized, "invalid_claims", "Invalid token claims"))
-			c.Abort()
+		user, err := auth.Authenticate(c.Request.Context(), raw)
+		if err != nil {
+			apiErr, _ := customErrors.IsAPIError(err)
+			switch {
+			case rejections[apiErr.Code] != nil:
+				if rejected != nil {
+					rejected.remember(c.Request.Context(), raw, apiErr)
+				}
+				RenderError(c, apiErr)
+			case errors.Is(err, customErrors.ErrServiceUnavailable):
+				if abandoned(c, err) {
+					return
+				}
+				logger.ErrorContext(c.Request.Context(), "failed to authenticate token", "error", err)
+				RenderError(c, customErrors.ErrServiceUnavailable)
+			default:
+				// Not cached: a deleted user may be restored
+				RenderError(c, apiErr)
+			}
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("role", claims.Role)
+		ctx := authctx.WithUser(c.Request.Context(), user)
+		c.Request = c.Request.WithContext(ctx)
+		phase.End()
 		c.Next()
 	}
 }
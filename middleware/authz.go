@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"strings"
+
+	"idiomatic-go/authctx"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rule grants the authenticated user access to a request. Routes declare
+// their rules when they are registered and Authorize enforces them, so
+// handlers do not repeat access checks.
+type Rule struct {
+	name  string
+	allow func(c *gin.Context, user authctx.User) bool
+}
+
+func (r Rule) String() string {
+	return r.name
+}
+
+// Role grants access to users with one of roles
+func Role(roles ...string) Rule {
+	return Rule{
+		name: "role(" + strings.Join(roles, ",") + ")",
+		allow: func(_ *gin.Context, user authctx.User) bool {
+			for _, role := range roles {
+				if user.Role == role {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Owns grants access when the path parameter param, as resolved by
+// ResolveIDMiddleware, is the user's own ID, as for Owns("id") on
+// /users/:id
+func Owns(param string) Rule {
+	return Rule{
+		name: "owns(" + param + ")",
+		allow: func(c *gin.Context, user authctx.User) bool {
+			id, ok := ResolvedID(c.Request.Context(), param)
+			return ok && int64(id) == user.ID
+		},
+	}
+}
+
+// Authorize lets a request through if any of rules grants access and
+// rejects it with 403 otherwise, or 401 without an authenticated user. It
+// must run after AuthMiddleware.
+func Authorize(rules ...Rule) gin.HandlerFunc {
+	return AuthorizeOr(customErrors.ErrForbidden, rules...)
+}
+
+// AuthorizeOr is Authorize rejecting denied requests with denied instead
+// of 403. Answering 404 for other users' resources keeps callers from
+// learning which IDs exist.
+func AuthorizeOr(denied *customErrors.APIError, rules ...Rule) gin.HandlerFunc {
+	names := make([]string, len(rules))
+	for i, rule := range rules {
+		names[i] = rule.String()
+	}
+	return func(c *gin.Context) {
+		user, ok := authctx.UserFromContext(c.Request.Context())
+		if !ok {
+			RenderError(c, customErrors.ErrUnauthorized)
+			return
+		}
+
+		for _, rule := range rules {
+			if rule.allow(c, user) {
+				c.Next()
+				return
+			}
+		}
+
+		_ = RenderError(c, denied).SetMeta(gin.H{"authz_rules": names})
+	}
+}
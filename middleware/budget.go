@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var budgetExceeded = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_latency_budget_exceeded_total",
+		Help: "Requests that took longer than their route's latency budget",
+	},
+	[]string{"route"},
+)
+
+func init() {
+	prometheus.MustRegister(budgetExceeded)
+}
+
+// latencyBudgetKey holds the budget set by LatencyBudget in the gin context
+const latencyBudgetKey = "latency_budget"
+
+// LatencyBudget sets how long the route should take. LoggerMiddleware
+// warns about requests exceeding it, with the time they spent in the
+// database, cache and Redis.
+func LatencyBudget(budget time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(latencyBudgetKey, budget)
+		c.Next()
+	}
+}
+
+// latencyBudget returns the budget of the route serving c, if it has one
+func latencyBudget(c *gin.Context) (time.Duration, bool) {
+	v, ok := c.Get(latencyBudgetKey)
+	if !ok {
+		return 0, false
+	}
+	budget, ok := v.(time.Duration)
+	return budget, ok && budget > 0
+}
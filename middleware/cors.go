@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig holds configuration for CORSMiddleware
+type CORSConfig struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"), patterns
+	// with one wildcard ("https://*.example.com") or "*" for any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string // request headers browsers may send
+	ExposedHeaders []string // response headers scripts may read
+
+	// AllowCredentials lets browsers send cookies and HTTP auth. With "*"
+	// the request origin is echoed back, since browsers reject a wildcard
+	// together with credentials.
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers may cache a preflight response
+}
+
+// CORSMiddleware adds CORS headers for allowed origins and answers
+// preflight requests itself, so it must run before authentication and rate
+// limiting. Requests from other origins pass through without CORS headers
+// and are blocked by the browser.
+func CORSMiddleware(config CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		allowed, wildcard := matchOrigin(config.AllowedOrigins, origin)
+		if !allowed {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		if wildcard && !config.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", methods)
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if config.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// matchOrigin reports whether origin is allowed and whether it matched "*"
+func matchOrigin(allowed []string, origin string) (ok, wildcard bool) {
+	for _, pattern := range allowed {
+		if pattern == "*" {
+			return true, true
+		}
+		if strings.EqualFold(pattern, origin) {
+			return true, false
+		}
+		if prefix, suffix, found := strings.Cut(pattern, "*"); found &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true, false
+		}
+	}
+	return false, false
+}
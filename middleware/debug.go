@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"strings"
+
+	"idiomatic-go/debugmode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugMiddleware marks requests for forced trace sampling and debug
+// logging when they carry a valid X-Debug-Token or come from a user under
+// live debugging. It runs before tracing, so the bearer token is only
+// peeked at here; AuthMiddleware still performs the real authentication.
+// Tokens in rejected are not parsed at all; it may be nil.
+func DebugMiddleware(controller *debugmode.Controller, tokens *TokenParser, rejected *RejectedTokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		forced := false
+		if token := c.GetHeader(debugmode.TokenHeader); token != "" {
+			forced = controller.VerifyToken(token)
+		}
+		if !forced {
+			if userID, ok := peekUserID(c, tokens, rejected); ok {
+				forced = controller.UserEnabled(userID)
+			}
+		}
+
+		if forced {
+			c.Request = c.Request.WithContext(debugmode.WithForced(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
+
+// peekUserID returns the user ID of a validly signed bearer token that
+// has not recently been rejected
+func peekUserID(c *gin.Context, tokens *TokenParser, rejected *RejectedTokens) (int64, bool) {
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return 0, false
+	}
+	if rejected != nil && rejected.contains(c.Request.Context(), tokenString) {
+		return 0, false
+	}
+	claims, err := tokens.Parse(tokenString)
+	if err != nil {
+		return 0, false
+	}
+	return claims.UserID, true
+}
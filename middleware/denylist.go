@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"idiomatic-go/denylist"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DenylistMiddleware rejects clients whose IP is on the shared denylist.
+// Redis errors fail open: a denylist outage must not take the API down.
+func DenylistMiddleware(logger *slog.Logger, store *denylist.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		reason, denied, err := store.Reason(c.Request.Context(), ip)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "failed to check IP denylist", "error", err)
+			c.Next()
+			return
+		}
+		if denied {
+			logger.InfoContext(c.Request.Context(), "request from denied IP rejected",
+				"ip", ip,
+				"reason", reason,
+				"path", c.Request.URL.Path,
+			)
+			RenderError(c, customErrors.NewAPIError(http.StatusForbidden, customErrors.CodeIPDenied, "Access denied"))
+			return
+		}
+		c.Next()
+	}
+}
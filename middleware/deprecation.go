@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/deprecation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecatedMiddleware marks a route as deprecated by notice: responses
+// carry the Deprecation, Sunset and Link headers, and every call is logged
+// and counted per caller in tracker. Callers are identified by user ID
+// when authenticated, otherwise by IP, so declare it after AuthMiddleware
+// on authenticated routes.
+func DeprecatedMiddleware(logger *slog.Logger, tracker *deprecation.Tracker, notice deprecation.Notice) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		notice.SetHeaders(c.Writer.Header())
+		c.Next()
+
+		ctx := c.Request.Context()
+		route := c.Request.Method + " " + c.FullPath()
+		caller := "ip:" + c.ClientIP()
+		if userID, ok := authctx.UserID(ctx); ok {
+			caller = "user:" + strconv.FormatInt(userID, 10)
+		}
+		logger.InfoContext(ctx, "deprecated route called", "route", route, "caller", caller, "sunset", notice.Sunset)
+		// Count the call even if the request timed out
+		if err := tracker.Record(context.WithoutCancel(ctx), route, notice, caller); err != nil {
+			logger.WarnContext(ctx, "failed to record deprecated route usage", "error", err)
+		}
+	}
+}
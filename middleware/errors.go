@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"idiomatic-go/correlation"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenderError records err for the error logging middleware, writes it as
+// the JSON error envelope and aborts the chain. Anything that is not an
+// APIError is rendered as a generic 500 so internal details never reach the
+// client. The returned gin.Error can carry log metadata via SetMeta.
+func RenderError(c *gin.Context, err error) *gin.Error {
+	ginErr := c.Error(err)
+	writeError(c, err)
+	c.Abort()
+	return ginErr
+}
+
+// ErrorRenderingMiddleware renders the last error pushed via c.Error for
+// handlers that returned without writing a response
+func ErrorRenderingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if last := c.Errors.Last(); last != nil && !c.Writer.Written() {
+			writeError(c, last.Err)
+		}
+	}
+}
+
+func writeError(c *gin.Context, err error) {
+	apiErr, ok := customErrors.IsAPIError(err)
+	if !ok {
+		apiErr = customErrors.ErrInternalServerError
+	}
+	// A query cancelled by the request deadline surfaces as an internal
+	// error; the client should see that it took too long
+	if apiErr.StatusCode == http.StatusInternalServerError && timedOut(c) {
+		apiErr = customErrors.ErrGatewayTimeout
+	}
+	// Likewise when the client went away, though it will never read this
+	if apiErr.StatusCode == http.StatusInternalServerError && clientGone(c) {
+		apiErr = customErrors.ErrClientClosedRequest
+	}
+	apiErr = apiErr.WithRequestID(correlation.RequestID(c.Request.Context()))
+	apiErr.SetHeaders(c.Writer.Header())
+	c.JSON(apiErr.StatusCode, apiErr)
+}
+
+// abandoned renders err as a closed request if it failed because the
+// client went away, so middleware can skip logging a dependency failure
+// that did not happen
+func abandoned(c *gin.Context, err error) bool {
+	if !errors.Is(err, context.Canceled) || !clientGone(c) {
+		return false
+	}
+	RenderError(c, customErrors.ErrClientClosedRequest.Wrap(err))
+	return true
+}
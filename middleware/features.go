@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"idiomatic-go/features"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FeatureToggleMiddleware opts requests carrying a valid X-Feature-Toggles
+// token into the features it names and records them on the request span;
+// the logger's handler adds them to every log line of the request. It must
+// run after tracing so the span exists. Invalid tokens are logged and
+// otherwise ignored, leaving the request on the default code paths.
+func FeatureToggleMiddleware(signer *features.Signer, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.GetHeader(features.Header)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		names, ok := signer.Verify(token)
+		if !ok {
+			logger.WarnContext(ctx, "ignoring invalid feature toggle token")
+			c.Next()
+			return
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("feature.toggles", names))
+		c.Request = c.Request.WithContext(features.With(ctx, names))
+		c.Next()
+	}
+}
@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/correlation"
+	"idiomatic-go/jsontime"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FlightRecorderConfig holds configuration for the failed request recorder
+type FlightRecorderConfig struct {
+	Size       int         // Number of failed requests kept in memory
+	MinStatus  int         // Lowest status code recorded (e.g., 400)
+	SnippetLen int         // Maximum number of request body bytes kept
+	Clock      clock.Clock // Time source for entry timestamps
+}
+
+// RecordedRequest is a sanitized snapshot of a failed request
+type RecordedRequest struct {
+	Time        jsontime.Time `json:"time"`
+	Method      string        `json:"method"`
+	Path        string        `json:"path"`
+	Status      int           `json:"status"`
+	Latency     time.Duration `json:"latency_ns"`
+	BodySnippet string        `json:"body_snippet,omitempty"`
+	RequestID   string        `json:"request_id,omitempty"`
+	TraceID     string        `json:"trace_id,omitempty"`
+	Errors      []string      `json:"errors,omitempty"`
+}
+
+// FlightRecorder keeps the last N failed requests in a ring buffer so
+// intermittent production errors can be inspected without debug logging.
+type FlightRecorder struct {
+	config  FlightRecorderConfig
+	mu      sync.Mutex
+	entries []RecordedRequest
+	next    int
+	full    bool
+}
+
+func NewFlightRecorder(config FlightRecorderConfig) *FlightRecorder {
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+	return &FlightRecorder{
+		config:  config,
+		entries: make([]RecordedRequest, config.Size),
+	}
+}
+
+// sensitiveKeys are JSON fields whose values never reach the recorder
+var sensitiveKeys = []string{"password", "token", "secret", "authorization"}
+
+// Middleware captures requests that finish with a status >= MinStatus
+func (r *FlightRecorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r.config.Size <= 0 {
+			c.Next()
+			return
+		}
+
+		start := r.config.Clock.Now()
+		var snippet []byte
+		if c.Request.Body != nil && r.config.SnippetLen > 0 {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, int64(r.config.SnippetLen)))
+			if err == nil {
+				snippet = body
+				c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+			}
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < r.config.MinStatus {
+			return
+		}
+
+		entry := RecordedRequest{
+			Time:        jsontime.New(start),
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Status:      status,
+			Latency:     r.config.Clock.Now().Sub(start),
+			BodySnippet: sanitizeBody(snippet),
+			RequestID:   correlation.RequestID(c.Request.Context()),
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			entry.TraceID = sc.TraceID().String()
+		}
+		for _, err := range c.Errors {
+			entry.Errors = append(entry.Errors, err.Error())
+		}
+		r.add(entry)
+	}
+}
+
+func (r *FlightRecorder) add(entry RecordedRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot returns the recorded requests, newest first
+func (r *FlightRecorder) Snapshot() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.next
+	if r.full {
+		n = len(r.entries)
+	}
+	out := make([]RecordedRequest, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (r.next - i + len(r.entries)) % len(r.entries)
+		out = append(out, r.entries[idx])
+	}
+	return out
+}
+
+// Handler serves the recorded requests as JSON
+func (r *FlightRecorder) Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"requests": r.Snapshot()})
+}
+
+// sanitizeBody redacts sensitive fields from JSON bodies, at any depth.
+// Bodies that are not complete JSON (e.g. truncated) are dropped entirely,
+// since they cannot be redacted reliably.
+func sanitizeBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "[unparseable body omitted]"
+	}
+	sanitized, err := json.Marshal(redact(value))
+	if err != nil {
+		return ""
+	}
+	return string(sanitized)
+}
+
+// redact replaces the values of sensitive keys in objects nested anywhere
+// in value, in place
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveKey(key) {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redact(field)
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = redact(elem)
+		}
+	}
+	return value
+}
+
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(lower, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// readCloser pairs a replayed body reader with the original body's Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
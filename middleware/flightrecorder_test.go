@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "top level",
+			body: `{"username":"alice","password":"hunter2"}`,
+			want: `{"password":"[REDACTED]","username":"alice"}`,
+		},
+		{
+			name: "nested object",
+			body: `{"user":{"name":"alice","password":"hunter2","api_token":"t0k"}}`,
+			want: `{"user":{"api_token":"[REDACTED]","name":"alice","password":"[REDACTED]"}}`,
+		},
+		{
+			name: "objects in an array",
+			body: `{"credentials":[{"id":1,"secret":"s1"},{"id":2,"secret":"s2"}]}`,
+			want: `{"credentials":[{"id":1,"secret":"[REDACTED]"},{"id":2,"secret":"[REDACTED]"}]}`,
+		},
+		{
+			name: "top level array",
+			body: `[{"Authorization":"Bearer abc"},["x",{"new_password":"p"}]]`,
+			want: `[{"Authorization":"[REDACTED]"},["x",{"new_password":"[REDACTED]"}]]`,
+		},
+		{
+			name: "sensitive key holding an array",
+			body: `{"tokens":["a","b"]}`,
+			want: `{"tokens":"[REDACTED]"}`,
+		},
+		{
+			name: "truncated",
+			body: `{"user":{"password":"hun`,
+			want: "[unparseable body omitted]",
+		},
+		{
+			name: "empty",
+			body: "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeBody([]byte(tt.body))
+			if got != tt.want {
+				t.Errorf("sanitizeBody(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+			for _, secret := range []string{"hunter2", "t0k", "s1", "s2", "abc"} {
+				if strings.Contains(got, secret) {
+					t.Errorf("sanitizeBody(%s) leaked %q", tt.body, secret)
+				}
+			}
+		})
+	}
+}
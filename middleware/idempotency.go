@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"idiomatic-go/authctx"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyPrefix namespaces stored responses in Redis
+const IdempotencyKeyPrefix = "idempotency:"
+
+const (
+	// IdempotencyHeader names the client-chosen key identifying a request
+	// across retries
+	IdempotencyHeader = "Idempotency-Key"
+
+	// maxIdempotencyKeyLen bounds the key; a UUID is 36 characters
+	maxIdempotencyKeyLen = 255
+
+	// idempotencyLockTTL bounds how long a crashed replica can leave a key
+	// locked as in progress
+	idempotencyLockTTL = time.Minute
+)
+
+var (
+	errIdempotencyInProgress = customErrors.NewAPIError(http.StatusConflict, customErrors.CodeConflict,
+		"A request with this Idempotency-Key is still in progress").WithRetry(time.Second)
+	errIdempotencyKeyReused = customErrors.NewAPIError(http.StatusUnprocessableEntity, customErrors.CodeIdempotencyKeyReused,
+		"This Idempotency-Key was already used for a different request")
+	errIdempotencyKeyTooLong = customErrors.NewAPIError(http.StatusBadRequest, customErrors.CodeBadRequest,
+		"Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLen)+" characters")
+)
+
+// perRequestHeaders describe the request being answered rather than the
+// stored response, so a replay keeps the current request's values
+var perRequestHeaders = map[string]bool{
+	http.CanonicalHeaderKey(RequestIDHeader): true,
+	"Traceparent":                            true,
+	"Tracestate":                             true,
+	"Server-Timing":                          true,
+}
+
+// idempotentResponse is what is stored under a key: only the fingerprint
+// while the first request runs, then its response as well
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyMiddleware lets clients retry non-idempotent requests safely.
+// The first request with an Idempotency-Key runs normally and its response
+// is stored in Redis for ttl, keyed by the authenticated user and the key;
+// repeats get that response replayed with Idempotent-Replayed: true
+// instead of running again. Reusing a key for a different method, path or
+// body is rejected with 422, and a repeat arriving while the first request
+// still runs with a retryable 409. Server errors are not stored, so they
+// can be retried with the same key. If Redis is unavailable requests run
+// unprotected. It must run after AuthMiddleware.
+func IdempotencyMiddleware(logger *slog.Logger, rdb *redis.Client, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		idemKey := c.GetHeader(IdempotencyHeader)
+		if idemKey == "" {
+			c.Next()
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLen {
+			RenderError(c, errIdempotencyKeyTooLong)
+			return
+		}
+		userID, ok := authctx.UserID(c.Request.Context())
+		if !ok {
+			RenderError(c, customErrors.ErrUnauthorized)
+			return
+		}
+
+		ctx := c.Request.Context()
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			renderBodyError(c, err)
+			return
+		}
+		key := IdempotencyKeyPrefix + strconv.FormatInt(userID, 10) + ":" + idemKey
+
+		pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+		acquired, err := rdb.SetNX(ctx, key, pending, idempotencyLockTTL).Result()
+		if err != nil {
+			logger.WarnContext(ctx, "failed to claim idempotency key, running request unprotected", "error", err)
+			c.Next()
+			return
+		}
+		if !acquired {
+			replayIdempotent(c, rdb, key, fingerprint, logger)
+			return
+		}
+
+		// Storing and releasing must happen even if the request timed out
+		bg := context.WithoutCancel(ctx)
+		stored := false
+		defer func() {
+			// Release the key if the response was not stored, so the
+			// client's retry runs instead of waiting for the lock to expire
+			if !stored {
+				if err := rdb.Del(bg, key).Err(); err != nil {
+					logger.WarnContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		w := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if !w.Written() || w.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record, _ := json.Marshal(idempotentResponse{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      w.Status(),
+			Header:      w.Header().Clone(),
+			Body:        w.body.Bytes(),
+		})
+		if err := rdb.Set(bg, key, record, ttl).Err(); err != nil {
+			logger.WarnContext(ctx, "failed to store idempotent response", "error", err)
+			return
+		}
+		stored = true
+	}
+}
+
+// replayIdempotent answers a repeated key from the stored response
+func replayIdempotent(c *gin.Context, rdb *redis.Client, key, fingerprint string, logger *slog.Logger) {
+	ctx := c.Request.Context()
+	raw, err := rdb.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		// The first request failed and released the key just now
+		RenderError(c, errIdempotencyInProgress)
+		return
+	}
+	var prev idempotentResponse
+	if err == nil {
+		err = json.Unmarshal(raw, &prev)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "failed to read idempotent response", "error", err)
+		RenderError(c, customErrors.ErrServiceUnavailable.Wrap(err))
+		return
+	}
+
+	switch {
+	case prev.Fingerprint != fingerprint:
+		RenderError(c, errIdempotencyKeyReused)
+	case !prev.Done:
+		RenderError(c, errIdempotencyInProgress)
+	default:
+		header := c.Writer.Header()
+		for name, values := range prev.Header {
+			if !perRequestHeaders[http.CanonicalHeaderKey(name)] {
+				header[name] = values
+			}
+		}
+		header.Set("Idempotent-Replayed", "true")
+		c.Writer.WriteHeader(prev.Status)
+		_, _ = c.Writer.Write(prev.Body)
+		c.Abort()
+	}
+}
+
+// requestFingerprint hashes what makes a request the same request: its
+// method, path and body. The body is restored for the handler.
+func requestFingerprint(c *gin.Context) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.Path+"\n")
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// renderBodyError rejects a request whose body could not be read, most
+// likely because it exceeded the BodyLimitMiddleware limit
+func renderBodyError(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		RenderError(c, customErrors.ErrPayloadTooLarge.Wrap(err))
+		return
+	}
+	RenderError(c, customErrors.ErrBadRequest.Wrap(err))
+}
+
+// capturingWriter keeps a copy of the response body as it is written
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret = "test-secret"
+	testLeeway = 30 * time.Second
+)
+
+var testNow = time.Unix(1_700_000_000, 0)
+
+func testClaims(mutate func(*Claims)) *Claims {
+	claims := &Claims{
+		UserID: 42,
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(testNow.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(testNow.Add(time.Hour)),
+		},
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+	return claims
+}
+
+func signHS256(t *testing.T, claims *Claims) string {
+	t.Helper()
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// forgeHMAC builds a token whose header names alg but whose signature is an
+// HMAC-SHA256 over the secret, the shape of an algorithm confusion attack
+func forgeHMAC(t *testing.T, alg string, claims *Claims) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(signing))
+	return signing + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestTokenParser(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr bool
+	}{
+		{
+			name:  "valid HS256",
+			token: func(t *testing.T) string { return signHS256(t, testClaims(nil)) },
+		},
+		{
+			name: "expired within leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, testClaims(func(c *Claims) {
+					c.ExpiresAt = jwt.NewNumericDate(testNow.Add(-testLeeway / 2))
+				}))
+			},
+		},
+		{
+			name: "issued in the future within leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, testClaims(func(c *Claims) {
+					c.IssuedAt = jwt.NewNumericDate(testNow.Add(testLeeway / 2))
+				}))
+			},
+		},
+		{
+			name: "alg none",
+			token: func(t *testing.T) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name: "alg none with HS256 signature stripped",
+			token: func(t *testing.T) string {
+				parts := strings.Split(forgeHMAC(t, "none", testClaims(nil)), ".")
+				return parts[0] + "." + parts[1] + "."
+			},
+			wantErr: true,
+		},
+		{
+			name: "RS256",
+			token: func(t *testing.T) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims(nil)).SignedString(rsaKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name: "ES256",
+			token: func(t *testing.T) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodES256, testClaims(nil)).SignedString(ecKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name:    "RS256 header with HMAC signature",
+			token:   func(t *testing.T) string { return forgeHMAC(t, "RS256", testClaims(nil)) },
+			wantErr: true,
+		},
+		{
+			name:    "ES256 header with HMAC signature",
+			token:   func(t *testing.T) string { return forgeHMAC(t, "ES256", testClaims(nil)) },
+			wantErr: true,
+		},
+		{
+			name: "HS512 with the right secret",
+			token: func(t *testing.T) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodHS512, testClaims(nil)).SignedString([]byte(testSecret))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 with the wrong secret",
+			token: func(t *testing.T) string {
+				raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(nil)).SignedString([]byte("other-secret"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return raw
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing exp",
+			token: func(t *testing.T) string {
+				return signHS256(t, testClaims(func(c *Claims) { c.ExpiresAt = nil }))
+			},
+			wantErr: true,
+		},
+		{
+			name: "issued in the future beyond leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, testClaims(func(c *Claims) {
+					c.IssuedAt = jwt.NewNumericDate(testNow.Add(2 * testLeeway))
+				}))
+			},
+			wantErr: true,
+		},
+		{
+			name: "expired beyond leeway",
+			token: func(t *testing.T) string {
+				return signHS256(t, testClaims(func(c *Claims) {
+					c.ExpiresAt = jwt.NewNumericDate(testNow.Add(-2 * testLeeway))
+				}))
+			},
+			wantErr: true,
+		},
+	}
+
+	parser := NewTokenParser(testSecret, testLeeway, clock.NewMock(testNow))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parser.Parse(tt.token(t))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse accepted the token with claims %+v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims.UserID != 42 {
+				t.Errorf("UserID = %d, want 42", claims.UserID)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than maxBytes with a
+// 413. A declared Content-Length over the limit is refused before reading;
+// otherwise the body is cut off once the limit is read, which bindJSON
+// reports as the same 413. Paths with one of the exempt prefixes (file
+// uploads) are left to a limit of their own.
+func BodyLimitMiddleware(maxBytes int64, exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		if c.Request.ContentLength > maxBytes {
+			RenderError(c, customErrors.ErrPayloadTooLarge)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// TimeoutMiddleware gives every request a deadline of timeout from its
+// arrival. The deadline travels in the request context, so database
+// queries and outgoing calls made for the request are cancelled with it.
+// Handlers are not preempted: one that ignores its context finishes, but
+// its response still becomes a 504 if it did not write one in time or
+// failed because of the deadline. Paths with one of the exempt prefixes
+// (profiles, streamed exports, file transfers) run without a deadline.
+func TimeoutMiddleware(timeout time.Duration, exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			RenderError(c, customErrors.ErrGatewayTimeout)
+		}
+	}
+}
+
+// timedOut reports whether the request's deadline from TimeoutMiddleware
+// has passed
+func timedOut(c *gin.Context) bool {
+	return c.Request.Context().Err() == context.DeadlineExceeded
+}
+
+// clientGone reports whether the client closed the connection before the
+// response was written
+func clientGone(c *gin.Context) bool {
+	return c.Request.Context().Err() == context.Canceled
+}
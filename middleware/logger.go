@@ -1,29 +1,62 @@
 package middleware
 
 import (
+	"log/slog"
 	"time"
 
+	"idiomatic-go/debugmode"
+	"idiomatic-go/timing"
+
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
-func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
+// LoggerMiddleware logs every request once it has been served, with the
+// time spent per phase and layer. The request, trace and user IDs are
+// added by the logger's handler from the request context. Requests
+// exceeding their route's LatencyBudget are also logged as a warning.
+func LoggerMiddleware(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
 		method := c.Request.Method
+		ctx, breakdown := timing.WithBreakdown(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
 
 		c.Next()
 
 		latency := time.Since(start)
 		status := c.Writer.Status()
 
-		logger.WithFields(logrus.Fields{
-			"method":  method,
-			"path":    path,
-			"status":  status,
-			"latency": latency,
-			"ip":      c.ClientIP(),
-		}).Info("request processed")
+		attrs := []any{
+			"method", method,
+			"path", path,
+			"status", status,
+			"latency", latency,
+			"ip", c.ClientIP(),
+		}
+		if debugmode.Forced(c.Request.Context()) {
+			attrs = append(attrs,
+				"debug", true,
+				"query", c.Request.URL.RawQuery,
+				"user_agent", c.Request.UserAgent(),
+				"request_size", c.Request.ContentLength,
+				"response_size", c.Writer.Size(),
+				"errors", c.Errors.String(),
+			)
+		}
+		attrs = append(attrs, breakdown.Attrs()...)
+		logger.InfoContext(c.Request.Context(), "request processed", attrs...)
+
+		if budget, ok := latencyBudget(c); ok && latency > budget {
+			budgetExceeded.WithLabelValues(c.FullPath()).Inc()
+			attrs := append([]any{
+				"method", method,
+				"route", c.FullPath(),
+				"status", status,
+				"latency", latency,
+				"budget", budget,
+			}, breakdown.Attrs()...)
+			logger.WarnContext(c.Request.Context(), "request exceeded latency budget", attrs...)
+		}
 	}
 }
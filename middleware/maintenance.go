@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"strings"
+	"time"
+
+	customErrors "idiomatic-go/errors"
+	"idiomatic-go/flags"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMiddleware rejects requests with a retryable 503 while the
+// maintenance_mode flag is on. Paths with one of the exempt prefixes (health
+// checks, admin tooling) keep working so the flag can be turned off again.
+func MaintenanceMiddleware(store *flags.Store, exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !store.Bool(flags.MaintenanceMode) {
+			c.Next()
+			return
+		}
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		apiErr := customErrors.ErrServiceUnavailable.WithRetry(30 * time.Second)
+		apiErr.Message = "Service is under maintenance"
+		RenderError(c, apiErr)
+	}
+}
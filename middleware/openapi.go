@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	customErrors "idiomatic-go/errors"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIValidator checks requests against the Swagger spec generated from
+// the handlers' annotations, so the documented contract is the enforced
+// one. Object schemas are closed: a field the spec does not list is
+// rejected, like strict binding does. Routes missing from the spec are
+// let through unchecked.
+type OpenAPIValidator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewOpenAPIValidator loads spec, a Swagger 2.0 document such as the one
+// in docs/swagger.json. Its paths are matched below its basePath whatever
+// host and scheme it names.
+func NewOpenAPIValidator(spec []byte) (*OpenAPIValidator, error) {
+	var doc2 openapi2.T
+	if err := json.Unmarshal(spec, &doc2); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	doc, err := openapi2conv.ToV3(&doc2)
+	if err != nil {
+		return nil, fmt.Errorf("convert spec to OpenAPI 3: %w", err)
+	}
+	doc.Servers = openapi3.Servers{{URL: doc2.BasePath}}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("validate spec: %w", err)
+	}
+
+	closed := map[*openapi3.Schema]struct{}{}
+	for _, path := range doc.Paths.Map() {
+		for _, op := range path.Operations() {
+			if op.RequestBody == nil || op.RequestBody.Value == nil {
+				continue
+			}
+			for _, media := range op.RequestBody.Value.Content {
+				closeSchema(media.Schema, closed)
+			}
+		}
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("route spec: %w", err)
+	}
+	return &OpenAPIValidator{
+		router: router,
+		options: &openapi3filter.Options{
+			MultiError:          true,
+			SkipSettingDefaults: true,
+			// Authentication is AuthMiddleware's job, not the spec's
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}, nil
+}
+
+// closeSchema forbids properties that ref's object schemas do not declare,
+// unless they say what extra properties may hold
+func closeSchema(ref *openapi3.SchemaRef, seen map[*openapi3.Schema]struct{}) {
+	if ref == nil || ref.Value == nil {
+		return
+	}
+	s := ref.Value
+	if _, ok := seen[s]; ok {
+		return
+	}
+	seen[s] = struct{}{}
+
+	if len(s.Properties) > 0 && s.AdditionalProperties.Has == nil && s.AdditionalProperties.Schema == nil {
+		closed := false
+		s.AdditionalProperties.Has = &closed
+	}
+	for _, prop := range s.Properties {
+		closeSchema(prop, seen)
+	}
+	closeSchema(s.Items, seen)
+	closeSchema(s.AdditionalProperties.Schema, seen)
+}
+
+// Middleware rejects requests that do not match their documented operation
+// with a 400 listing every offending field
+func (v *OpenAPIValidator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, params, err := v.router.FindRoute(c.Request)
+		if err != nil {
+			c.Next() // undocumented, or a method the spec does not know
+			return
+		}
+		err = openapi3filter.ValidateRequest(c.Request.Context(), &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route:      route,
+			Options:    v.options,
+		})
+		if err != nil {
+			RenderError(c, specViolation(err))
+			return
+		}
+		c.Next()
+	}
+}
+
+// specViolation describes the failures in err as an APIError. Only
+// undeclared fields make it unknown_fields, as in renderBindError.
+func specViolation(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return customErrors.ErrPayloadTooLarge.Wrap(err)
+	}
+
+	var fields []customErrors.FieldError
+	unknownOnly := true
+	for _, reqErr := range requestErrors(err) {
+		var schemaErrs []*openapi3.SchemaError
+		collectSchemaErrors(reqErr.Err, &schemaErrs)
+		if len(schemaErrs) == 0 {
+			if reqErr.Parameter == nil {
+				// The body is missing, is not JSON or has the wrong
+				// content type; there is no field to blame
+				return customErrors.NewAPIError(http.StatusBadRequest, customErrors.CodeBadRequest, "Request does not match the API specification").Wrap(err)
+			}
+			fields = append(fields, customErrors.FieldError{Field: reqErr.Parameter.Name, Message: "is invalid"})
+			unknownOnly = false
+			continue
+		}
+		for _, se := range schemaErrs {
+			field, message, unknown := describeSchemaError(se)
+			if reqErr.Parameter != nil {
+				field = reqErr.Parameter.Name
+			}
+			fields = append(fields, customErrors.FieldError{Field: field, Message: message})
+			unknownOnly = unknownOnly && unknown
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+
+	if unknownOnly && len(fields) > 0 {
+		return customErrors.NewAPIError(http.StatusBadRequest, customErrors.CodeUnknownFields, "Unknown fields in request body").WithFields(fields).Wrap(err)
+	}
+	return customErrors.ErrValidation.WithFields(fields).Wrap(err)
+}
+
+// requestErrors flattens the errors ValidateRequest collects with
+// MultiError set
+func requestErrors(err error) []*openapi3filter.RequestError {
+	var me openapi3.MultiError
+	if errors.As(err, &me) {
+		var out []*openapi3filter.RequestError
+		for _, e := range me {
+			out = append(out, requestErrors(e)...)
+		}
+		return out
+	}
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		return []*openapi3filter.RequestError{reqErr}
+	}
+	return []*openapi3filter.RequestError{{Err: err}}
+}
+
+func collectSchemaErrors(err error, out *[]*openapi3.SchemaError) {
+	var me openapi3.MultiError
+	if errors.As(err, &me) {
+		for _, e := range me {
+			collectSchemaErrors(e, out)
+		}
+		return
+	}
+	var se *openapi3.SchemaError
+	if errors.As(err, &se) {
+		*out = append(*out, se)
+	}
+}
+
+// describeSchemaError names the field se is about, in the dotted form
+// validation errors use, and says what is wrong with it in client terms.
+// unknown is set for fields the schema does not declare.
+func describeSchemaError(se *openapi3.SchemaError) (field, message string, unknown bool) {
+	path := se.JSONPointer()
+	switch se.SchemaField {
+	case "required":
+		message = "is required"
+	case "properties":
+		// Reported on the object; the property is only named in the reason
+		if name, ok := quotedProperty(se.Reason); ok {
+			path = append(path, name)
+		}
+		message, unknown = "is not accepted by this endpoint", true
+	case "type":
+		message = "must be a " + strings.Join(se.Schema.Type.Slice(), " or ")
+	default:
+		message = se.Reason
+	}
+	return strings.Join(path, "."), message, unknown
+}
+
+// quotedProperty extracts the name from a reason such as
+// `property "foo" is unsupported`
+func quotedProperty(reason string) (string, bool) {
+	rest, ok := strings.CutPrefix(reason, "property ")
+	if !ok {
+		return "", false
+	}
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		return "", false
+	}
+	name, err := strconv.Unquote(quoted)
+	return name, err == nil
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+
+	"idiomatic-go/authctx"
+	"idiomatic-go/presence"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceMiddleware counts every authenticated request as activity. It
+// records it after the handler has run, since AuthMiddleware further down
+// the chain is what identifies the user, and a presence outage only costs
+// a log line.
+func PresenceMiddleware(logger *slog.Logger, tracker *presence.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		userID, ok := authctx.UserID(c.Request.Context())
+		if !ok {
+			return
+		}
+		// The client may already have gone, but the activity still happened
+		if err := tracker.Touch(context.WithoutCancel(c.Request.Context()), userID); err != nil {
+			logger.WarnContext(c.Request.Context(), "failed to record user activity", "error", err)
+		}
+	}
+}
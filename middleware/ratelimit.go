@@ -1,63 +1,107 @@
 package middleware
 
 import (
-	"context"
-	"net/http"
+	"log/slog"
 	"strconv"
 	"time"
 
+	"idiomatic-go/authctx"
+	"idiomatic-go/clock"
 	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/ratelimit"
+	"idiomatic-go/timing"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis_rate/v10"
-	"github.com/redis/go-redis/v9"
-	"github.com/sirupsen/logrus"
 )
 
 // RateLimiterConfig holds configuration for the rate limiter
 type RateLimiterConfig struct {
 	Rate   int           // Requests allowed per period
 	Period time.Duration // Time period (e.g., time.Minute)
+	Clock  clock.Clock   // Time source for reset headers; defaults to the system clock
+
+	// KeyPrefix separates the counters of limiters that apply to the same
+	// clients, e.g. a stricter limit on a route group
+	KeyPrefix string
+
+	// Routes overrides Rate and Period for single routes, keyed by method
+	// and route pattern, e.g. "POST /api/v1/login". Each overridden route
+	// is counted separately from the rest of the API.
+	Routes map[string]ratelimit.Limit
+
+	// PerUser counts requests per authenticated user instead of per client
+	// IP. The middleware must then run after AuthMiddleware; requests
+	// without a user are still counted by IP.
+	PerUser bool
+
+	ExemptIPs     []string // Client IPs or CIDR ranges that are never limited (e.g. monitoring probes)
+	ExemptAPIKeys []string // Values of the X-API-Key header that are never limited (internal services)
+	BypassSecret  string   // HMAC secret for signed X-RateLimit-Bypass tokens; empty disables them
 }
 
 // RateLimitMiddleware creates a rate limiter middleware
-func RateLimitMiddleware(logger *logrus.Logger, rdb *redis.Client, config RateLimiterConfig) gin.HandlerFunc {
-	limiter := redis_rate.NewLimiter(rdb)
+func RateLimitMiddleware(logger *slog.Logger, limiter ratelimit.Limiter, config RateLimiterConfig) gin.HandlerFunc {
+	if config.Clock == nil {
+		config.Clock = clock.New()
+	}
+	exempt := newExemptions(config)
 
 	return func(c *gin.Context) {
-		key := c.ClientIP()
+		phase := timing.StartPhase(c.Request.Context(), timing.PhaseRateLimit)
+		defer phase.End()
+
+		ip := c.ClientIP()
+
+		if reason, ok := exempt.match(c); ok {
+			logger.DebugContext(c.Request.Context(), "rate limit exemption applied",
+				"ip", ip,
+				"reason", reason,
+				"path", c.Request.URL.Path,
+			)
+			rateLimitExemptionsTotal.WithLabelValues(reason).Inc()
+			phase.End()
+			c.Next()
+			return
+		}
+
+		subject := ip
+		if config.PerUser {
+			if userID, ok := authctx.UserID(c.Request.Context()); ok {
+				subject = "user:" + strconv.FormatInt(userID, 10)
+			}
+		}
+		key := config.KeyPrefix + subject
+		limit := ratelimit.Limit{Rate: config.Rate, Period: config.Period}
+		if override, ok := config.Routes[c.Request.Method+" "+c.FullPath()]; ok {
+			key = config.KeyPrefix + "route:" + c.Request.Method + ":" + c.FullPath() + ":" + subject
+			limit = override
+		}
 
-		res, err := limiter.Allow(context.Background(), key, redis_rate.Limit{
-			Rate:   config.Rate,
-			Burst:  config.Rate,
-			Period: config.Period,
-		})
+		res, err := limiter.Allow(c.Request.Context(), key, limit)
 		if err != nil {
-			logger.WithError(err).Error("failed to check rate limit")
-			c.JSON(http.StatusInternalServerError, custom_errors.ErrInternalServerError)
-			c.Abort()
+			if abandoned(c, err) {
+				return
+			}
+			logger.ErrorContext(c.Request.Context(), "failed to check rate limit", "error", err)
+			RenderError(c, custom_errors.ErrServiceUnavailable.WithRetry(time.Second).Wrap(err))
 			return
 		}
 
-		if res.Allowed <= 0 {
-			logger.WithFields(logrus.Fields{
-				"ip":          key,
-				"retry_after": res.RetryAfter.Seconds(),
-			}).Warn("rate limit exceeded")
-			c.Header("Retry-After", res.RetryAfter.String())
-			c.JSON(http.StatusTooManyRequests, custom_errors.NewAPIError(
-				http.StatusTooManyRequests,
-				"rate_limit_exceeded",
-				"Too many requests",
-			))
-			c.Abort()
+		if !res.Allowed {
+			logger.WarnContext(c.Request.Context(), "rate limit exceeded",
+				"ip", ip,
+				"key", key,
+				"retry_after", res.RetryAfter.Seconds(),
+			)
+			RenderError(c, custom_errors.ErrTooManyRequests.WithRetry(res.RetryAfter))
 			return
 		}
 
-		c.Header("X-RateLimit-Limit", strconv.Itoa(config.Rate))
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Rate))
 		c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
-		c.Header("X-RateLimit-Reset", time.Now().Add(res.ResetAfter).Format(time.RFC1123))
+		c.Header("X-RateLimit-Reset", config.Clock.Now().Add(res.ResetAfter).Format(time.RFC1123))
 
+		phase.End()
 		c.Next()
 	}
 }
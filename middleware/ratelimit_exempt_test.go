@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBypassTokenExpires(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	exempt := newExemptions(RateLimiterConfig{BypassSecret: "bypass-secret", Clock: clk})
+	expires := clk.Now().Add(time.Hour)
+	token := SignBypassToken("bypass-secret", expires)
+
+	bypassed := func() bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set(BypassHeader, token)
+		reason, ok := exempt.match(c)
+		return ok && reason == "bypass_token"
+	}
+
+	clk.Set(expires.Add(-time.Second))
+	if !bypassed() {
+		t.Fatal("token rejected a second before it expires")
+	}
+	clk.Set(expires)
+	if bypassed() {
+		t.Fatal("token accepted once it expired")
+	}
+}
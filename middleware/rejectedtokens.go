@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"idiomatic-go/cache"
+	"idiomatic-go/clock"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var rejectedTokenLookups = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "auth_rejected_token_cache_lookups_total",
+		Help: "Bearer tokens looked up in the cache of recently rejected tokens, by result (hit or miss)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(rejectedTokenLookups)
+}
+
+// RejectedTokens remembers bearer tokens that recently failed
+// authentication, so a client retrying the same bad token is turned away
+// without parsing it, checking revocation or logging the failure again.
+// Only failures that cannot heal are cached: bad signatures, expiry,
+// unreadable claims and revocation. Entries live in process memory,
+// keyed by a hash of the token.
+type RejectedTokens struct {
+	store *cache.Memory
+	ttl   time.Duration
+}
+
+func NewRejectedTokens(maxEntries int, ttl time.Duration, clk clock.Clock) *RejectedTokens {
+	return &RejectedTokens{
+		store: cache.NewMemory(cache.MemoryConfig{MaxEntries: maxEntries, Clock: clk}),
+		ttl:   ttl,
+	}
+}
+
+// lookup returns the error token was last rejected with, if it is cached
+func (r *RejectedTokens) lookup(ctx context.Context, token string) (*customErrors.APIError, bool) {
+	code, found, _ := r.store.Get(ctx, tokenHash(token))
+	if apiErr, ok := rejections[customErrors.ErrorCode(code)]; found && ok {
+		rejectedTokenLookups.WithLabelValues("hit").Inc()
+		return apiErr, true
+	}
+	rejectedTokenLookups.WithLabelValues("miss").Inc()
+	return nil, false
+}
+
+// contains reports whether token is cached, without counting a lookup;
+// AuthMiddleware counts the one that decides the request
+func (r *RejectedTokens) contains(ctx context.Context, token string) bool {
+	_, found, _ := r.store.Get(ctx, tokenHash(token))
+	return found
+}
+
+func (r *RejectedTokens) remember(ctx context.Context, token string, apiErr *customErrors.APIError) {
+	_ = r.store.Set(ctx, tokenHash(token), []byte(apiErr.Code), r.ttl)
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"idiomatic-go/correlation"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLen = 128
+
+// RequestIDMiddleware reuses a well-formed incoming X-Request-ID or
+// generates one, stores it and the matched route in the request context,
+// records it on the active span and echoes it in the response. It must run after the tracing
+// middleware so the span exists.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = correlation.NewRequestID()
+		}
+
+		ctx := correlation.WithRequestID(c.Request.Context(), id)
+		if route := c.FullPath(); route != "" {
+			ctx = correlation.WithRoute(ctx, c.Request.Method+" "+route)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs made of characters that are safe to echo
+// in headers and log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"idiomatic-go/authctx"
+	customErrors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var numericIDRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_numeric_id_requests_total",
+		Help: "Requests addressing a resource by its deprecated numeric ID, by route and whether it was accepted",
+	},
+	[]string{"route", "accepted"},
+)
+
+func init() {
+	prometheus.MustRegister(numericIDRequests)
+}
+
+// IDResolver maps the external ID in a path to the resource's primary key
+type IDResolver func(ctx context.Context, externalID string) (int32, error)
+
+type resolvedIDKey string
+
+// ResolvedID returns the primary key ResolveIDMiddleware found for the
+// path parameter param
+func ResolvedID(ctx context.Context, param string) (int32, bool) {
+	id, ok := ctx.Value(resolvedIDKey(param)).(int32)
+	return id, ok
+}
+
+// ResolveIDMiddleware translates the opaque external ID in the path
+// parameter param into the primary key handlers and authorization rules
+// work with, answering 404 for unknown IDs. While numeric is set, the
+// primary key itself is still accepted, for clients that stored IDs
+// before external ones were introduced; such requests are counted and
+// answered with a Deprecation header. It must follow AuthMiddleware so
+// anonymous callers cannot probe IDs.
+func ResolveIDMiddleware(logger *slog.Logger, param string, resolve IDResolver, numeric bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ref := c.Param(param)
+		if ref == "" {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+
+		var id int32
+		if n, err := strconv.ParseInt(ref, 10, 32); err == nil {
+			numericIDRequests.WithLabelValues(c.FullPath(), strconv.FormatBool(numeric)).Inc()
+			if !numeric || n <= 0 {
+				RenderError(c, customErrors.ErrNotFound)
+				return
+			}
+			logger.DebugContext(ctx, "resource addressed by deprecated numeric ID", "route", c.FullPath())
+			c.Header("Deprecation", "true")
+			id = int32(n)
+		} else {
+			id, err = resolve(ctx, ref)
+			if err != nil {
+				RenderError(c, err)
+				return
+			}
+		}
+
+		c.Request = c.Request.WithContext(context.WithValue(ctx, resolvedIDKey(param), id))
+		c.Next()
+	}
+}
+
+// ResolveSelfMiddleware resolves the path parameter param to the caller,
+// for routes such as /users/me that address the user the token was issued
+// to. Handlers and rules reading ResolvedID then work unchanged. It must
+// follow AuthMiddleware.
+func ResolveSelfMiddleware(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := authctx.UserID(c.Request.Context())
+		if !ok {
+			RenderError(c, customErrors.ErrUnauthorized)
+			return
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), resolvedIDKey(param), int32(id)))
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	customErrors "idiomatic-go/errors"
+	"idiomatic-go/signer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SignedURLMiddleware rejects requests whose URL does not carry a valid,
+// unexpired signature from s
+func SignedURLMiddleware(s *signer.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := s.Verify(c.Request.Context(), c.Request.URL)
+		switch {
+		case err == nil:
+			c.Next()
+		case errors.Is(err, signer.ErrExpired):
+			RenderError(c, customErrors.NewAPIError(http.StatusGone, customErrors.CodeLinkExpired, "Link has expired").Wrap(err))
+		case errors.Is(err, signer.ErrAlreadyUsed):
+			RenderError(c, customErrors.NewAPIError(http.StatusGone, customErrors.CodeLinkUsed, "Link has already been used").Wrap(err))
+		case errors.Is(err, signer.ErrInvalidSignature), errors.Is(err, signer.ErrUnknownKey):
+			RenderError(c, customErrors.NewAPIError(http.StatusForbidden, customErrors.CodeInvalidSignature, "Invalid link signature").Wrap(err))
+		default:
+			RenderError(c, customErrors.ErrServiceUnavailable.Wrap(err))
+		}
+	}
+}
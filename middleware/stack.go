@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stage is a named position in the global middleware stack. Middleware run
+// in ascending stage order regardless of the order they are registered in,
+// which keeps the following constraints intact:
+//
+//   - StageRecovery is outermost so a panic in any later middleware is caught.
+//   - StageTracing precedes StageLogging so log lines can reference the span.
+//   - StageMetrics precedes StageRateLimit so rejected (429) requests are
+//     still counted and timed.
+//   - StageErrors runs last so it observes errors pushed by every handler.
+type Stage int
+
+const (
+	StageRecovery Stage = iota
+	StageRequestContext
+	StageTracing
+	StageLogging
+	StageMetrics
+	StageSecurity
+	StageRateLimit
+	StageErrors
+)
+
+var stageNames = map[Stage]string{
+	StageRecovery:       "recovery",
+	StageRequestContext: "request_context",
+	StageTracing:        "tracing",
+	StageLogging:        "logging",
+	StageMetrics:        "metrics",
+	StageSecurity:       "security",
+	StageRateLimit:      "rate_limit",
+	StageErrors:         "errors",
+}
+
+func (s Stage) String() string {
+	if name, ok := stageNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+type stackEntry struct {
+	stage   Stage
+	name    string
+	handler gin.HandlerFunc
+}
+
+// Stack collects middleware by stage and applies them in a deterministic order
+type Stack struct {
+	entries []stackEntry
+}
+
+// NewStack returns an empty Stack
+func NewStack() *Stack {
+	return &Stack{}
+}
+
+// Use registers handler under stage. Middleware sharing a stage keep their
+// registration order.
+func (s *Stack) Use(stage Stage, name string, handler gin.HandlerFunc) *Stack {
+	s.entries = append(s.entries, stackEntry{stage: stage, name: name, handler: handler})
+	return s
+}
+
+func (s *Stack) sorted() []stackEntry {
+	entries := make([]stackEntry, len(s.entries))
+	copy(entries, s.entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].stage < entries[j].stage
+	})
+	return entries
+}
+
+// Names returns the effective execution order as "stage/name" pairs
+func (s *Stack) Names() []string {
+	entries := s.sorted()
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.stage.String() + "/" + e.name
+	}
+	return names
+}
+
+// Handlers returns the middleware in execution order
+func (s *Stack) Handlers() []gin.HandlerFunc {
+	entries := s.sorted()
+	handlers := make([]gin.HandlerFunc, len(entries))
+	for i, e := range entries {
+		handlers[i] = e.handler
+	}
+	return handlers
+}
+
+// Apply installs the stack on r
+func (s *Stack) Apply(r gin.IRoutes) {
+	r.Use(s.Handlers()...)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// record returns a middleware that appends name to *order when it runs
+func record(order *[]string, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		*order = append(*order, name)
+		c.Next()
+	}
+}
+
+func TestStackOrder(t *testing.T) {
+	var order []string
+	stack := NewStack().
+		Use(StageErrors, "errors", record(&order, "errors")).
+		Use(StageRateLimit, "ratelimit", record(&order, "ratelimit")).
+		Use(StageLogging, "logger", record(&order, "logger")).
+		Use(StageMetrics, "metrics", record(&order, "metrics")).
+		Use(StageSecurity, "cors", record(&order, "cors")).
+		Use(StageTracing, "otel", record(&order, "otel")).
+		Use(StageSecurity, "botguard", record(&order, "botguard")).
+		Use(StageRequestContext, "request_id", record(&order, "request_id")).
+		Use(StageRecovery, "recovery", record(&order, "recovery")).
+		Use(StageSecurity, "denylist", record(&order, "denylist"))
+
+	wantNames := []string{
+		"recovery/recovery",
+		"request_context/request_id",
+		"tracing/otel",
+		"logging/logger",
+		"metrics/metrics",
+		"security/cors",
+		"security/botguard",
+		"security/denylist",
+		"rate_limit/ratelimit",
+		"errors/errors",
+	}
+	if got := stack.Names(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("Names() = %v, want %v", got, wantNames)
+	}
+
+	r := gin.New()
+	stack.Apply(r)
+	r.GET("/", func(c *gin.Context) {
+		order = append(order, "handler")
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	wantRun := []string{
+		"recovery", "request_id", "otel", "logger", "metrics",
+		"cors", "botguard", "denylist", "ratelimit", "errors", "handler",
+	}
+	if !reflect.DeepEqual(order, wantRun) {
+		t.Errorf("run order = %v, want %v", order, wantRun)
+	}
+}
+
+func TestStackKeepsRegistrationOrderWithinStage(t *testing.T) {
+	var order []string
+	stack := NewStack()
+	names := []string{"c", "a", "d", "b"}
+	for _, name := range names {
+		stack.Use(StageSecurity, name, record(&order, name))
+		// Interleave registrations in other stages so stability is exercised
+		// across a real sort, not just an already-ordered slice.
+		stack.Use(StageRecovery, "r-"+name, record(&order, "r-"+name))
+	}
+
+	r := gin.New()
+	stack.Apply(r)
+	r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"r-c", "r-a", "r-d", "r-b", "c", "a", "d", "b"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("run order = %v, want %v", order, want)
+	}
+}
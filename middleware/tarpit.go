@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// TarpitConfig holds configuration for the progressive auth-failure delay
+type TarpitConfig struct {
+	BaseDelay time.Duration // Delay after the first failure (e.g., 100ms)
+	MaxDelay  time.Duration // Upper bound for the delay (e.g., 2s)
+	Window    time.Duration // How long failures are remembered per IP
+}
+
+// TarpitKeyPrefix namespaces the per-IP failure counters in Redis. Each
+// IP has a hash of failure counts by the account they were against.
+const TarpitKeyPrefix = "tarpit:"
+
+// Gin context keys of the account a request's credentials are for, and
+// of a request whose credentials were accepted
+const (
+	tarpitAccountKey       = "tarpit_account"
+	tarpitAuthenticatedKey = "tarpit_authenticated"
+)
+
+// TarpitAccount names the account the request's credentials are for, so a
+// failure is counted against it. Handlers behind the tarpit call it once
+// they have read the credentials.
+func TarpitAccount(c *gin.Context, account string) {
+	sum := sha256.Sum256([]byte(strings.ToLower(account)))
+	c.Set(tarpitAccountKey, hex.EncodeToString(sum[:8]))
+}
+
+// MarkAuthenticated tells TarpitMiddleware that the request's credentials
+// were accepted, which clears the failures counted against the account
+// named with TarpitAccount. Failures against other accounts from the same
+// IP keep counting. Handlers call it once authentication succeeds.
+func MarkAuthenticated(c *gin.Context) {
+	c.Set(tarpitAuthenticatedKey, true)
+}
+
+// TarpitMiddleware slows down clients that keep failing authentication.
+// Every 401 from the wrapped handler doubles the delay applied to the next
+// attempt from the same IP, up to MaxDelay. Only a request the handler
+// marked with MarkAuthenticated resets anything, and only the failures
+// against its own account, so a client cannot clear the failures it
+// piled up against other accounts by logging into one it owns. The wait
+// is timer-based and abandoned as soon as the client goes away.
+func TarpitMiddleware(logger *slog.Logger, rdb *redis.Client, config TarpitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		key := TarpitKeyPrefix + c.ClientIP()
+
+		counts, err := rdb.HVals(ctx, key).Result()
+		if err != nil {
+			logger.WarnContext(ctx, "failed to read tarpit state", "error", err)
+		}
+		failures := 0
+		for _, count := range counts {
+			n, _ := strconv.Atoi(count)
+			failures += n
+		}
+
+		if delay := tarpitDelay(failures, config); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+
+		account := c.GetString(tarpitAccountKey)
+		switch {
+		case c.Writer.Status() == http.StatusUnauthorized:
+			recordTarpitFailure(ctx, rdb, key, account, config.Window, logger)
+		case c.GetBool(tarpitAuthenticatedKey) && failures > 0:
+			rdb.HDel(ctx, key, account)
+		}
+	}
+}
+
+// tarpitDelay returns BaseDelay doubled for each failure beyond the first
+func tarpitDelay(failures int, config TarpitConfig) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	delay := config.BaseDelay
+	for i := 1; i < failures && delay < config.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > config.MaxDelay {
+		delay = config.MaxDelay
+	}
+	return delay
+}
+
+func recordTarpitFailure(ctx context.Context, rdb *redis.Client, key, account string, window time.Duration, logger *slog.Logger) {
+	pipe := rdb.TxPipeline()
+	pipe.HIncrBy(ctx, key, account, 1)
+	pipe.Expire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.WarnContext(ctx, "failed to record tarpit failure", "error", err)
+	}
+}
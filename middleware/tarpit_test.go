@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTarpitResetsOnlyTheAuthenticatedAccount(t *testing.T) {
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	config := TarpitConfig{BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond, Window: time.Minute}
+
+	r := gin.New()
+	r.POST("/login", TarpitMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)), rdb, config), func(c *gin.Context) {
+		account := c.Query("account")
+		TarpitAccount(c, account)
+		if account != "mine@example.com" {
+			c.Status(http.StatusUnauthorized)
+			return
+		}
+		MarkAuthenticated(c)
+		c.Status(http.StatusOK)
+	})
+	login := func(account string) {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login?account="+account, nil))
+	}
+	failedAccounts := func() int {
+		counts, err := rdb.HVals(context.Background(), TarpitKeyPrefix+"192.0.2.1").Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(counts)
+	}
+
+	login("victim@example.com")
+	login("mine@example.com")
+	login("MINE@example.com")
+	if got := failedAccounts(); got != 2 {
+		t.Fatalf("%d accounts with failures, want the victim's and the mistyped one", got)
+	}
+	login("mine@example.com")
+	if got := failedAccounts(); got != 1 {
+		t.Fatalf("%d accounts with failures after logging in, want only the victim's", got)
+	}
+}
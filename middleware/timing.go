@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"idiomatic-go/timing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimingMiddleware times the serialization of responses and, with
+// serverTiming, sends the time spent per phase and layer in a
+// Server-Timing header. It must follow LoggerMiddleware, which starts the
+// breakdown.
+func TimingMiddleware(serverTiming bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		breakdown, ok := timing.FromContext(ctx)
+		if !ok {
+			c.Next()
+			return
+		}
+		w := &timingWriter{
+			ResponseWriter: c.Writer,
+			ctx:            ctx,
+			breakdown:      breakdown,
+			start:          time.Now(),
+			serverTiming:   serverTiming,
+		}
+		c.Writer = w
+		c.Next()
+		// Bodiless responses are written by gin after the last handler
+		w.send()
+	}
+}
+
+// timingWriter notices when a handler sets the status, which gin does just
+// before rendering the body, and when the first byte goes out. The time in
+// between is serialization; once the headers are sent nothing more can be
+// reported in them.
+type timingWriter struct {
+	gin.ResponseWriter
+	ctx          context.Context
+	breakdown    *timing.Breakdown
+	start        time.Time
+	serverTiming bool
+
+	rendering time.Time // when the status was set
+	sent      bool
+}
+
+func (w *timingWriter) WriteHeader(code int) {
+	if w.rendering.IsZero() {
+		w.rendering = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.send()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	w.send()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.send()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) Flush() {
+	w.send()
+	w.ResponseWriter.Flush()
+}
+
+// send records serialization and sets the Server-Timing header, once,
+// before the headers are written
+func (w *timingWriter) send() {
+	if w.sent || w.Written() {
+		return
+	}
+	w.sent = true
+	if !w.rendering.IsZero() {
+		timing.Since(w.ctx, timing.PhaseSerialize, w.rendering)
+	}
+	if w.serverTiming {
+		w.Header().Set("Server-Timing", w.breakdown.ServerTiming(time.Since(w.start)))
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"strings"
+
+	"idiomatic-go/ws"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebSocketTokenMiddleware lets browsers authenticate a WebSocket upgrade.
+// They cannot set headers on one, so they offer the access token as a
+// second subprotocol, "bearer, <token>", which is moved to the
+// Authorization header for AuthMiddleware. An Authorization header that
+// is already set wins.
+func WebSocketTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			protocols := strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",")
+			if len(protocols) == 2 && strings.TrimSpace(protocols[0]) == ws.Subprotocol {
+				c.Request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(protocols[1]))
+			}
+		}
+		c.Next()
+	}
+}
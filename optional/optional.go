@@ -0,0 +1,74 @@
+// Package optional distinguishes the three states a field can have in a
+// JSON Merge Patch (RFC 7396) document:
+//
+//   - absent:  the key is missing, the field must be left unchanged
+//   - null:    the key is present with a null value, the field must be cleared
+//   - value:   the key is present with a value, the field must be replaced
+//
+// PATCH request structs declare fields as Option[T] instead of *T, since a
+// pointer cannot tell "absent" and "null" apart.
+package optional
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Option holds a possibly absent, possibly null value of type T
+type Option[T any] struct {
+	value   T
+	present bool
+	null    bool
+}
+
+// Some returns an Option set to v
+func Some[T any](v T) Option[T] {
+	return Option[T]{value: v, present: true}
+}
+
+// Null returns an Option explicitly set to null
+func Null[T any]() Option[T] {
+	return Option[T]{present: true, null: true}
+}
+
+// IsSet reports whether the field was present, including as null
+func (o Option[T]) IsSet() bool {
+	return o.present
+}
+
+// IsNull reports whether the field was explicitly set to null
+func (o Option[T]) IsNull() bool {
+	return o.present && o.null
+}
+
+// Get returns the value and whether the field carries a non-null value
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.present && !o.null
+}
+
+// OrElse returns the value if one is set, otherwise fallback
+func (o Option[T]) OrElse(fallback T) T {
+	if v, ok := o.Get(); ok {
+		return v
+	}
+	return fallback
+}
+
+func (o *Option[T]) UnmarshalJSON(data []byte) error {
+	o.present = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		o.null = true
+		var zero T
+		o.value = zero
+		return nil
+	}
+	o.null = false
+	return json.Unmarshal(data, &o.value)
+}
+
+func (o Option[T]) MarshalJSON() ([]byte, error) {
+	if v, ok := o.Get(); ok {
+		return json.Marshal(v)
+	}
+	return []byte("null"), nil
+}
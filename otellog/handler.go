@@ -0,0 +1,262 @@
+// Package otellog ships log records to an OpenTelemetry collector over
+// OTLP/HTTP, so logs land next to the traces and metrics of the same
+// service and can be joined on trace and span IDs.
+package otellog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"idiomatic-go/otlpjson"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scopeName = "idiomatic-go/otellog"
+
+// Config controls batching and where logs are sent
+type Config struct {
+	Endpoint      string        // full URL of the collector's logs endpoint, e.g. http://localhost:4318/v1/logs
+	BatchSize     int           // records per export request
+	QueueSize     int           // records buffered before new ones are dropped
+	FlushInterval time.Duration // longest a record waits before being exported
+}
+
+// exporter queues records and exports them in batches from a background
+// goroutine; when the collector falls behind, new records are dropped
+// rather than blocking the caller. It is shared by a Handler and every
+// handler derived from it.
+type exporter struct {
+	config   Config
+	client   *http.Client
+	resource []otlpjson.KeyValue
+	queue    chan logRecord
+	done     chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+	closed  bool
+}
+
+// Handler is a slog.Handler that exports every record at or above its
+// level as an OTLP log record
+type Handler struct {
+	exp    *exporter
+	level  slog.Leveler
+	attrs  []otlpjson.KeyValue
+	prefix string // dotted path of the groups opened with WithGroup
+}
+
+// NewHandler starts a handler that exports records at or above level to
+// config.Endpoint with the attributes of res attached to every batch. Call
+// Shutdown to flush it.
+func NewHandler(res *resource.Resource, level slog.Leveler, config Config) *Handler {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.QueueSize < config.BatchSize {
+		config.QueueSize = 4 * config.BatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	exp := &exporter{
+		config:   config,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resource: otlpjson.Attributes(res.Attributes()),
+		queue:    make(chan logRecord, config.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go exp.run()
+	return &Handler{exp: exp, level: level}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	record := newLogRecord(ctx, r, h.attrs, h.prefix)
+
+	e := h.exp
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	select {
+	case e.queue <- record:
+	default:
+		e.dropped++
+	}
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = appendAttrs(slices.Clip(h.attrs), h.prefix, attrs)
+	return &next
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.prefix = h.prefix + name + "."
+	return &next
+}
+
+// Shutdown stops accepting records and exports whatever is still queued,
+// giving up when ctx is done
+func (h *Handler) Shutdown(ctx context.Context) error {
+	e := h.exp
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *exporter) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]logRecord, 0, h.config.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		// The handler cannot log its own failures through the logger
+		// without feeding them back into itself, so they go to stderr only
+		if err := h.export(batch); err != nil {
+			fmt.Fprintf(os.Stderr, "otellog: %v\n", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case record, ok := <-h.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= h.config.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		}
+	}
+}
+
+func (h *exporter) export(records []logRecord) error {
+	h.mu.Lock()
+	dropped := h.dropped
+	h.dropped = 0
+	h.mu.Unlock()
+
+	if dropped > 0 {
+		records = append(records, droppedRecord(dropped))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.client.Timeout)
+	defer cancel()
+	return otlpjson.Post(ctx, h.client, h.config.Endpoint, fmt.Sprintf("%d logs", len(records)), exportRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource:  otlpjson.Resource{Attributes: h.resource},
+			ScopeLogs: []scopeLogs{{Scope: otlpjson.Scope{Name: scopeName}, LogRecords: records}},
+		}},
+	})
+}
+
+func newLogRecord(ctx context.Context, r slog.Record, preset []otlpjson.KeyValue, prefix string) logRecord {
+	record := logRecord{
+		TimeUnixNano:         r.Time.UnixNano(),
+		ObservedTimeUnixNano: time.Now().UnixNano(),
+		SeverityNumber:       severity(r.Level),
+		SeverityText:         r.Level.String(),
+		Body:                 otlpjson.String(r.Message),
+	}
+
+	attrs := slices.Clone(preset)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttrs(attrs, prefix, []slog.Attr{a})
+		return true
+	})
+
+	// Prefer the span carried by the context; fall back to the trace_id
+	// attribute added by logging.ContextHandler
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.TraceID = sc.TraceID().String()
+		record.SpanID = sc.SpanID().String()
+	}
+	for _, kv := range attrs {
+		if kv.Key == "trace_id" || kv.Key == "span_id" {
+			if record.TraceID == "" && kv.Key == "trace_id" && kv.Value.StringValue != nil {
+				record.TraceID = *kv.Value.StringValue
+			}
+			continue
+		}
+		record.Attributes = append(record.Attributes, kv)
+	}
+	return record
+}
+
+// appendAttrs flattens attrs, naming nested groups with dotted keys
+func appendAttrs(kvs []otlpjson.KeyValue, prefix string, attrs []slog.Attr) []otlpjson.KeyValue {
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			p := prefix
+			if a.Key != "" {
+				p += a.Key + "."
+			}
+			kvs = appendAttrs(kvs, p, v.Group())
+			continue
+		}
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		kvs = append(kvs, otlpjson.KeyValue{Key: prefix + a.Key, Value: slogValue(v)})
+	}
+	return kvs
+}
+
+func droppedRecord(n int) logRecord {
+	msg := fmt.Sprintf("otellog: dropped %d log records because the export queue was full", n)
+	now := time.Now().UnixNano()
+	return logRecord{
+		TimeUnixNano:         now,
+		ObservedTimeUnixNano: now,
+		SeverityNumber:       severity(slog.LevelWarn),
+		SeverityText:         slog.LevelWarn.String(),
+		Body:                 otlpjson.String(msg),
+	}
+}
+
+// severity maps slog levels onto the OTLP severity numbers, which put
+// INFO at 9 and space the levels four apart just as slog does
+func severity(level slog.Level) int {
+	return min(max(int(level)+9, 1), 24)
+}
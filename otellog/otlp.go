@@ -0,0 +1,59 @@
+package otellog
+
+import (
+	"log/slog"
+	"time"
+
+	"idiomatic-go/otlpjson"
+)
+
+// The types below mirror the OTLP/JSON encoding of
+// opentelemetry.proto.collector.logs.v1.ExportLogsServiceRequest.
+
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  otlpjson.Resource `json:"resource"`
+	ScopeLogs []scopeLogs       `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      otlpjson.Scope `json:"scope"`
+	LogRecords []logRecord    `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         int64               `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano int64               `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int                 `json:"severityNumber"`
+	SeverityText         string              `json:"severityText"`
+	Body                 otlpjson.AnyValue   `json:"body"`
+	Attributes           []otlpjson.KeyValue `json:"attributes,omitempty"`
+	TraceID              string              `json:"traceId,omitempty"`
+	SpanID               string              `json:"spanId,omitempty"`
+}
+
+// slogValue converts a resolved log attribute value into an OTLP value
+func slogValue(v slog.Value) otlpjson.AnyValue {
+	switch v.Kind() {
+	case slog.KindString:
+		return otlpjson.String(v.String())
+	case slog.KindInt64:
+		return otlpjson.Int(v.Int64())
+	case slog.KindUint64:
+		return otlpjson.Int(int64(v.Uint64()))
+	case slog.KindFloat64:
+		f := v.Float64()
+		return otlpjson.AnyValue{DoubleValue: &f}
+	case slog.KindBool:
+		b := v.Bool()
+		return otlpjson.AnyValue{BoolValue: &b}
+	case slog.KindDuration:
+		return otlpjson.String(v.Duration().String())
+	case slog.KindTime:
+		return otlpjson.String(v.Time().Format(time.RFC3339Nano))
+	}
+	return otlpjson.Value(v.Any())
+}
@@ -0,0 +1,209 @@
+// Package oteltrace exports spans as OTLP/JSON, either over HTTP to an
+// OpenTelemetry collector or as JSON lines to a writer for local
+// debugging. Both implement sdktrace.SpanExporter, for use with
+// sdktrace.WithBatcher.
+package oteltrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"idiomatic-go/otlpjson"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// HTTPExporter sends spans to an OTLP/HTTP traces endpoint
+type HTTPExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPExporter exports to endpoint, the collector's full traces URL,
+// e.g. http://localhost:4318/v1/traces
+func NewHTTPExporter(endpoint string) *HTTPExporter {
+	return &HTTPExporter{endpoint: endpoint, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *HTTPExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	return otlpjson.Post(ctx, e.client, e.endpoint, fmt.Sprintf("%d spans", len(spans)), newExportRequest(spans))
+}
+
+func (e *HTTPExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// WriterExporter writes every span as one JSON object per line
+type WriterExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+func (e *WriterExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		if err := e.enc.Encode(newSpan(s)); err != nil {
+			return fmt.Errorf("write span: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *WriterExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// The types below mirror the OTLP/JSON encoding of
+// opentelemetry.proto.collector.trace.v1.ExportTraceServiceRequest.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   otlpjson.Resource `json:"resource"`
+	ScopeSpans []scopeSpans      `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope otlpjson.Scope `json:"scope"`
+	Spans []span         `json:"spans"`
+}
+
+type span struct {
+	TraceID                string              `json:"traceId"`
+	SpanID                 string              `json:"spanId"`
+	TraceState             string              `json:"traceState,omitempty"`
+	ParentSpanID           string              `json:"parentSpanId,omitempty"`
+	Name                   string              `json:"name"`
+	Kind                   int                 `json:"kind"`
+	StartTimeUnixNano      int64               `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano        int64               `json:"endTimeUnixNano,string"`
+	Attributes             []otlpjson.KeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int                 `json:"droppedAttributesCount,omitempty"`
+	Events                 []event             `json:"events,omitempty"`
+	DroppedEventsCount     int                 `json:"droppedEventsCount,omitempty"`
+	Links                  []link              `json:"links,omitempty"`
+	DroppedLinksCount      int                 `json:"droppedLinksCount,omitempty"`
+	Status                 status              `json:"status"`
+}
+
+type event struct {
+	TimeUnixNano int64               `json:"timeUnixNano,string"`
+	Name         string              `json:"name"`
+	Attributes   []otlpjson.KeyValue `json:"attributes,omitempty"`
+}
+
+type link struct {
+	TraceID    string              `json:"traceId"`
+	SpanID     string              `json:"spanId"`
+	TraceState string              `json:"traceState,omitempty"`
+	Attributes []otlpjson.KeyValue `json:"attributes,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// newExportRequest groups spans by resource and instrumentation scope, as
+// the collector expects. A tracer provider shares one resource between all
+// of its spans, so comparing pointers is enough.
+func newExportRequest(spans []sdktrace.ReadOnlySpan) exportRequest {
+	var req exportRequest
+	resources := make(map[*resource.Resource]int)
+	scopes := make(map[*resource.Resource]map[instrumentation.Scope]int)
+	for _, s := range spans {
+		resKey := s.Resource()
+		ri, ok := resources[resKey]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resources[resKey] = ri
+			scopes[resKey] = make(map[instrumentation.Scope]int)
+			req.ResourceSpans = append(req.ResourceSpans, resourceSpans{
+				Resource: otlpjson.Resource{Attributes: otlpjson.Attributes(s.Resource().Attributes())},
+			})
+		}
+		rs := &req.ResourceSpans[ri]
+
+		scope := s.InstrumentationScope()
+		si, ok := scopes[resKey][scope]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[resKey][scope] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, scopeSpans{Scope: otlpjson.Scope{Name: scope.Name, Version: scope.Version}})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, newSpan(s))
+	}
+	return req
+}
+
+func newSpan(s sdktrace.ReadOnlySpan) span {
+	sc := s.SpanContext()
+	out := span{
+		TraceID:                sc.TraceID().String(),
+		SpanID:                 sc.SpanID().String(),
+		TraceState:             sc.TraceState().String(),
+		Name:                   s.Name(),
+		Kind:                   int(s.SpanKind()), // the OTLP enum uses the same numbering
+		StartTimeUnixNano:      s.StartTime().UnixNano(),
+		EndTimeUnixNano:        s.EndTime().UnixNano(),
+		Attributes:             otlpjson.Attributes(s.Attributes()),
+		DroppedAttributesCount: s.DroppedAttributes(),
+		DroppedEventsCount:     s.DroppedEvents(),
+		DroppedLinksCount:      s.DroppedLinks(),
+		Status:                 newStatus(s.Status()),
+	}
+	if parent := s.Parent(); parent.HasSpanID() {
+		out.ParentSpanID = parent.SpanID().String()
+	}
+	for _, e := range s.Events() {
+		out.Events = append(out.Events, event{
+			TimeUnixNano: e.Time.UnixNano(),
+			Name:         e.Name,
+			Attributes:   otlpjson.Attributes(e.Attributes),
+		})
+	}
+	for _, l := range s.Links() {
+		out.Links = append(out.Links, link{
+			TraceID:    l.SpanContext.TraceID().String(),
+			SpanID:     l.SpanContext.SpanID().String(),
+			TraceState: l.SpanContext.TraceState().String(),
+			Attributes: otlpjson.Attributes(l.Attributes),
+		})
+	}
+	return out
+}
+
+// newStatus maps the SDK status onto OTLP's, which numbers OK and ERROR
+// the other way round
+func newStatus(s sdktrace.Status) status {
+	switch s.Code {
+	case codes.Ok:
+		return status{Code: 1}
+	case codes.Error:
+		return status{Code: 2, Message: s.Description}
+	}
+	return status{}
+}
+
+var (
+	_ sdktrace.SpanExporter = (*HTTPExporter)(nil)
+	_ sdktrace.SpanExporter = (*WriterExporter)(nil)
+)
@@ -0,0 +1,119 @@
+// Package otlpjson holds the parts of the OTLP/JSON encoding shared by the
+// log and trace exporters, and the HTTP request that sends a payload to an
+// OpenTelemetry collector. 64-bit integers are encoded as strings and
+// trace/span IDs as hex, as the specification requires.
+package otlpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// String returns s as an OTLP value
+func String(s string) AnyValue {
+	return AnyValue{StringValue: &s}
+}
+
+// Int returns i as an OTLP value
+func Int(i int64) AnyValue {
+	s := strconv.FormatInt(i, 10)
+	return AnyValue{IntValue: &s}
+}
+
+// Value converts a log attribute or resource attribute into an OTLP value.
+// Types without a direct OTLP counterpart are sent as their JSON encoding,
+// or their fmt representation when that fails.
+func Value(v any) AnyValue {
+	switch v := v.(type) {
+	case string:
+		return String(v)
+	case bool:
+		return AnyValue{BoolValue: &v}
+	case int:
+		return Int(int64(v))
+	case int32:
+		return Int(int64(v))
+	case int64:
+		return Int(v)
+	case uint32:
+		return Int(int64(v))
+	case float32:
+		f := float64(v)
+		return AnyValue{DoubleValue: &f}
+	case float64:
+		return AnyValue{DoubleValue: &v}
+	case time.Duration:
+		return String(v.String())
+	case error:
+		return String(v.Error())
+	case fmt.Stringer:
+		return String(v.String())
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return String(string(b))
+	}
+	return String(fmt.Sprint(v))
+}
+
+// Attributes converts OpenTelemetry attributes, such as those of a
+// resource or span
+func Attributes(attrs []attribute.KeyValue) []KeyValue {
+	kvs := make([]KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		kvs = append(kvs, KeyValue{Key: string(kv.Key), Value: Value(kv.Value.AsInterface())})
+	}
+	return kvs
+}
+
+// Post sends payload as JSON to the collector endpoint. what names the
+// payload in errors, e.g. "12 logs".
+func Post(ctx context.Context, client *http.Client, endpoint, what string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", what, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("export %s: collector returned %s", what, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+// Package parquet writes flat tables as Apache Parquet files, so exports
+// load straight into a data warehouse. It covers what the exports need:
+// required and optional columns of a few primitive types, PLAIN encoded
+// and Snappy compressed. Rows are buffered into row groups of
+// RowGroupSize and each group is written out once full, so memory stays
+// bounded however many rows an export holds.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// ContentType is the media type of a Parquet file
+const ContentType = "application/vnd.apache.parquet"
+
+// RowGroupSize is how many rows a row group holds
+const RowGroupSize = 10000
+
+var magic = []byte("PAR1")
+
+// Type is the type of a column's values
+type Type int
+
+const (
+	Boolean   Type = iota // bool
+	Int32                 // int32
+	Int64                 // int64
+	String                // string, stored as UTF-8
+	Timestamp             // time.Time, stored as microseconds since the Unix epoch in UTC
+)
+
+// Column describes one column of the table
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool // whether a value may be nil
+}
+
+// Physical types, repetitions, encodings and codecs from parquet.thrift
+const (
+	physicalBoolean   = 0
+	physicalInt32     = 1
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecSnappy = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	pageTypeData = 0
+)
+
+func (t Type) physical() int32 {
+	switch t {
+	case Boolean:
+		return physicalBoolean
+	case Int32:
+		return physicalInt32
+	case String:
+		return physicalByteArray
+	default:
+		return physicalInt64
+	}
+}
+
+// columnBuffer holds a column's values of the current row group
+type columnBuffer struct {
+	defined []bool // per row, for optional columns
+	bools   []bool // the values of a Boolean column
+	plain   []byte // the PLAIN encoding of the values of other columns
+}
+
+// columnChunk records where a column chunk was written, for the footer
+type columnChunk struct {
+	offset            int64
+	values            int64
+	uncompressedBytes int64
+	compressedBytes   int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	rows    int64
+}
+
+// Writer writes rows to a Parquet file. Close must be called to write the
+// footer, without which the file cannot be read.
+type Writer struct {
+	w         io.Writer
+	offset    int64
+	columns   []Column
+	buffers   []columnBuffer
+	rows      int
+	rowGroups []rowGroup
+	err       error
+}
+
+// NewWriter returns a Writer of a table with columns to w
+func NewWriter(w io.Writer, columns ...Column) *Writer {
+	return &Writer{w: w, columns: columns, buffers: make([]columnBuffer, len(columns))}
+}
+
+// Write adds a row, one value per column in the types listed on Type, or
+// nil for an optional column without a value
+func (w *Writer) Write(row ...any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.columns))
+	}
+	// Checked up front, so a bad row leaves no partial values behind
+	for i, col := range w.columns {
+		if err := col.check(row[i]); err != nil {
+			return err
+		}
+	}
+	for i, col := range w.columns {
+		buf := &w.buffers[i]
+		if col.Optional {
+			buf.defined = append(buf.defined, row[i] != nil)
+		}
+		switch v := row[i].(type) {
+		case nil:
+		case bool:
+			buf.bools = append(buf.bools, v)
+		case int32:
+			buf.plain = binary.LittleEndian.AppendUint32(buf.plain, uint32(v))
+		case int64:
+			buf.plain = binary.LittleEndian.AppendUint64(buf.plain, uint64(v))
+		case string:
+			buf.plain = binary.LittleEndian.AppendUint32(buf.plain, uint32(len(v)))
+			buf.plain = append(buf.plain, v...)
+		case time.Time:
+			buf.plain = binary.LittleEndian.AppendUint64(buf.plain, uint64(v.UnixMicro()))
+		}
+	}
+	w.rows++
+	if w.rows == RowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+func (c Column) check(v any) error {
+	var ok bool
+	switch v.(type) {
+	case nil:
+		ok = c.Optional
+	case bool:
+		ok = c.Type == Boolean
+	case int32:
+		ok = c.Type == Int32
+	case int64:
+		ok = c.Type == Int64
+	case string:
+		ok = c.Type == String
+	case time.Time:
+		ok = c.Type == Timestamp
+	}
+	if !ok {
+		return fmt.Errorf("parquet: column %s cannot hold %T", c.Name, v)
+	}
+	return nil
+}
+
+// Close writes the buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.rows > 0 || w.offset == 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	footer := w.footer()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	w.write(append(footer, magic...))
+	if w.err == nil {
+		w.err = errors.New("parquet: writer is closed")
+		return nil
+	}
+	return w.err
+}
+
+func (w *Writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// flush writes the buffered rows as a row group of one data page per
+// column, preceded by the file's magic number if they are the first
+func (w *Writer) flush() error {
+	if w.offset == 0 {
+		w.write(magic)
+	}
+	if w.rows == 0 {
+		return w.err
+	}
+	group := rowGroup{columns: make([]columnChunk, len(w.columns)), rows: int64(w.rows)}
+	for i, col := range w.columns {
+		buf := &w.buffers[i]
+		var page []byte
+		if col.Optional {
+			levels := encodeLevels(buf.defined)
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+			page = append(page, levels...)
+		}
+		if col.Type == Boolean {
+			page = appendBits(page, buf.bools)
+		} else {
+			page = append(page, buf.plain...)
+		}
+		compressed := snappy.Encode(nil, page)
+
+		header := newCompact()
+		header.i32(1, pageTypeData)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(compressed)))
+		header.structField(5)
+		header.i32(1, int32(w.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		headerBytes := header.bytes()
+
+		group.columns[i] = columnChunk{
+			offset:            w.offset,
+			values:            int64(w.rows),
+			uncompressedBytes: int64(len(headerBytes) + len(page)),
+			compressedBytes:   int64(len(headerBytes) + len(compressed)),
+		}
+		w.write(headerBytes)
+		w.write(compressed)
+		*buf = columnBuffer{defined: buf.defined[:0], bools: buf.bools[:0], plain: buf.plain[:0]}
+	}
+	w.rowGroups = append(w.rowGroups, group)
+	w.rows = 0
+	return w.err
+}
+
+// footer encodes the FileMetaData
+func (w *Writer) footer() []byte {
+	var rows int64
+	for _, g := range w.rowGroups {
+		rows += g.rows
+	}
+
+	c := newCompact()
+	c.i32(1, 1)
+	c.list(2, compactStruct, len(w.columns)+1)
+	c.begin()
+	c.string(4, "schema")
+	c.i32(5, int32(len(w.columns)))
+	c.end()
+	for _, col := range w.columns {
+		c.begin()
+		c.i32(1, col.Type.physical())
+		repetition := int32(repetitionRequired)
+		if col.Optional {
+			repetition = repetitionOptional
+		}
+		c.i32(3, repetition)
+		c.string(4, col.Name)
+		switch col.Type {
+		case String:
+			c.i32(6, convertedUTF8)
+			c.structField(10)
+			c.structField(1) // STRING
+			c.end()
+			c.end()
+		case Timestamp:
+			c.i32(6, convertedTimestampMicros)
+			c.structField(10)
+			c.structField(8) // TIMESTAMP
+			c.bool(1, true)  // isAdjustedToUTC
+			c.structField(2)
+			c.structField(2) // MICROS
+			c.end()
+			c.end()
+			c.end()
+			c.end()
+		}
+		c.end()
+	}
+	c.i64(3, rows)
+	c.list(4, compactStruct, len(w.rowGroups))
+	for _, g := range w.rowGroups {
+		c.begin()
+		c.list(1, compactStruct, len(g.columns))
+		var uncompressed, compressed int64
+		for i, chunk := range g.columns {
+			c.begin()
+			c.i64(2, chunk.offset)
+			c.structField(3)
+			c.i32(1, w.columns[i].Type.physical())
+			c.list(2, compactI32, 2)
+			c.i32Elem(encodingPlain)
+			c.i32Elem(encodingRLE)
+			c.list(3, compactBinary, 1)
+			c.stringElem(w.columns[i].Name)
+			c.i32(4, codecSnappy)
+			c.i64(5, chunk.values)
+			c.i64(6, chunk.uncompressedBytes)
+			c.i64(7, chunk.compressedBytes)
+			c.i64(9, chunk.offset)
+			c.end()
+			c.end()
+			uncompressed += chunk.uncompressedBytes
+			compressed += chunk.compressedBytes
+		}
+		c.i64(2, uncompressed)
+		c.i64(3, g.rows)
+		c.i64(5, g.columns[0].offset)
+		c.i64(6, compressed)
+		c.end()
+	}
+	c.string(6, "idiomatic-go")
+	return c.bytes()
+}
+
+// encodeLevels encodes definition levels of bit width 1 in the RLE
+// flavour of Parquet's RLE/bit-packing hybrid: each run of equal levels
+// is its length, shifted left once, followed by the level in a byte
+func encodeLevels(defined []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defined); {
+		j := i + 1
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defined[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// appendBits appends the PLAIN encoding of booleans: one bit each, least
+// significant first
+func appendBits(dst []byte, bools []bool) []byte {
+	packed := make([]byte, (len(bools)+7)/8)
+	for i, b := range bools {
+		if b {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(dst, packed...)
+}
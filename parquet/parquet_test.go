@@ -0,0 +1,270 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+var testColumns = []Column{
+	{Name: "id", Type: Int64},
+	{Name: "name", Type: String},
+	{Name: "active", Type: Boolean},
+	{Name: "rank", Type: Int32, Optional: true},
+	{Name: "deleted_at", Type: Timestamp, Optional: true},
+}
+
+func testRow(i int) []any {
+	row := []any{int64(i), fmt.Sprintf("user %d", i), i%3 == 0, nil, nil}
+	if i%2 == 0 {
+		row[3] = int32(-i)
+	}
+	if i%5 == 0 {
+		row[4] = time.Unix(1_700_000_000, int64(i)*1000).UTC()
+	}
+	return row
+}
+
+func TestWriter(t *testing.T) {
+	for _, n := range []int{0, 1, 7, RowGroupSize, RowGroupSize + 3} {
+		t.Run(fmt.Sprint(n, " rows"), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, testColumns...)
+			var want [][]any
+			for i := 0; i < n; i++ {
+				row := testRow(i)
+				if err := w.Write(row...); err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, row)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, groups := readFile(t, buf.Bytes())
+			if wantGroups := (n + RowGroupSize - 1) / RowGroupSize; groups != wantGroups {
+				t.Errorf("%d row groups, want %d", groups, wantGroups)
+			}
+			if len(got) != n {
+				t.Fatalf("read %d rows, want %d", len(got), n)
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Fatalf("row %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRejectsBadRows(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, testColumns...)
+	for _, row := range [][]any{
+		{int64(1), "a", true, nil},
+		{int32(1), "a", true, nil, nil},
+		{nil, "a", true, nil, nil},
+		{int64(1), "a", true, int64(2), nil},
+	} {
+		if err := w.Write(row...); err == nil {
+			t.Errorf("Write(%v) succeeded", row)
+		}
+	}
+	// Rejected rows leave nothing behind
+	if err := w.Write(testRow(2)...); err != nil {
+		t.Fatal(err)
+	}
+	if w.rows != 1 || len(w.buffers[3].defined) != 1 {
+		t.Fatalf("buffered %d rows, %d rank levels; want 1", w.rows, len(w.buffers[3].defined))
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriterReportsWriteErrors(t *testing.T) {
+	w := NewWriter(failingWriter{}, testColumns...)
+	if err := w.Write(testRow(1)...); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err == nil || err.Error() != "disk full" {
+		t.Fatalf("Close error = %v, want disk full", err)
+	}
+}
+
+// readFile decodes a file written for testColumns, returning its rows
+// and how many row groups held them
+func readFile(t *testing.T, file []byte) ([][]any, int) {
+	t.Helper()
+	if !bytes.HasPrefix(file, magic) || !bytes.HasSuffix(file, magic) {
+		t.Fatal("missing magic number")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLen
+	meta, _ := decodeStruct(t, file[footerStart:len(file)-8])
+
+	schema := meta[2].([]any)
+	if len(schema) != len(testColumns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(testColumns)+1)
+	}
+	for i, col := range testColumns {
+		el := schema[i+1].(map[int16]any)
+		if el[4] != col.Name || el[1] != int64(col.Type.physical()) {
+			t.Fatalf("schema element %d = %v, want %s", i+1, el, col.Name)
+		}
+	}
+
+	var rows [][]any
+	groups := meta[4].([]any)
+	for _, g := range groups {
+		group := g.(map[int16]any)
+		n := int(group[3].(int64))
+		groupRows := make([][]any, n)
+		for i := range groupRows {
+			groupRows[i] = make([]any, len(testColumns))
+		}
+		for c, chunk := range group[1].([]any) {
+			md := chunk.(map[int16]any)[3].(map[int16]any)
+			offset := int(md[9].(int64))
+			header, headerLen := decodeStruct(t, file[offset:])
+			compressed := file[offset+headerLen : offset+headerLen+int(header[3].(int64))]
+			page, err := snappy.Decode(nil, compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) != int(header[2].(int64)) {
+				t.Fatalf("page is %d bytes, header says %d", len(page), header[2])
+			}
+			for i, v := range decodePage(t, testColumns[c], page, n) {
+				groupRows[i][c] = v
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	if total := meta[3].(int64); total != int64(len(rows)) {
+		t.Fatalf("footer counts %d rows, groups hold %d", total, len(rows))
+	}
+	return rows, len(groups)
+}
+
+func decodePage(t *testing.T, col Column, page []byte, n int) []any {
+	t.Helper()
+	defined := make([]bool, n)
+	for i := range defined {
+		defined[i] = true
+	}
+	if col.Optional {
+		size := int(binary.LittleEndian.Uint32(page))
+		levels := page[4 : 4+size]
+		page = page[4+size:]
+		for i := 0; i < n; {
+			run, k := binary.Uvarint(levels)
+			if run&1 != 0 {
+				t.Fatal("bit-packed run in definition levels")
+			}
+			for j := 0; j < int(run>>1); j++ {
+				defined[i] = levels[k] == 1
+				i++
+			}
+			levels = levels[k+1:]
+		}
+	}
+
+	values := make([]any, n)
+	var bit int
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch col.Type {
+		case Boolean:
+			values[i] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case Int32:
+			values[i] = int32(binary.LittleEndian.Uint32(page))
+			page = page[4:]
+		case Int64:
+			values[i] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case Timestamp:
+			values[i] = time.UnixMicro(int64(binary.LittleEndian.Uint64(page))).UTC()
+			page = page[8:]
+		case String:
+			size := int(binary.LittleEndian.Uint32(page))
+			values[i] = string(page[4 : 4+size])
+			page = page[4+size:]
+		}
+	}
+	return values
+}
+
+// decodeStruct reads a Thrift compact struct into its fields by ID,
+// returning it and its encoded length. Integers decode as int64, binary
+// as string, lists as []any and structs as map[int16]any.
+func decodeStruct(t *testing.T, b []byte) (map[int16]any, int) {
+	t.Helper()
+	fields := make(map[int16]any)
+	var last int16
+	pos := 0
+	for {
+		h := b[pos]
+		pos++
+		if h == 0 {
+			return fields, pos
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			v, k := binary.Uvarint(b[pos:])
+			id = int16(unzigzag(v))
+			pos += k
+		}
+		last = id
+		var n int
+		fields[id], n = decodeValue(t, h&0x0f, b[pos:])
+		pos += n
+	}
+}
+
+func decodeValue(t *testing.T, typ byte, b []byte) (any, int) {
+	switch typ {
+	case compactTrue:
+		return true, 0
+	case compactFalse:
+		return false, 0
+	case compactI32, compactI64:
+		v, k := binary.Uvarint(b)
+		return unzigzag(v), k
+	case compactBinary:
+		size, k := binary.Uvarint(b)
+		return string(b[k : k+int(size)]), k + int(size)
+	case compactStruct:
+		return decodeStruct(t, b)
+	case compactList:
+		size, elem, pos := uint64(b[0]>>4), b[0]&0x0f, 1
+		if size == 15 {
+			var k int
+			size, k = binary.Uvarint(b[1:])
+			pos += k
+		}
+		list := make([]any, size)
+		for i := range list {
+			var n int
+			list[i], n = decodeValue(t, elem, b[pos:])
+			pos += n
+		}
+		return list, pos
+	}
+	t.Fatalf("unexpected compact type %d", typ)
+	return nil, 0
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,110 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type IDs
+const (
+	compactTrue   = 1
+	compactFalse  = 2
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compact encodes Thrift structs in the compact protocol, which Parquet
+// uses for page headers and the footer. Field IDs are written as deltas
+// from the previous field of the same struct, so every open struct
+// remembers its last ID.
+type compact struct {
+	buf  []byte
+	last []int16
+}
+
+// newCompact returns an encoder with the outermost struct open
+func newCompact() *compact {
+	return &compact{last: []int16{0}}
+}
+
+func (c *compact) field(id int16, typ byte) {
+	top := len(c.last) - 1
+	if delta := id - c.last[top]; delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|typ)
+	} else {
+		c.buf = append(c.buf, typ)
+		c.buf = binary.AppendUvarint(c.buf, zigzag(int64(id)))
+	}
+	c.last[top] = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, compactI32)
+	c.buf = binary.AppendUvarint(c.buf, zigzag(int64(v)))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, compactI64)
+	c.buf = binary.AppendUvarint(c.buf, zigzag(v))
+}
+
+func (c *compact) bool(id int16, v bool) {
+	if v {
+		c.field(id, compactTrue)
+	} else {
+		c.field(id, compactFalse)
+	}
+}
+
+func (c *compact) string(id int16, v string) {
+	c.field(id, compactBinary)
+	c.stringElem(v)
+}
+
+// structField opens a struct-valued field; end closes it
+func (c *compact) structField(id int16) {
+	c.field(id, compactStruct)
+	c.begin()
+}
+
+// begin opens a struct that is a list element
+func (c *compact) begin() {
+	c.last = append(c.last, 0)
+}
+
+// end writes the stop field of the innermost open struct
+func (c *compact) end() {
+	c.buf = append(c.buf, 0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+// list starts a list field of n elements of type elem, which follow as
+// i32Elem, stringElem or begin/end calls
+func (c *compact) list(id int16, elem byte, n int) {
+	c.field(id, compactList)
+	if n < 15 {
+		c.buf = append(c.buf, byte(n)<<4|elem)
+		return
+	}
+	c.buf = append(c.buf, 0xf0|elem)
+	c.buf = binary.AppendUvarint(c.buf, uint64(n))
+}
+
+func (c *compact) i32Elem(v int32) {
+	c.buf = binary.AppendUvarint(c.buf, zigzag(int64(v)))
+}
+
+func (c *compact) stringElem(v string) {
+	c.buf = binary.AppendUvarint(c.buf, uint64(len(v)))
+	c.buf = append(c.buf, v...)
+}
+
+// bytes closes the outermost struct and returns the encoding
+func (c *compact) bytes() []byte {
+	c.end()
+	return c.buf
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
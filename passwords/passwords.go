@@ -0,0 +1,107 @@
+// Package passwords hashes and checks passwords with bcrypt at a cost that
+// can be calibrated against the host at startup.
+package passwords
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	costGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "password_hash_cost",
+			Help: "bcrypt cost used for new password hashes",
+		},
+	)
+	durationGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "password_hash_duration_seconds",
+			Help: "Time one bcrypt hash took at the last startup calibration; 0 if calibration is off",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(costGauge, durationGauge)
+}
+
+// Hasher hashes passwords at a fixed bcrypt cost. Existing hashes are
+// checked at whatever cost they were made with.
+type Hasher struct {
+	cost  int
+	dummy []byte
+}
+
+// NewHasher returns a Hasher using cost, which must be between
+// bcrypt.MinCost and bcrypt.MaxCost
+func NewHasher(cost int) *Hasher {
+	// The error is only for an out-of-range cost, which config rejects
+	dummy, _ := bcrypt.GenerateFromPassword([]byte("not-a-real-password"), cost)
+	costGauge.Set(float64(cost))
+	return &Hasher{cost: cost, dummy: dummy}
+}
+
+// Cost returns the bcrypt cost of new hashes
+func (h *Hasher) Cost() int {
+	return h.cost
+}
+
+// Hash returns the bcrypt hash of password
+func (h *Hasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Compare returns nil if password matches hash
+func (h *Hasher) Compare(hash, password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// CompareDummy spends as long as Compare on a hash of the Hasher's own
+// cost, so that a login naming an unknown account takes as long as one
+// with a wrong password
+func (h *Hasher) CompareDummy(password string) {
+	_ = bcrypt.CompareHashAndPassword(h.dummy, []byte(password))
+}
+
+// Calibration is the result of timing bcrypt on this host
+type Calibration struct {
+	Cost      int           // the cost that was measured
+	Duration  time.Duration // median time of one hash at Cost
+	Suggested int           // the cost whose hash time is closest to the target
+}
+
+// calibrationRuns is how many hashes are timed; the median is used so a
+// single slow run during startup does not skew the result
+const calibrationRuns = 3
+
+// Calibrate times hashing at cost and suggests the cost closest to target.
+// Each step of cost doubles the work, so the suggestion is extrapolated
+// from the one measurement. It never suggests less than bcrypt.DefaultCost,
+// however slow the host. With a zero target the suggestion is cost itself.
+func Calibrate(cost int, target time.Duration) Calibration {
+	runs := make([]time.Duration, calibrationRuns)
+	for i := range runs {
+		start := time.Now()
+		_, _ = bcrypt.GenerateFromPassword([]byte("calibration"), cost)
+		runs[i] = time.Since(start)
+	}
+	slices.Sort(runs)
+	took := runs[len(runs)/2]
+	durationGauge.Set(took.Seconds())
+
+	c := Calibration{Cost: cost, Duration: took, Suggested: cost}
+	if target > 0 && took > 0 {
+		steps := int(math.Round(math.Log2(float64(target) / float64(took))))
+		c.Suggested = min(max(cost+steps, bcrypt.DefaultCost), bcrypt.MaxCost)
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+// Package presence records when users were last active, in Redis, so any
+// instance can tell whether a user is online
+package presence
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix namespaces presence keys in Redis
+const KeyPrefix = "presence:"
+
+const (
+	lastSeenPrefix = KeyPrefix + "user:"  // user ID -> last activity, in Unix milliseconds
+	onlineKey      = KeyPrefix + "online" // sorted set of user IDs scored by last activity, in Unix seconds
+)
+
+var usersOnline = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "users_online",
+		Help: "Users active within the presence online window at the last count",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(usersOnline)
+}
+
+// Config configures a Tracker
+type Config struct {
+	OnlineWindow time.Duration // users active within it are online
+	Retention    time.Duration // how long a last-seen time is kept
+	MinInterval  time.Duration // writes for one user are coalesced to one per interval per instance
+}
+
+// Tracker records user activity. Writes are coalesced per instance, so a
+// busy user costs at most one Redis round trip per MinInterval.
+type Tracker struct {
+	rdb    *redis.Client
+	clock  clock.Clock
+	config Config
+
+	mu          sync.Mutex
+	recent      map[int64]struct{} // users written since recentSince
+	recentSince time.Time
+}
+
+func NewTracker(rdb *redis.Client, clk clock.Clock, config Config) *Tracker {
+	if config.MinInterval <= 0 {
+		config.MinInterval = config.OnlineWindow / 10
+	}
+	return &Tracker{rdb: rdb, clock: clk, config: config, recent: make(map[int64]struct{})}
+}
+
+// Touch records that userID is active now
+func (t *Tracker) Touch(ctx context.Context, userID int64) error {
+	now := t.clock.Now()
+	if !t.due(userID, now) {
+		return nil
+	}
+
+	id := strconv.FormatInt(userID, 10)
+	pipe := t.rdb.TxPipeline()
+	pipe.Set(ctx, lastSeenPrefix+id, now.UnixMilli(), t.config.Retention)
+	pipe.ZAdd(ctx, onlineKey, redis.Z{Score: float64(now.Unix()), Member: id})
+	// Every member is stale once nobody has been active for a whole window
+	pipe.Expire(ctx, onlineKey, t.config.OnlineWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.forget(userID)
+		return err
+	}
+	return nil
+}
+
+// LastSeen returns when userID was last active, and false when that is
+// unknown or older than the retention period
+func (t *Tracker) LastSeen(ctx context.Context, userID int64) (time.Time, bool, error) {
+	ms, err := t.rdb.Get(ctx, lastSeenPrefix+strconv.FormatInt(userID, 10)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return time.UnixMilli(ms), true, nil
+}
+
+// Online reports whether a user last seen at lastSeen is still online
+func (t *Tracker) Online(lastSeen time.Time) bool {
+	return t.clock.Now().Sub(lastSeen) < t.config.OnlineWindow
+}
+
+// CountOnline drops users outside the online window from the online set
+// and returns, and records as users_online, how many remain
+func (t *Tracker) CountOnline(ctx context.Context) (int64, error) {
+	cutoff := t.clock.Now().Add(-t.config.OnlineWindow).Unix()
+	pipe := t.rdb.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, onlineKey, "-inf", "("+strconv.FormatInt(cutoff, 10))
+	count := pipe.ZCard(ctx, onlineKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	usersOnline.Set(float64(count.Val()))
+	return count.Val(), nil
+}
+
+// due reports whether userID should be written now. The set of recent
+// writes is reset every MinInterval rather than expired per user, so a
+// user is written at most twice per interval.
+func (t *Tracker) due(userID int64, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.recentSince) >= t.config.MinInterval {
+		t.recent = make(map[int64]struct{})
+		t.recentSince = now
+	}
+	if _, ok := t.recent[userID]; ok {
+		return false
+	}
+	t.recent[userID] = struct{}{}
+	return true
+}
+
+// forget lets the next Touch of userID retry a failed write
+func (t *Tracker) forget(userID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.recent, userID)
+}
@@ -0,0 +1,257 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	"idiomatic-go/memcache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// conformanceStart is aligned to the hour, so the fixed-window backends
+// start every test at the beginning of a window like GCRA starts with a
+// full burst
+var conformanceStart = time.Unix(1_700_000_000, 0).Truncate(time.Hour)
+
+// backend builds a Limiter whose notion of time follows clk
+type backend func(t *testing.T, clk *clock.Mock) Limiter
+
+// TestConformance runs the same checks against every backend. Postgres
+// needs a migrated database named by RATELIMIT_TEST_DATABASE_URL and is
+// skipped without one.
+func TestConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		new  backend
+	}{
+		{BackendMemory, func(t *testing.T, clk *clock.Mock) Limiter { return NewMemory(clk) }},
+		{BackendMemcached, newMemcachedBackend},
+		{BackendRedis, newRedisBackend},
+		{BackendPostgres, newPostgresBackend},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			for _, c := range conformanceChecks {
+				t.Run(c.name, func(t *testing.T) {
+					clk := clock.NewMock(conformanceStart)
+					limiter := b.new(t, clk)
+					// Keys are unique per run, so a reused database holds
+					// no counters from an earlier one
+					key := fmt.Sprintf("conformance:%d:%s", time.Now().UnixNano(), c.name)
+					c.run(t, limiter, clk, key)
+				})
+			}
+		})
+	}
+}
+
+var conformanceLimit = Limit{Rate: 5, Period: time.Minute}
+
+var conformanceChecks = []struct {
+	name string
+	run  func(t *testing.T, l Limiter, clk *clock.Mock, key string)
+}{
+	{"limits", checkLimits},
+	{"retry after", checkRetryAfter},
+	{"window reset", checkWindowReset},
+	{"key isolation", checkKeyIsolation},
+}
+
+func allow(t *testing.T, l Limiter, key string) Result {
+	t.Helper()
+	res, err := l.Allow(context.Background(), key, conformanceLimit)
+	if err != nil {
+		t.Fatalf("Allow(%q): %v", key, err)
+	}
+	return res
+}
+
+// exhaust spends the full limit of key, checking Remaining as it goes
+func exhaust(t *testing.T, l Limiter, key string) {
+	t.Helper()
+	for i := 1; i <= conformanceLimit.Rate; i++ {
+		res := allow(t, l, key)
+		if !res.Allowed {
+			t.Fatalf("request %d of %d denied: %+v", i, conformanceLimit.Rate, res)
+		}
+		if want := conformanceLimit.Rate - i; res.Remaining != want {
+			t.Errorf("request %d: Remaining = %d, want %d", i, res.Remaining, want)
+		}
+		if res.ResetAfter <= 0 || res.ResetAfter > conformanceLimit.Period {
+			t.Errorf("request %d: ResetAfter = %v, want within (0, %v]", i, res.ResetAfter, conformanceLimit.Period)
+		}
+	}
+}
+
+func checkLimits(t *testing.T, l Limiter, clk *clock.Mock, key string) {
+	exhaust(t, l, key)
+	for i := 0; i < 3; i++ {
+		if res := allow(t, l, key); res.Allowed {
+			t.Fatalf("request %d over the limit allowed: %+v", conformanceLimit.Rate+i+1, res)
+		}
+	}
+}
+
+func checkRetryAfter(t *testing.T, l Limiter, clk *clock.Mock, key string) {
+	exhaust(t, l, key)
+	res := allow(t, l, key)
+	if res.Allowed {
+		t.Fatalf("request over the limit allowed: %+v", res)
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > conformanceLimit.Period {
+		t.Fatalf("RetryAfter = %v, want within (0, %v]", res.RetryAfter, conformanceLimit.Period)
+	}
+
+	clk.Advance(res.RetryAfter - time.Second)
+	if res := allow(t, l, key); res.Allowed {
+		t.Errorf("allowed a second before RetryAfter elapsed: %+v", res)
+	}
+	clk.Advance(time.Second)
+	if res := allow(t, l, key); !res.Allowed {
+		t.Errorf("denied once RetryAfter elapsed: %+v", res)
+	}
+}
+
+func checkWindowReset(t *testing.T, l Limiter, clk *clock.Mock, key string) {
+	exhaust(t, l, key)
+	clk.Advance(conformanceLimit.Period)
+	exhaust(t, l, key)
+}
+
+func checkKeyIsolation(t *testing.T, l Limiter, clk *clock.Mock, key string) {
+	exhaust(t, l, key+":a")
+	if res := allow(t, l, key+":a"); res.Allowed {
+		t.Fatalf("request over the limit allowed: %+v", res)
+	}
+	exhaust(t, l, key+":b")
+}
+
+// clockedRedis moves miniredis's clock, which the redis_rate script reads
+// with TIME, to the mock clock before every call
+type clockedRedis struct {
+	*Redis
+	server *miniredis.Miniredis
+	clock  clock.Clock
+}
+
+func (r clockedRedis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	r.server.SetTime(r.clock.Now())
+	return r.Redis.Allow(ctx, key, limit)
+}
+
+func newRedisBackend(t *testing.T, clk *clock.Mock) Limiter {
+	server := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return clockedRedis{Redis: NewRedis(rdb), server: server, clock: clk}
+}
+
+func newMemcachedBackend(t *testing.T, clk *clock.Mock) Limiter {
+	addr := runFakeMemcached(t)
+	return NewMemcached(memcache.New(memcache.Config{Addr: addr, Timeout: time.Second}), clk)
+}
+
+func newPostgresBackend(t *testing.T, clk *clock.Mock) Limiter {
+	dsn := os.Getenv("RATELIMIT_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("RATELIMIT_TEST_DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return NewPostgres(database.New(pool), clk)
+}
+
+// runFakeMemcached serves the get, add, set and incr commands of the
+// memcached text protocol from a map. Expiry is ignored: the limiter puts
+// the window in its keys, so stale counters are never read again.
+func runFakeMemcached(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	items := make(map[string][]byte)
+	serve := func(nc net.Conn) {
+		defer nc.Close()
+		rw := bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				fmt.Fprint(rw, "ERROR\r\n")
+				rw.Flush()
+				continue
+			}
+
+			mu.Lock()
+			switch cmd, key := fields[0], fields[1]; cmd {
+			case "get":
+				if value, ok := items[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d\r\n%s\r\n", key, len(value), value)
+				}
+				fmt.Fprint(rw, "END\r\n")
+			case "add", "set":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				value := make([]byte, size+2)
+				if _, err := io.ReadFull(rw, value); err != nil {
+					mu.Unlock()
+					return
+				}
+				if _, exists := items[key]; cmd == "add" && exists {
+					fmt.Fprint(rw, "NOT_STORED\r\n")
+					break
+				}
+				items[key] = value[:size]
+				fmt.Fprint(rw, "STORED\r\n")
+			case "incr":
+				value, ok := items[key]
+				if !ok {
+					fmt.Fprint(rw, "NOT_FOUND\r\n")
+					break
+				}
+				n, _ := strconv.ParseUint(string(value), 10, 64)
+				delta, _ := strconv.ParseUint(fields[2], 10, 64)
+				items[key] = []byte(strconv.FormatUint(n+delta, 10))
+				fmt.Fprintf(rw, "%s\r\n", items[key])
+			default:
+				fmt.Fprint(rw, "ERROR\r\n")
+			}
+			mu.Unlock()
+			rw.Flush()
+		}
+	}
+
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(nc)
+		}
+	}()
+	return ln.Addr().String()
+}
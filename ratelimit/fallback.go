@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	fallbackActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rate_limit_fallback_active",
+			Help: "1 while rate limits are enforced by the per-replica fallback because the shared backend is failing",
+		},
+	)
+	backendErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rate_limit_backend_errors_total",
+			Help: "Failed calls to the shared rate limit backend",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(fallbackActive, backendErrors)
+}
+
+// BreakerConfig controls when Fallback gives up on the shared backend
+type BreakerConfig struct {
+	Threshold int           // consecutive failures that open the breaker
+	Cooldown  time.Duration // how long the breaker stays open before a probe
+	Timeout   time.Duration // bound on each call to the shared backend
+}
+
+// Fallback limits through a shared backend and, while that backend is
+// failing, through a local one instead, so an outage loosens rate limits
+// rather than rejecting every request. Local limits are counted per
+// replica, so the effective limit rises with the replica count.
+//
+// After Threshold consecutive failures the breaker opens and the shared
+// backend is not called for Cooldown, so requests do not each wait for a
+// timeout. Then a single probe request tries it again: success closes the
+// breaker, failure reopens it.
+type Fallback struct {
+	shared Limiter
+	local  Limiter
+	logger *slog.Logger
+	clock  clock.Clock
+	config BreakerConfig
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // zero while closed
+	probing   bool
+}
+
+func NewFallback(shared, local Limiter, logger *slog.Logger, clk clock.Clock, config BreakerConfig) *Fallback {
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+	return &Fallback{shared: shared, local: local, logger: logger, clock: clk, config: config}
+}
+
+func (f *Fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	if !f.useShared() {
+		return f.local.Allow(ctx, key, limit)
+	}
+
+	sharedCtx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+	res, err := f.shared.Allow(sharedCtx, key, limit)
+	if ctx.Err() != nil {
+		// The caller gave up, which says nothing about the backend
+		f.abandon()
+		return Result{}, ctx.Err()
+	}
+	f.record(err)
+	if err != nil {
+		return f.local.Allow(ctx, key, limit)
+	}
+	return res, nil
+}
+
+// useShared reports whether this call should go to the shared backend:
+// always while the breaker is closed, and for one probe at a time once the
+// cooldown of an open breaker has passed
+func (f *Fallback) useShared() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.openUntil.IsZero() {
+		return true
+	}
+	if f.probing || f.clock.Now().Before(f.openUntil) {
+		return false
+	}
+	f.probing = true
+	return true
+}
+
+// abandon ends a call whose outcome is not recorded, letting another
+// request probe the backend if this one was the probe
+func (f *Fallback) abandon() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing = false
+}
+
+func (f *Fallback) record(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wasProbe := f.probing
+	f.probing = false
+
+	if err == nil {
+		if !f.openUntil.IsZero() {
+			f.logger.Info("rate limit backend recovered, leaving per-replica fallback")
+			fallbackActive.Set(0)
+		}
+		f.failures = 0
+		f.openUntil = time.Time{}
+		return
+	}
+
+	backendErrors.Inc()
+	f.failures++
+	if wasProbe || f.failures >= f.config.Threshold {
+		if f.openUntil.IsZero() {
+			f.logger.Error("rate limit backend failing, falling back to per-replica limits", "error", err, "failures", f.failures)
+			fallbackActive.Set(1)
+		}
+		f.openUntil = f.clock.Now().Add(f.config.Cooldown)
+	}
+}
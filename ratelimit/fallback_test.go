@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+)
+
+// stubLimiter answers every call with err, or allows it when err is nil.
+// It waits for the context first when block is set, like a slow backend.
+type stubLimiter struct {
+	err   error
+	block bool
+	calls int
+}
+
+func (s *stubLimiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.calls++
+	if s.block {
+		<-ctx.Done()
+		return Result{}, ctx.Err()
+	}
+	if s.err != nil {
+		return Result{}, s.err
+	}
+	return Result{Allowed: true, Remaining: limit.Rate - 1}, nil
+}
+
+func newTestFallback(shared Limiter, clk clock.Clock) *Fallback {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewFallback(shared, NewMemory(clk), logger, clk, BreakerConfig{Threshold: 2, Cooldown: time.Minute, Timeout: time.Second})
+}
+
+func TestFallbackOpensAfterThreshold(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	shared := &stubLimiter{err: errors.New("connection refused")}
+	f := newTestFallback(shared, clk)
+	limit := Limit{Rate: 10, Period: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		res, err := f.Allow(context.Background(), "k", limit)
+		if err != nil || !res.Allowed {
+			t.Fatalf("call %d: got %+v, %v; want the local backend to allow it", i, res, err)
+		}
+	}
+	if shared.calls != 2 {
+		t.Errorf("shared backend called %d times, want 2 before the breaker opened", shared.calls)
+	}
+
+	clk.Advance(time.Minute)
+	shared.err = nil
+	if _, err := f.Allow(context.Background(), "k", limit); err != nil {
+		t.Fatal(err)
+	}
+	if shared.calls != 3 {
+		t.Errorf("shared backend called %d times, want a probe after the cooldown", shared.calls)
+	}
+}
+
+func TestFallbackIgnoresCancelledCallers(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	shared := &stubLimiter{block: true}
+	f := newTestFallback(shared, clk)
+	limit := Limit{Rate: 10, Period: time.Minute}
+
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := f.Allow(ctx, "k", limit); !errors.Is(err, context.Canceled) {
+			t.Fatalf("call %d: got error %v, want context.Canceled", i, err)
+		}
+	}
+
+	shared.block = false
+	if _, err := f.Allow(context.Background(), "k", limit); err != nil {
+		t.Fatal(err)
+	}
+	if shared.calls != 6 {
+		t.Errorf("shared backend called %d times, want 6: abandoned calls must not open the breaker", shared.calls)
+	}
+}
+
+func TestFallbackCancelledProbeLetsAnotherProbe(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	shared := &stubLimiter{err: errors.New("connection refused")}
+	f := newTestFallback(shared, clk)
+	limit := Limit{Rate: 10, Period: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		f.Allow(context.Background(), "k", limit)
+	}
+	clk.Advance(time.Minute)
+
+	// The probe's caller goes away before the backend answers
+	shared.err, shared.block = nil, true
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Allow(ctx, "k", limit)
+
+	shared.block = false
+	f.Allow(context.Background(), "k", limit)
+	if shared.calls != 4 {
+		t.Errorf("shared backend called %d times, want the next request to probe again", shared.calls)
+	}
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/memcache"
+)
+
+// MemcachedKeyPrefix namespaces rate limit counters in memcached
+const MemcachedKeyPrefix = "rate:"
+
+// Memcached counts requests in fixed windows with memcached's atomic
+// increment. Fixed windows allow up to twice the rate across a window
+// boundary, which is the price of needing no scripting.
+type Memcached struct {
+	client *memcache.Client
+	clock  clock.Clock
+}
+
+func NewMemcached(client *memcache.Client, clk clock.Clock) *Memcached {
+	return &Memcached{client: client, clock: clk}
+}
+
+func (m *Memcached) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	start, resetAfter := window(m.clock.Now(), limit.Period)
+	key = MemcachedKeyPrefix + key + ":" + strconv.FormatInt(start.Unix(), 10)
+
+	count, found, err := m.client.Incr(ctx, key, 1)
+	if err != nil {
+		return Result{}, err
+	}
+	if !found {
+		// First request of the window. Another replica may win the race
+		// to create the counter, in which case increment theirs.
+		added, err := m.client.Add(ctx, key, []byte("1"), limit.Period)
+		if err != nil {
+			return Result{}, err
+		}
+		count = 1
+		if !added {
+			if count, _, err = m.client.Incr(ctx, key, 1); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+	return windowResult(int64(count), limit, resetAfter), nil
+}
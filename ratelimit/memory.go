@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+)
+
+// Memory applies the same generic cell rate algorithm as Redis, in
+// process memory. Every replica counts on its own, so it is only exact
+// for single-instance deployments.
+type Memory struct {
+	clock clock.Clock
+
+	mu        sync.Mutex
+	tat       map[string]time.Time // theoretical arrival time per key
+	nextPrune time.Time
+}
+
+// pruneInterval is how often keys with no remaining state are dropped
+const pruneInterval = time.Minute
+
+func NewMemory(clk clock.Clock) *Memory {
+	return &Memory{clock: clk, tat: make(map[string]time.Time)}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := m.clock.Now()
+	interval := limit.Period / time.Duration(limit.Rate)
+	burst := limit.Period // Rate requests may arrive at once
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+
+	tat, ok := m.tat[key]
+	if !ok || tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	if allowAt := next.Add(-burst); now.Before(allowAt) {
+		return Result{
+			Allowed:    false,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	m.tat[key] = next
+	return Result{
+		Allowed:    true,
+		Remaining:  int((burst - next.Sub(now)) / interval),
+		ResetAfter: next.Sub(now),
+	}, nil
+}
+
+// prune drops keys whose state has fully decayed, which a fresh key would
+// reproduce exactly, so the map cannot grow without bound
+func (m *Memory) prune(now time.Time) {
+	if now.Before(m.nextPrune) {
+		return
+	}
+	for key, tat := range m.tat {
+		if tat.Before(now) {
+			delete(m.tat, key)
+		}
+	}
+	m.nextPrune = now.Add(pruneInterval)
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Postgres counts requests in fixed windows in the rate_limit_counters
+// table, for deployments without Redis or memcached. Each check is one
+// upsert, so it suits modest request rates only.
+type Postgres struct {
+	queries *database.Queries
+	clock   clock.Clock
+}
+
+func NewPostgres(queries *database.Queries, clk clock.Clock) *Postgres {
+	return &Postgres{queries: queries, clock: clk}
+}
+
+func (p *Postgres) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	start, resetAfter := window(p.clock.Now(), limit.Period)
+	count, err := p.queries.IncrementRateLimitCounter(ctx, database.IncrementRateLimitCounterParams{
+		Key:         key,
+		WindowStart: pgtype.Timestamptz{Time: start, Valid: true},
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return windowResult(int64(count), limit, resetAfter), nil
+}
+
+// Prune deletes counters of windows that started more than maxPeriod ago
+func (p *Postgres) Prune(ctx context.Context, maxPeriod time.Duration) error {
+	cutoff := pgtype.Timestamptz{Time: p.clock.Now().Add(-maxPeriod), Valid: true}
+	return p.queries.DeleteStaleRateLimitCounters(ctx, cutoff)
+}
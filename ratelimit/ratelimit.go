@@ -0,0 +1,75 @@
+// Package ratelimit counts requests per key against a Limit. Backends are
+// interchangeable so that single-instance or Redis-less deployments can
+// still enforce limits.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	"idiomatic-go/memcache"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Limit allows Rate requests per Period, all of which may arrive at once
+type Limit struct {
+	Rate   int
+	Period time.Duration
+}
+
+// Result is the outcome of one Allow call
+type Result struct {
+	Allowed    bool
+	Remaining  int           // requests left before the limit is hit
+	RetryAfter time.Duration // when denied, how long until a request would be allowed
+	ResetAfter time.Duration // how long until the full limit is available again
+}
+
+// Limiter decides whether the request identified by key fits within limit
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Backends accepted by New
+const (
+	BackendRedis     = "redis"
+	BackendMemory    = "memory"
+	BackendMemcached = "memcached"
+	BackendPostgres  = "postgres"
+)
+
+// New builds the named backend. Only the client that backend uses needs
+// to be non-nil.
+func New(backend string, rdb *redis.Client, mc *memcache.Client, queries *database.Queries, clk clock.Clock) (Limiter, error) {
+	switch backend {
+	case BackendRedis:
+		return NewRedis(rdb), nil
+	case BackendMemory:
+		return NewMemory(clk), nil
+	case BackendMemcached:
+		return NewMemcached(mc, clk), nil
+	case BackendPostgres:
+		return NewPostgres(queries, clk), nil
+	}
+	return nil, fmt.Errorf("unknown rate limit backend %q", backend)
+}
+
+// window returns the start of the fixed window containing now and the
+// time left until it ends. The fixed-window backends use it.
+func window(now time.Time, period time.Duration) (time.Time, time.Duration) {
+	start := now.Truncate(period)
+	return start, start.Add(period).Sub(now)
+}
+
+// windowResult turns the count of requests in the current window into a
+// Result
+func windowResult(count int64, limit Limit, resetAfter time.Duration) Result {
+	if count > int64(limit.Rate) {
+		return Result{Allowed: false, RetryAfter: resetAfter, ResetAfter: resetAfter}
+	}
+	return Result{Allowed: true, Remaining: limit.Rate - int(count), ResetAfter: resetAfter}
+}
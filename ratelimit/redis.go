@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/go-redis/redis_rate/v10"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisKeyPrefix is the prefix redis_rate puts in front of every key
+const RedisKeyPrefix = "rate:"
+
+// Redis applies the generic cell rate algorithm in Redis, so limits are
+// shared by every replica
+type Redis struct {
+	limiter *redis_rate.Limiter
+}
+
+func NewRedis(rdb *redis.Client) *Redis {
+	return &Redis{limiter: redis_rate.NewLimiter(rdb)}
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := r.limiter.Allow(ctx, key, redis_rate.Limit{
+		Rate:   limit.Rate,
+		Burst:  limit.Rate,
+		Period: limit.Period,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    res.Allowed > 0,
+		Remaining:  res.Remaining,
+		RetryAfter: res.RetryAfter,
+		ResetAfter: res.ResetAfter,
+	}, nil
+}
@@ -0,0 +1,144 @@
+// Package redismetrics instruments a go-redis client with Prometheus
+// metrics for command latency, errors and connection pool usage, and adds
+// command time to the request's timing breakdown
+package redismetrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"idiomatic-go/timing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	commandDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "redis_command_duration_seconds",
+			Help:    "Duration of Redis commands, including the network round trip; pipelines are observed as a whole",
+			Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+		},
+		[]string{"command"},
+	)
+	commandErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redis_command_errors_total",
+			Help: "Redis commands that failed; a missing key (redis.Nil) is not counted",
+		},
+		[]string{"command"},
+	)
+	dialErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "redis_dial_errors_total",
+			Help: "Failed attempts to open a connection to Redis",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(commandDuration, commandErrors, dialErrors)
+}
+
+// Instrument adds the metrics hook to rdb and returns a collector for its
+// pool statistics, which the caller registers
+func Instrument(rdb *redis.Client) *PoolCollector {
+	rdb.AddHook(hook{})
+	return NewPoolCollector(rdb)
+}
+
+type hook struct{}
+
+func (hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			dialErrors.Inc()
+		}
+		return conn, err
+	}
+}
+
+func (hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		took := time.Since(start)
+		commandDuration.WithLabelValues(cmd.Name()).Observe(took.Seconds())
+		timing.Record(ctx, timing.LayerRedis, took)
+		if failed(err) {
+			commandErrors.WithLabelValues(cmd.Name()).Inc()
+		}
+		return err
+	}
+}
+
+func (hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		took := time.Since(start)
+		commandDuration.WithLabelValues("pipeline").Observe(took.Seconds())
+		timing.Record(ctx, timing.LayerRedis, took)
+		for _, cmd := range cmds {
+			if failed(cmd.Err()) {
+				commandErrors.WithLabelValues(cmd.Name()).Inc()
+			}
+		}
+		return err
+	}
+}
+
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil)
+}
+
+// PoolCollector exposes the client's connection pool statistics, read at
+// scrape time
+type PoolCollector struct {
+	rdb *redis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func NewPoolCollector(rdb *redis.Client) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("redis_pool_"+name, help, nil, nil)
+	}
+	return &PoolCollector{
+		rdb:        rdb,
+		hits:       desc("hits_total", "Times an idle connection was found in the pool"),
+		misses:     desc("misses_total", "Times no idle connection was available and a new one was needed"),
+		timeouts:   desc("timeouts_total", "Times waiting for a connection timed out"),
+		totalConns: desc("total_connections", "Connections currently open"),
+		idleConns:  desc("idle_connections", "Connections currently idle in the pool"),
+		staleConns: desc("stale_connections_total", "Stale connections removed from the pool"),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.rdb.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(s.StaleConns))
+}
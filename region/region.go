@@ -0,0 +1,109 @@
+// Package region supports active-active deployments, where replicas in
+// several regions write to the same logical database. Rows record the
+// region that last wrote them; a Detector flags writes that race a recent
+// write from another region, which asynchronous replication may not have
+// delivered yet.
+package region
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var conflicts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "region_write_conflicts_total",
+		Help: "Writes to rows another region wrote within the conflict window, by table",
+	},
+	[]string{"table"},
+)
+
+func init() {
+	prometheus.MustRegister(conflicts)
+}
+
+// Conflict is a write to a row another region wrote shortly before
+type Conflict struct {
+	Table         string
+	ID            int32
+	Region        string    // this replica's region
+	RemoteRegion  string    // the region that last wrote the row
+	RemoteWriteAt time.Time // when it did
+}
+
+// ConflictHook is called for every Conflict inside the writing
+// transaction. Returning an error aborts the write with it; returning nil
+// lets the write go ahead, so the last writer wins.
+type ConflictHook func(ctx context.Context, c Conflict) error
+
+// RejectConflicts is a ConflictHook that aborts conflicting writes with a
+// retryable 409, so the client re-reads the row once replication caught up
+func RejectConflicts(context.Context, Conflict) error {
+	return custom_errors.ErrConflict
+}
+
+// Detector compares the write stamp of a row about to be changed with the
+// local region
+type Detector struct {
+	region string
+	window time.Duration
+	clock  clock.Clock
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	hooks []ConflictHook
+}
+
+// NewDetector treats a row as conflicting if another region wrote it less
+// than window ago. With an empty region, as in single-region deployments,
+// nothing conflicts.
+func NewDetector(region string, window time.Duration, clk clock.Clock, logger *slog.Logger) *Detector {
+	return &Detector{region: region, window: window, clock: clk, logger: logger}
+}
+
+// Region returns the local region
+func (d *Detector) Region() string {
+	return d.region
+}
+
+// OnConflict registers hook to be called for every conflict, in the order
+// the hooks were registered
+func (d *Detector) OnConflict(hook ConflictHook) {
+	d.mu.Lock()
+	d.hooks = append(d.hooks, hook)
+	d.mu.Unlock()
+}
+
+// Check reports a conflict if the row id of table, last written by
+// writeRegion at writtenAt, is about to be overwritten too soon. Conflicts
+// are always counted and logged; the first hook error is returned.
+func (d *Detector) Check(ctx context.Context, table string, id int32, writeRegion string, writtenAt time.Time) error {
+	if d.region == "" || writeRegion == "" || writeRegion == d.region {
+		return nil
+	}
+	if d.clock.Now().Sub(writtenAt) >= d.window {
+		return nil
+	}
+	c := Conflict{Table: table, ID: id, Region: d.region, RemoteRegion: writeRegion, RemoteWriteAt: writtenAt}
+	conflicts.WithLabelValues(table).Inc()
+	d.logger.WarnContext(ctx, "write races a recent write from another region",
+		"table", table, "id", id, "remote_region", writeRegion, "remote_write_at", writtenAt)
+
+	d.mu.Lock()
+	hooks := make([]ConflictHook, len(d.hooks))
+	copy(hooks, d.hooks)
+	d.mu.Unlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix namespaces revoked token IDs in Redis
+const KeyPrefix = "blacklist:jti:"
+
+// CutoffKey holds the time set by RevokeAll, in Unix milliseconds
+const CutoffKey = "blacklist:issued_before"
+
+// Store is a Redis-backed revocation list of JWT IDs. Entries expire
+// together with the token they revoke, so the list never outgrows the set
+// of still-valid tokens.
+type Store struct {
+	rdb   *redis.Client
+	clock clock.Clock
+}
+
+func NewStore(rdb *redis.Client, clk clock.Clock) *Store {
+	return &Store{rdb: rdb, clock: clk}
+}
+
+// Revoke blacklists jti until expiresAt. Tokens that already expired are
+// ignored since they can no longer be used.
+func (s *Store) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := expiresAt.Sub(s.clock.Now())
+	if ttl <= 0 {
+		return nil
+	}
+	return s.rdb.Set(ctx, KeyPrefix+jti, 1, ttl).Err()
+}
+
+// RevokeAll revokes every token issued until now. The cutoff is kept for
+// lifetime, the longest a token can live, and only ever moves forward
+// since it is always the current time.
+func (s *Store) RevokeAll(ctx context.Context, lifetime time.Duration) error {
+	return s.rdb.Set(ctx, CutoffKey, s.clock.Now().UnixMilli(), lifetime).Err()
+}
+
+// IsRevoked reports whether jti has been revoked, on its own or by a
+// RevokeAll after issuedAt. Both are read in one round trip.
+func (s *Store) IsRevoked(ctx context.Context, jti string, issuedAt time.Time) (bool, error) {
+	vals, err := s.rdb.MGet(ctx, KeyPrefix+jti, CutoffKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if vals[0] != nil {
+		return true, nil
+	}
+	raw, ok := vals[1].(string)
+	if !ok {
+		return false, nil
+	}
+	cutoff, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("parse revocation cutoff: %w", err)
+	}
+	// Issue times have second precision, so tokens from the second of the
+	// cutoff are revoked too
+	return issuedAt.Before(time.UnixMilli(cutoff)), nil
+}
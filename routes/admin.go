@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+	"idiomatic-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAdminRoutes mounts the admin-only user management, job control,
+// audit alerting, query statistics, deprecation usage, incident response
+// and broadcast endpoints
+func RegisterAdminRoutes(r *gin.RouterGroup, h *handlers.AdminHandler, jobs *handlers.JobHandler, alerts *handlers.AlertHandler, queryStats *handlers.QueryStatsHandler, deprecations *handlers.DeprecationHandler, incident *handlers.IncidentHandler, notifications *handlers.NotificationHandler, emailTemplates *handlers.EmailTemplateHandler, deps Dependencies) {
+	admin := r.Group("/admin")
+	admin.Use(deps.Auth(), deps.UserRateLimiter(), middleware.Authorize(middleware.Role("admin")))
+	{
+		admin.GET("/users", listBudget, h.ListUsers)
+		admin.POST("/users/roles", h.BulkChangeRole)
+
+		user := admin.Group("/users/:id", deps.ResolveUserID())
+		user.PUT("/role", h.ChangeRole)
+		user.POST("/password-reset", h.ForcePasswordReset)
+		user.GET("/audit-logs", h.ListAuditLogs)
+		user.POST("/deactivate", h.DeactivateUser)
+
+		admin.GET("/audit-logs", h.QueryAuditLogs)
+
+		admin.GET("/jobs", jobs.ListJobs)
+		admin.GET("/jobs/:name", jobs.GetJob)
+		admin.POST("/jobs/:name/run", jobs.RunJob)
+		admin.POST("/jobs/:name/pause", jobs.PauseJob)
+		admin.POST("/jobs/:name/resume", jobs.ResumeJob)
+
+		admin.GET("/alert-rules", alerts.ListAlertRules)
+		admin.POST("/alert-rules", alerts.CreateAlertRule)
+		admin.PUT("/alert-rules/:id", alerts.UpdateAlertRule)
+		admin.DELETE("/alert-rules/:id", alerts.DeleteAlertRule)
+		admin.GET("/alerts", alerts.ListAlerts)
+
+		admin.GET("/query-stats", queryStats.ListQueryStats)
+		admin.GET("/deprecations", deprecations.ListDeprecations)
+
+		admin.GET("/keyspace", incident.GetKeyspace)
+		admin.DELETE("/keyspace/:prefix", incident.FlushKeyspace)
+		admin.POST("/sessions/revoke", incident.RevokeSessions)
+
+		admin.POST("/broadcast", notifications.Broadcast)
+
+		admin.GET("/email-templates", emailTemplates.ListEmailTemplates)
+		admin.GET("/email-templates/:name", emailTemplates.GetEmailTemplate)
+		admin.PUT("/email-templates/:name", emailTemplates.SaveEmailTemplate)
+		admin.DELETE("/email-templates/:name", emailTemplates.ResetEmailTemplate)
+		admin.GET("/email-templates/:name/versions", emailTemplates.ListEmailTemplateVersions)
+		admin.POST("/email-templates/:name/versions/:version/restore", emailTemplates.RestoreEmailTemplate)
+		admin.POST("/email-templates/:name/preview", emailTemplates.PreviewEmailTemplate)
+	}
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"time"
+
+	"idiomatic-go/middleware"
+)
+
+// Latency budgets by kind of route. Requests over budget are logged with
+// the time spent in each layer.
+var (
+	readBudget    = middleware.LatencyBudget(100 * time.Millisecond) // a row or two by key
+	listBudget    = middleware.LatencyBudget(250 * time.Millisecond) // a page of rows
+	writeBudget   = middleware.LatencyBudget(250 * time.Millisecond) // a transaction with its audit entry
+	searchBudget  = middleware.LatencyBudget(500 * time.Millisecond) // fuzzy matching
+	graphqlBudget = middleware.LatencyBudget(500 * time.Millisecond) // several batched reads or writes
+)
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"net/http/pprof"
+
+	"idiomatic-go/handlers"
+	"idiomatic-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterDebugRoutes mounts admin-only diagnostics endpoints, including
+// the pprof profiles if profiling is set
+func RegisterDebugRoutes(r *gin.RouterGroup, recorder *middleware.FlightRecorder, h *handlers.DebugHandler, runtime *handlers.RuntimeHandler, profiling bool, deps Dependencies) {
+	r.Use(deps.Auth(), middleware.Authorize(middleware.Role("admin")))
+	r.GET("/requests", recorder.Handler)
+	r.GET("/vars", runtime.Vars)
+
+	tracing := r.Group("/tracing")
+	{
+		tracing.GET("", h.ListTracing)
+		tracing.POST("/users/:id", deps.ResolveUserID(), h.EnableUserTracing)
+		tracing.DELETE("/users/:id", deps.ResolveUserID(), h.DisableUserTracing)
+		tracing.POST("/tokens", h.IssueDebugToken)
+	}
+
+	r.POST("/features/tokens", h.IssueFeatureToken)
+
+	flags := r.Group("/flags")
+	{
+		flags.GET("", h.ListFlags)
+		flags.PUT("/:name", h.SetFlag)
+		flags.DELETE("/:name", h.DeleteFlag)
+	}
+
+	if profiling {
+		registerProfiles(r)
+	}
+}
+
+// RegisterProfilingRoutes mounts the runtime statistics and pprof profiles
+// without authentication, for a listener only reachable from inside the
+// deployment
+func RegisterProfilingRoutes(r *gin.RouterGroup, runtime *handlers.RuntimeHandler) {
+	r.GET("/vars", runtime.Vars)
+	registerProfiles(r)
+}
+
+// registerProfiles mounts the net/http/pprof handlers. r must be the /debug
+// group, as pprof.Index looks for profile names under /debug/pprof/.
+func registerProfiles(r *gin.RouterGroup) {
+	profiles := r.Group("/pprof")
+	{
+		profiles.GET("/", gin.WrapF(pprof.Index))
+		profiles.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		profiles.GET("/profile", gin.WrapF(pprof.Profile))
+		profiles.GET("/symbol", gin.WrapF(pprof.Symbol))
+		profiles.POST("/symbol", gin.WrapF(pprof.Symbol))
+		profiles.GET("/trace", gin.WrapF(pprof.Trace))
+		// allocs, block, goroutine, heap, mutex, threadcreate
+		profiles.GET("/:name", gin.WrapF(pprof.Index))
+	}
+}
@@ -0,0 +1,146 @@
+package routes
+
+import (
+	"idiomatic-go/clock"
+	"idiomatic-go/deprecation"
+	customErrors "idiomatic-go/errors"
+	"idiomatic-go/middleware"
+	"idiomatic-go/ratelimit"
+	"idiomatic-go/revocation"
+	"idiomatic-go/signer"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dependencies bundles the shared components route groups use to build
+// their middleware, so registration functions never construct hidden
+// loggers or clients of their own.
+type Dependencies struct {
+	Logger   *slog.Logger
+	Redis    *redis.Client
+	Clock    clock.Clock
+	Tokens   *middleware.TokenParser
+	Users    middleware.UserResolver
+	Revoked  *revocation.Store
+	Rejected *middleware.RejectedTokens // nil disables caching of rejected tokens
+	Limiter  ratelimit.Limiter
+	Signer   *signer.Signer
+	Tarpit   middleware.TarpitConfig
+
+	// ConcealForeign answers requests for other users' resources with 404
+	// instead of 403, so user IDs cannot be enumerated
+	ConcealForeign bool
+
+	// UserIDs resolves the external user IDs in /users/:id paths;
+	// NumericUserIDs still accepts the deprecated numeric ones
+	UserIDs        middleware.IDResolver
+	NumericUserIDs bool
+
+	// Deprecations records calls to routes marked with Deprecated
+	Deprecations *deprecation.Tracker
+
+	// IdempotencyTTL is how long responses to requests with an
+	// Idempotency-Key are kept for replay
+	IdempotencyTTL time.Duration
+
+	// MaxUploadBytes bounds file uploads, which the global body limit
+	// exempts
+	MaxUploadBytes int64
+
+	// AccountLimit is the stricter per-IP limit on public signup and
+	// password reset endpoints, which send email
+	AccountLimit middleware.RateLimiterConfig
+
+	// UserLimit applies per authenticated user, after Auth. A zero Rate
+	// disables it.
+	UserLimit middleware.RateLimiterConfig
+}
+
+// Auth returns the JWT authentication middleware
+func (d Dependencies) Auth() gin.HandlerFunc {
+	return middleware.AuthMiddleware(d.Logger, middleware.NewAuthenticator(d.Tokens, d.Users, d.Revoked), d.Rejected)
+}
+
+// RateLimiter returns a rate limiter with the given configuration
+func (d Dependencies) RateLimiter(config middleware.RateLimiterConfig) gin.HandlerFunc {
+	if config.Clock == nil {
+		config.Clock = d.Clock
+	}
+	return middleware.RateLimitMiddleware(d.Logger, d.Limiter, config)
+}
+
+// AccountRateLimiter returns the rate limiter for public account endpoints
+func (d Dependencies) AccountRateLimiter() gin.HandlerFunc {
+	config := d.AccountLimit
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "account:"
+	}
+	return d.RateLimiter(config)
+}
+
+// UserRateLimiter returns the per-user rate limiter. It must follow Auth.
+func (d Dependencies) UserRateLimiter() gin.HandlerFunc {
+	config := d.UserLimit
+	if config.Rate == 0 && len(config.Routes) == 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	config.PerUser = true
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = "user_limit:"
+	}
+	return d.RateLimiter(config)
+}
+
+// SignedURL returns the middleware that validates signed links
+func (d Dependencies) SignedURL() gin.HandlerFunc {
+	return middleware.SignedURLMiddleware(d.Signer)
+}
+
+// OwnerOrAdmin returns the ownership policy for routes addressing a user
+// by the path parameter param: only that user and admins get through. It
+// must follow Auth.
+func (d Dependencies) OwnerOrAdmin(param string) gin.HandlerFunc {
+	denied := customErrors.ErrForbidden
+	if d.ConcealForeign {
+		denied = customErrors.ErrNotFound
+	}
+	return middleware.AuthorizeOr(denied, middleware.Owns(param), middleware.Role("admin"))
+}
+
+// ResolveUserID returns the middleware resolving the user in the :id path
+// parameter. It must follow Auth.
+func (d Dependencies) ResolveUserID() gin.HandlerFunc {
+	return middleware.ResolveIDMiddleware(d.Logger, "id", d.UserIDs, d.NumericUserIDs)
+}
+
+// ResolveSelf returns the middleware resolving the :id a /me route stands
+// for to the caller. It must follow Auth.
+func (d Dependencies) ResolveSelf() gin.HandlerFunc {
+	return middleware.ResolveSelfMiddleware("id")
+}
+
+// Deprecated marks a route as deprecated by notice. Declare it after Auth
+// so callers are reported by user rather than IP, e.g.
+//
+//	users.PUT("/:id", deps.Deprecated(deprecation.Notice{Since: ..., Replacement: ...}), h.UpdateUser)
+func (d Dependencies) Deprecated(notice deprecation.Notice) gin.HandlerFunc {
+	return middleware.DeprecatedMiddleware(d.Logger, d.Deprecations, notice)
+}
+
+// Idempotency returns the Idempotency-Key middleware. It must follow Auth.
+func (d Dependencies) Idempotency() gin.HandlerFunc {
+	return middleware.IdempotencyMiddleware(d.Logger, d.Redis, d.IdempotencyTTL)
+}
+
+// UploadLimit returns the body limit for file uploads
+func (d Dependencies) UploadLimit() gin.HandlerFunc {
+	return middleware.BodyLimitMiddleware(d.MaxUploadBytes)
+}
+
+// LoginTarpit returns the progressive delay middleware for credential endpoints
+func (d Dependencies) LoginTarpit() gin.HandlerFunc {
+	return middleware.TarpitMiddleware(d.Logger, d.Redis, d.Tarpit)
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterFileRoutes mounts file upload and download. Content is served
+// to anyone with a signed link instead of to authenticated users.
+func RegisterFileRoutes(r *gin.RouterGroup, h *handlers.FileHandler, deps Dependencies) {
+	r.GET("/files/:id/content", deps.SignedURL(), h.DownloadFile)
+
+	files := r.Group("/files")
+	files.Use(deps.Auth(), deps.UserRateLimiter())
+	{
+		files.POST("", deps.UploadLimit(), h.UploadFile)
+		files.GET("", listBudget, h.ListFiles)
+		files.GET("/:id", readBudget, h.GetFile)
+		files.GET("/:id/download", readBudget, h.GetDownloadURL)
+		files.DELETE("/:id", h.DeleteFile)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterGraphQLRoutes mounts the GraphQL endpoint, which answers
+// queries with GET and everything with POST
+func RegisterGraphQLRoutes(r *gin.RouterGroup, h *handlers.GraphQLHandler, deps Dependencies) {
+	graphql := r.Group("/graphql")
+	graphql.Use(deps.Auth(), deps.UserRateLimiter(), graphqlBudget)
+	{
+		graphql.GET("", h.Serve)
+		graphql.POST("", h.Serve)
+	}
+}
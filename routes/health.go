@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHealthRoutes mounts the Kubernetes liveness and readiness probes
+func RegisterHealthRoutes(r gin.IRoutes, h *handlers.HealthHandler) {
+	r.GET("/healthz", h.Liveness)
+	r.GET("/readyz", h.Readiness)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"idiomatic-go/honeypot"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHoneypotRoutes mounts the decoy routes of trap on every method
+func RegisterHoneypotRoutes(r gin.IRoutes, trap *honeypot.Trap) {
+	for _, path := range trap.Paths() {
+		r.Any(path, trap.Handler)
+	}
+}
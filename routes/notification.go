@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+	"idiomatic-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterNotificationRoutes mounts the WebSocket notification stream. The
+// admin broadcast endpoint is mounted by RegisterAdminRoutes.
+func RegisterNotificationRoutes(r *gin.RouterGroup, h *handlers.NotificationHandler, deps Dependencies) {
+	r.GET("/ws", middleware.WebSocketTokenMiddleware(), deps.Auth(), deps.UserRateLimiter(), h.Connect)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPresenceRoutes mounts the heartbeat and presence lookup endpoints
+func RegisterPresenceRoutes(r *gin.RouterGroup, h *handlers.PresenceHandler, deps Dependencies) {
+	r.POST("/me/heartbeat", readBudget, deps.Auth(), deps.UserRateLimiter(), h.Heartbeat)
+	r.GET("/users/:id/presence", readBudget, deps.Auth(), deps.UserRateLimiter(), deps.ResolveUserID(), h.Presence)
+}
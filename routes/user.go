@@ -3,28 +3,62 @@ package routes
 import (
 	"idiomatic-go/handlers"
 	"idiomatic-go/middleware"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
-func RegisterUserRoutes(r *gin.RouterGroup, h *handlers.UserHandler, jwtSecret string) {
-	r.POST("/login", h.Login) // Public endpoint
+func RegisterUserRoutes(r *gin.RouterGroup, h *handlers.UserHandler, deps Dependencies) {
+	r.POST("/login", deps.LoginTarpit(), h.Login) // Public endpoint
+	r.POST("/logout", deps.Auth(), deps.UserRateLimiter(), h.Logout)
+	r.POST("/token/refresh", h.Refresh)               // Public, authenticated by the refresh token
+	r.GET("/verify", deps.SignedURL(), h.VerifyEmail) // Public, signed link
 
+	// Public account endpoints send email, so they get a stricter limit
+	account := r.Group("")
+	account.Use(deps.AccountRateLimiter())
+	{
+		account.POST("/signup", h.SignUp)
+		account.POST("/password/forgot", h.ForgotPassword)
+		account.POST("/password/reset", h.ResetPassword)
+	}
+
+	devices := r.Group("/me/devices")
+	devices.Use(deps.Auth(), deps.UserRateLimiter())
+	{
+		devices.GET("", listBudget, h.ListDevices)
+		devices.DELETE("", h.RevokeAllDevices)
+		devices.DELETE("/:device_id", h.RevokeDevice)
+	}
+
+	selfOrAdmin := deps.OwnerOrAdmin("id")
+	adminOnly := middleware.Authorize(middleware.Role("admin"))
 	users := r.Group("/users")
-	users.Use(middleware.AuthMiddleware(logrus.New(), jwtSecret))
+	users.Use(deps.Auth(), deps.UserRateLimiter())
 	{
-		users.POST("", h.CreateUser)
-		// Add other protected routes here
-		// users.GET("", h.ListUsers)
-		// users.GET("/:id", h.GetUser)
-		// users.PUT("/:id", h.UpdateUser)
-		// users.DELETE("/:id", h.DeleteUser)
+		users.POST("", deps.Idempotency(), h.CreateUser) // hashes the password, so no budget
+		users.GET("", listBudget, adminOnly, h.ListUsers)
+		users.GET("/search", searchBudget, adminOnly, h.SearchUsers)
 	}
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	})
+	// The caller's own account, whatever its ID. Gin matches the static
+	// segment before /:id, so "me" is never resolved as an external ID.
+	me := users.Group("/me", deps.ResolveSelf())
+	{
+		me.GET("", readBudget, h.GetMe)
+		me.PUT("", writeBudget, h.UpdateMe)
+		me.DELETE("", writeBudget, h.DeleteMe)
+	}
+
+	user := users.Group("/:id", deps.ResolveUserID())
+	{
+		user.GET("", readBudget, selfOrAdmin, h.GetUser)
+		user.PUT("", writeBudget, selfOrAdmin, h.UpdateUser)
+		user.PATCH("", writeBudget, selfOrAdmin, h.PatchUser)
+		user.DELETE("", writeBudget, selfOrAdmin, h.DeleteUser)
+		user.GET("/profile", readBudget, selfOrAdmin, h.GetProfile)
+		user.PUT("/profile", writeBudget, selfOrAdmin, h.PutProfile)
+		user.DELETE("/profile", writeBudget, selfOrAdmin, h.DeleteProfile)
+		user.POST("/restore", adminOnly, h.RestoreUser)
+		user.POST("/merge", adminOnly, h.MergeUser)
+	}
 }
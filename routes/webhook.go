@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"idiomatic-go/handlers"
+	"idiomatic-go/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhookRoutes mounts the admin-only webhook management endpoints
+func RegisterWebhookRoutes(r *gin.RouterGroup, h *handlers.WebhookHandler, deps Dependencies) {
+	webhooks := r.Group("/webhooks")
+	webhooks.Use(deps.Auth(), deps.UserRateLimiter(), middleware.Authorize(middleware.Role("admin")))
+	{
+		webhooks.POST("", h.CreateWebhook)
+		webhooks.GET("", h.ListWebhooks)
+		webhooks.GET("/:id", h.GetWebhook)
+		webhooks.PUT("/:id", h.UpdateWebhook)
+		webhooks.DELETE("/:id", h.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", h.ListDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", h.Redeliver)
+	}
+}
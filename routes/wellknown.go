@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"idiomatic-go/wellknown"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWellKnownRoutes mounts the /.well-known endpoints h is configured
+// to serve
+func RegisterWellKnownRoutes(r gin.IRoutes, h *wellknown.Handler) {
+	if h.ServesSecurityTxt() {
+		r.GET("/.well-known/security.txt", h.SecurityTxt)
+	}
+	if h.ServesChangePassword() {
+		r.GET("/.well-known/change-password", h.ChangePassword)
+	}
+}
@@ -0,0 +1,60 @@
+// Package scanner checks uploaded files for malware before they are
+// served. HTTP hands files to an external scanning service, such as a
+// clamd REST wrapper or a cloud scanning API.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"idiomatic-go/buildinfo"
+)
+
+// Scanner inspects file content
+type Scanner interface {
+	// Scan reads the content from r and reports whether it is clean. An
+	// error means no verdict was reached and the scan should be retried.
+	Scan(ctx context.Context, name string, r io.Reader) (clean bool, err error)
+}
+
+// HTTP posts file content to a scanning endpoint. It answers 200 for
+// clean content and 422 for infected content; anything else is an error.
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP returns an HTTP scanner for url, giving up on a scan after
+// timeout
+func NewHTTP(url string, timeout time.Duration) *HTTP {
+	return &HTTP{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTP) Scan(ctx context.Context, name string, r io.Reader) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, r)
+	if err != nil {
+		return false, fmt.Errorf("build scan request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", "idiomatic-go-scanner/"+buildinfo.ServiceVersion())
+	req.Header.Set("X-File-Name", name)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusUnprocessableEntity:
+		return false, nil
+	default:
+		return false, fmt.Errorf("scanner answered %s", resp.Status)
+	}
+}
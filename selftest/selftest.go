@@ -0,0 +1,198 @@
+// Package selftest checks that a deployment can start: its configuration
+// is valid, its dependencies are reachable and its schema is current. The
+// report is JSON, for deploy pipelines to gate a rollout on.
+package selftest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/config"
+	"idiomatic-go/database"
+	"idiomatic-go/middleware"
+	"idiomatic-go/signer"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// checkTimeout bounds each check, so an unreachable dependency fails the
+// run instead of hanging the pipeline
+const checkTimeout = 10 * time.Second
+
+// Result is the outcome of one check
+type Result struct {
+	Name       string  `json:"name"`
+	OK         bool    `json:"ok"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+	Detail     string  `json:"detail,omitempty"`
+}
+
+// Report is the outcome of a run. OK is false if any check failed.
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+// errSkipped marks a check that does not apply to the configuration
+var errSkipped = errors.New("skipped")
+
+type check struct {
+	name string
+	run  func(ctx context.Context) (detail string, err error)
+}
+
+// Run loads the configuration from path and runs every check, writing the
+// report to w. Later checks are skipped when the configuration is invalid.
+func Run(ctx context.Context, path string, w io.Writer) (Report, error) {
+	cfgStart := time.Now()
+	cfg, cfgErr := config.Load(path)
+	report := Report{OK: true}
+	record := func(name string, start time.Time, detail string, err error) {
+		r := Result{Name: name, OK: err == nil, DurationMS: float64(time.Since(start)) / float64(time.Millisecond), Detail: detail}
+		switch {
+		case errors.Is(err, errSkipped):
+			r.OK, r.Skipped = true, true
+		case err != nil:
+			r.Error = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, r)
+	}
+
+	record("config", cfgStart, "", cfgErr)
+	if cfgErr == nil {
+		var db *database.DB
+		defer func() {
+			if db != nil {
+				db.Close()
+			}
+		}()
+		checks := []check{
+			{"postgres", func(ctx context.Context) (string, error) {
+				var err error
+				// Connection errors are reported here rather than logged
+				db, err = database.NewDB(ctx, database.Config{DBConn: cfg.DBConn, MaxConns: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+				return "", err
+			}},
+			{"migrations", func(ctx context.Context) (string, error) {
+				if db == nil {
+					return "", errSkipped
+				}
+				return checkMigrations(ctx, db)
+			}},
+			{"redis", func(ctx context.Context) (string, error) {
+				rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPass})
+				defer rdb.Close()
+				return "", rdb.Ping(ctx).Err()
+			}},
+			{"jwt", func(context.Context) (string, error) {
+				return "", checkJWT(cfg)
+			}},
+			{"signing_keys", func(context.Context) (string, error) {
+				keys, err := signer.ParseKeys(cfg.SigningKeys)
+				if err != nil {
+					return "", err
+				}
+				if len(keys) == 0 {
+					return "no keys configured; links are signed with an ephemeral key", nil
+				}
+				return fmt.Sprintf("%d keys, signing with %q", len(keys), keys[0].ID), nil
+			}},
+			{"tracing", func(ctx context.Context) (string, error) {
+				return checkTracing(ctx, cfg)
+			}},
+		}
+		for _, c := range checks {
+			start := time.Now()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			detail, err := c.run(checkCtx)
+			cancel()
+			record(c.name, start, detail, err)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return report, enc.Encode(report)
+}
+
+func checkMigrations(ctx context.Context, db *database.DB) (string, error) {
+	latest, err := database.LatestMigration()
+	if err != nil {
+		return "", err
+	}
+	applied, dirty, err := db.MigrationVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("read schema_migrations: %w", err)
+	}
+	detail := fmt.Sprintf("applied %d, latest %d", applied, latest)
+	switch {
+	case dirty:
+		return detail, fmt.Errorf("migration %d failed halfway and must be fixed by hand", applied)
+	case applied < latest:
+		return detail, fmt.Errorf("%d migrations pending", latest-applied)
+	case applied > latest:
+		return detail, errors.New("database is ahead of this build; it was migrated by a newer version")
+	}
+	return detail, nil
+}
+
+// checkJWT signs and verifies a token with the configured secret, the same
+// way login and the auth middleware do
+func checkJWT(cfg config.Config) error {
+	clk := clock.New()
+	now := clk.Now()
+	token, err := jwt.NewWithClaims(middleware.SigningMethod, middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "0",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}).SignedString([]byte(cfg.JWTSecret))
+	if err != nil {
+		return fmt.Errorf("sign: %w", err)
+	}
+	if _, err := middleware.NewTokenParser(cfg.JWTSecret, cfg.JWTLeeway, clk).Parse(token); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	return nil
+}
+
+// checkTracing opens a TCP connection to the trace collector. The exporters
+// send over HTTP without a handshake of their own, so reaching the port is
+// as much as can be checked without exporting a span.
+func checkTracing(ctx context.Context, cfg config.Config) (string, error) {
+	endpoint := cfg.TraceCollectorURL()
+	if endpoint == "" {
+		return "", errSkipped
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse endpoint: %w", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return endpoint, err
+	}
+	conn.Close()
+	return endpoint, nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/mailer"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const passwordResetTTL = time.Hour
+
+var (
+	errUsernameTaken = custom_errors.NewAPIError(http.StatusConflict, custom_errors.CodeUsernameTaken, "Username is already taken")
+	errEmailTaken    = custom_errors.NewAPIError(http.StatusConflict, custom_errors.CodeEmailTaken, "Email address is already taken")
+	errInvalidReset  = custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeInvalidReset, "Invalid or expired password reset token")
+)
+
+// SignUp registers a new account. Its result must not reveal whether the
+// email is already registered: in that case the owner of the address is
+// notified instead and SignUp returns nil, exactly as for a new account.
+func (s *UserService) SignUp(ctx context.Context, params database.CreateUserParams) error {
+	log := s.logger.With("email_hash", emailFingerprint(params.Email))
+
+	_, err := s.CreateUser(ctx, params)
+	switch {
+	case err == nil:
+		log.InfoContext(ctx, "signup: account created")
+		return nil
+	case errors.Is(err, errEmailTaken):
+		log.InfoContext(ctx, "signup: email already registered")
+		s.sendAccountExistsEmail(ctx, params.Email)
+		return nil
+	default:
+		return err
+	}
+}
+
+// userConflict returns the conflict a violation of the unique username or
+// email of users means to the client, or nil for any other error
+func userConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return nil
+	}
+	switch pgErr.ConstraintName {
+	case "users_username_key":
+		return errUsernameTaken.Wrap(err)
+	case "users_email_key":
+		return errEmailTaken.Wrap(err)
+	}
+	return nil
+}
+
+// RequestPasswordReset mails a reset link to email if it belongs to an
+// account. Unknown addresses are logged privately and reported as success.
+func (s *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	log := s.logger.With("email_hash", emailFingerprint(email))
+
+	user, err := s.cachedUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.InfoContext(ctx, "password reset: email not registered")
+			return nil
+		}
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user by email: %w", err))
+	}
+
+	token, err := s.createPasswordReset(ctx, s.db.Queries, user.ID)
+	if err != nil {
+		return err
+	}
+
+	log.InfoContext(ctx, "password reset: link issued", "user_id", user.ID)
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	s.sendAccountTemplate(ctx, user.ID, user.Email, emails.PasswordReset, emails.AccountData{Username: user.Username, Link: link})
+	return nil
+}
+
+// createPasswordReset stores a new reset token for userID and returns it
+func (s *UserService) createPasswordReset(ctx context.Context, queries *database.Queries, userID int32) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("generate reset token: %w", err))
+	}
+	token := hex.EncodeToString(raw)
+
+	_, err := queries.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: pgtype.Timestamptz{Time: s.clock.Now().Add(passwordResetTTL), Valid: true},
+	})
+	if err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create password reset: %w", err))
+	}
+	return token, nil
+}
+
+// ResetPassword sets a new password for the owner of token, consumes
+// every outstanding reset token for that user and signs out all of their
+// devices
+func (s *UserService) ResetPassword(ctx context.Context, token, password string) error {
+	var userID int32
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		reset, err := queries.GetPasswordReset(ctx, hashToken(token))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidReset.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get password reset: %w", err))
+		}
+		if !s.clock.Now().Before(reset.ExpiresAt.Time) {
+			return errInvalidReset
+		}
+
+		hashedPassword, err := s.hasher.Hash(password)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err))
+		}
+		_, err = queries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			ID:           reset.UserID,
+			PasswordHash: hashedPassword,
+		})
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update password: %w", err))
+		}
+		if err := queries.DeletePasswordResetsForUser(ctx, reset.UserID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete password resets: %w", err))
+		}
+		// Whoever knew the old password may hold a refresh token too
+		if _, err := queries.RevokeUserRefreshTokens(ctx, reset.UserID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke refresh tokens: %w", err))
+		}
+
+		entry := audit.Entry(ctx, reset.UserID, "password_reset")
+		entry.Changes = passwordChange()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		userID = reset.UserID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetUser(ctx, userID)
+	return nil
+}
+
+func (s *UserService) sendAccountExistsEmail(ctx context.Context, email string) {
+	s.sendAccountTemplate(ctx, 0, email, emails.AccountExists, emails.AccountData{})
+}
+
+// sendAccountTemplate renders the email name and delivers it like
+// sendAccountEmail
+func (s *UserService) sendAccountTemplate(ctx context.Context, userID int32, to, name string, data emails.AccountData) {
+	msg, err := s.templates.Message(ctx, name, to, data)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to render account email", "error", err, "user_id", userID, "template", name)
+		return
+	}
+	s.sendAccountEmail(ctx, userID, msg)
+}
+
+// sendAccountEmail delivers msg, logging rather than returning failures so
+// the caller's response does not depend on delivery
+func (s *UserService) sendAccountEmail(ctx context.Context, userID int32, msg mailer.Message) {
+	err := s.mailer.Send(ctx, msg)
+	if err == nil {
+		return
+	}
+	log := s.logger.With("email_hash", emailFingerprint(msg.To))
+	if userID != 0 {
+		log = log.With("user_id", userID)
+	}
+	if errors.Is(err, mailer.ErrRecipientThrottled) {
+		log.WarnContext(ctx, "recipient throttled, email not sent")
+		return
+	}
+	log.ErrorContext(ctx, "failed to send email", "subject", msg.Subject, "error", err)
+}
+
+// emailFingerprint identifies an address in logs without recording it in
+// clear text
+func emailFingerprint(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:8])
+}
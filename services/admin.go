@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/optional"
+	"idiomatic-go/webhooks"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Roles a user can hold
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles lists every role
+var Roles = []string{RoleUser, RoleAdmin}
+
+var errOwnRole = custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Admins cannot change their own role")
+
+// userExportBatch is how many rows AdminUsers reads per query
+const userExportBatch = 500
+
+// UserFilter narrows AdminListUsers and AdminUsers. Unset options match
+// every user.
+type UserFilter struct {
+	Role           optional.Option[string]
+	EmailVerified  optional.Option[bool]
+	IncludeDeleted bool
+}
+
+// AdminListUsers returns a page of users matching filter in ID order,
+// optionally including soft-deleted ones
+func (s *UserService) AdminListUsers(ctx context.Context, filter UserFilter, req PageRequest) (Page[database.User], error) {
+	return FetchPage(ctx, req, s.userPages(filter), userCursor)
+}
+
+// AdminUsers iterates over every user matching filter in ID order, read
+// in batches by cursor like AuditLogs
+func (s *UserService) AdminUsers(ctx context.Context, filter UserFilter) iter.Seq2[database.User, error] {
+	return Paginate(ctx, userExportBatch, s.userPages(filter), userCursor)
+}
+
+func (s *UserService) userPages(filter UserFilter) PageFunc[database.User] {
+	return func(ctx context.Context, req PageRequest) ([]database.User, error) {
+		params := database.ListUsersFilteredParams{
+			IncludeDeleted: filter.IncludeDeleted,
+			PageLimit:      req.Limit,
+			PageOffset:     req.Offset,
+		}
+		if role, ok := filter.Role.Get(); ok {
+			params.Role = pgtype.Text{String: role, Valid: true}
+		}
+		if verified, ok := filter.EmailVerified.Get(); ok {
+			params.EmailVerified = pgtype.Bool{Bool: verified, Valid: true}
+		}
+		if id, ok := req.Cursor.Get(); ok {
+			params.AfterID = pgtype.Int4{Int32: id, Valid: true}
+			params.PageOffset = 0
+		}
+
+		users, err := s.db.Queries.ListUsersFiltered(ctx, params)
+		if err != nil {
+			return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list users: %w", err))
+		}
+		return users, nil
+	}
+}
+
+func userCursor(u database.User) int32 { return u.ID }
+
+// ChangeRole gives user id the role. actorID is the admin making the
+// change, who may not change their own role and so cannot lock the last
+// admin out by accident. The user's token version is bumped, so tokens
+// issued under the old role stop working at once.
+func (s *UserService) ChangeRole(ctx context.Context, actorID, id int32, role string) (database.User, error) {
+	if !slices.Contains(Roles, role) {
+		return database.User{}, custom_errors.ErrValidation.WithFields([]custom_errors.FieldError{
+			{Field: "role", Message: fmt.Sprintf("must be one of %v", Roles)},
+		})
+	}
+	if actorID == id {
+		return database.User{}, errOwnRole
+	}
+
+	var user database.User
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		current, err := queries.GetUserForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if err := s.checkConflict(ctx, current); err != nil {
+			return err
+		}
+		user, err = queries.UpdateUserRoleAndTokenVersion(ctx, database.UpdateUserRoleAndTokenVersionParams{ID: id, Role: role})
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update role: %w", err))
+		}
+
+		entry := audit.Entry(ctx, id, "role_changed")
+		entry.Changes = userChanges(current, user, false).JSON()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return s.publish(ctx, queries, webhooks.EventUserUpdated, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	s.forgetUser(ctx, id)
+	s.logger.InfoContext(ctx, "admin changed user role", "actor_id", actorID, "user_id", id, "role", role)
+	return user, nil
+}
+
+// Role actions for BulkChangeRole
+const (
+	RoleAssign = "assign"
+	RoleRemove = "remove"
+)
+
+// Outcomes of a RoleChange
+const (
+	RoleChangeUpdated   = "updated"
+	RoleChangeUnchanged = "unchanged"
+	RoleChangeFailed    = "failed"
+)
+
+// RoleChange is what BulkChangeRole did to one user. User is set unless
+// the change failed, Err only if it did.
+type RoleChange struct {
+	ExternalID string
+	Outcome    string
+	User       database.User
+	Err        *custom_errors.APIError
+}
+
+// BulkChangeRole assigns role to, or removes it from, the users with the
+// given external IDs in a single transaction. Removing a role gives the
+// user the plain user role back. Users that are missing, are the actor or
+// conflict with a write from another region fail on their own without
+// holding back the rest. Changed users have their token version bumped,
+// so their access tokens stop working at once.
+func (s *UserService) BulkChangeRole(ctx context.Context, actorID int32, externalIDs []string, role, action string) ([]RoleChange, error) {
+	if !slices.Contains(Roles, role) {
+		return nil, custom_errors.ErrValidation.WithFields([]custom_errors.FieldError{
+			{Field: "role", Message: fmt.Sprintf("must be one of %v", Roles)},
+		})
+	}
+	if action == RoleRemove && role == RoleUser {
+		return nil, custom_errors.ErrValidation.WithFields([]custom_errors.FieldError{
+			{Field: "role", Message: "cannot be removed, every user holds it"},
+		})
+	}
+
+	changes := make([]RoleChange, 0, len(externalIDs))
+	seen := make(map[string]struct{}, len(externalIDs))
+	var keys []pgtype.UUID
+	for _, ref := range externalIDs {
+		if _, dup := seen[ref]; dup {
+			continue
+		}
+		seen[ref] = struct{}{}
+		changes = append(changes, RoleChange{ExternalID: ref})
+		if parsed, err := uuid.Parse(ref); err == nil {
+			keys = append(keys, pgtype.UUID{Bytes: parsed, Valid: true})
+		}
+	}
+
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		// Locked in ID order, so concurrent bulk changes cannot deadlock
+		users, err := queries.ListUsersByExternalIDsForUpdate(ctx, keys)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("lock users: %w", err))
+		}
+		byExternalID := make(map[string]database.User, len(users))
+		for _, u := range users {
+			byExternalID[ExternalUserID(u)] = u
+		}
+
+		for i := range changes {
+			change := &changes[i]
+			parsed, err := uuid.Parse(change.ExternalID)
+			current, found := byExternalID[parsed.String()]
+			if err != nil || !found {
+				change.Outcome, change.Err = RoleChangeFailed, custom_errors.ErrNotFound
+				continue
+			}
+			if current.ID == actorID {
+				change.Outcome, change.Err = RoleChangeFailed, errOwnRole
+				continue
+			}
+			target := role
+			if action == RoleRemove {
+				target = RoleUser
+			}
+			if current.Role == target || (action == RoleRemove && current.Role != role) {
+				change.Outcome, change.User = RoleChangeUnchanged, current
+				continue
+			}
+			if err := s.checkConflict(ctx, current); err != nil {
+				apiErr, ok := custom_errors.IsAPIError(err)
+				if !ok || apiErr.StatusCode >= http.StatusInternalServerError {
+					return err
+				}
+				change.Outcome, change.Err = RoleChangeFailed, apiErr
+				continue
+			}
+
+			user, err := queries.UpdateUserRoleAndTokenVersion(ctx, database.UpdateUserRoleAndTokenVersionParams{ID: current.ID, Role: target})
+			if err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update role of user %d: %w", current.ID, err))
+			}
+			entry := audit.Entry(ctx, user.ID, "role_changed")
+			entry.Changes = userChanges(current, user, false).JSON()
+			if _, err := queries.CreateAuditLog(ctx, entry); err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+			}
+			if err := s.publish(ctx, queries, webhooks.EventUserUpdated, user); err != nil {
+				return err
+			}
+			change.Outcome, change.User = RoleChangeUpdated, user
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated := 0
+	for _, change := range changes {
+		if change.Outcome == RoleChangeUpdated {
+			s.forgetUser(ctx, change.User.ID)
+			updated++
+		}
+	}
+	s.logger.InfoContext(ctx, "admin changed roles in bulk",
+		"actor_id", actorID, "role", role, "action", action, "requested", len(changes), "updated", updated)
+	return changes, nil
+}
+
+// ForcePasswordReset replaces the password of user id with an unusable
+// one, signs out all of their devices and mails them a reset link.
+// Their access tokens stop working at once.
+func (s *UserService) ForcePasswordReset(ctx context.Context, actorID, id int32) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("generate password: %w", err))
+	}
+	unusable, err := s.hasher.Hash(string(raw))
+	if err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err))
+	}
+
+	var user database.User
+	var token string
+	err = s.db.WithTx(ctx, func(queries *database.Queries) error {
+		var err error
+		user, err = queries.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+			ID:           id,
+			PasswordHash: unusable,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update password: %w", err))
+		}
+		if err := queries.DeletePasswordResetsForUser(ctx, id); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete password resets: %w", err))
+		}
+		if _, err := queries.RevokeUserRefreshTokens(ctx, id); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke refresh tokens: %w", err))
+		}
+		if token, err = s.createPasswordReset(ctx, queries, id); err != nil {
+			return err
+		}
+
+		entry := audit.Entry(ctx, id, "password_force_reset")
+		entry.Changes = passwordChange()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetUser(ctx, id)
+	s.logger.InfoContext(ctx, "admin forced password reset", "actor_id", actorID, "user_id", id)
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	s.sendAccountTemplate(ctx, user.ID, user.Email, emails.PasswordForceReset, emails.AccountData{Username: user.Username, Link: link})
+	return nil
+}
+
+// DeactivateUser soft-deletes user id and signs out all of their devices.
+// Unlike DeleteUser it is an admin action and audited as such; the account
+// can be brought back with RestoreUser until it is purged.
+func (s *UserService) DeactivateUser(ctx context.Context, actorID, id int32) error {
+	if actorID == id {
+		return custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "Admins cannot deactivate themselves")
+	}
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		user, err := queries.GetUserForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if err := softDeleteUser(ctx, queries, id, s.clock.Now()); err != nil {
+			return err
+		}
+
+		_, err = queries.CreateAuditLog(ctx, audit.Entry(ctx, id, "user_deactivated"))
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return s.publish(ctx, queries, webhooks.EventUserDeleted, user)
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetUser(ctx, id)
+	s.logger.InfoContext(ctx, "admin deactivated user", "actor_id", actorID, "user_id", id)
+	return nil
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/mailer"
+	"idiomatic-go/webhooks"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// maxAlertWindow bounds how far back a rule may count, keeping the count
+// query on a small slice of audit_logs
+const maxAlertWindow = 24 * time.Hour
+
+// AlertService manages the audit alert rules and fires their alerts. A
+// rule fires when an audit action is recorded at least Threshold times
+// within Window, counted per actor or across all actors, and then stays
+// quiet for Cooldown.
+type AlertService struct {
+	db         *database.DB
+	logger     *slog.Logger
+	clock      clock.Clock
+	mailer     mailer.Mailer
+	templates  *EmailTemplateService
+	recipients []string
+}
+
+func NewAlertService(db *database.DB, logger *slog.Logger, clk clock.Clock, mail mailer.Mailer, templates *EmailTemplateService, recipients []string) *AlertService {
+	return &AlertService{db: db, logger: logger, clock: clk, mailer: mail, templates: templates, recipients: recipients}
+}
+
+// AlertRuleParams are the settable fields of an alert rule
+type AlertRuleParams struct {
+	Name      string
+	Action    string // audit action counted, e.g. user_deleted
+	PerActor  bool   // count each actor separately
+	Threshold int32
+	Window    time.Duration
+	Cooldown  time.Duration
+	Enabled   bool // ignored on create; new rules start enabled
+}
+
+func (p AlertRuleParams) validate() error {
+	var fields []custom_errors.FieldError
+	if p.Name == "" || len(p.Name) > 100 {
+		fields = append(fields, custom_errors.FieldError{Field: "name", Message: "must be 1 to 100 characters"})
+	}
+	if p.Action == "" || len(p.Action) > 50 {
+		fields = append(fields, custom_errors.FieldError{Field: "action", Message: "must be 1 to 50 characters"})
+	}
+	if p.Threshold <= 0 {
+		fields = append(fields, custom_errors.FieldError{Field: "threshold", Message: "must be positive"})
+	}
+	if p.Window < time.Second || p.Window > maxAlertWindow {
+		fields = append(fields, custom_errors.FieldError{Field: "window_seconds", Message: fmt.Sprintf("must be between 1 and %d", int(maxAlertWindow.Seconds()))})
+	}
+	// A shorter cooldown would fire again on the same burst
+	if p.Cooldown < p.Window {
+		fields = append(fields, custom_errors.FieldError{Field: "cooldown_seconds", Message: "must be at least window_seconds"})
+	}
+	if fields != nil {
+		return custom_errors.ErrValidation.WithFields(fields)
+	}
+	return nil
+}
+
+func (s *AlertService) CreateRule(ctx context.Context, params AlertRuleParams) (database.AuditAlertRule, error) {
+	if err := params.validate(); err != nil {
+		return database.AuditAlertRule{}, err
+	}
+	rule, err := s.db.Queries.CreateAuditAlertRule(ctx, database.CreateAuditAlertRuleParams{
+		Name:            params.Name,
+		Action:          params.Action,
+		PerActor:        params.PerActor,
+		Threshold:       params.Threshold,
+		WindowSeconds:   int32(params.Window.Seconds()),
+		CooldownSeconds: int32(params.Cooldown.Seconds()),
+	})
+	if err != nil {
+		return database.AuditAlertRule{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit alert rule: %w", err))
+	}
+	s.logger.InfoContext(ctx, "audit alert rule created", "rule_id", rule.ID, "action", rule.Action)
+	return rule, nil
+}
+
+func (s *AlertService) ListRules(ctx context.Context) ([]database.AuditAlertRule, error) {
+	rules, err := s.db.Queries.ListAuditAlertRules(ctx)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list audit alert rules: %w", err))
+	}
+	return rules, nil
+}
+
+func (s *AlertService) UpdateRule(ctx context.Context, id int32, params AlertRuleParams) (database.AuditAlertRule, error) {
+	if err := params.validate(); err != nil {
+		return database.AuditAlertRule{}, err
+	}
+	rule, err := s.db.Queries.UpdateAuditAlertRule(ctx, database.UpdateAuditAlertRuleParams{
+		ID:              id,
+		Name:            params.Name,
+		Action:          params.Action,
+		PerActor:        params.PerActor,
+		Threshold:       params.Threshold,
+		WindowSeconds:   int32(params.Window.Seconds()),
+		CooldownSeconds: int32(params.Cooldown.Seconds()),
+		Enabled:         params.Enabled,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.AuditAlertRule{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.AuditAlertRule{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update audit alert rule: %w", err))
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a rule along with the alerts it fired
+func (s *AlertService) DeleteRule(ctx context.Context, id int32) error {
+	n, err := s.db.Queries.DeleteAuditAlertRule(ctx, id)
+	if err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete audit alert rule: %w", err))
+	}
+	if n == 0 {
+		return custom_errors.ErrNotFound
+	}
+	s.logger.InfoContext(ctx, "audit alert rule deleted", "rule_id", id)
+	return nil
+}
+
+// ListAlerts returns the most recently fired alerts, newest first
+func (s *AlertService) ListAlerts(ctx context.Context, limit int32) ([]database.AuditAlert, error) {
+	alerts, err := s.db.Queries.ListAuditAlerts(ctx, limit)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list audit alerts: %w", err))
+	}
+	return alerts, nil
+}
+
+// Evaluate checks every enabled rule against the audit log and fires the
+// alerts that are due. Every replica runs it; the cooldown bucket stored
+// with each alert lets only one of them fire a given alert.
+func (s *AlertService) Evaluate(ctx context.Context) error {
+	rules, err := s.db.Queries.ListAuditAlertRules(ctx)
+	if err != nil {
+		return fmt.Errorf("list audit alert rules: %w", err)
+	}
+	now := s.clock.Now()
+	var errs []error
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if err := s.evaluate(ctx, rule, now); err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", rule.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *AlertService) evaluate(ctx context.Context, rule database.AuditAlertRule, now time.Time) error {
+	since := now.Add(-time.Duration(rule.WindowSeconds) * time.Second)
+	counts, err := s.db.Queries.CountAuditActions(ctx, database.CountAuditActionsParams{
+		PerActor:  rule.PerActor,
+		Action:    rule.Action,
+		Since:     pgtype.Timestamptz{Time: since, Valid: true},
+		Threshold: rule.Threshold,
+	})
+	if err != nil {
+		return fmt.Errorf("count audit actions: %w", err)
+	}
+	for _, count := range counts {
+		if err := s.fire(ctx, rule, count, since, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fire records an alert and queues its webhook event in one transaction,
+// then notifies the recipients. An alert already fired in the current
+// cooldown period is skipped.
+func (s *AlertService) fire(ctx context.Context, rule database.AuditAlertRule, count database.CountAuditActionsRow, since, now time.Time) error {
+	var alert database.AuditAlert
+	fired := false
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		var err error
+		alert, err = queries.CreateAuditAlert(ctx, database.CreateAuditAlertParams{
+			RuleID:      rule.ID,
+			ActorID:     count.ActorID,
+			EventCount:  int32(count.EventCount),
+			WindowStart: pgtype.Timestamptz{Time: since, Valid: true},
+			Bucket:      now.Unix() / int64(rule.CooldownSeconds),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("create audit alert: %w", err)
+		}
+		fired = true
+
+		data := webhooks.AuditAlertData{
+			AlertID:       alert.ID,
+			RuleID:        rule.ID,
+			RuleName:      rule.Name,
+			Action:        rule.Action,
+			Count:         alert.EventCount,
+			Threshold:     rule.Threshold,
+			WindowSeconds: rule.WindowSeconds,
+			WindowStart:   since.UTC(),
+		}
+		if count.ActorID.Valid {
+			data.ActorID = &count.ActorID.Int32
+		}
+		if _, err := webhooks.Enqueue(ctx, queries, now, webhooks.EventAuditAlert, data); err != nil {
+			return fmt.Errorf("enqueue %s webhooks: %w", webhooks.EventAuditAlert, err)
+		}
+		return nil
+	})
+	if err != nil || !fired {
+		return err
+	}
+
+	actor := "all actors"
+	if count.ActorID.Valid {
+		actor = fmt.Sprintf("user %d", count.ActorID.Int32)
+	}
+	s.logger.WarnContext(ctx, "audit alert fired",
+		"alert_id", alert.ID,
+		"rule_id", rule.ID,
+		"action", rule.Action,
+		"actor_id", count.ActorID.Int32,
+		"count", alert.EventCount,
+		"threshold", rule.Threshold,
+	)
+
+	data := emails.AlertData{
+		Rule:      rule.Name,
+		Action:    rule.Action,
+		Actor:     actor,
+		Count:     alert.EventCount,
+		Threshold: rule.Threshold,
+		Window:    time.Duration(rule.WindowSeconds) * time.Second,
+	}
+	msg, err := s.templates.Message(ctx, emails.AuditAlert, "", data)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to render audit alert email", "error", err)
+		return nil
+	}
+	for _, to := range s.recipients {
+		msg.To = to
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			s.logger.WarnContext(ctx, "failed to send audit alert email", "error", err, "to", to)
+		}
+	}
+	return nil
+}
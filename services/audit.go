@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"time"
+
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/optional"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// auditExportBatch is how many rows AuditLogs reads per query
+const auditExportBatch = 500
+
+// AuditLogFilter narrows ListAuditLogs and AuditLogs. Unset options
+// match every entry; From is inclusive and Before exclusive.
+type AuditLogFilter struct {
+	UserID  optional.Option[int32]
+	ActorID optional.Option[int32]
+	Action  optional.Option[string]
+	From    optional.Option[time.Time]
+	Before  optional.Option[time.Time]
+}
+
+func (f AuditLogFilter) params() database.ListAuditLogsParams {
+	var params database.ListAuditLogsParams
+	if id, ok := f.UserID.Get(); ok {
+		params.UserID = pgtype.Int4{Int32: id, Valid: true}
+	}
+	if id, ok := f.ActorID.Get(); ok {
+		params.ActorID = pgtype.Int4{Int32: id, Valid: true}
+	}
+	if action, ok := f.Action.Get(); ok {
+		params.Action = pgtype.Text{String: action, Valid: true}
+	}
+	if from, ok := f.From.Get(); ok {
+		params.CreatedFrom = pgtype.Timestamptz{Time: from, Valid: true}
+	}
+	if before, ok := f.Before.Get(); ok {
+		params.CreatedBefore = pgtype.Timestamptz{Time: before, Valid: true}
+	}
+	return params
+}
+
+// ListUserAuditLogs returns the most recent audit entries about userID
+func (s *UserService) ListUserAuditLogs(ctx context.Context, userID int32, limit int32) ([]database.AuditLog, error) {
+	logs, err := s.db.Queries.ListAuditLogsForUser(ctx, database.ListAuditLogsForUserParams{
+		UserID: userID,
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list audit logs: %w", err))
+	}
+	return logs, nil
+}
+
+// ListAuditLogs returns a page of audit entries matching filter, newest
+// first
+func (s *UserService) ListAuditLogs(ctx context.Context, filter AuditLogFilter, req PageRequest) (Page[database.AuditLog], error) {
+	return FetchPage(ctx, req, s.auditLogPages(filter), auditLogCursor)
+}
+
+// AuditLogs iterates over every audit entry matching filter, newest first.
+// Entries are read in batches by cursor, so entries written during the
+// iteration neither shift nor repeat the ones already yielded.
+func (s *UserService) AuditLogs(ctx context.Context, filter AuditLogFilter) iter.Seq2[database.AuditLog, error] {
+	return Paginate(ctx, auditExportBatch, s.auditLogPages(filter), auditLogCursor)
+}
+
+func (s *UserService) auditLogPages(filter AuditLogFilter) PageFunc[database.AuditLog] {
+	return func(ctx context.Context, req PageRequest) ([]database.AuditLog, error) {
+		params := filter.params()
+		params.PageLimit = req.Limit
+		params.PageOffset = req.Offset
+		if id, ok := req.Cursor.Get(); ok {
+			params.BeforeID = pgtype.Int4{Int32: id, Valid: true}
+			params.PageOffset = 0
+		}
+		logs, err := s.db.Queries.ListAuditLogs(ctx, params)
+		if err != nil {
+			return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list audit logs: %w", err))
+		}
+		return logs, nil
+	}
+}
+
+func auditLogCursor(l database.AuditLog) int32 { return l.ID }
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"idiomatic-go/cache"
+	"idiomatic-go/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Names of the user caches, used as metric labels
+const (
+	userCache      = "user"
+	userEmailCache = "user_email"
+)
+
+func userIDKey(id int32) string {
+	return "user:id:" + strconv.FormatInt(int64(id), 10)
+}
+
+// userEmailKey hashes the address so it never appears in the cache's key
+// space
+func userEmailKey(email string) string {
+	return "user:email:" + emailFingerprint(email)
+}
+
+// cachedUser is GetUser through the cache
+func (s *UserService) cachedUser(ctx context.Context, id int32) (database.User, error) {
+	return cache.GetOrLoad(ctx, s.cache, userCache, userIDKey(id), s.cacheTTL, func(ctx context.Context) (database.User, error) {
+		return s.db.Queries.GetUser(ctx, id)
+	})
+}
+
+// cachedUserByEmail is GetUserByEmail through the cache. Only the ID is
+// cached under the email and the row itself comes from cachedUser, so
+// forgetUser invalidates both; an index entry left pointing at a user
+// whose email has since changed is detected and dropped.
+func (s *UserService) cachedUserByEmail(ctx context.Context, email string) (database.User, error) {
+	var loaded *database.User
+	id, err := cache.GetOrLoad(ctx, s.cache, userEmailCache, userEmailKey(email), s.cacheTTL, func(ctx context.Context) (int32, error) {
+		user, err := s.db.Queries.GetUserByEmail(ctx, email)
+		if err != nil {
+			return 0, err
+		}
+		loaded = &user
+		cache.Put(ctx, s.cache, userCache, userIDKey(user.ID), user, s.cacheTTL)
+		return user.ID, nil
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	if loaded != nil {
+		return *loaded, nil
+	}
+
+	user, err := s.cachedUser(ctx, id)
+	if err == nil && user.Email == email {
+		return user, nil
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return database.User{}, err
+	}
+	// Stale index: the user changed email or was deleted
+	s.forget(ctx, userEmailKey(email))
+	return s.db.Queries.GetUserByEmail(ctx, email)
+}
+
+// forgetUser drops the cached row of a user after it changed. Call it
+// once the change is committed, never inside the transaction.
+func (s *UserService) forgetUser(ctx context.Context, id int32) {
+	s.forget(ctx, userIDKey(id))
+}
+
+func (s *UserService) forget(ctx context.Context, keys ...string) {
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		s.logger.WarnContext(ctx, "failed to invalidate cache; entries expire with their TTL", "error", err, "keys", keys)
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"idiomatic-go/database"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/mailer"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// EmailTemplate is the template in use for an email. Version is 0 for the
+// embedded default, which has no ID, author or creation time.
+type EmailTemplate struct {
+	Name    string
+	Version int32
+	emails.Template
+	Stored database.EmailTemplate
+}
+
+// Default reports whether t is the template embedded in the binary
+func (t EmailTemplate) Default() bool {
+	return t.Version == 0
+}
+
+func storedTemplate(row database.EmailTemplate) EmailTemplate {
+	return EmailTemplate{
+		Name:     row.Name,
+		Version:  row.Version,
+		Template: emails.Template{Subject: row.Subject, Body: row.Body},
+		Stored:   row,
+	}
+}
+
+// EmailTemplateService keeps the versions of the email templates operators
+// customized and renders outgoing email from the latest one, falling back
+// to the embedded default for templates never customized
+type EmailTemplateService struct {
+	db     *database.DB
+	logger *slog.Logger
+}
+
+func NewEmailTemplateService(db *database.DB, logger *slog.Logger) *EmailTemplateService {
+	return &EmailTemplateService{db: db, logger: logger}
+}
+
+func knownTemplate(name string) error {
+	if !emails.Known(name) {
+		return custom_errors.ErrNotFound
+	}
+	return nil
+}
+
+// List returns the template in use for every email, by name
+func (s *EmailTemplateService) List(ctx context.Context) ([]EmailTemplate, error) {
+	rows, err := s.db.Queries.ListLatestEmailTemplates(ctx)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list email templates: %w", err))
+	}
+	stored := make(map[string]database.EmailTemplate, len(rows))
+	for _, row := range rows {
+		stored[row.Name] = row
+	}
+
+	names := emails.Names()
+	templates := make([]EmailTemplate, len(names))
+	for i, name := range names {
+		if row, ok := stored[name]; ok {
+			templates[i] = storedTemplate(row)
+			continue
+		}
+		def, _ := emails.Default(name)
+		templates[i] = EmailTemplate{Name: name, Template: def}
+	}
+	return templates, nil
+}
+
+// Get returns the template in use for name
+func (s *EmailTemplateService) Get(ctx context.Context, name string) (EmailTemplate, error) {
+	if err := knownTemplate(name); err != nil {
+		return EmailTemplate{}, err
+	}
+	row, err := s.db.Queries.GetLatestEmailTemplate(ctx, name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			def, _ := emails.Default(name)
+			return EmailTemplate{Name: name, Template: def}, nil
+		}
+		return EmailTemplate{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get email template: %w", err))
+	}
+	return storedTemplate(row), nil
+}
+
+// Versions returns the stored versions of name, newest first
+func (s *EmailTemplateService) Versions(ctx context.Context, name string) ([]EmailTemplate, error) {
+	if err := knownTemplate(name); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Queries.ListEmailTemplateVersions(ctx, name)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list email template versions: %w", err))
+	}
+	versions := make([]EmailTemplate, len(rows))
+	for i, row := range rows {
+		versions[i] = storedTemplate(row)
+	}
+	return versions, nil
+}
+
+// Save stores t as the next version of name, which is used from then on
+func (s *EmailTemplateService) Save(ctx context.Context, actorID int32, name string, t emails.Template) (EmailTemplate, error) {
+	if err := knownTemplate(name); err != nil {
+		return EmailTemplate{}, err
+	}
+	if err := t.Check(name); err != nil {
+		return EmailTemplate{}, templateError(err)
+	}
+	row, err := s.db.Queries.CreateEmailTemplateVersion(ctx, database.CreateEmailTemplateVersionParams{
+		Name:      name,
+		Subject:   t.Subject,
+		Body:      t.Body,
+		CreatedBy: pgtype.Int4{Int32: actorID, Valid: true},
+	})
+	if err != nil {
+		// Another admin saved the same version number first
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return EmailTemplate{}, custom_errors.ErrConflict.Wrap(err)
+		}
+		return EmailTemplate{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create email template version: %w", err))
+	}
+	s.logger.InfoContext(ctx, "admin saved email template", "actor_id", actorID, "name", name, "version", row.Version)
+	return storedTemplate(row), nil
+}
+
+// Restore saves a copy of an earlier version of name as its next version
+func (s *EmailTemplateService) Restore(ctx context.Context, actorID int32, name string, version int32) (EmailTemplate, error) {
+	if err := knownTemplate(name); err != nil {
+		return EmailTemplate{}, err
+	}
+	row, err := s.db.Queries.GetEmailTemplateVersion(ctx, database.GetEmailTemplateVersionParams{Name: name, Version: version})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return EmailTemplate{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return EmailTemplate{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get email template version: %w", err))
+	}
+	return s.Save(ctx, actorID, name, emails.Template{Subject: row.Subject, Body: row.Body})
+}
+
+// Reset deletes every stored version of name, so the embedded default is
+// used again
+func (s *EmailTemplateService) Reset(ctx context.Context, actorID int32, name string) error {
+	if err := knownTemplate(name); err != nil {
+		return err
+	}
+	n, err := s.db.Queries.DeleteEmailTemplate(ctx, name)
+	if err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete email template: %w", err))
+	}
+	s.logger.InfoContext(ctx, "admin reset email template to default", "actor_id", actorID, "name", name, "versions", n)
+	return nil
+}
+
+// Preview renders t, or the template in use when t is nil, with the
+// sample data of name
+func (s *EmailTemplateService) Preview(ctx context.Context, name string, t *emails.Template) (subject, body string, err error) {
+	if t == nil {
+		current, err := s.Get(ctx, name)
+		if err != nil {
+			return "", "", err
+		}
+		t = &current.Template
+	} else if err := knownTemplate(name); err != nil {
+		return "", "", err
+	}
+	if err := t.Check(name); err != nil {
+		return "", "", templateError(err)
+	}
+	return t.Render(emails.Sample(name))
+}
+
+// templateError reports a failed Check against the field at fault
+func templateError(err error) error {
+	field, part := "body", emails.ErrBody
+	if errors.Is(err, emails.ErrSubject) {
+		field, part = "subject", emails.ErrSubject
+	}
+	message := strings.TrimPrefix(err.Error(), part.Error()+": ")
+	return custom_errors.ErrValidation.WithFields([]custom_errors.FieldError{{Field: field, Message: message}}).Wrap(err)
+}
+
+// Message renders the email name for to with data. A stored template that
+// cannot be loaded or fails to render is logged and the embedded default
+// used instead, so a bad customization never stops mail from going out.
+func (s *EmailTemplateService) Message(ctx context.Context, name, to string, data any) (mailer.Message, error) {
+	t, err := s.Get(ctx, name)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to load email template; using the default", "error", err, "name", name)
+		t.Template, _ = emails.Default(name)
+		t.Version = 0
+	}
+	subject, body, err := t.Render(data)
+	if err != nil && !t.Default() {
+		s.logger.ErrorContext(ctx, "failed to render email template; using the default", "error", err, "name", name, "version", t.Version)
+		def, _ := emails.Default(name)
+		subject, body, err = def.Render(data)
+	}
+	if err != nil {
+		return mailer.Message{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	return mailer.Message{To: to, Subject: subject, Body: body}, nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ResolveUserID maps the external ID clients address a user by to its
+// primary key. Anything that is not a known external ID is not found.
+// Deleted users resolve too, so admins can still restore them.
+func (s *UserService) ResolveUserID(ctx context.Context, externalID string) (int32, error) {
+	parsed, err := uuid.Parse(externalID)
+	if err != nil {
+		return 0, custom_errors.ErrNotFound.Wrap(err)
+	}
+	id, err := s.db.Queries.GetUserIDByExternalID(ctx, pgtype.UUID{Bytes: parsed, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return 0, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("resolve user id: %w", err))
+	}
+	return id, nil
+}
+
+// ExternalUserID returns the ID u is exposed under in the API
+func ExternalUserID(u database.User) string {
+	return uuid.UUID(u.ExternalID.Bytes).String()
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/scanner"
+	"idiomatic-go/signer"
+	"idiomatic-go/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// File statuses. Only available files can be downloaded.
+const (
+	FilePendingScan = "pending_scan"
+	FileAvailable   = "available"
+	FileRejected    = "rejected"    // the scanner found malware; the content is deleted
+	FileScanFailed  = "scan_failed" // no verdict after every attempt
+)
+
+var fileScans = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "file_scans_total",
+		Help: "Virus scans of uploaded files, by result (clean, infected, retry, failed)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(fileScans)
+}
+
+var (
+	errFileScanning = custom_errors.NewAPIError(http.StatusConflict, custom_errors.CodeFileNotAvailable,
+		"The file is still being scanned").WithRetry(30 * time.Second)
+	errFileRejected = custom_errors.NewAPIError(http.StatusConflict, custom_errors.CodeFileNotAvailable,
+		"The file did not pass the virus scan")
+)
+
+// FileConfig configures a FileService
+type FileConfig struct {
+	ContentURL      string        // absolute URL of the files collection; content is at ContentURL/{id}/content
+	DownloadTTL     time.Duration // lifetime of download links
+	ScanBatchSize   int           // files scanned per run
+	ScanTimeout     time.Duration // bound on each scan
+	ScanMaxAttempts int           // attempts before a file is marked scan_failed
+}
+
+// FileService stores uploaded files and hands out download links. With a
+// scanner, new files stay pending until ScanPending has found them clean.
+type FileService struct {
+	db      *database.DB
+	store   storage.Store
+	scanner scanner.Scanner
+	links   *signer.Signer
+	logger  *slog.Logger
+	clock   clock.Clock
+	config  FileConfig
+}
+
+// NewFileService returns a FileService. scan may be nil to make uploads
+// available at once.
+func NewFileService(db *database.DB, store storage.Store, scan scanner.Scanner, links *signer.Signer, logger *slog.Logger, clk clock.Clock, config FileConfig) *FileService {
+	if config.ScanBatchSize <= 0 {
+		config.ScanBatchSize = 10
+	}
+	if config.ScanTimeout <= 0 {
+		config.ScanTimeout = time.Minute
+	}
+	if config.ScanMaxAttempts <= 0 {
+		config.ScanMaxAttempts = 5
+	}
+	return &FileService{db: db, store: store, scanner: scan, links: links, logger: logger, clock: clk, config: config}
+}
+
+// Scanning reports whether uploads are scanned before becoming available
+func (s *FileService) Scanning() bool {
+	return s.scanner != nil
+}
+
+// Upload streams the content read from r into the store as a new file
+// named name and owned by ownerID. The content type is sniffed rather
+// than taken from the client.
+func (s *FileService) Upload(ctx context.Context, ownerID int32, name string, r io.Reader) (database.File, error) {
+	id := uuid.New()
+	content := bufio.NewReaderSize(r, 512)
+	head, err := content.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return database.File{}, uploadError(err)
+	}
+	contentType := http.DetectContentType(head)
+
+	hash := sha256.New()
+	size, err := s.store.Put(ctx, id.String(), io.TeeReader(content, hash))
+	if err != nil {
+		return database.File{}, uploadError(err)
+	}
+
+	params := database.CreateFileParams{
+		ID:          pgtype.UUID{Bytes: id, Valid: true},
+		OwnerID:     ownerID,
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		Sha256:      hex.EncodeToString(hash.Sum(nil)),
+		Status:      FileAvailable,
+	}
+	if s.scanner != nil {
+		params.Status = FilePendingScan
+		params.NextScanAt = pgtype.Timestamptz{Time: s.clock.Now(), Valid: true}
+	}
+	file, err := s.db.Queries.CreateFile(ctx, params)
+	if err != nil {
+		s.deleteContent(ctx, id.String())
+		return database.File{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create file: %w", err))
+	}
+	s.logger.InfoContext(ctx, "file uploaded", "file_id", id, "owner_id", ownerID, "size", size, "content_type", contentType)
+	return file, nil
+}
+
+// uploadError maps a failure to read or store upload content
+func uploadError(err error) error {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return custom_errors.ErrPayloadTooLarge.Wrap(err)
+	}
+	return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("store file: %w", err))
+}
+
+// GetFile returns the file with the given ID; malformed IDs are not found
+func (s *FileService) GetFile(ctx context.Context, id string) (database.File, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return database.File{}, custom_errors.ErrNotFound.Wrap(err)
+	}
+	file, err := s.db.Queries.GetFile(ctx, pgtype.UUID{Bytes: parsed, Valid: true})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.File{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.File{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get file: %w", err))
+	}
+	return file, nil
+}
+
+// ListFiles returns a page of the files of ownerID, newest first
+func (s *FileService) ListFiles(ctx context.Context, ownerID, limit, offset int32) ([]database.File, error) {
+	files, err := s.db.Queries.ListFilesByOwner(ctx, database.ListFilesByOwnerParams{
+		OwnerID: ownerID,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list files: %w", err))
+	}
+	return files, nil
+}
+
+// DeleteFile removes file and its content
+func (s *FileService) DeleteFile(ctx context.Context, file database.File) error {
+	if _, err := s.db.Queries.DeleteFile(ctx, file.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return custom_errors.ErrNotFound.Wrap(err)
+		}
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete file: %w", err))
+	}
+	s.deleteContent(ctx, FileID(file))
+	return nil
+}
+
+// DownloadURL returns a signed link to the content of file and when it
+// expires. Anyone holding the link can download the file until then.
+func (s *FileService) DownloadURL(file database.File) (string, time.Time, error) {
+	if err := checkAvailable(file); err != nil {
+		return "", time.Time{}, err
+	}
+	link, err := s.links.Sign(s.config.ContentURL+"/"+FileID(file)+"/content", s.config.DownloadTTL, false)
+	if err != nil {
+		return "", time.Time{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("sign download link: %w", err))
+	}
+	return link, s.clock.Now().Add(s.config.DownloadTTL), nil
+}
+
+// OpenContent returns the content of file, which must be available
+func (s *FileService) OpenContent(ctx context.Context, file database.File) (io.ReadCloser, error) {
+	if err := checkAvailable(file); err != nil {
+		return nil, err
+	}
+	content, err := s.store.Open(ctx, FileID(file))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("open file: %w", err))
+	}
+	return content, nil
+}
+
+func checkAvailable(file database.File) error {
+	switch file.Status {
+	case FileAvailable:
+		return nil
+	case FilePendingScan:
+		return errFileScanning
+	default:
+		return errFileRejected
+	}
+}
+
+// ScanRunTimeout is how long a ScanPending run may take. Use it as the job
+// timeout.
+func (s *FileService) ScanRunTimeout() time.Duration {
+	return time.Duration(s.config.ScanBatchSize)*s.config.ScanTimeout + 30*time.Second
+}
+
+// ScanPending scans one batch of files awaiting a scan. Each run claims
+// its batch by pushing the next scan past the time the batch can take, so
+// replicas never scan a file twice at once.
+func (s *FileService) ScanPending(ctx context.Context) error {
+	now := s.clock.Now()
+	batch, err := s.db.Queries.ClaimFileScans(ctx, database.ClaimFileScansParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(s.ScanRunTimeout()), Valid: true},
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+		BatchSize:  int32(s.config.ScanBatchSize),
+	})
+	if err != nil {
+		return fmt.Errorf("claim file scans: %w", err)
+	}
+	for _, file := range batch {
+		if ctx.Err() != nil {
+			// The rest are scanned once their lease runs out
+			return ctx.Err()
+		}
+		if err := s.scan(ctx, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan runs one scan of file and records the verdict. Only a failure to
+// record is returned.
+func (s *FileService) scan(ctx context.Context, file database.File) error {
+	id := FileID(file)
+	log := s.logger.With("file_id", id, "attempt", file.ScanAttempts+1)
+
+	clean, scanErr := s.scanContent(ctx, file)
+	now := s.clock.Now()
+	params := database.RecordFileScanParams{ID: file.ID}
+	switch {
+	case scanErr == nil && clean:
+		params.Status = FileAvailable
+		fileScans.WithLabelValues("clean").Inc()
+		log.Debug("file scanned clean")
+	case scanErr == nil:
+		params.Status = FileRejected
+		fileScans.WithLabelValues("infected").Inc()
+		log.Warn("file rejected by virus scan", "owner_id", file.OwnerID)
+		s.deleteContent(ctx, id)
+	case int(file.ScanAttempts)+1 >= s.config.ScanMaxAttempts:
+		params.Status = FileScanFailed
+		params.ScanError = pgtype.Text{String: scanErr.Error(), Valid: true}
+		fileScans.WithLabelValues("failed").Inc()
+		log.Warn("file scan failed, giving up", "error", scanErr)
+	default:
+		params.Status = FilePendingScan
+		params.ScanError = pgtype.Text{String: scanErr.Error(), Valid: true}
+		params.NextScanAt = pgtype.Timestamptz{Time: now.Add(time.Duration(file.ScanAttempts+1) * time.Minute), Valid: true}
+		fileScans.WithLabelValues("retry").Inc()
+		log.Info("file scan failed, will retry", "error", scanErr)
+	}
+	if params.Status != FilePendingScan {
+		params.ScannedAt = pgtype.Timestamptz{Time: now, Valid: true}
+	}
+
+	if err := s.db.Queries.RecordFileScan(context.WithoutCancel(ctx), params); err != nil {
+		return fmt.Errorf("record file scan %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *FileService) scanContent(ctx context.Context, file database.File) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.ScanTimeout)
+	defer cancel()
+
+	content, err := s.store.Open(ctx, FileID(file))
+	if err != nil {
+		return false, fmt.Errorf("open file: %w", err)
+	}
+	defer content.Close()
+	return s.scanner.Scan(ctx, file.Name, content)
+}
+
+// deleteContent removes stored content. A failure only leaves an orphaned
+// object behind, so it is logged rather than returned.
+func (s *FileService) deleteContent(ctx context.Context, key string) {
+	if err := s.store.Delete(context.WithoutCancel(ctx), key); err != nil {
+		s.logger.WarnContext(ctx, "failed to delete file content", "file_id", key, "error", err)
+	}
+}
+
+// FileID returns the ID file is exposed under in the API
+func FileID(file database.File) string {
+	return uuid.UUID(file.ID.Bytes).String()
+}
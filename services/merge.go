@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/webhooks"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errSelfMerge = custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "A user cannot be merged into itself")
+
+// MergeResult describes a merge, or with DryRun the merge that would happen
+type MergeResult struct {
+	Source             database.User // the account folded into Target
+	Target             database.User // the surviving account
+	AuditLogsMoved     int64
+	FilesMoved         int64
+	RefreshTokensMoved int64
+	ProfileMoved       bool // false when the target has a profile of its own, which wins
+	DryRun             bool
+}
+
+// MergeUsers folds the duplicate account sourceID into targetID. The
+// source's audit history, files and refresh tokens move to the target, as
+// does its profile unless the target has one. Its pending verification
+// and password reset tokens are discarded, and it is soft-deleted, so it
+// can still be restored until it is purged. With dryRun nothing is
+// written and the result previews the merge.
+func (s *UserService) MergeUsers(ctx context.Context, sourceID, targetID int32, dryRun bool) (MergeResult, error) {
+	if sourceID == targetID {
+		return MergeResult{}, errSelfMerge
+	}
+	if dryRun {
+		return s.previewMerge(ctx, sourceID, targetID)
+	}
+
+	result := MergeResult{}
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		// Lock both rows in ID order, so two merges of the same pair in
+		// opposite directions cannot deadlock and neither account can be
+		// changed or deleted underneath us
+		first, second := sourceID, targetID
+		if first > second {
+			first, second = second, first
+		}
+		locked := make(map[int32]database.User, 2)
+		for _, id := range []int32{first, second} {
+			user, err := queries.GetUserForUpdate(ctx, id)
+			if err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					return custom_errors.ErrNotFound.Wrap(fmt.Errorf("user %d: %w", id, err))
+				}
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("lock user %d: %w", id, err))
+			}
+			locked[id] = user
+		}
+		result.Source, result.Target = locked[sourceID], locked[targetID]
+
+		moved, err := queries.ReassignAuditLogs(ctx, database.ReassignAuditLogsParams{
+			ToUserID:   targetID,
+			FromUserID: sourceID,
+		})
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("reassign audit logs: %w", err))
+		}
+		result.AuditLogsMoved = moved
+
+		if result.FilesMoved, err = queries.ReassignFiles(ctx, database.ReassignFilesParams{
+			ToUserID:   targetID,
+			FromUserID: sourceID,
+		}); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("reassign files: %w", err))
+		}
+		// Sessions of the source carry on as sessions of the target
+		if result.RefreshTokensMoved, err = queries.ReassignRefreshTokens(ctx, database.ReassignRefreshTokensParams{
+			ToUserID:   targetID,
+			FromUserID: sourceID,
+		}); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("reassign refresh tokens: %w", err))
+		}
+		profiles, err := queries.ReassignProfile(ctx, database.ReassignProfileParams{
+			ToUserID:   targetID,
+			FromUserID: sourceID,
+		})
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("reassign profile: %w", err))
+		}
+		result.ProfileMoved = profiles > 0
+
+		if err := queries.DeleteEmailVerificationsForUser(ctx, sourceID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete email verifications: %w", err))
+		}
+		if err := queries.DeletePasswordResetsForUser(ctx, sourceID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete password resets: %w", err))
+		}
+		if err := softDeleteUser(ctx, queries, sourceID, s.clock.Now()); err != nil {
+			return err
+		}
+
+		// The target records the merge; the source keeps a tombstone entry
+		// of its own, written after its history was moved
+		merged := audit.Entry(ctx, targetID, "user_merged")
+		merged.TargetID = pgtype.Int4{Int32: sourceID, Valid: true}
+		mergedInto := audit.Entry(ctx, sourceID, "user_merged_into")
+		mergedInto.TargetID = pgtype.Int4{Int32: targetID, Valid: true}
+		for _, params := range []database.CreateAuditLogParams{merged, mergedInto} {
+			if _, err := queries.CreateAuditLog(ctx, params); err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+			}
+		}
+		return s.publish(ctx, queries, webhooks.EventUserDeleted, result.Source)
+	})
+	if err != nil {
+		return MergeResult{}, err
+	}
+	s.forgetUser(ctx, sourceID)
+	s.logger.InfoContext(ctx, "merged user accounts",
+		"source_id", sourceID,
+		"target_id", targetID,
+		"audit_logs_moved", result.AuditLogsMoved,
+		"files_moved", result.FilesMoved,
+		"refresh_tokens_moved", result.RefreshTokensMoved,
+		"profile_moved", result.ProfileMoved,
+	)
+	return result, nil
+}
+
+func (s *UserService) previewMerge(ctx context.Context, sourceID, targetID int32) (MergeResult, error) {
+	result := MergeResult{DryRun: true}
+	var err error
+	if result.Source, err = s.GetUser(ctx, sourceID); err != nil {
+		return MergeResult{}, err
+	}
+	if result.Target, err = s.GetUser(ctx, targetID); err != nil {
+		return MergeResult{}, err
+	}
+	if result.AuditLogsMoved, err = s.db.Queries.CountAuditLogsForUser(ctx, sourceID); err != nil {
+		return MergeResult{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("count audit logs: %w", err))
+	}
+	if result.FilesMoved, err = s.db.Queries.CountFilesByOwner(ctx, sourceID); err != nil {
+		return MergeResult{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("count files: %w", err))
+	}
+	if result.RefreshTokensMoved, err = s.db.Queries.CountRefreshTokensForUser(ctx, sourceID); err != nil {
+		return MergeResult{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("count refresh tokens: %w", err))
+	}
+	sourceProfile, err := s.hasProfile(ctx, sourceID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	targetProfile, err := s.hasProfile(ctx, targetID)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	result.ProfileMoved = sourceProfile && !targetProfile
+	return result, nil
+}
+
+func (s *UserService) hasProfile(ctx context.Context, userID int32) (bool, error) {
+	_, err := s.db.Queries.GetProfile(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get profile: %w", err))
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"idiomatic-go/database"
+	"idiomatic-go/storage"
+)
+
+func TestMergeUsers(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		targetProfile bool
+		dryRun        bool
+	}{
+		{name: "moves everything"},
+		{name: "keeps the target's own profile", targetProfile: true},
+		{name: "dry run", dryRun: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, clk := newTestUserService(t)
+			dir, err := storage.NewDir(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := NewFileService(s.db, dir, nil, s.links, s.logger, clk, FileConfig{})
+			source := createTestUser(t, s, "jane")
+			target := createTestUser(t, s, "jane2")
+
+			file, err := files.Upload(ctx, source.ID, "notes.txt", strings.NewReader("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			session, err := s.IssueRefreshToken(ctx, source.ID, "phone")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := s.PutProfile(ctx, database.UpsertProfileParams{UserID: source.ID, DisplayName: "Jane"}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.targetProfile {
+				if _, _, err := s.PutProfile(ctx, database.UpsertProfileParams{UserID: target.ID, DisplayName: "Jane Doe"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			auditLogs, err := s.db.Queries.CountAuditLogsForUser(ctx, source.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := s.MergeUsers(ctx, source.ID, target.ID, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.AuditLogsMoved != auditLogs || result.FilesMoved != 1 || result.RefreshTokensMoved != 1 || result.ProfileMoved == tt.targetProfile {
+				t.Fatalf("result = %+v, want %d audit logs, 1 file, 1 refresh token moved and profile moved %v",
+					result, auditLogs, !tt.targetProfile)
+			}
+			if tt.dryRun {
+				if owned, err := files.ListFiles(ctx, source.ID, 10, 0); err != nil || len(owned) != 1 {
+					t.Fatalf("source files after a dry run = %v, %v; want the file", owned, err)
+				}
+				return
+			}
+
+			// Every moved row belongs to the target now
+			if owned, err := files.ListFiles(ctx, target.ID, 10, 0); err != nil || len(owned) != 1 || owned[0].ID != file.ID {
+				t.Errorf("target files = %v, %v; want the source's file", owned, err)
+			}
+			if user, _, err := s.RotateRefreshToken(ctx, session, "phone"); err != nil || user.ID != target.ID {
+				t.Errorf("rotating the source's session = user %d, %v; want the target", user.ID, err)
+			}
+			wantName := "Jane"
+			if tt.targetProfile {
+				wantName = "Jane Doe"
+			}
+			if profile, err := s.GetProfile(ctx, target.ID); err != nil || profile.DisplayName != wantName {
+				t.Errorf("target profile = %+v, %v; want display name %q", profile, err, wantName)
+			}
+			if moved, err := s.db.Queries.CountAuditLogsForUser(ctx, target.ID); err != nil || moved < auditLogs {
+				t.Errorf("target has %d audit logs, %v; want at least the %d moved", moved, err, auditLogs)
+			}
+			if _, err := s.GetUser(ctx, source.ID); err == nil {
+				t.Error("source still active after the merge")
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"iter"
+
+	"idiomatic-go/optional"
+)
+
+// PageRequest selects a page of a listing ordered by a unique integer key.
+// With Cursor set, the page starts after the item whose key it is and
+// Offset is ignored; items inserted or deleted meanwhile then neither
+// shift nor repeat the ones already returned.
+type PageRequest struct {
+	Limit  int32
+	Offset int32
+	Cursor optional.Option[int32]
+}
+
+// Page is one page of a listing. Next is the cursor of the following page
+// and is unset on the last one.
+type Page[T any] struct {
+	Items []T
+	Next  optional.Option[int32]
+}
+
+// PageFunc fetches the items selected by req
+type PageFunc[T any] func(ctx context.Context, req PageRequest) ([]T, error)
+
+// FetchPage returns the page selected by req, with key giving the cursor
+// of an item. It asks fetch for one item more than req.Limit to learn
+// whether another page follows.
+func FetchPage[T any](ctx context.Context, req PageRequest, fetch PageFunc[T], key func(T) int32) (Page[T], error) {
+	limit := req.Limit
+	req.Limit++
+	items, err := fetch(ctx, req)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	page := Page[T]{Items: items}
+	if len(items) > int(limit) {
+		page.Items = items[:limit]
+		page.Next = optional.Some(key(page.Items[limit-1]))
+	}
+	return page, nil
+}
+
+// Paginate iterates over every item of a listing, fetching batchSize
+// items at a time by cursor. A failed fetch is yielded with the zero item
+// and ends the iteration.
+func Paginate[T any](ctx context.Context, batchSize int32, fetch PageFunc[T], key func(T) int32) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		req := PageRequest{Limit: batchSize}
+		for {
+			page, err := FetchPage(ctx, req, fetch, key)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if !page.Next.IsSet() {
+				return
+			}
+			req.Cursor = page.Next
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/optional"
+	"idiomatic-go/ws"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// profileEvent is the data of profile notifications. Clients fetch the
+// profile itself.
+type profileEvent struct {
+	UserID int32 `json:"user_id"`
+}
+
+// GetUserWithProfile returns user id together with their profile, unset
+// if they have none, in one query. Unlike GetUser it does not read
+// through the user cache.
+func (s *UserService) GetUserWithProfile(ctx context.Context, id int32) (database.User, optional.Option[database.Profile], error) {
+	var none optional.Option[database.Profile]
+	row, err := s.db.Queries.GetUserWithProfile(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.User{}, none, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.User{}, none, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user with profile: %w", err))
+	}
+	// Every profile column is NOT NULL, so a NULL one means no profile row
+	if !row.DisplayName.Valid {
+		return row.User, none, nil
+	}
+	return row.User, optional.Some(database.Profile{
+		UserID:      row.User.ID,
+		DisplayName: row.DisplayName.String,
+		Bio:         row.Bio.String,
+		AvatarUrl:   row.AvatarUrl.String,
+		Locale:      row.Locale.String,
+		Timezone:    row.Timezone.String,
+		CreatedAt:   row.ProfileCreatedAt,
+		UpdatedAt:   row.ProfileUpdatedAt,
+	}), nil
+}
+
+// GetProfile returns the profile of user userID
+func (s *UserService) GetProfile(ctx context.Context, userID int32) (database.Profile, error) {
+	profile, err := s.db.Queries.GetProfile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.Profile{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.Profile{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get profile: %w", err))
+	}
+	return profile, nil
+}
+
+// ProfilesByUserID returns the profiles of those of userIDs that have
+// one, keyed by user ID, in one query
+func (s *UserService) ProfilesByUserID(ctx context.Context, userIDs []int32) (map[int32]database.Profile, error) {
+	profiles, err := s.db.Queries.ListProfilesByUserIDs(ctx, userIDs)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list profiles: %w", err))
+	}
+	byUser := make(map[int32]database.Profile, len(profiles))
+	for _, p := range profiles {
+		byUser[p.UserID] = p
+	}
+	return byUser, nil
+}
+
+// PutProfile creates or replaces the profile of user params.UserID and
+// reports whether it was created. The user's updated_at moves too, since
+// GetUser includes the profile and is versioned by it.
+func (s *UserService) PutProfile(ctx context.Context, params database.UpsertProfileParams) (database.Profile, bool, error) {
+	var profile database.Profile
+	var created bool
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		user, err := queries.GetUserForUpdate(ctx, params.UserID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if err := s.checkConflict(ctx, user); err != nil {
+			return err
+		}
+
+		current, err := queries.GetProfile(ctx, params.UserID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get profile: %w", err))
+		}
+		created = err != nil
+		profile, err = queries.UpsertProfile(ctx, params)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("upsert profile: %w", err))
+		}
+		if err := queries.TouchUser(ctx, params.UserID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("touch user: %w", err))
+		}
+
+		entry := audit.Entry(ctx, params.UserID, "profile_updated")
+		entry.Changes = profileChanges(current, profile).JSON()
+		if _, err := queries.CreateAuditLog(ctx, entry); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return database.Profile{}, false, err
+	}
+	s.forgetUser(ctx, params.UserID)
+	s.notify(ctx, params.UserID, ws.TypeProfileUpdated, profileEvent{UserID: params.UserID})
+	return profile, created, nil
+}
+
+// DeleteProfile removes the profile of user userID
+func (s *UserService) DeleteProfile(ctx context.Context, userID int32) error {
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		profile, err := queries.DeleteProfile(ctx, userID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete profile: %w", err))
+		}
+		if err := queries.TouchUser(ctx, userID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("touch user: %w", err))
+		}
+
+		entry := audit.Entry(ctx, userID, "profile_deleted")
+		entry.Changes = profileChanges(profile, database.Profile{}).JSON()
+		if _, err := queries.CreateAuditLog(ctx, entry); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetUser(ctx, userID)
+	s.notify(ctx, userID, ws.TypeProfileDeleted, profileEvent{UserID: userID})
+	return nil
+}
+
+// profileChanges records the profile fields that differ between before and
+// after; a missing profile is the zero Profile
+func profileChanges(before, after database.Profile) audit.Changes {
+	changes := audit.Changes{}
+	changes.Add("display_name", before.DisplayName, after.DisplayName)
+	changes.Add("bio", before.Bio, after.Bio)
+	changes.Add("avatar_url", before.AvatarUrl, after.AvatarUrl)
+	changes.Add("locale", before.Locale, after.Locale)
+	changes.Add("timezone", before.Timezone, after.Timezone)
+	return changes
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// snapshotQueries is how many of the most expensive statements a snapshot
+// reads; the rest are too cheap to matter
+const snapshotQueries = 500
+
+var (
+	queryCalls = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_query_calls",
+			Help: "Executions of each sqlc query recorded by pg_stat_statements at the last snapshot",
+		},
+		[]string{"query"},
+	)
+	queryExecSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_query_exec_seconds",
+			Help: "Total execution time of each sqlc query recorded by pg_stat_statements at the last snapshot",
+		},
+		[]string{"query"},
+	)
+	queryRows = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_query_rows",
+			Help: "Rows returned or affected by each sqlc query recorded by pg_stat_statements at the last snapshot",
+		},
+		[]string{"query"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(queryCalls, queryExecSeconds, queryRows)
+}
+
+var errQueryStatsUnavailable = custom_errors.NewAPIError(http.StatusNotImplemented, custom_errors.CodeQueryStatsUnavailable, "pg_stat_statements is not enabled in the database")
+
+// QueryStatsService reports query performance from pg_stat_statements,
+// mapping statements back to the sqlc queries that issued them
+type QueryStatsService struct {
+	db     *database.DB
+	logger *slog.Logger
+}
+
+func NewQueryStatsService(db *database.DB, logger *slog.Logger) *QueryStatsService {
+	return &QueryStatsService{db: db, logger: logger}
+}
+
+// TopQueries returns the limit most expensive statements by order, one of
+// database.QueryStatsOrders
+func (s *QueryStatsService) TopQueries(ctx context.Context, order string, limit int32) ([]database.QueryStat, error) {
+	if !slices.Contains(database.QueryStatsOrders, order) {
+		return nil, custom_errors.ErrValidation.WithFields([]custom_errors.FieldError{
+			{Field: "order", Message: fmt.Sprintf("must be one of %v", database.QueryStatsOrders)},
+		})
+	}
+	stats, err := s.db.Queries.QueryStats(ctx, order, limit)
+	if err != nil {
+		return nil, queryStatsError(err)
+	}
+	return stats, nil
+}
+
+// Snapshot copies the pg_stat_statements totals of every sqlc query into
+// the db_query_* gauges. Statements not issued through Queries are left
+// out to keep the label set bounded.
+func (s *QueryStatsService) Snapshot(ctx context.Context) error {
+	stats, err := s.db.Queries.QueryStats(ctx, database.QueryStatsByTotalTime, snapshotQueries)
+	if err != nil {
+		return queryStatsError(err)
+	}
+
+	// A query can have several entries, e.g. one per role or per
+	// sqlcommenter variant that Postgres did not normalise away
+	totals := make(map[string]database.QueryStat)
+	for _, st := range stats {
+		if st.Name == "" {
+			continue
+		}
+		t := totals[st.Name]
+		t.Calls += st.Calls
+		t.Rows += st.Rows
+		t.TotalTime += st.TotalTime
+		totals[st.Name] = t
+	}
+
+	queryCalls.Reset()
+	queryExecSeconds.Reset()
+	queryRows.Reset()
+	for name, t := range totals {
+		queryCalls.WithLabelValues(name).Set(float64(t.Calls))
+		queryExecSeconds.WithLabelValues(name).Set(t.TotalTime.Seconds())
+		queryRows.WithLabelValues(name).Set(float64(t.Rows))
+	}
+	s.logger.DebugContext(ctx, "query stats snapshot taken", "queries", len(totals))
+	return nil
+}
+
+// queryStatsError reports a missing or unloaded pg_stat_statements as
+// unavailable rather than as an internal error
+func queryStatsError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == "42P01" || pgErr.Code == "55000") {
+		return errQueryStatsUnavailable.Wrap(err)
+	}
+	return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("query stats: %w", err))
+}
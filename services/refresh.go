@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// refreshTokenTTL is how long a refresh token stays usable. Every rotation
+// issues a fresh one, so a device that keeps refreshing stays signed in.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	activeSessions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "active_sessions",
+		Help: "Sign-ins holding a usable refresh token at the last count",
+	})
+	activeSessionUsers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "active_session_users",
+		Help: "Users with at least one active sign-in at the last count",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(activeSessions, activeSessionUsers)
+}
+
+var errInvalidRefresh = custom_errors.NewAPIError(http.StatusUnauthorized, custom_errors.CodeInvalidRefresh, "Invalid or expired refresh token")
+
+// IssueRefreshToken starts a new token family for a sign-in on deviceID
+// and returns its first token
+func (s *UserService) IssueRefreshToken(ctx context.Context, userID int32, deviceID string) (string, error) {
+	family := pgtype.UUID{Bytes: uuid.New(), Valid: true}
+	return s.createRefreshToken(ctx, s.db.Queries, userID, family, pgtype.Int4{}, deviceID)
+}
+
+// RotateRefreshToken exchanges raw, presented by deviceID, for a new token
+// in the same family and returns the user it belongs to.
+//
+// Each token is good for one rotation. A token presented again, or by a
+// device other than the one it was issued to, was copied: the whole
+// family is revoked, so neither the thief nor the victim can refresh
+// again and the victim has to sign in anew.
+func (s *UserService) RotateRefreshToken(ctx context.Context, raw, deviceID string) (database.User, string, error) {
+	var (
+		user   database.User
+		next   string
+		replay string // why the family was revoked, if it was
+		token  database.RefreshToken
+	)
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		var err error
+		token, err = queries.GetRefreshTokenForUpdate(ctx, hashToken(raw))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidRefresh.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get refresh token: %w", err))
+		}
+		switch {
+		case token.RevokedAt.Valid, !s.clock.Now().Before(token.ExpiresAt.Time):
+			return errInvalidRefresh
+		case token.UsedAt.Valid:
+			replay = "reused"
+		case token.DeviceID != deviceID:
+			replay = "device_mismatch"
+		}
+
+		if replay != "" {
+			// Committed even though the caller is refused
+			if _, err := queries.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke refresh token family: %w", err))
+			}
+			_, err := queries.CreateAuditLog(ctx, audit.Entry(ctx, token.UserID, "refresh_token_replayed"))
+			if err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+			}
+			return nil
+		}
+
+		user, err = queries.GetUser(ctx, token.UserID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidRefresh.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if err := queries.MarkRefreshTokenUsed(ctx, token.ID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("mark refresh token used: %w", err))
+		}
+		next, err = s.createRefreshToken(ctx, queries, user.ID, token.FamilyID, pgtype.Int4{Int32: token.ID, Valid: true}, deviceID)
+		return err
+	})
+	if err != nil {
+		return database.User{}, "", err
+	}
+	if replay != "" {
+		s.logger.WarnContext(ctx, "refresh token replay detected, revoked token family",
+			"user_id", token.UserID,
+			"family_id", uuid.UUID(token.FamilyID.Bytes).String(),
+			"issued_device", token.DeviceID,
+			"request_device", deviceID,
+			"reason", replay,
+		)
+		return database.User{}, "", errInvalidRefresh
+	}
+	return user, next, nil
+}
+
+func (s *UserService) createRefreshToken(ctx context.Context, queries *database.Queries, userID int32, family pgtype.UUID, parent pgtype.Int4, deviceID string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("generate refresh token: %w", err))
+	}
+	token := hex.EncodeToString(raw)
+
+	_, err := queries.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		UserID:    userID,
+		FamilyID:  family,
+		ParentID:  parent,
+		DeviceID:  deviceID,
+		TokenHash: hashToken(token),
+		ExpiresAt: pgtype.Timestamptz{Time: s.clock.Now().Add(refreshTokenTTL), Valid: true},
+	})
+	if err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create refresh token: %w", err))
+	}
+	return token, nil
+}
+
+// ListDevices returns the devices holding a usable refresh token for userID
+func (s *UserService) ListDevices(ctx context.Context, userID int32) ([]database.ListUserDevicesRow, error) {
+	devices, err := s.db.Queries.ListUserDevices(ctx, database.ListUserDevicesParams{
+		UserID:    userID,
+		ExpiresAt: pgtype.Timestamptz{Time: s.clock.Now(), Valid: true},
+	})
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list devices: %w", err))
+	}
+	return devices, nil
+}
+
+// RevokeDevice signs deviceID out: its refresh tokens stop working, while
+// access tokens it already holds run until they expire
+func (s *UserService) RevokeDevice(ctx context.Context, userID int32, deviceID string) error {
+	n, err := s.db.Queries.RevokeDeviceRefreshTokens(ctx, database.RevokeDeviceRefreshTokensParams{
+		UserID:   userID,
+		DeviceID: deviceID,
+	})
+	if err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke device refresh tokens: %w", err))
+	}
+	if n == 0 {
+		return custom_errors.ErrNotFound
+	}
+	s.logger.InfoContext(ctx, "revoked device refresh tokens", "user_id", userID, "device_id", deviceID)
+	return nil
+}
+
+// RevokeAllDevices signs every device of userID out and returns how many
+// refresh tokens were revoked
+func (s *UserService) RevokeAllDevices(ctx context.Context, userID int32) (int64, error) {
+	n, err := s.db.Queries.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return 0, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke user refresh tokens: %w", err))
+	}
+	s.logger.InfoContext(ctx, "revoked all refresh tokens", "user_id", userID, "count", n)
+	return n, nil
+}
+
+// SessionStats counts sign-ins, that is refresh token families, that can
+// still be refreshed
+type SessionStats struct {
+	Sessions int64 `json:"sessions" example:"1200"`
+	Users    int64 `json:"users" example:"800"`
+}
+
+// CountSessions counts the active sign-ins and records them as metrics
+func (s *UserService) CountSessions(ctx context.Context) (SessionStats, error) {
+	row, err := s.db.Queries.CountActiveSessions(ctx, pgtype.Timestamptz{Time: s.clock.Now(), Valid: true})
+	if err != nil {
+		return SessionStats{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("count active sessions: %w", err))
+	}
+	activeSessions.Set(float64(row.Sessions))
+	activeSessionUsers.Set(float64(row.Users))
+	return SessionStats{Sessions: row.Sessions, Users: row.Users}, nil
+}
+
+// RevokeAllSessions signs every user out of every device, for incident
+// response, and returns how many refresh tokens were revoked. Access
+// tokens are left to the caller.
+func (s *UserService) RevokeAllSessions(ctx context.Context, actorID int32) (int64, error) {
+	var n int64
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		var err error
+		n, err = queries.RevokeAllRefreshTokens(ctx, pgtype.Timestamptz{Time: s.clock.Now(), Valid: true})
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke all refresh tokens: %w", err))
+		}
+		_, err = queries.CreateAuditLog(ctx, audit.Entry(ctx, actorID, "sessions_revoked_all"))
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.logger.WarnContext(ctx, "admin revoked all sessions", "actor_id", actorID, "count", n)
+	return n, nil
+}
+
+// PruneRefreshTokens deletes refresh tokens that expired more than
+// retention ago, keeping recent lineage around for investigating replays.
+// Children of a pruned token are left without a parent.
+func (s *UserService) PruneRefreshTokens(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := pgtype.Timestamptz{Time: s.clock.Now().Add(-retention), Valid: true}
+	n, err := s.db.Queries.DeleteExpiredRefreshTokens(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired refresh tokens: %w", err)
+	}
+	if n > 0 {
+		s.logger.InfoContext(ctx, "pruned expired refresh tokens", "count", n)
+	}
+	return n, nil
+}
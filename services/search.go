@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/optional"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// likeEscaper escapes the ILIKE wildcards in a search query so that they
+// match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// UserSearch selects the users SearchUsers returns. Query matches any part
+// of the username or email, case-insensitively; unset options match every
+// user.
+type UserSearch struct {
+	Query        string
+	Role         optional.Option[string]
+	CreatedAfter optional.Option[time.Time]
+}
+
+// UserMatch is a user found by SearchUsers. Rank is the trigram
+// similarity of the query to the closer of username and email, from 0 to 1.
+type UserMatch struct {
+	User database.User
+	Rank float32
+}
+
+// SearchUsers returns a page of the active users matching search, best
+// match first. more reports whether another page follows. Ranking is not
+// stable under concurrent writes, so pages are selected by offset.
+func (s *UserService) SearchUsers(ctx context.Context, search UserSearch, limit, offset int32) (matches []UserMatch, more bool, err error) {
+	params := database.SearchUsersParams{
+		Query:      search.Query,
+		Pattern:    "%" + likeEscaper.Replace(search.Query) + "%",
+		PageLimit:  limit + 1,
+		PageOffset: offset,
+	}
+	if role, ok := search.Role.Get(); ok {
+		params.Role = pgtype.Text{String: role, Valid: true}
+	}
+	if after, ok := search.CreatedAfter.Get(); ok {
+		params.CreatedAfter = pgtype.Timestamptz{Time: after, Valid: true}
+	}
+
+	rows, err := s.db.Queries.SearchUsers(ctx, params)
+	if err != nil {
+		return nil, false, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("search users: %w", err))
+	}
+	if len(rows) > int(limit) {
+		rows, more = rows[:limit], true
+	}
+	matches = make([]UserMatch, 0, len(rows))
+	for _, row := range rows {
+		matches = append(matches, UserMatch{User: row.User, Rank: row.Rank})
+	}
+	return matches, more, nil
+}
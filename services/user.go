@@ -2,14 +2,30 @@ package services
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
 	"time"
 
+	"idiomatic-go/audit"
+	"idiomatic-go/cache"
+	"idiomatic-go/clock"
 	"idiomatic-go/database"
 	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/mailer"
+	"idiomatic-go/optional"
+	"idiomatic-go/passwords"
+	"idiomatic-go/region"
+	"idiomatic-go/signer"
+	"idiomatic-go/storage"
+	"idiomatic-go/webhooks"
 
-	"github.com/sirupsen/logrus"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type User struct {
@@ -23,69 +39,472 @@ type User struct {
 }
 
 type UserService struct {
-	db     *database.DB // Change to full DB to access transactions
-	logger *logrus.Logger
+	db        *database.DB // Change to full DB to access transactions
+	logger    *slog.Logger
+	clock     clock.Clock
+	mailer    mailer.Mailer
+	links     *signer.Signer
+	verifyURL string // base URL of the email verification link
+	resetURL  string // base URL of the password reset page
+	cache     cache.Cache
+	cacheTTL  time.Duration
+	hasher    *passwords.Hasher
+	conflicts *region.Detector
+	notifier  Notifier // nil when nothing is pushed to clients
+	templates *EmailTemplateService
+	files     storage.Store // content of uploaded files; nil leaves it behind on purge
 }
 
-func NewUserService(db *database.DB, logger *logrus.Logger) *UserService {
+// Notifier pushes real-time notifications to a user's connected clients
+type Notifier interface {
+	Notify(ctx context.Context, userID int64, kind string, data any) error
+}
+
+func NewUserService(db *database.DB, logger *slog.Logger, clk clock.Clock, mail mailer.Mailer, links *signer.Signer, userCache cache.Cache, cacheTTL time.Duration, hasher *passwords.Hasher, conflicts *region.Detector, templates *EmailTemplateService, verifyURL, resetURL string) *UserService {
 	return &UserService{
-		db:     db,
-		logger: logger,
+		db:        db,
+		logger:    logger,
+		clock:     clk,
+		mailer:    mail,
+		links:     links,
+		verifyURL: verifyURL,
+		resetURL:  resetURL,
+		cache:     userCache,
+		cacheTTL:  cacheTTL,
+		hasher:    hasher,
+		conflicts: conflicts,
+		templates: templates,
+	}
+}
+
+// SetNotifier has changes pushed to the clients of the user they concern
+func (s *UserService) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
+// SetFileStore has purged users' file content deleted from store
+func (s *UserService) SetFileStore(store storage.Store) {
+	s.files = store
+}
+
+// notify pushes a notification if a Notifier is set. Failures are only
+// logged: the change itself is committed and clients can refetch.
+func (s *UserService) notify(ctx context.Context, userID int32, kind string, data any) {
+	if s.notifier == nil {
+		return
+	}
+	if err := s.notifier.Notify(ctx, int64(userID), kind, data); err != nil {
+		s.logger.WarnContext(ctx, "failed to send notification", "error", err, "user_id", userID, "type", kind)
 	}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, params database.CreateUserParams) (database.User, error) {
 	var user database.User
+	var verificationToken string
 	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
 		// Hash password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.PasswordHash), bcrypt.DefaultCost)
+		hashedPassword, err := s.hasher.Hash(params.PasswordHash)
 		if err != nil {
-			s.logger.WithError(err).Error("failed to hash password")
-			return custom_errors.ErrInternalServerError
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err))
 		}
-		params.PasswordHash = string(hashedPassword)
+		params.PasswordHash = hashedPassword
 
 		// Create user
 		user, err = queries.CreateUser(ctx, params)
 		if err != nil {
-			s.logger.WithError(err).Error("failed to create user")
-			return custom_errors.ErrInternalServerError
+			if conflict := userConflict(err); conflict != nil {
+				return conflict
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create user: %w", err))
 		}
 
 		// Create audit log
-		auditParams := database.CreateAuditLogParams{
-			UserID: user.ID,
-			Action: "user_created",
-		}
-		_, err = queries.CreateAuditLog(ctx, auditParams)
+		_, err = queries.CreateAuditLog(ctx, audit.Entry(ctx, user.ID, "user_created"))
 		if err != nil {
-			s.logger.WithError(err).Error("failed to create audit log")
-			return custom_errors.ErrInternalServerError
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		if err := s.publish(ctx, queries, webhooks.EventUserCreated, user); err != nil {
+			return err
 		}
 
-		return nil
+		// Create email verification token
+		verificationToken, err = s.createVerification(ctx, queries, user.ID)
+		return err
 	})
 	if err != nil {
 		return database.User{}, err
 	}
+
+	s.sendVerificationEmail(ctx, user, verificationToken)
 	return user, nil
 }
 
 func (s *UserService) Login(ctx context.Context, email, password string) (database.User, error) {
-	user, err := s.db.Queries.GetUserByEmail(ctx, email)
+	user, err := s.cachedUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			s.hasher.CompareDummy(password)
+			s.logger.InfoContext(ctx, "login failed: email not registered", "email_hash", emailFingerprint(email))
+			return database.User{}, custom_errors.ErrUnauthorized.Wrap(err)
+		}
+		return database.User{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user by email: %w", err))
+	}
+
+	if err := s.hasher.Compare(user.PasswordHash, password); err != nil {
+		s.logger.InfoContext(ctx, "login failed: invalid password", "user_id", user.ID)
+		s.auditLogin(ctx, user.ID, "login_failed")
+		return database.User{}, custom_errors.ErrUnauthorized.Wrap(err)
+	}
+
+	if !user.EmailVerified {
+		return database.User{}, custom_errors.ErrEmailNotVerified
+	}
+
+	s.auditLogin(ctx, user.ID, "login")
+	return user, nil
+}
+
+// auditLogin records a login attempt on an existing account. The caller is
+// not authenticated yet, so the account is recorded as the actor. A failure
+// to record is logged rather than failing the login.
+func (s *UserService) auditLogin(ctx context.Context, userID int32, action string) {
+	params := audit.Entry(ctx, userID, action)
+	params.ActorID = pgtype.Int4{Int32: userID, Valid: true}
+	if _, err := s.db.Queries.CreateAuditLog(ctx, params); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write audit log", "error", err, "user_id", userID, "action", action)
+	}
+}
+
+func (s *UserService) GetUser(ctx context.Context, id int32) (database.User, error) {
+	user, err := s.cachedUser(ctx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			s.logger.WithField("email", email).Warn("user not found")
-			return database.User{}, custom_errors.ErrUnauthorized
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.User{}, custom_errors.ErrNotFound.Wrap(err)
 		}
-		s.logger.WithError(err).Error("failed to get user")
-		return database.User{}, custom_errors.ErrInternalServerError
+		return database.User{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+	}
+	return user, nil
+}
+
+// ResolveUser returns the current role and token version of a user, for
+// authenticating minimal tokens. It reads through the user cache, which
+// every change to the user invalidates.
+func (s *UserService) ResolveUser(ctx context.Context, userID int64) (string, int32, error) {
+	if userID > math.MaxInt32 {
+		return "", 0, custom_errors.ErrNotFound
+	}
+	user, err := s.GetUser(ctx, int32(userID))
+	if err != nil {
+		return "", 0, err
+	}
+	return user.Role, user.TokenVersion, nil
+}
+
+// ListUsers returns a page of users. When columns is non-nil only those
+// columns are selected and the other fields are left zero.
+func (s *UserService) ListUsers(ctx context.Context, columns []string, limit, offset int32) ([]database.User, error) {
+	params := database.ListUsersParams{
+		Limit:  limit,
+		Offset: offset,
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		s.logger.WithField("email", email).Warn("invalid password")
-		return database.User{}, custom_errors.ErrUnauthorized
+	var users []database.User
+	var err error
+	if columns != nil {
+		users, err = s.db.Queries.ListUsersProjected(ctx, columns, params)
+	} else {
+		users, err = s.db.Queries.ListUsers(ctx, params)
 	}
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list users: %w", err))
+	}
+	return users, nil
+}
+
+// ListUsersPage returns a page of users that are not deleted, in ID order
+func (s *UserService) ListUsersPage(ctx context.Context, req PageRequest) (Page[database.User], error) {
+	return FetchPage(ctx, req, s.userPages(UserFilter{}), userCursor)
+}
+
+// Precondition decides whether a write may go ahead given the row as it
+// currently is, for HTTP conditional requests. It runs with the row
+// locked, so the row cannot change between the check and the write.
+type Precondition func(current database.User) bool
+
+// UpdateUser replaces the user's username, email and password. A non-nil
+// precondition that rejects the current row fails it with 412.
+func (s *UserService) UpdateUser(ctx context.Context, params database.UpdateUserParams, precondition Precondition) (database.User, error) {
+	var user database.User
+	var verificationToken string
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		current, err := queries.GetUserForUpdate(ctx, params.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if precondition != nil && !precondition(current) {
+			return custom_errors.ErrPreconditionFailed
+		}
+		if err := s.checkConflict(ctx, current); err != nil {
+			return err
+		}
+
+		hashedPassword, err := s.hasher.Hash(params.PasswordHash)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err))
+		}
+		params.PasswordHash = hashedPassword
+
+		user, err = queries.UpdateUser(ctx, params)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			if conflict := userConflict(err); conflict != nil {
+				return conflict
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update user: %w", err))
+		}
+		if verificationToken, err = s.reverifyEmail(ctx, queries, current, user); err != nil {
+			return err
+		}
+
+		// A full update always sets the password
+		entry := audit.Entry(ctx, user.ID, "user_updated")
+		entry.Changes = userChanges(current, user, true).JSON()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
 
+		return s.publish(ctx, queries, webhooks.EventUserUpdated, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	s.forgetUser(ctx, user.ID)
+	if verificationToken != "" {
+		s.sendVerificationEmail(ctx, user, verificationToken)
+	}
 	return user, nil
 }
+
+func (s *UserService) DeleteUser(ctx context.Context, id int32) error {
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		// Look the user up first so a missing user surfaces as not found
+		// rather than a silent no-op delete.
+		user, err := queries.GetUser(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+
+		if err := softDeleteUser(ctx, queries, id, s.clock.Now()); err != nil {
+			return err
+		}
+
+		_, err = queries.CreateAuditLog(ctx, audit.Entry(ctx, id, "user_deleted"))
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return s.publish(ctx, queries, webhooks.EventUserDeleted, user)
+	})
+	if err != nil {
+		return err
+	}
+	s.forgetUser(ctx, id)
+	return nil
+}
+
+// softDeleteUser marks the user deleted as of now and invalidates every
+// token issued to it, so a later restore does not bring old sessions back
+// with it
+func softDeleteUser(ctx context.Context, queries *database.Queries, id int32, now time.Time) error {
+	if err := queries.IncrementTokenVersion(ctx, id); err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("bump token version: %w", err))
+	}
+	if err := queries.DeleteUser(ctx, database.DeleteUserParams{DeletedAt: pgtype.Timestamptz{Time: now, Valid: true}, ID: id}); err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete user: %w", err))
+	}
+	if _, err := queries.RevokeUserRefreshTokens(ctx, id); err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("revoke refresh tokens: %w", err))
+	}
+	return nil
+}
+
+// RestoreUser undoes a soft delete. Users that are not deleted, or were
+// already purged, are reported as not found, and a user whose username or
+// email was registered again since as a conflict.
+func (s *UserService) RestoreUser(ctx context.Context, id int32) (database.User, error) {
+	var user database.User
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		var err error
+		user, err = queries.RestoreUser(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			if conflict := userConflict(err); conflict != nil {
+				return conflict
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("restore user: %w", err))
+		}
+
+		_, err = queries.CreateAuditLog(ctx, audit.Entry(ctx, user.ID, "user_restored"))
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return s.publish(ctx, queries, webhooks.EventUserRestored, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	s.forgetUser(ctx, user.ID)
+	return user, nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted more than
+// retention ago, together with their audit logs, tokens and files. File
+// content is deleted before the rows; a user whose content could not all
+// be deleted is kept, and retried on the next run, so no content is left
+// behind without a row pointing at it.
+func (s *UserService) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := pgtype.Timestamptz{Time: s.clock.Now().Add(-retention), Valid: true}
+	// Never nil: ANY of a NULL array is NULL, which would keep every user
+	keep := []int32{}
+	if s.files != nil {
+		files, err := s.db.Queries.ListPurgeableFiles(ctx, cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("list files of deleted users: %w", err)
+		}
+		for _, file := range files {
+			key := uuid.UUID(file.ID.Bytes).String()
+			if err := s.files.Delete(ctx, key); err != nil {
+				s.logger.WarnContext(ctx, "failed to delete file content, user kept until the next purge", "error", err, "file_id", key, "user_id", file.OwnerID)
+				if !slices.Contains(keep, file.OwnerID) {
+					keep = append(keep, file.OwnerID)
+				}
+			}
+		}
+	}
+	n, err := s.db.Queries.PurgeDeletedUsers(ctx, database.PurgeDeletedUsersParams{Cutoff: cutoff, KeepIds: keep})
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted users: %w", err)
+	}
+	if n > 0 {
+		s.logger.InfoContext(ctx, "purged soft-deleted users", "count", n, "retention", retention)
+	}
+	return n, nil
+}
+
+var errEmptyPatch = custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeBadRequest, "The patch sets no fields; send at least one of username, email, password")
+
+// UserPatch describes a JSON Merge Patch against a user. Absent fields are
+// left unchanged; users have no nullable fields, so null is rejected.
+type UserPatch struct {
+	Username optional.Option[string]
+	Email    optional.Option[string]
+	Password optional.Option[string]
+}
+
+// IsEmpty reports whether the patch sets no field
+func (p UserPatch) IsEmpty() bool {
+	return !p.Username.IsSet() && !p.Email.IsSet() && !p.Password.IsSet()
+}
+
+// PatchUser changes only the fields set in patch. The row is locked while
+// the patch is applied, so concurrent patches to different fields both
+// take effect.
+func (s *UserService) PatchUser(ctx context.Context, id int32, patch UserPatch) (database.User, error) {
+	if patch.IsEmpty() {
+		return database.User{}, errEmptyPatch
+	}
+
+	var user database.User
+	var verificationToken string
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		current, err := queries.GetUserForUpdate(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return custom_errors.ErrNotFound.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get user: %w", err))
+		}
+		if err := s.checkConflict(ctx, current); err != nil {
+			return err
+		}
+
+		params := database.PatchUserParams{ID: id}
+		if username, ok := patch.Username.Get(); ok {
+			params.Username = pgtype.Text{String: username, Valid: true}
+		}
+		if email, ok := patch.Email.Get(); ok {
+			params.Email = pgtype.Text{String: email, Valid: true}
+		}
+		if password, ok := patch.Password.Get(); ok {
+			hashedPassword, err := s.hasher.Hash(password)
+			if err != nil {
+				return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("hash password: %w", err))
+			}
+			params.PasswordHash = pgtype.Text{String: hashedPassword, Valid: true}
+		}
+
+		user, err = queries.PatchUser(ctx, params)
+		if err != nil {
+			if conflict := userConflict(err); conflict != nil {
+				return conflict
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("patch user: %w", err))
+		}
+		if verificationToken, err = s.reverifyEmail(ctx, queries, current, user); err != nil {
+			return err
+		}
+
+		entry := audit.Entry(ctx, user.ID, "user_updated")
+		entry.Changes = userChanges(current, user, patch.Password.IsSet()).JSON()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+
+		return s.publish(ctx, queries, webhooks.EventUserUpdated, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	s.forgetUser(ctx, user.ID)
+	if verificationToken != "" {
+		s.sendVerificationEmail(ctx, user, verificationToken)
+	}
+	return user, nil
+}
+
+// checkConflict runs the cross-region conflict hooks for an update of
+// current, the row as read in the updating transaction
+func (s *UserService) checkConflict(ctx context.Context, current database.User) error {
+	return s.conflicts.Check(ctx, "users", current.ID, current.WriteRegion, current.UpdatedAt.Time)
+}
+
+// userChanges lists the fields an update changed between before and after.
+// The password hash changes on every rehash, so whether the password was
+// set is passed in and only that is recorded.
+func userChanges(before, after database.User, passwordSet bool) audit.Changes {
+	changes := audit.Changes{}
+	changes.Add("username", before.Username, after.Username)
+	changes.Add("email", before.Email, after.Email)
+	changes.Add("role", before.Role, after.Role)
+	changes.Add("email_verified", before.EmailVerified, after.EmailVerified)
+	changes.AddSecret("password", passwordSet)
+	return changes
+}
+
+// passwordChange is the changes of an action that only set the password
+func passwordChange() []byte {
+	changes := audit.Changes{}
+	changes.AddSecret("password", true)
+	return changes.JSON()
+}
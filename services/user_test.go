@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"idiomatic-go/cache"
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/mailer"
+	"idiomatic-go/optional"
+	"idiomatic-go/passwords"
+	"idiomatic-go/region"
+	"idiomatic-go/signer"
+	"idiomatic-go/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTestUserService returns a UserService on a fresh SQLite database,
+// with its clock
+func newTestUserService(t *testing.T) (*UserService, *clock.Mock) {
+	t.Helper()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := database.NewDB(ctx, database.Config{DBConn: "sqlite:" + filepath.Join(t.TempDir(), "test.db")}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	links, err := signer.New(clk, nil, signer.Key{ID: "test", Secret: []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewUserService(db, logger, clk, mailer.NewLogMailer(logger), links, cache.Noop{}, time.Minute,
+		passwords.NewHasher(bcrypt.MinCost), region.NewDetector("", 0, clk, logger),
+		NewEmailTemplateService(db, logger), "https://example.com/verify", "https://example.com/reset")
+	return s, clk
+}
+
+func createTestUser(t *testing.T, s *UserService, name string) database.User {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), database.CreateUserParams{Username: name, Email: name + "@example.com", PasswordHash: "password"})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", name, err)
+	}
+	return user
+}
+
+func TestUserConflicts(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUserService(t)
+	jane := createTestUser(t, s, "jane")
+	john := createTestUser(t, s, "john")
+
+	for _, tt := range []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"create with a taken username", func() error {
+			_, err := s.CreateUser(ctx, database.CreateUserParams{Username: jane.Username, Email: "new@example.com", PasswordHash: "password"})
+			return err
+		}, errUsernameTaken},
+		{"create with a taken email", func() error {
+			_, err := s.CreateUser(ctx, database.CreateUserParams{Username: "new", Email: jane.Email, PasswordHash: "password"})
+			return err
+		}, errEmailTaken},
+		{"update to a taken username", func() error {
+			_, err := s.UpdateUser(ctx, database.UpdateUserParams{ID: john.ID, Username: jane.Username, Email: john.Email, PasswordHash: "password"}, nil)
+			return err
+		}, errUsernameTaken},
+		{"update to a taken email", func() error {
+			_, err := s.UpdateUser(ctx, database.UpdateUserParams{ID: john.ID, Username: john.Username, Email: jane.Email, PasswordHash: "password"}, nil)
+			return err
+		}, errEmailTaken},
+		{"patch to a taken username", func() error {
+			_, err := s.PatchUser(ctx, john.ID, UserPatch{Username: optional.Some(jane.Username)})
+			return err
+		}, errUsernameTaken},
+		{"patch to a taken email", func() error {
+			_, err := s.PatchUser(ctx, john.ID, UserPatch{Email: optional.Some(jane.Email)})
+			return err
+		}, errEmailTaken},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeletedUserReleasesUsernameAndEmail(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUserService(t)
+	jane := createTestUser(t, s, "jane")
+	if err := s.DeleteUser(ctx, jane.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	again := createTestUser(t, s, "jane")
+	if again.ID == jane.ID {
+		t.Fatalf("re-registration reused ID %d", jane.ID)
+	}
+	// Both are taken again; which conflict is reported is up to the database
+	if _, err := s.RestoreUser(ctx, jane.ID); !errors.Is(err, errUsernameTaken) && !errors.Is(err, errEmailTaken) {
+		t.Fatalf("RestoreUser error = %v, want a username or email conflict", err)
+	}
+}
+
+// failingDeletes is a Store whose deletes fail while fail is set
+type failingDeletes struct {
+	storage.Store
+	fail bool
+}
+
+func (f *failingDeletes) Delete(ctx context.Context, key string) error {
+	if f.fail {
+		return errors.New("store unavailable")
+	}
+	return f.Store.Delete(ctx, key)
+}
+
+func TestPurgeDeletedUsersDeletesFiles(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestUserService(t)
+	dir, err := storage.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &failingDeletes{Store: dir, fail: true}
+	s.SetFileStore(store)
+	files := NewFileService(s.db, store, nil, s.links, s.logger, clk, FileConfig{})
+
+	jane := createTestUser(t, s, "jane")
+	file, err := files.Upload(ctx, jane.ID, "notes.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(ctx, jane.ID); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(31 * 24 * time.Hour)
+
+	// The content cannot be deleted, so the user stays for the next run
+	if n, err := s.PurgeDeletedUsers(ctx, 30*24*time.Hour); err != nil || n != 0 {
+		t.Fatalf("PurgeDeletedUsers with failing deletes = %d, %v; want 0", n, err)
+	}
+	if _, err := files.GetFile(ctx, FileID(file)); err != nil {
+		t.Fatalf("file row gone after a failed content delete: %v", err)
+	}
+
+	store.fail = false
+	if n, err := s.PurgeDeletedUsers(ctx, 30*24*time.Hour); err != nil || n != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v; want 1", n, err)
+	}
+	if _, err := dir.Open(ctx, FileID(file)); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("content after purge: %v, want %v", err, storage.ErrNotFound)
+	}
+	if _, err := files.GetFile(ctx, FileID(file)); !errors.Is(err, custom_errors.ErrNotFound) {
+		t.Fatalf("file row after purge: %v, want not found", err)
+	}
+}
+
+func TestPurgeDeletedUsersCutoff(t *testing.T) {
+	ctx := context.Background()
+	s, clk := newTestUserService(t)
+	const retention = 30 * 24 * time.Hour
+	jane := createTestUser(t, s, "jane")
+	if err := s.DeleteUser(ctx, jane.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	clk.Advance(retention - time.Second)
+	if n, err := s.PurgeDeletedUsers(ctx, retention); err != nil || n != 0 {
+		t.Fatalf("PurgeDeletedUsers within retention = %d, %v; want 0", n, err)
+	}
+	clk.Advance(2 * time.Second)
+	if n, err := s.PurgeDeletedUsers(ctx, retention); err != nil || n != 1 {
+		t.Fatalf("PurgeDeletedUsers past retention = %d, %v; want 1", n, err)
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"idiomatic-go/audit"
+	"idiomatic-go/database"
+	"idiomatic-go/emails"
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const verificationTTL = 24 * time.Hour
+
+var errInvalidVerification = custom_errors.NewAPIError(http.StatusBadRequest, custom_errors.CodeInvalidVerification, "Invalid or expired verification token")
+
+// createVerification stores the hash of a new random token for userID and
+// returns the token itself, which is only ever sent to the user.
+func (s *UserService) createVerification(ctx context.Context, queries *database.Queries, userID int32) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("generate verification token: %w", err))
+	}
+	token := hex.EncodeToString(raw)
+
+	_, err := queries.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: pgtype.Timestamptz{Time: s.clock.Now().Add(verificationTTL), Valid: true},
+	})
+	if err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create email verification: %w", err))
+	}
+	return token, nil
+}
+
+// reverifyEmail restarts verification when an update changed the user's
+// email from before to after; the update itself clears email_verified.
+// Tokens mailed to the old address are discarded, and the returned token
+// is to be mailed to the new one once the transaction commits. It returns
+// "" when the email did not change.
+func (s *UserService) reverifyEmail(ctx context.Context, queries *database.Queries, before, after database.User) (string, error) {
+	if before.Email == after.Email {
+		return "", nil
+	}
+	if err := queries.DeleteEmailVerificationsForUser(ctx, after.ID); err != nil {
+		return "", custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete email verifications: %w", err))
+	}
+	return s.createVerification(ctx, queries, after.ID)
+}
+
+// sendVerificationEmail mails the verification link. Failures are logged
+// rather than returned: the account exists, and verification can be retried.
+func (s *UserService) sendVerificationEmail(ctx context.Context, user database.User, token string) {
+	link, err := s.links.Sign(s.verifyURL+"?token="+url.QueryEscape(token), verificationTTL, false)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to sign verification link", "error", err, "user_id", user.ID)
+		return
+	}
+	s.sendAccountTemplate(ctx, user.ID, user.Email, emails.VerifyEmail, emails.AccountData{Username: user.Username, Link: link})
+}
+
+// VerifyEmail marks the owner of token as verified and consumes every
+// outstanding token for that user
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (database.User, error) {
+	var user database.User
+	err := s.db.WithTx(ctx, func(queries *database.Queries) error {
+		verification, err := queries.GetEmailVerification(ctx, hashToken(token))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errInvalidVerification.Wrap(err)
+			}
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get email verification: %w", err))
+		}
+		if !s.clock.Now().Before(verification.ExpiresAt.Time) {
+			return errInvalidVerification
+		}
+
+		user, err = queries.MarkEmailVerified(ctx, verification.UserID)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("mark email verified: %w", err))
+		}
+		if err := queries.DeleteEmailVerificationsForUser(ctx, verification.UserID); err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete email verifications: %w", err))
+		}
+
+		entry := audit.Entry(ctx, user.ID, "email_verified")
+		entry.Changes = audit.Changes{"email_verified": {From: false, To: true}}.JSON()
+		_, err = queries.CreateAuditLog(ctx, entry)
+		if err != nil {
+			return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create audit log: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	s.forgetUser(ctx, user.ID)
+	return user, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+	custom_errors "idiomatic-go/errors"
+	"idiomatic-go/webhooks"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// WebhookService manages webhook endpoints and their delivery logs
+type WebhookService struct {
+	db     *database.DB
+	logger *slog.Logger
+	clock  clock.Clock
+}
+
+func NewWebhookService(db *database.DB, logger *slog.Logger, clk clock.Clock) *WebhookService {
+	return &WebhookService{db: db, logger: logger, clock: clk}
+}
+
+// WebhookParams are the settable fields of a webhook
+type WebhookParams struct {
+	URL         string
+	Events      []string
+	Description string
+	Active      bool // ignored on create; new webhooks start active
+}
+
+func (p WebhookParams) validate() error {
+	var fields []custom_errors.FieldError
+	u, err := url.Parse(p.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		fields = append(fields, custom_errors.FieldError{Field: "url", Message: "must be an absolute http or https URL"})
+	}
+	if len(p.Events) == 0 {
+		fields = append(fields, custom_errors.FieldError{Field: "events", Message: "must name at least one event"})
+	}
+	for _, event := range p.Events {
+		if !slices.Contains(webhooks.Events, event) {
+			fields = append(fields, custom_errors.FieldError{Field: "events", Message: fmt.Sprintf("unknown event %q", event)})
+		}
+	}
+	if fields != nil {
+		return custom_errors.ErrValidation.WithFields(fields)
+	}
+	return nil
+}
+
+// CreateWebhook registers an endpoint with a freshly generated signing
+// secret. The secret is only ever returned here.
+func (s *WebhookService) CreateWebhook(ctx context.Context, params WebhookParams) (database.Webhook, error) {
+	if err := params.validate(); err != nil {
+		return database.Webhook{}, err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return database.Webhook{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("generate webhook secret: %w", err))
+	}
+
+	hook, err := s.db.Queries.CreateWebhook(ctx, database.CreateWebhookParams{
+		Url:         params.URL,
+		Secret:      hex.EncodeToString(raw),
+		Events:      params.Events,
+		Description: params.Description,
+	})
+	if err != nil {
+		return database.Webhook{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create webhook: %w", err))
+	}
+	s.logger.InfoContext(ctx, "webhook created", "webhook_id", hook.ID, "events", hook.Events)
+	return hook, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id int32) (database.Webhook, error) {
+	hook, err := s.db.Queries.GetWebhook(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.Webhook{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.Webhook{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get webhook: %w", err))
+	}
+	return hook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	hooks, err := s.db.Queries.ListWebhooks(ctx)
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list webhooks: %w", err))
+	}
+	return hooks, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int32, params WebhookParams) (database.Webhook, error) {
+	if err := params.validate(); err != nil {
+		return database.Webhook{}, err
+	}
+	hook, err := s.db.Queries.UpdateWebhook(ctx, database.UpdateWebhookParams{
+		ID:          id,
+		Url:         params.URL,
+		Events:      params.Events,
+		Description: params.Description,
+		Active:      params.Active,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.Webhook{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.Webhook{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("update webhook: %w", err))
+	}
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int32) error {
+	n, err := s.db.Queries.DeleteWebhook(ctx, id)
+	if err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("delete webhook: %w", err))
+	}
+	if n == 0 {
+		return custom_errors.ErrNotFound
+	}
+	s.logger.InfoContext(ctx, "webhook deleted", "webhook_id", id)
+	return nil
+}
+
+// ListDeliveries returns the most recent deliveries to a webhook, newest
+// first
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID, limit int32) ([]database.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.db.Queries.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+		WebhookID: webhookID,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("list webhook deliveries: %w", err))
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues the payload of an earlier delivery again as a new
+// delivery, leaving the log of the original intact
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID int32) (database.WebhookDelivery, error) {
+	original, err := s.db.Queries.GetWebhookDelivery(ctx, database.GetWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: webhookID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return database.WebhookDelivery{}, custom_errors.ErrNotFound.Wrap(err)
+		}
+		return database.WebhookDelivery{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("get webhook delivery: %w", err))
+	}
+
+	delivery, err := s.db.Queries.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		WebhookID:     webhookID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		NextAttemptAt: pgtype.Timestamptz{Time: s.clock.Now(), Valid: true},
+		RedeliveryOf:  pgtype.Int4{Int32: original.ID, Valid: true},
+	})
+	if err != nil {
+		return database.WebhookDelivery{}, custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("create webhook delivery: %w", err))
+	}
+	return delivery, nil
+}
+
+// PruneDeliveries deletes finished deliveries older than retention
+func (s *WebhookService) PruneDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	cutoff := pgtype.Timestamptz{Time: s.clock.Now().Add(-retention), Valid: true}
+	n, err := s.db.Queries.DeleteOldWebhookDeliveries(ctx, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("delete old webhook deliveries: %w", err)
+	}
+	if n > 0 {
+		s.logger.InfoContext(ctx, "pruned webhook deliveries", "count", n)
+	}
+	return n, nil
+}
+
+// publish queues a user event for the subscribed webhooks within the
+// caller's transaction
+func (s *UserService) publish(ctx context.Context, queries *database.Queries, event string, user database.User) error {
+	if _, err := webhooks.Enqueue(ctx, queries, s.clock.Now(), event, webhooks.NewUserData(user)); err != nil {
+		return custom_errors.ErrInternalServerError.Wrap(fmt.Errorf("enqueue %s webhooks: %w", event, err))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package signer
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NonceKeyPrefix namespaces claimed single-use nonces in Redis
+const NonceKeyPrefix = "signer:nonce:"
+
+// RedisNonceStore shares claimed nonces between instances
+type RedisNonceStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisNonceStore(rdb *redis.Client) *RedisNonceStore {
+	return &RedisNonceStore{rdb: rdb}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.rdb.SetNX(ctx, NonceKeyPrefix+nonce, 1, ttl).Result()
+}
@@ -0,0 +1,187 @@
+package signer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"idiomatic-go/clock"
+)
+
+// Query parameters added to signed URLs
+const (
+	ParamExpires   = "expires"
+	ParamKeyID     = "kid"
+	ParamNonce     = "nonce"
+	ParamSignature = "sig"
+)
+
+var (
+	ErrInvalidSignature = errors.New("signer: invalid signature")
+	ErrExpired          = errors.New("signer: link expired")
+	ErrUnknownKey       = errors.New("signer: unknown key")
+	ErrAlreadyUsed      = errors.New("signer: link already used")
+)
+
+// Key is a named HMAC secret. The ID travels in signed URLs so links signed
+// with a retired key keep working while it is still configured.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// NonceStore records the nonces of single-use links
+type NonceStore interface {
+	// Claim marks nonce as used until ttl elapses and reports whether this
+	// was the first claim
+	Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// Signer creates and verifies time-limited signed URLs. The signature
+// covers the path and every query parameter but not the host, so links
+// survive being served from another hostname.
+type Signer struct {
+	keys   []Key // keys[0] signs; all of them verify
+	clock  clock.Clock
+	nonces NonceStore
+}
+
+// New returns a Signer that signs with keys[0] and accepts any of keys.
+// nonces may be nil if single-use links are never issued.
+func New(clk clock.Clock, nonces NonceStore, keys ...Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("signer: at least one key is required")
+	}
+	seen := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || len(k.Secret) < 32 {
+			return nil, fmt.Errorf("signer: key %q needs an ID and a secret of at least 32 bytes", k.ID)
+		}
+		if _, dup := seen[k.ID]; dup {
+			return nil, fmt.Errorf("signer: duplicate key ID %q", k.ID)
+		}
+		seen[k.ID] = struct{}{}
+	}
+	return &Signer{keys: keys, clock: clk, nonces: nonces}, nil
+}
+
+// ParseKeys parses "id:secret" entries, e.g. from a comma-separated
+// SIGNING_KEYS variable. The first entry becomes the signing key.
+func ParseKeys(entries []string) ([]Key, error) {
+	keys := make([]Key, 0, len(entries))
+	for i, entry := range entries {
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			// Never echo the entry itself, it may be a bare secret
+			return nil, fmt.Errorf("signer: key entry %d is not of the form id:secret", i+1)
+		}
+		keys = append(keys, Key{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+// EphemeralKey returns a random key for development setups without
+// configured keys. Links signed with it break on restart and are not
+// accepted by other instances.
+func EphemeralKey() Key {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("signer: generate key: %v", err))
+	}
+	return Key{ID: "ephemeral", Secret: secret}
+}
+
+// Sign returns rawURL with an expiry and signature appended. Single-use
+// links also carry a nonce and are rejected after their first successful
+// verification.
+func (s *Signer) Sign(rawURL string, ttl time.Duration, singleUse bool) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("signer: parse url: %w", err)
+	}
+	if singleUse && s.nonces == nil {
+		return "", errors.New("signer: single-use links need a nonce store")
+	}
+
+	key := s.keys[0]
+	q := u.Query()
+	q.Del(ParamSignature)
+	q.Set(ParamExpires, strconv.FormatInt(s.clock.Now().Add(ttl).Unix(), 10))
+	q.Set(ParamKeyID, key.ID)
+	if singleUse {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("signer: generate nonce: %w", err)
+		}
+		q.Set(ParamNonce, hex.EncodeToString(nonce))
+	} else {
+		q.Del(ParamNonce)
+	}
+
+	q.Set(ParamSignature, sign(key.Secret, u.Path, q))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Verify checks the signature and expiry of u and, for single-use links,
+// claims the nonce
+func (s *Signer) Verify(ctx context.Context, u *url.URL) error {
+	q := u.Query()
+	got := q.Get(ParamSignature)
+	q.Del(ParamSignature)
+
+	var key *Key
+	for i := range s.keys {
+		if s.keys[i].ID == q.Get(ParamKeyID) {
+			key = &s.keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return ErrUnknownKey
+	}
+	if !hmac.Equal([]byte(got), []byte(sign(key.Secret, u.Path, q))) {
+		return ErrInvalidSignature
+	}
+
+	expires, err := strconv.ParseInt(q.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	remaining := time.Unix(expires, 0).Sub(s.clock.Now())
+	if remaining <= 0 {
+		return ErrExpired
+	}
+
+	if nonce := q.Get(ParamNonce); nonce != "" {
+		if s.nonces == nil {
+			return errors.New("signer: single-use link but no nonce store configured")
+		}
+		first, err := s.nonces.Claim(ctx, nonce, remaining)
+		if err != nil {
+			return fmt.Errorf("signer: claim nonce: %w", err)
+		}
+		if !first {
+			return ErrAlreadyUsed
+		}
+	}
+	return nil
+}
+
+// sign computes the MAC over the path and the canonical (sorted) query
+func sign(secret []byte, path string, q url.Values) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package signer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"idiomatic-go/clock"
+)
+
+func TestTokenSigner(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	s := NewTokenSigner("token-secret", clk)
+	other := NewTokenSigner("other-secret", clk)
+
+	withPayload, _ := s.IssueFor("beta,cursor_pagination", time.Minute)
+	bare, _ := s.IssueFor("", time.Minute)
+	dotted, _ := s.IssueFor("a.b", time.Minute)
+	expired := s.Issue("beta", clk.Now().Add(-time.Second))
+	foreign, _ := other.IssueFor("beta", time.Minute)
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{"payload", withPayload, "beta,cursor_pagination", nil},
+		{"no payload", bare, "", nil},
+		{"payload with dots", dotted, "a.b", nil},
+		{"expired", expired, "", ErrExpired},
+		{"other secret", foreign, "", ErrInvalidSignature},
+		{"payload swapped", "gamma" + withPayload[len("beta,cursor_pagination"):], "", ErrInvalidSignature},
+		{"expiry extended", "beta.9999999999" + expired[len("beta.1699999999"):], "", ErrInvalidSignature},
+		{"no signature", "beta.1700000060", "", ErrInvalidSignature},
+		{"garbage", "not-a-token", "", ErrInvalidSignature},
+		{"empty", "", "", ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify(%q) error = %v, want %v", tt.token, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify(%q) = %q, want %q", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSignerExpiry(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	s := NewTokenSigner("token-secret", clk)
+	token, expires := s.IssueFor("", time.Minute)
+
+	clk.Set(expires.Add(-time.Second))
+	if _, err := s.Verify(token); err != nil {
+		t.Fatalf("Verify a second before expiry: %v", err)
+	}
+	clk.Set(expires)
+	if _, err := s.Verify(token); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Verify at expiry error = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestTokenSignerDisabled(t *testing.T) {
+	clk := clock.NewMock(time.Unix(1_700_000_000, 0))
+	s := NewTokenSigner("", clk)
+	if s.Enabled() {
+		t.Fatal("Enabled with an empty secret")
+	}
+	// A token MACed with an empty key must not pass either
+	token, _ := s.IssueFor("beta", time.Minute)
+	if _, err := s.Verify(token); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify error = %v, want %v", err, ErrInvalidSignature)
+	}
+}
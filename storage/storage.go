@@ -0,0 +1,123 @@
+// Package storage keeps the content of uploaded files. Store is what the
+// API needs from an object store; Dir implements it on a local or mounted
+// directory, which is also how volumes shared between replicas are used.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned for a key that holds no object
+var ErrNotFound = errors.New("storage: object not found")
+
+// Store holds objects by key
+type Store interface {
+	// Put stores everything read from r under key and returns its size.
+	// If reading r fails nothing is stored.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open returns the object stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing key is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Dir stores objects as files in a directory, two levels deep by key
+// prefix so no single directory grows too large
+type Dir struct {
+	root string
+}
+
+// NewDir returns a Dir storing under root, creating it if needed
+func NewDir(root string) (*Dir, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("storage: create %s: %w", root, err)
+	}
+	return &Dir{root: root}, nil
+}
+
+func (d *Dir) path(key string) (string, error) {
+	if len(key) < 4 || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(d.root, key[:2], key[2:4], key), nil
+}
+
+// Put writes to a temporary file and renames it into place, so readers
+// never see a partial object
+func (d *Dir) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("storage: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("storage: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // a no-op once renamed
+
+	n, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("storage: %w", err)
+	}
+	return n, nil
+}
+
+func (d *Dir) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	return f, nil
+}
+
+func (d *Dir) Delete(_ context.Context, key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("storage: %w", err)
+	}
+	return nil
+}
+
+// contextReader stops a copy once ctx is done, e.g. when the client of an
+// upload went away
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
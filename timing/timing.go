@@ -0,0 +1,156 @@
+// Package timing attributes the latency of a request to the layers it was
+// spent in, such as the database and Redis, and the phases of handling it,
+// such as authentication, so a slow request can be traced to the part that
+// made it slow
+package timing
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Layers recorded by the instrumented clients. Time spent in the cache
+// includes the Redis round trips it makes.
+const (
+	LayerDB    = "db"
+	LayerRedis = "redis"
+	LayerCache = "cache"
+)
+
+// Phases of handling a request, recorded by the middleware doing them.
+// They overlap the layers: authentication time includes the Redis
+// revocation check.
+const (
+	PhaseAuth      = "auth"
+	PhaseRateLimit = "ratelimit"
+	PhaseSerialize = "serialize"
+)
+
+type breakdownKey struct{}
+
+// Breakdown sums the time a request spent per layer. It is safe for use by
+// the goroutines a request fans out to.
+type Breakdown struct {
+	mu     sync.Mutex
+	layers map[string]*layer
+}
+
+type layer struct {
+	calls int
+	spent time.Duration
+}
+
+// WithBreakdown returns a copy of ctx in which Record adds up time, and the
+// Breakdown it adds up to
+func WithBreakdown(ctx context.Context) (context.Context, *Breakdown) {
+	b := &Breakdown{layers: make(map[string]*layer)}
+	return context.WithValue(ctx, breakdownKey{}, b), b
+}
+
+// Record adds one call of duration d to name in the Breakdown of ctx.
+// Outside a request it does nothing.
+func Record(ctx context.Context, name string, d time.Duration) {
+	b, ok := FromContext(ctx)
+	if !ok {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := b.layers[name]
+	if l == nil {
+		l = &layer{}
+		b.layers[name] = l
+	}
+	l.calls++
+	l.spent += d
+}
+
+// Since records the time elapsed since start, for use with defer
+func Since(ctx context.Context, name string, start time.Time) {
+	Record(ctx, name, time.Since(start))
+}
+
+// FromContext returns the Breakdown of the request ctx belongs to
+func FromContext(ctx context.Context) (*Breakdown, bool) {
+	b, ok := ctx.Value(breakdownKey{}).(*Breakdown)
+	return b, ok
+}
+
+// Phase times the work a middleware does before handing the request on
+type Phase struct {
+	ctx   context.Context
+	name  string
+	start time.Time
+	ended bool
+}
+
+// StartPhase starts timing name
+func StartPhase(ctx context.Context, name string) *Phase {
+	return &Phase{ctx: ctx, name: name, start: time.Now()}
+}
+
+// End records the phase. Only the first call counts, so a middleware can
+// defer End for the requests it rejects and call it before c.Next for the
+// ones it lets through.
+func (p *Phase) End() {
+	if p.ended {
+		return
+	}
+	p.ended = true
+	Since(p.ctx, p.name, p.start)
+}
+
+// Attrs returns a group per layer with its calls and time spent, in layer
+// order, for logging
+func (b *Breakdown) Attrs() []any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.layers))
+	for name := range b.layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		l := b.layers[name]
+		attrs = append(attrs, slog.Group(name, "calls", l.calls, "duration", l.spent))
+	}
+	return attrs
+}
+
+// ServerTiming formats the breakdown as a Server-Timing header value, in
+// layer order and followed by total, e.g.
+//
+//	auth;dur=1.204;desc="1 call", db;dur=8.531;desc="3 calls", total;dur=12.870
+func (b *Breakdown) ServerTiming(total time.Duration) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	names := make([]string, 0, len(b.layers))
+	for name := range b.layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		l := b.layers[name]
+		calls := strconv.Itoa(l.calls) + " calls"
+		if l.calls == 1 {
+			calls = "1 call"
+		}
+		metrics = append(metrics, name+";dur="+millis(l.spent)+`;desc="`+calls+`"`)
+	}
+	metrics = append(metrics, "total;dur="+millis(total))
+	return strings.Join(metrics, ", ")
+}
+
+// millis formats d in milliseconds, the unit of Server-Timing durations
+func millis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,82 @@
+// Package tlsserver serves the API over HTTPS without a terminating proxy,
+// with a certificate from files or obtained from Let's Encrypt, and
+// redirects plain HTTP to it.
+package tlsserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Config selects where the certificate comes from. Set either CertFile and
+// KeyFile or Domains.
+type Config struct {
+	CertFile string
+	KeyFile  string
+
+	Domains  []string // obtain certificates for these hosts from Let's Encrypt
+	CacheDir string   // where obtained certificates and the account key are kept
+	Email    string   // contact for the ACME account; optional
+}
+
+// Enabled reports whether cfg asks for HTTPS
+func (cfg Config) Enabled() bool {
+	return cfg.CertFile != "" || len(cfg.Domains) > 0
+}
+
+// Server adds TLS to an http.Server
+type Server struct {
+	cfg     Config
+	manager *autocert.Manager
+}
+
+func New(cfg Config) *Server {
+	s := &Server{cfg: cfg}
+	if len(cfg.Domains) > 0 {
+		s.manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+			Cache:      autocert.DirCache(cfg.CacheDir),
+			Email:      cfg.Email,
+		}
+	}
+	return s
+}
+
+// ListenAndServe serves srv over HTTPS, with HTTP/2 negotiated by ALPN. It
+// returns http.ErrServerClosed after Shutdown, like http.Server's.
+func (s *Server) ListenAndServe(srv *http.Server) error {
+	if s.manager == nil {
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		return srv.ListenAndServeTLS(s.cfg.CertFile, s.cfg.KeyFile)
+	}
+	// Also answers tls-alpn-01 challenges, so on port 443 the HTTP
+	// listener is not needed to obtain certificates
+	srv.TLSConfig = s.manager.TLSConfig()
+	srv.TLSConfig.MinVersion = tls.VersionTLS12
+	return srv.ListenAndServeTLS("", "")
+}
+
+// RedirectHandler answers HTTP-01 challenges when certificates come from
+// Let's Encrypt and permanently redirects every other request to the same
+// URL on HTTPS at httpsPort
+func (s *Server) RedirectHandler(httpsPort string) http.Handler {
+	redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+	if s.manager == nil {
+		return redirect
+	}
+	return s.manager.HTTPHandler(redirect)
+}
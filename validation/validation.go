@@ -0,0 +1,180 @@
+// Package validation registers the API's own validator rules on gin's
+// binding engine and translates validation failures into the field
+// details of an APIError, so REST and GraphQL report them alike.
+//
+// Rules beyond the validator's built-in ones:
+//
+//   - password: 8 to 72 bytes, mixing letters with digits or symbols
+//   - username: letters, digits, '.', '_' and '-', starting with a letter or digit
+//   - notreserved: not one of the names reserved for the service itself
+package validation
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+
+	custom_errors "idiomatic-go/errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72 // bcrypt ignores the rest
+)
+
+// reserved are usernames that could pass for the service, its staff or a
+// route, compared case-insensitively
+var reserved = map[string]struct{}{
+	"admin": {}, "administrator": {}, "root": {}, "system": {}, "support": {},
+	"security": {}, "staff": {}, "moderator": {}, "help": {}, "api": {},
+	"me": {}, "null": {}, "undefined": {}, "anonymous": {}, "webmaster": {},
+	"postmaster": {}, "noreply": {}, "no-reply": {},
+}
+
+func init() {
+	v := Engine()
+	// Report failures under the JSON names clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	for tag, fn := range map[string]validator.Func{
+		"password":    password,
+		"username":    username,
+		"notreserved": notReserved,
+	} {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			panic("validation: register " + tag + ": " + err.Error())
+		}
+	}
+}
+
+// Engine returns the validator gin binds requests with, which has the
+// rules of this package registered
+func Engine() *validator.Validate {
+	return binding.Validator.Engine().(*validator.Validate)
+}
+
+func password(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	if len([]rune(s)) < minPasswordLen || len(s) > maxPasswordLen {
+		return false
+	}
+	var letter, other bool
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			letter = true
+		} else if !unicode.IsSpace(r) {
+			other = true
+		}
+	}
+	return letter && other
+}
+
+func username(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	for i, r := range s {
+		alnum := r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		if !alnum && (i == 0 || !strings.ContainsRune("._-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+func notReserved(fl validator.FieldLevel) bool {
+	_, ok := reserved[strings.ToLower(fl.Field().String())]
+	return !ok
+}
+
+// Struct validates v against its binding tags and returns an ErrValidation
+// listing every failed field, or nil
+func Struct(v any) error {
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		return Error(err)
+	}
+	return nil
+}
+
+// Error turns the validation failures in err into an ErrValidation with
+// field details. Other errors come back as a plain ErrValidation.
+func Error(err error) error {
+	if fields, ok := Fields(err); ok {
+		return custom_errors.ErrValidation.WithFields(fields).Wrap(err)
+	}
+	return custom_errors.ErrValidation.Wrap(err)
+}
+
+// Fields describes each failure in err in client terms, and reports
+// whether err held validation failures at all. Nested fields are named
+// by their dotted path below the validated struct, e.g. profile.bio.
+func Fields(err error) ([]custom_errors.FieldError, bool) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return nil, false
+	}
+	fields := make([]custom_errors.FieldError, len(verrs))
+	for i, fe := range verrs {
+		_, path, found := strings.Cut(fe.Namespace(), ".")
+		if !found {
+			path = fe.Field()
+		}
+		fields[i] = custom_errors.FieldError{Field: path, Message: Message(fe)}
+	}
+	return fields, true
+}
+
+// Var checks a single value against rules such as "required,email" and
+// describes the first failure in client terms
+func Var(value string, rules string) (string, bool) {
+	err := Engine().Var(value, rules)
+	var verrs validator.ValidationErrors
+	switch {
+	case err == nil:
+		return "", true
+	case errors.As(err, &verrs) && len(verrs) > 0:
+		return Message(verrs[0]), false
+	default:
+		return "is invalid", false
+	}
+}
+
+// Message describes a failed rule in client terms
+func Message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + fe.Param()
+	case "http_url":
+		return "must be an http or https URL"
+	case "bcp47_language_tag":
+		return "must be a BCP 47 language tag such as en-GB"
+	case "timezone":
+		return "must be an IANA time zone name such as Europe/London"
+	case "password":
+		return "must be 8 to 72 characters long and mix letters with digits or symbols"
+	case "username":
+		return "may only contain letters, digits, '.', '_' and '-', and must start with a letter or digit"
+	case "notreserved":
+		return "is reserved"
+	default:
+		return "failed the " + fe.Tag() + " check"
+	}
+}
@@ -0,0 +1,259 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"idiomatic-go/buildinfo"
+	"idiomatic-go/clock"
+	"idiomatic-go/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deliveryAttempts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webhook_delivery_attempts_total",
+			Help: "Webhook delivery attempts, by event and result (succeeded, retry, failed)",
+		},
+		[]string{"event", "result"},
+	)
+	deliveryDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "webhook_delivery_duration_seconds",
+			Help:    "Time taken by receivers to answer a webhook delivery",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(deliveryAttempts, deliveryDuration)
+}
+
+// maxErrorLen bounds the error recorded in the delivery log
+const maxErrorLen = 500
+
+// Config holds configuration for a Dispatcher
+type Config struct {
+	BatchSize   int           // deliveries claimed per run
+	MaxAttempts int           // attempts before a delivery is marked failed
+	Timeout     time.Duration // bound on each HTTP request
+	BaseBackoff time.Duration // wait after the first failed attempt, doubled after each one
+	MaxBackoff  time.Duration
+}
+
+// Dispatcher sends due deliveries. Each run claims a batch by pushing its
+// next attempt past the time the batch can take, so replicas polling the
+// same table never send a delivery twice at once, and a replica that dies
+// mid-batch only delays its deliveries until the lease runs out.
+type Dispatcher struct {
+	queries *database.Queries
+	client  *http.Client
+	logger  *slog.Logger
+	clock   clock.Clock
+	config  Config
+}
+
+func NewDispatcher(queries *database.Queries, logger *slog.Logger, clk clock.Clock, config Config) *Dispatcher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 10
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.BaseBackoff {
+		config.MaxBackoff = 6 * time.Hour
+	}
+	return &Dispatcher{
+		queries: queries,
+		client: &http.Client{
+			Timeout: config.Timeout,
+			// A redirect could point a signed payload anywhere
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		logger: logger,
+		clock:  clk,
+		config: config,
+	}
+}
+
+// RunTimeout is how long a run may take. Use it as the job timeout.
+func (d *Dispatcher) RunTimeout() time.Duration {
+	return time.Duration(d.config.BatchSize)*d.config.Timeout + 30*time.Second
+}
+
+// Run sends one batch of due deliveries. Once ctx is done it stops and
+// releases the deliveries it has not sent, so other replicas pick them
+// up at once instead of after the lease.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	now := d.clock.Now()
+	batch, err := d.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: pgtype.Timestamptz{Time: now.Add(d.RunTimeout()), Valid: true},
+		Now:        pgtype.Timestamptz{Time: now, Valid: true},
+		BatchSize:  int32(d.config.BatchSize),
+	})
+	if err != nil {
+		return fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	for i, delivery := range batch {
+		if ctx.Err() != nil {
+			if err := d.release(ctx, batch[i:]); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+		if err := d.deliver(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	// Set if the last delivery was interrupted and released
+	return ctx.Err()
+}
+
+// deliver makes one attempt at delivery and records its outcome. Only a
+// failure to record is returned.
+func (d *Dispatcher) deliver(ctx context.Context, delivery database.WebhookDelivery) error {
+	log := d.logger.With(
+		"delivery_id", delivery.ID,
+		"webhook_id", delivery.WebhookID,
+		"event", delivery.Event,
+		"attempt", delivery.Attempts+1,
+	)
+
+	hook, err := d.queries.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("get webhook %d: %w", delivery.WebhookID, err)
+	}
+	if err != nil || !hook.Active {
+		return d.record(ctx, delivery, StatusFailed, 0, errors.New("webhook disabled"))
+	}
+
+	statusCode, sendErr := d.send(ctx, hook, delivery)
+	if sendErr != nil && ctx.Err() != nil {
+		// Interrupted rather than refused, so it does not count as an
+		// attempt; the receiver may still have got it
+		log.Info("webhook delivery interrupted, releasing it", "error", sendErr)
+		return d.release(ctx, []database.WebhookDelivery{delivery})
+	}
+	if sendErr == nil {
+		log.Debug("webhook delivered", "status", statusCode)
+		return d.record(ctx, delivery, StatusSucceeded, statusCode, nil)
+	}
+	if int(delivery.Attempts)+1 >= d.config.MaxAttempts {
+		log.Warn("webhook delivery failed, giving up", "error", sendErr)
+		return d.record(ctx, delivery, StatusFailed, statusCode, sendErr)
+	}
+	log.Info("webhook delivery failed, will retry", "error", sendErr)
+	return d.record(ctx, delivery, StatusPending, statusCode, sendErr)
+}
+
+// send posts the delivery and returns the response status, if there was a
+// response. Anything but a 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, hook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+	timestamp := d.clock.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "idiomatic-go-webhooks/"+buildinfo.ServiceVersion())
+	req.Header.Set(HeaderID, strconv.Itoa(int(delivery.ID)))
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	deliveryDuration.Observe(time.Since(start).Seconds())
+	// Drain a little so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) record(ctx context.Context, delivery database.WebhookDelivery, status string, statusCode int, sendErr error) error {
+	now := d.clock.Now()
+	params := database.RecordWebhookAttemptParams{
+		ID:            delivery.ID,
+		Status:        status,
+		NextAttemptAt: pgtype.Timestamptz{Time: now, Valid: true},
+	}
+	if statusCode != 0 {
+		params.LastStatusCode = pgtype.Int4{Int32: int32(statusCode), Valid: true}
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		if len(msg) > maxErrorLen {
+			msg = msg[:maxErrorLen]
+		}
+		params.LastError = pgtype.Text{String: msg, Valid: true}
+	}
+	switch status {
+	case StatusSucceeded:
+		params.DeliveredAt = pgtype.Timestamptz{Time: now, Valid: true}
+		deliveryAttempts.WithLabelValues(delivery.Event, "succeeded").Inc()
+	case StatusPending:
+		params.NextAttemptAt.Time = now.Add(d.backoff(int(delivery.Attempts) + 1))
+		deliveryAttempts.WithLabelValues(delivery.Event, "retry").Inc()
+	default:
+		deliveryAttempts.WithLabelValues(delivery.Event, "failed").Inc()
+	}
+
+	// Recorded even if the run is being cancelled, or the delivery would
+	// be sent again once its lease runs out
+	if err := d.queries.RecordWebhookAttempt(context.WithoutCancel(ctx), params); err != nil {
+		return fmt.Errorf("record webhook attempt %d: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+// release hands deliveries back as due now, without recording an attempt
+func (d *Dispatcher) release(ctx context.Context, deliveries []database.WebhookDelivery) error {
+	ids := make([]int32, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		ids = append(ids, delivery.ID)
+	}
+	// Released even though the run is being cancelled, like record
+	released, err := d.queries.ReleaseWebhookDeliveries(context.WithoutCancel(ctx), database.ReleaseWebhookDeliveriesParams{
+		Now: pgtype.Timestamptz{Time: d.clock.Now(), Valid: true},
+		Ids: ids,
+	})
+	if err != nil {
+		return fmt.Errorf("release webhook deliveries: %w", err)
+	}
+	d.logger.Info("released unsent webhook deliveries", "count", released)
+	return nil
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.BaseBackoff
+	for i := 1; i < attempts && wait < d.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.config.MaxBackoff)
+}
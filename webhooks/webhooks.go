@@ -0,0 +1,109 @@
+// Package webhooks delivers signed HTTP callbacks for user and audit
+// events to the endpoints registered in the webhooks table
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"idiomatic-go/database"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Events webhooks can subscribe to
+const (
+	EventUserCreated  = "user.created"
+	EventUserUpdated  = "user.updated"
+	EventUserDeleted  = "user.deleted"
+	EventUserRestored = "user.restored"
+	EventAuditAlert   = "audit.alert"
+)
+
+// Events lists every event, in the order they are documented
+var Events = []string{EventUserCreated, EventUserUpdated, EventUserDeleted, EventUserRestored, EventAuditAlert}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed" // gave up after the last attempt
+)
+
+// Headers sent with every delivery
+const (
+	HeaderID        = "X-Webhook-ID" // the delivery ID; identical across retries
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Envelope is the JSON body of every delivery
+type Envelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// UserData is the data of the user events. It never carries credentials.
+type UserData struct {
+	ID            int32  `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+func NewUserData(u database.User) UserData {
+	return UserData{
+		ID:            u.ID,
+		Username:      u.Username,
+		Email:         u.Email,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerified,
+	}
+}
+
+// AuditAlertData is the data of the audit.alert event
+type AuditAlertData struct {
+	AlertID       int32     `json:"alert_id"`
+	RuleID        int32     `json:"rule_id"`
+	RuleName      string    `json:"rule_name"`
+	Action        string    `json:"action"`
+	ActorID       *int32    `json:"actor_id,omitempty"` // set for per-actor rules
+	Count         int32     `json:"count"`
+	Threshold     int32     `json:"threshold"`
+	WindowSeconds int32     `json:"window_seconds"`
+	WindowStart   time.Time `json:"window_start"`
+}
+
+// Enqueue queues event for every active webhook subscribed to it and
+// returns how many deliveries were queued. Run it in the transaction that
+// makes the change, so the event goes out if and only if it commits.
+func Enqueue(ctx context.Context, queries *database.Queries, now time.Time, event string, data any) (int64, error) {
+	payload, err := json.Marshal(Envelope{Event: event, CreatedAt: now.UTC(), Data: data})
+	if err != nil {
+		return 0, fmt.Errorf("marshal %s payload: %w", event, err)
+	}
+	return queries.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		Event:         event,
+		Payload:       payload,
+		NextAttemptAt: pgtype.Timestamptz{Time: now, Valid: true},
+	})
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp:
+// "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the webhook's secret. Receivers should recompute it, compare in
+// constant time and reject timestamps more than a few minutes old.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
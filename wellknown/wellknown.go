@@ -0,0 +1,78 @@
+// Package wellknown serves the /.well-known URIs that scanners, researchers
+// and password managers look for.
+package wellknown
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"idiomatic-go/clock"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Config holds configuration for the well-known endpoints. An endpoint
+// whose settings are empty is not served.
+type Config struct {
+	// Contacts are the security.txt Contact URIs, e.g. "mailto:..." or
+	// "https://...". security.txt is only served when there is one.
+	Contacts           []string
+	Policy             string        // URL of the vulnerability disclosure policy
+	Acknowledgments    string        // URL of the hall of fame
+	PreferredLanguages string        // comma-separated language tags
+	Expiry             time.Duration // how far ahead the Expires field lies
+	Canonical          string        // the URL security.txt is published at
+
+	ChangePasswordURL string // where /.well-known/change-password redirects
+}
+
+type Handler struct {
+	config Config
+	clock  clock.Clock
+}
+
+func New(config Config, clk clock.Clock) *Handler {
+	if config.Expiry <= 0 {
+		config.Expiry = 180 * 24 * time.Hour
+	}
+	return &Handler{config: config, clock: clk}
+}
+
+// ServesSecurityTxt reports whether security.txt is configured
+func (h *Handler) ServesSecurityTxt() bool {
+	return len(h.config.Contacts) > 0
+}
+
+// ServesChangePassword reports whether a change-password page is configured
+func (h *Handler) ServesChangePassword() bool {
+	return h.config.ChangePasswordURL != ""
+}
+
+// SecurityTxt serves an RFC 9116 security.txt. Expires is computed per
+// request, so the file never goes stale while the service is running.
+func (h *Handler) SecurityTxt(c *gin.Context) {
+	var b strings.Builder
+	field := func(name, value string) {
+		if value != "" {
+			b.WriteString(name + ": " + value + "\n")
+		}
+	}
+	for _, contact := range h.config.Contacts {
+		field("Contact", contact)
+	}
+	field("Expires", h.clock.Now().Add(h.config.Expiry).UTC().Truncate(time.Second).Format(time.RFC3339))
+	field("Policy", h.config.Policy)
+	field("Acknowledgments", h.config.Acknowledgments)
+	field("Preferred-Languages", h.config.PreferredLanguages)
+	field("Canonical", h.config.Canonical)
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(b.String()))
+}
+
+// ChangePassword redirects to the page where users change their password,
+// as defined by the W3C "well-known URL for changing passwords"
+func (h *Handler) ChangePassword(c *gin.Context) {
+	c.Redirect(http.StatusFound, h.config.ChangePasswordURL)
+}
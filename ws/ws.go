@@ -0,0 +1,296 @@
+// Package ws pushes real-time notifications to clients connected over
+// WebSocket. Notifications are published on Redis, so a client receives
+// them whichever replica it is connected to.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"idiomatic-go/clock"
+	"idiomatic-go/jsontime"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/net/websocket"
+)
+
+var (
+	connections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ws_connections",
+		Help: "WebSocket clients connected to this replica",
+	})
+	notificationsSent = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ws_notifications_sent_total",
+			Help: "Notifications written to connected WebSocket clients",
+		},
+		[]string{"type"},
+	)
+	clientsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ws_clients_dropped_total",
+		Help: "WebSocket clients disconnected for falling behind on notifications",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(connections, notificationsSent, clientsDropped)
+}
+
+// Channel carries notifications between replicas
+const Channel = "ws:notifications"
+
+// Subprotocol is offered by browsers, which cannot set headers on a
+// WebSocket, together with the access token: "bearer, <token>"
+const Subprotocol = "bearer"
+
+// Notification types
+const (
+	TypeProfileUpdated = "profile_updated"
+	TypeProfileDeleted = "profile_deleted"
+	TypeBroadcast      = "broadcast"
+)
+
+const (
+	sendBuffer   = 16 // notifications queued per client before it is dropped
+	pingInterval = 30 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// Notification is what clients receive, one JSON text frame each
+type Notification struct {
+	Type   string          `json:"type" example:"profile_updated"`
+	Data   json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	SentAt jsontime.Time   `json:"sent_at" swaggertype:"string" example:"2025-03-23T15:04:05Z"`
+}
+
+// message is a Notification as published on Channel
+type message struct {
+	UserID int64 `json:"user_id,omitempty"` // 0 addresses every client
+	Notification
+}
+
+type client struct {
+	userID int64
+	send   chan Notification
+	done   chan struct{} // closed once the client is unregistered
+}
+
+// Hub tracks the clients connected to this replica and delivers the
+// notifications published by any replica to them
+type Hub struct {
+	rdb    *redis.Client
+	logger *slog.Logger
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	clients map[int64][]*client
+	closed  bool
+}
+
+func NewHub(rdb *redis.Client, logger *slog.Logger, clk clock.Clock) *Hub {
+	return &Hub{rdb: rdb, logger: logger, clock: clk, clients: make(map[int64][]*client)}
+}
+
+// Notify sends a notification of kind carrying data to every client of
+// userID, on any replica
+func (h *Hub) Notify(ctx context.Context, userID int64, kind string, data any) error {
+	return h.publish(ctx, userID, kind, data)
+}
+
+// Broadcast sends a notification of kind carrying data to every client
+func (h *Hub) Broadcast(ctx context.Context, kind string, data any) error {
+	return h.publish(ctx, 0, kind, data)
+}
+
+func (h *Hub) publish(ctx context.Context, userID int64, kind string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal %s notification: %w", kind, err)
+	}
+	msg, err := json.Marshal(message{
+		UserID:       userID,
+		Notification: Notification{Type: kind, Data: raw, SentAt: jsontime.New(h.clock.Now())},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal %s notification: %w", kind, err)
+	}
+	if err := h.rdb.Publish(ctx, Channel, msg).Err(); err != nil {
+		return fmt.Errorf("publish %s notification: %w", kind, err)
+	}
+	return nil
+}
+
+// Run delivers published notifications to the clients connected here
+// until ctx is cancelled, then disconnects them all. Notifications
+// published while it is not subscribed are lost.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.closeAll()
+	pubsub := h.rdb.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m, ok := <-messages:
+			if !ok {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+				h.logger.Warn("malformed notification", "error", err)
+				continue
+			}
+			h.deliver(msg)
+		}
+	}
+}
+
+// deliver queues msg for its recipients. A client whose queue is full is
+// dropped rather than allowed to hold up everyone else; it reconnects and
+// refetches what it missed.
+func (h *Hub) deliver(msg message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var recipients []*client
+	if msg.UserID == 0 {
+		for _, clients := range h.clients {
+			recipients = append(recipients, clients...)
+		}
+	} else {
+		recipients = h.clients[msg.UserID]
+	}
+	for _, c := range slices.Clone(recipients) {
+		select {
+		case c.send <- msg.Notification:
+		default:
+			clientsDropped.Inc()
+			h.remove(c)
+		}
+	}
+}
+
+func (h *Hub) register(userID int64) (*client, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, false
+	}
+	c := &client{userID: userID, send: make(chan Notification, sendBuffer), done: make(chan struct{})}
+	h.clients[userID] = append(h.clients[userID], c)
+	connections.Inc()
+	return c, true
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(c)
+}
+
+// remove unregisters c unless that already happened. h.mu must be held.
+func (h *Hub) remove(c *client) {
+	clients := h.clients[c.userID]
+	i := slices.Index(clients, c)
+	if i < 0 {
+		return
+	}
+	if len(clients) == 1 {
+		delete(h.clients, c.userID)
+	} else {
+		h.clients[c.userID] = slices.Delete(clients, i, i+1)
+	}
+	close(c.done)
+	connections.Dec()
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, clients := range h.clients {
+		for _, c := range slices.Clone(clients) {
+			h.remove(c)
+		}
+	}
+}
+
+// Serve upgrades the request to a WebSocket and streams userID's
+// notifications to it until either side hangs up. The caller has already
+// authenticated the request.
+func (h *Hub) Serve(w http.ResponseWriter, r *http.Request, userID int64) {
+	server := websocket.Server{
+		// Tokens are not cookies, so any origin may connect. The bearer
+		// subprotocol is echoed so browsers accept the upgrade.
+		Handshake: func(config *websocket.Config, _ *http.Request) error {
+			if slices.Contains(config.Protocol, Subprotocol) {
+				config.Protocol = []string{Subprotocol}
+			} else {
+				config.Protocol = nil
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			h.stream(conn, userID)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+func (h *Hub) stream(conn *websocket.Conn, userID int64) {
+	c, ok := h.register(userID)
+	if !ok {
+		return // shutting down
+	}
+	defer h.unregister(c)
+
+	// The server's read and write timeouts survive the upgrade
+	_ = conn.SetDeadline(time.Time{})
+
+	// Clients have nothing to say; reading only notices them hanging up
+	// and answers their pings
+	hungUp := make(chan struct{})
+	go func() {
+		defer close(hungUp)
+		buf := make([]byte, 512)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-hungUp:
+			return
+		case <-c.done:
+			return
+		case n := <-c.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := websocket.JSON.Send(conn, n); err != nil {
+				return
+			}
+			notificationsSent.WithLabelValues(n.Type).Inc()
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			conn.PayloadType = websocket.PingFrame
+			_, err := conn.Write(nil)
+			conn.PayloadType = websocket.TextFrame
+			if err != nil {
+				return
+			}
+		}
+	}
+}